@@ -12,11 +12,13 @@ import (
 	"context"
 	_ "embed"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -36,6 +38,8 @@ import (
 	"github.com/charmbracelet/crush/internal/agent/tools/mcp"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/hooks"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/session"
@@ -72,6 +76,19 @@ type SessionAgentCall struct {
 	TopK             *int64
 	FrequencyPenalty *float64
 	PresencePenalty  *float64
+	// PlanMode restricts the agent to the read-only tools and has it write
+	// a plan instead of making changes.
+	PlanMode bool
+	// Budget limits what the agent spends. Sub-agents run without one, and
+	// stop along with the agent that runs them.
+	Budget config.Budget
+
+	// promptHooksRan is set once the prompt went through the prompt hooks,
+	// so queued prompts don't go through them again.
+	promptHooksRan bool
+	// stopHookActive is set when the call continues the agent because a
+	// Stop hook blocked it.
+	stopHookActive bool
 }
 
 type SessionAgent interface {
@@ -94,6 +111,15 @@ type Model struct {
 	Model      fantasy.LanguageModel
 	CatwalkCfg catwalk.Model
 	ModelCfg   config.SelectedModel
+	// Retry is the retry policy of the provider of the model.
+	Retry config.RetryPolicy
+	// Fallbacks are the models to carry on with, in order, when the model
+	// keeps failing.
+	Fallbacks []Model
+
+	// settings are the request settings of a fallback model, which replace
+	// the ones of the primary model the calls come with.
+	settings callSettings
 }
 
 type sessionAgent struct {
@@ -107,7 +133,9 @@ type sessionAgent struct {
 	sessions             session.Service
 	messages             message.Service
 	disableAutoSummarize bool
+	compaction           config.Compaction
 	isYolo               bool
+	hooks                *hooks.Runner
 
 	messageQueue   *csync.Map[string, []SessionAgentCall]
 	activeRequests *csync.Map[string, context.CancelFunc]
@@ -120,10 +148,12 @@ type SessionAgentOptions struct {
 	SystemPrompt         string
 	IsSubAgent           bool
 	DisableAutoSummarize bool
+	Compaction           config.Compaction
 	IsYolo               bool
 	Sessions             session.Service
 	Messages             message.Service
 	Tools                []fantasy.AgentTool
+	Hooks                *hooks.Runner
 }
 
 func NewSessionAgent(
@@ -138,8 +168,10 @@ func NewSessionAgent(
 		sessions:             opts.Sessions,
 		messages:             opts.Messages,
 		disableAutoSummarize: opts.DisableAutoSummarize,
+		compaction:           opts.Compaction,
 		tools:                csync.NewSliceFrom(opts.Tools),
 		isYolo:               opts.IsYolo,
+		hooks:                opts.Hooks,
 		messageQueue:         csync.NewMap[string, []SessionAgentCall](),
 		activeRequests:       csync.NewMap[string, context.CancelFunc](),
 	}
@@ -153,6 +185,13 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		return nil, ErrSessionMissing
 	}
 
+	if !a.isSubAgent && !call.promptHooksRan {
+		var err error
+		if call, err = a.runPromptHooks(ctx, call); err != nil {
+			return nil, err
+		}
+	}
+
 	// Queue the message if busy
 	if a.IsSessionBusy(call.SessionID) {
 		existing, ok := a.messageQueue.Get(call.SessionID)
@@ -185,13 +224,58 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		systemPrompt += "\n\n<mcp-instructions>\n" + s + "\n</mcp-instructions>"
 	}
 
+	if call.PlanMode {
+		agentTools = slices.DeleteFunc(agentTools, func(tool fantasy.AgentTool) bool {
+			return !slices.Contains(planModeTools, tool.Info().Name)
+		})
+		systemPrompt += "\n\n" + planModePrompt
+	}
+
 	if len(agentTools) > 0 {
-		// Add Anthropic caching to the last tool.
-		agentTools[len(agentTools)-1].SetProviderOptions(a.getCacheControlOptions())
+		// Add Anthropic caching to the last tool. The tools are shared
+		// between runs, and the last one depends on the mode of the run, so
+		// the options go on a copy.
+		last := len(agentTools) - 1
+		agentTools[last] = &cachedTool{AgentTool: agentTools[last], options: a.getCacheControlOptions()}
+	}
+
+	var currentAssistant *message.Message
+	model := newRetryModel(largeModel)
+	model.onRetry = func(attempt int, err *fantasy.ProviderError, delay time.Duration) {
+		if currentAssistant == nil {
+			return
+		}
+		reason := cmp.Or(stringext.Capitalize(err.Title), fantasy.ErrorTitleForStatusCode(err.StatusCode), "Provider error")
+		currentAssistant.SetRetry(attempt, reason, time.Now().Add(delay))
+		if updateErr := a.messages.Update(ctx, *currentAssistant); updateErr != nil {
+			slog.Error("Failed to update message", "error", updateErr)
+		}
+	}
+	model.onResume = func() {
+		if currentAssistant == nil {
+			return
+		}
+		currentAssistant.ClearRetry()
+		if updateErr := a.messages.Update(ctx, *currentAssistant); updateErr != nil {
+			slog.Error("Failed to update message", "error", updateErr)
+		}
+	}
+	providerOptions := call.ProviderOptions
+	model.onFallback = func(_, to Model, _ error) {
+		largeModel = to
+		providerOptions = to.settings.providerOptions
+		if currentAssistant == nil {
+			return
+		}
+		currentAssistant.Model = to.ModelCfg.Model
+		currentAssistant.Provider = to.ModelCfg.Provider
+		if updateErr := a.messages.Update(ctx, *currentAssistant); updateErr != nil {
+			slog.Error("Failed to update message", "error", updateErr)
+		}
 	}
 
 	agent := fantasy.NewAgent(
-		largeModel.Model,
+		model,
 		fantasy.WithSystemPrompt(systemPrompt),
 		fantasy.WithTools(agentTools...),
 	)
@@ -202,6 +286,11 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 
+	if err := a.checkBudget(ctx, call.Budget, currentSession); err != nil {
+		a.ClearQueue(call.SessionID)
+		return nil, err
+	}
+
 	msgs, err := a.getSessionMessages(ctx, currentSession)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get session messages: %w", err)
@@ -232,17 +321,20 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 	defer cancel()
 	defer a.activeRequests.Del(call.SessionID)
 
-	history, files := a.preparePrompt(msgs, call.Attachments...)
+	promptHistory, files := a.preparePrompt(msgs, call.Attachments...)
 
 	startTime := time.Now()
 	a.eventPromptSent(call.SessionID)
 
-	var currentAssistant *message.Message
 	var shouldSummarize bool
+	var budgetErr *BudgetError
+	// extraMedia holds the media of the tool results of the run that doesn't
+	// fit in their tool results, by tool call ID.
+	extraMedia := csync.NewMap[string, []fantasy.FilePart]()
 	result, err := agent.Stream(genCtx, fantasy.AgentStreamCall{
 		Prompt:           message.PromptWithTextAttachments(call.Prompt, call.Attachments),
 		Files:            files,
-		Messages:         history,
+		Messages:         promptHistory,
 		ProviderOptions:  call.ProviderOptions,
 		MaxOutputTokens:  &call.MaxOutputTokens,
 		TopP:             call.TopP,
@@ -250,6 +342,9 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		PresencePenalty:  call.PresencePenalty,
 		TopK:             call.TopK,
 		FrequencyPenalty: call.FrequencyPenalty,
+		// Retries are up to the model, which follows the retry policy of
+		// its provider.
+		MaxRetries: new(int),
 		PrepareStep: func(callContext context.Context, options fantasy.PrepareStepFunctionOptions) (_ context.Context, prepared fantasy.PrepareStepResult, err error) {
 			prepared.Messages = options.Messages
 			for i := range prepared.Messages {
@@ -266,6 +361,7 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 				prepared.Messages = append(prepared.Messages, userMessage.ToAIMessage()...)
 			}
 
+			prepared.Messages = attachExtraToolMedia(prepared.Messages, extraMedia)
 			prepared.Messages = a.workaroundProviderMediaLimitations(prepared.Messages, largeModel)
 
 			lastSystemRoleInx := 0
@@ -299,6 +395,7 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 				return callContext, prepared, err
 			}
 			callContext = context.WithValue(callContext, tools.MessageIDContextKey, assistantMsg.ID)
+			callContext = history.WithMessageID(callContext, assistantMsg.ID)
 			callContext = context.WithValue(callContext, tools.SupportsImagesContextKey, largeModel.CatwalkCfg.SupportsImages)
 			callContext = context.WithValue(callContext, tools.ModelNameContextKey, largeModel.CatwalkCfg.Name)
 			currentAssistant = &assistantMsg
@@ -310,7 +407,7 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		},
 		OnReasoningDelta: func(id string, text string) error {
 			currentAssistant.AppendReasoningContent(text)
-			return a.messages.Update(genCtx, *currentAssistant)
+			return a.messages.UpdateStreaming(genCtx, *currentAssistant)
 		},
 		OnReasoningEnd: func(id string, reasoning fantasy.ReasoningContent) error {
 			// handle anthropic signature
@@ -341,7 +438,7 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 			}
 
 			currentAssistant.AppendContent(text)
-			return a.messages.Update(genCtx, *currentAssistant)
+			return a.messages.UpdateStreaming(genCtx, *currentAssistant)
 		},
 		OnToolInputStart: func(id string, toolName string) error {
 			toolCall := message.ToolCall{
@@ -353,9 +450,6 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 			currentAssistant.AddToolCall(toolCall)
 			return a.messages.Update(genCtx, *currentAssistant)
 		},
-		OnRetry: func(err *fantasy.ProviderError, delay time.Duration) {
-			// TODO: implement
-		},
 		OnToolCall: func(tc fantasy.ToolCallContent) error {
 			toolCall := message.ToolCall{
 				ID:               tc.ToolCallID,
@@ -369,6 +463,9 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		},
 		OnToolResult: func(result fantasy.ToolResultContent) error {
 			toolResult := a.convertToToolResult(result)
+			if files := toolResult.ExtraMedia(); len(files) > 0 {
+				extraMedia.Set(toolResult.ToolCallID, files)
+			}
 			_, createMsgErr := a.messages.Create(genCtx, currentAssistant.SessionID, message.CreateMessageParams{
 				Role: message.Tool,
 				Parts: []message.ContentPart{
@@ -395,7 +492,8 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 			if getSessionErr != nil {
 				return getSessionErr
 			}
-			a.updateSessionUsage(largeModel, &updatedSession, stepResult.Usage, a.openrouterCost(stepResult.ProviderMetadata))
+			cost := a.updateSessionUsage(largeModel, &updatedSession, stepResult.Usage, a.openrouterCost(stepResult.ProviderMetadata))
+			a.recordCost(ctx, call.SessionID, cost)
 			_, sessionErr := a.sessions.Save(ctx, updatedSession)
 			if sessionErr != nil {
 				return sessionErr
@@ -405,16 +503,11 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		},
 		StopWhen: []fantasy.StopCondition{
 			func(_ []fantasy.StepResult) bool {
-				cw := int64(largeModel.CatwalkCfg.ContextWindow)
-				tokens := currentSession.CompletionTokens + currentSession.PromptTokens
-				remaining := cw - tokens
-				var threshold int64
-				if cw > largeContextWindowThreshold {
-					threshold = largeContextWindowBuffer
-				} else {
-					threshold = int64(float64(cw) * smallContextWindowRatio)
-				}
-				if (remaining <= threshold) && !a.disableAutoSummarize {
+				err := a.checkBudget(genCtx, call.Budget, currentSession)
+				return errors.As(err, &budgetErr)
+			},
+			func(_ []fantasy.StepResult) bool {
+				if isContextLow(largeModel, currentSession) && !a.disableAutoSummarize {
 					shouldSummarize = true
 					return true
 				}
@@ -530,10 +623,16 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		return nil, err
 	}
 
+	if budgetErr != nil {
+		// Nothing runs until the limit is raised, queued prompts included.
+		a.ClearQueue(call.SessionID)
+		return nil, budgetErr
+	}
+
 	if shouldSummarize {
 		a.activeRequests.Del(call.SessionID)
-		if summarizeErr := a.Summarize(genCtx, call.SessionID, call.ProviderOptions); summarizeErr != nil {
-			return nil, summarizeErr
+		if compactErr := a.compact(genCtx, call.SessionID, largeModel, providerOptions); compactErr != nil {
+			return nil, compactErr
 		}
 		// If the agent wasn't done...
 		if len(currentAssistant.ToolCalls()) > 0 {
@@ -551,6 +650,10 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 	a.activeRequests.Del(call.SessionID)
 	cancel()
 
+	if !a.isSubAgent && !shouldSummarize {
+		a.runStopHooks(ctx, call)
+	}
+
 	queuedMessages, ok := a.messageQueue.Get(call.SessionID)
 	if !ok || len(queuedMessages) == 0 {
 		return result, err
@@ -590,7 +693,7 @@ func (a *sessionAgent) Summarize(ctx context.Context, sessionID string, opts fan
 	defer a.activeRequests.Del(sessionID)
 	defer cancel()
 
-	agent := fantasy.NewAgent(largeModel.Model,
+	agent := fantasy.NewAgent(newRetryModel(largeModel),
 		fantasy.WithSystemPrompt(string(summaryPrompt)),
 	)
 	summaryMessage, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
@@ -609,6 +712,7 @@ func (a *sessionAgent) Summarize(ctx context.Context, sessionID string, opts fan
 		Prompt:          summaryPromptText,
 		Messages:        aiMsgs,
 		ProviderOptions: opts,
+		MaxRetries:      new(int),
 		PrepareStep: func(callContext context.Context, options fantasy.PrepareStepFunctionOptions) (_ context.Context, prepared fantasy.PrepareStepResult, err error) {
 			prepared.Messages = options.Messages
 			if systemPromptPrefix != "" {
@@ -618,7 +722,7 @@ func (a *sessionAgent) Summarize(ctx context.Context, sessionID string, opts fan
 		},
 		OnReasoningDelta: func(id string, text string) error {
 			summaryMessage.AppendReasoningContent(text)
-			return a.messages.Update(genCtx, summaryMessage)
+			return a.messages.UpdateStreaming(genCtx, summaryMessage)
 		},
 		OnReasoningEnd: func(id string, reasoning fantasy.ReasoningContent) error {
 			// Handle anthropic signature.
@@ -632,7 +736,7 @@ func (a *sessionAgent) Summarize(ctx context.Context, sessionID string, opts fan
 		},
 		OnTextDelta: func(id, text string) error {
 			summaryMessage.AppendContent(text)
-			return a.messages.Update(genCtx, summaryMessage)
+			return a.messages.UpdateStreaming(genCtx, summaryMessage)
 		},
 	})
 	if err != nil {
@@ -663,7 +767,8 @@ func (a *sessionAgent) Summarize(ctx context.Context, sessionID string, opts fan
 		}
 	}
 
-	a.updateSessionUsage(largeModel, &currentSession, resp.TotalUsage, openrouterCost)
+	cost := a.updateSessionUsage(largeModel, &currentSession, resp.TotalUsage, openrouterCost)
+	a.recordCost(ctx, sessionID, cost)
 
 	// Just in case, get just the last usage info.
 	usage := resp.Response.Usage
@@ -691,6 +796,20 @@ func (a *sessionAgent) getCacheControlOptions() fantasy.ProviderOptions {
 	}
 }
 
+// cachedTool gives a tool its own provider options for a single run.
+type cachedTool struct {
+	fantasy.AgentTool
+	options fantasy.ProviderOptions
+}
+
+func (t *cachedTool) ProviderOptions() fantasy.ProviderOptions {
+	return t.options
+}
+
+func (t *cachedTool) SetProviderOptions(opts fantasy.ProviderOptions) {
+	t.options = opts
+}
+
 func (a *sessionAgent) createUserMessage(ctx context.Context, call SessionAgentCall) (message.Message, error) {
 	parts := []message.ContentPart{message.TextContent{Text: call.Prompt}}
 	var attachmentParts []message.ContentPart
@@ -867,11 +986,7 @@ func (a *sessionAgent) generateTitle(ctx context.Context, sessionID string, user
 		}
 	}
 
-	modelConfig := model.CatwalkCfg
-	cost := modelConfig.CostPer1MInCached/1e6*float64(resp.TotalUsage.CacheCreationTokens) +
-		modelConfig.CostPer1MOutCached/1e6*float64(resp.TotalUsage.CacheReadTokens) +
-		modelConfig.CostPer1MIn/1e6*float64(resp.TotalUsage.InputTokens) +
-		modelConfig.CostPer1MOut/1e6*float64(resp.TotalUsage.OutputTokens)
+	cost := modelCost(model.CatwalkCfg, resp.TotalUsage)
 
 	// Use override cost if available (e.g., from OpenRouter).
 	if openrouterCost != nil {
@@ -888,6 +1003,7 @@ func (a *sessionAgent) generateTitle(ctx context.Context, sessionID string, user
 		slog.Error("Failed to save session title and usage", "error", saveErr)
 		return
 	}
+	a.recordCost(ctx, sessionID, cost)
 }
 
 func (a *sessionAgent) openrouterCost(metadata fantasy.ProviderMetadata) *float64 {
@@ -903,23 +1019,28 @@ func (a *sessionAgent) openrouterCost(metadata fantasy.ProviderMetadata) *float6
 	return &opts.Usage.Cost
 }
 
-func (a *sessionAgent) updateSessionUsage(model Model, session *session.Session, usage fantasy.Usage, overrideCost *float64) {
-	modelConfig := model.CatwalkCfg
-	cost := modelConfig.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
-		modelConfig.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
-		modelConfig.CostPer1MIn/1e6*float64(usage.InputTokens) +
-		modelConfig.CostPer1MOut/1e6*float64(usage.OutputTokens)
+// modelCost returns what usage costs on a model, at the model's list prices.
+func modelCost(model catwalk.Model, usage fantasy.Usage) float64 {
+	return model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
+		model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
+		model.CostPer1MIn/1e6*float64(usage.InputTokens) +
+		model.CostPer1MOut/1e6*float64(usage.OutputTokens)
+}
+
+// updateSessionUsage adds usage to the session, and returns its cost.
+func (a *sessionAgent) updateSessionUsage(model Model, session *session.Session, usage fantasy.Usage, overrideCost *float64) float64 {
+	cost := modelCost(model.CatwalkCfg, usage)
 
 	a.eventTokensUsed(session.ID, model, usage, cost)
 
 	if overrideCost != nil {
-		session.Cost += *overrideCost
-	} else {
-		session.Cost += cost
+		cost = *overrideCost
 	}
+	session.Cost += cost
 
 	session.CompletionTokens = usage.OutputTokens
 	session.PromptTokens = usage.InputTokens + usage.CacheReadTokens
+	return cost
 }
 
 func (a *sessionAgent) Cancel(sessionID string) {
@@ -1053,9 +1174,48 @@ func (a *sessionAgent) convertToToolResult(result fantasy.ToolResultContent) mes
 		}
 	}
 
+	// MCP tools send the parts of their results in the metadata, which only
+	// exists to carry them here.
+	if strings.HasPrefix(result.ToolName, "mcp_") && result.ClientMetadata != "" {
+		var metadata tools.MCPResponseMetadata
+		if err := json.Unmarshal([]byte(result.ClientMetadata), &metadata); err == nil {
+			baseResult.Parts = metadata.Parts
+			baseResult.Metadata = ""
+		}
+	}
+
 	return baseResult
 }
 
+// attachExtraToolMedia adds the media of tool results that doesn't fit in the
+// tool result, such as the second image of an MCP tool, in a user message
+// after the tool message. [message.Message.ToAIMessage] does the same for the
+// tool results in the history.
+func attachExtraToolMedia(messages []fantasy.Message, media *csync.Map[string, []fantasy.FilePart]) []fantasy.Message {
+	if media.Len() == 0 {
+		return messages
+	}
+
+	converted := make([]fantasy.Message, 0, len(messages))
+	for _, msg := range messages {
+		converted = append(converted, msg)
+		if msg.Role != fantasy.MessageRoleTool {
+			continue
+		}
+		var files []fantasy.FilePart
+		for _, part := range msg.Content {
+			if toolResult, ok := fantasy.AsMessagePart[fantasy.ToolResultPart](part); ok {
+				extra, _ := media.Get(toolResult.ToolCallID)
+				files = append(files, extra...)
+			}
+		}
+		if len(files) > 0 {
+			converted = append(converted, message.ToolMediaMessage(files))
+		}
+	}
+	return converted
+}
+
 // workaroundProviderMediaLimitations converts media content in tool results to
 // user messages for providers that don't natively support images in tool results.
 //
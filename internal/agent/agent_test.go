@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -651,3 +652,37 @@ func BenchmarkBuildSummaryPrompt(b *testing.B) {
 		})
 	}
 }
+
+func TestRunCachesOnlyTheLastTool(t *testing.T) {
+	env := testEnv(t)
+	large := &failingModel{name: "large"}
+	small := &failingModel{name: "small"}
+	newTool := func(name string) fantasy.AgentTool {
+		return fantasy.NewAgentTool(name, name, func(context.Context, struct{}, fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			return fantasy.NewTextResponse(name), nil
+		})
+	}
+	agentTools := []fantasy.AgentTool{newTool(tools.ViewToolName), newTool(tools.WriteToolName)}
+	agent := testSessionAgent(env, large, small, "You are a test agent.", agentTools...)
+
+	sess, err := env.sessions.Create(t.Context(), "Cache")
+	require.NoError(t, err)
+
+	// A plan-mode run drops the write tool, and caches the view tool
+	// instead, which must not stick for the next runs.
+	for _, planMode := range []bool{true, false} {
+		_, err = agent.Run(t.Context(), SessionAgentCall{SessionID: sess.ID, Prompt: "hi", PlanMode: planMode})
+		require.NoError(t, err)
+
+		var cached []string
+		for _, tool := range large.call.Tools {
+			if len(tool.(fantasy.FunctionTool).ProviderOptions) > 0 {
+				cached = append(cached, tool.GetName())
+			}
+		}
+		require.Equal(t, []string{large.call.Tools[len(large.call.Tools)-1].GetName()}, cached)
+	}
+	for _, tool := range agentTools {
+		require.Empty(t, tool.ProviderOptions())
+	}
+}
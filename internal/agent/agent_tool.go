@@ -1,51 +1,92 @@
 package agent
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	_ "embed"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
 
 	"charm.land/fantasy"
 
-	"github.com/charmbracelet/crush/internal/agent/prompt"
 	"github.com/charmbracelet/crush/internal/agent/tools"
 	"github.com/charmbracelet/crush/internal/config"
 )
 
-//go:embed templates/agent_tool.md
-var agentToolDescription []byte
+//go:embed templates/agent_tool.md.tpl
+var agentToolDescriptionTmpl []byte
+
+var agentToolDescriptionTpl = template.Must(
+	template.New("agentToolDescription").
+		Funcs(template.FuncMap{"join": strings.Join}).
+		Parse(string(agentToolDescriptionTmpl)),
+)
 
 type AgentParams struct {
-	Prompt string `json:"prompt" description:"The task for the agent to perform"`
+	Prompt       string `json:"prompt" description:"The task for the agent to perform"`
+	SubagentType string `json:"subagent_type,omitempty" description:"The type of subagent to perform the task; defaults to task"`
 }
 
 const (
 	AgentToolName = "agent"
 )
 
-func (c *coordinator) agentTool(ctx context.Context) (fantasy.AgentTool, error) {
-	agentCfg, ok := c.cfg.Agents[config.AgentTask]
-	if !ok {
-		return nil, errors.New("task agent not configured")
+func agentToolDescription(subagents []config.Agent) string {
+	var out bytes.Buffer
+	if err := agentToolDescriptionTpl.Execute(&out, struct{ Subagents []config.Agent }{subagents}); err != nil {
+		// this should never happen.
+		panic("failed to execute agent tool description template: " + err.Error())
 	}
-	prompt, err := taskPrompt(prompt.WithWorkingDir(c.cfg.WorkingDir()))
-	if err != nil {
-		return nil, err
+	return out.String()
+}
+
+func (c *coordinator) agentTool(ctx context.Context, workingDir string) (fantasy.AgentTool, error) {
+	if _, ok := c.cfg.Agents[config.AgentTask]; !ok {
+		return nil, errors.New("task agent not configured")
 	}
 
-	agent, err := c.buildAgent(ctx, prompt, agentCfg, true)
-	if err != nil {
-		return nil, err
+	subagentCfgs := c.cfg.Subagents()
+	subagents := make(map[string]SessionAgent, len(subagentCfgs))
+	for i, agentCfg := range subagentCfgs {
+		// Subagents can't delegate further.
+		agentCfg.AllowedTools = slices.DeleteFunc(slices.Clone(agentCfg.AllowedTools), func(name string) bool {
+			return name == AgentToolName
+		})
+		subagentCfgs[i] = agentCfg
+
+		prompt, err := c.agentPrompt(agentCfg, taskPrompt, workingDir)
+		if err != nil {
+			return nil, err
+		}
+		agent, err := c.buildAgent(ctx, prompt, agentCfg, true, workingDir)
+		if err != nil {
+			return nil, err
+		}
+		subagents[agentCfg.ID] = agent
 	}
+
 	return fantasy.NewParallelAgentTool(
 		AgentToolName,
-		string(agentToolDescription),
+		agentToolDescription(subagentCfgs),
 		func(ctx context.Context, params AgentParams, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
 			if params.Prompt == "" {
 				return fantasy.NewTextErrorResponse("prompt is required"), nil
 			}
 
+			subagentType := cmp.Or(params.SubagentType, config.AgentTask)
+			agent, ok := subagents[subagentType]
+			if !ok {
+				return fantasy.NewTextErrorResponse(fmt.Sprintf(
+					"unknown subagent_type %q, expected one of: %s",
+					subagentType, strings.Join(slices.Sorted(maps.Keys(subagents)), ", "),
+				)), nil
+			}
+
 			sessionID := tools.GetSessionFromContext(ctx)
 			if sessionID == "" {
 				return fantasy.ToolResponse{}, errors.New("session id missing from context")
@@ -61,6 +102,8 @@ func (c *coordinator) agentTool(ctx context.Context) (fantasy.AgentTool, error)
 			if err != nil {
 				return fantasy.ToolResponse{}, fmt.Errorf("error creating session: %s", err)
 			}
+			// Sub-agents answer permission requests like their parent.
+			c.permissions.SetSessionMode(session.ID, c.permissions.SessionMode(sessionID))
 			model := agent.Model()
 			maxTokens := model.CatwalkCfg.DefaultMaxTokens
 			if model.ModelCfg.MaxTokens != 0 {
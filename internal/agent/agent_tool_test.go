@@ -0,0 +1,19 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentToolDescription(t *testing.T) {
+	t.Parallel()
+
+	description := agentToolDescription([]config.Agent{
+		{ID: config.AgentTask, Description: "Searches the codebase.", AllowedTools: []string{"glob", "grep"}},
+		{ID: "tester", Description: "Runs the tests.", AllowedTools: []string{"bash"}},
+		{ID: "thinker"},
+	})
+	require.Contains(t, description, "<subagent_types>\n- task: Searches the codebase. (tools: glob, grep)\n- tester: Runs the tests. (tools: bash)\n- thinker: (tools: none)\n</subagent_types>")
+}
@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// BudgetUsage is how much of a budget was spent.
+type BudgetUsage struct {
+	Kind  config.BudgetKind
+	Limit float64
+	Spent float64
+}
+
+// Exceeded reports whether the budget is used up.
+func (u BudgetUsage) Exceeded() bool {
+	return u.Spent >= u.Limit
+}
+
+// Fraction returns the fraction of the budget that was spent.
+func (u BudgetUsage) Fraction() float64 {
+	return u.Spent / u.Limit
+}
+
+// BudgetError is returned when the agent stops because a budget is used up.
+// The agent doesn't carry on until the limit is raised.
+type BudgetError struct {
+	BudgetUsage
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("%s budget of $%.2f reached ($%.2f spent)", e.Kind, e.Limit, e.Spent)
+}
+
+type runBudgetKey struct{}
+
+// runBudget keeps track of what a run costs, sub-agents included.
+type runBudget struct {
+	limit float64
+
+	mu    sync.Mutex
+	spent float64
+}
+
+// WithRunBudget returns a context in which the agent stops once it spent
+// limit, in US dollars. Non-interactive runs use it.
+func WithRunBudget(ctx context.Context, limit float64) context.Context {
+	return context.WithValue(ctx, runBudgetKey{}, &runBudget{limit: limit})
+}
+
+func runBudgetFrom(ctx context.Context) *runBudget {
+	b, _ := ctx.Value(runBudgetKey{}).(*runBudget)
+	return b
+}
+
+func (b *runBudget) add(cost float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent += cost
+}
+
+func (b *runBudget) usage() BudgetUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BudgetUsage{Kind: config.BudgetRun, Limit: b.limit, Spent: b.spent}
+}
+
+// budgetUsage returns the usage of the budgets that apply to a session:
+// the budgets of the configuration, and the budget of the run in ctx.
+func budgetUsage(ctx context.Context, sessions session.Service, budget config.Budget, sess session.Session) ([]BudgetUsage, error) {
+	var usage []BudgetUsage
+	if budget.Session > 0 {
+		usage = append(usage, BudgetUsage{Kind: config.BudgetSession, Limit: budget.Session, Spent: sess.Cost})
+	}
+	if budget.Daily > 0 {
+		spent, err := sessions.CostSince(ctx, startOfDay(time.Now()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the cost of the day: %w", err)
+		}
+		usage = append(usage, BudgetUsage{Kind: config.BudgetDaily, Limit: budget.Daily, Spent: spent})
+	}
+	if run := runBudgetFrom(ctx); run != nil && run.limit > 0 {
+		usage = append(usage, run.usage())
+	}
+	return usage, nil
+}
+
+// checkBudget returns a [BudgetError] if a budget that applies to the
+// session is used up.
+func (a *sessionAgent) checkBudget(ctx context.Context, budget config.Budget, sess session.Session) error {
+	usage, err := budgetUsage(ctx, a.sessions, budget, sess)
+	if err != nil {
+		return err
+	}
+	for _, u := range usage {
+		if u.Exceeded() {
+			return &BudgetError{u}
+		}
+	}
+	return nil
+}
+
+// recordCost records the cost spent on a session against the budget of the
+// run and the daily budget of the project.
+func (a *sessionAgent) recordCost(ctx context.Context, sessionID string, cost float64) {
+	if run := runBudgetFrom(ctx); run != nil {
+		run.add(cost)
+	}
+	if err := a.sessions.RecordCost(ctx, sessionID, cost); err != nil {
+		slog.Error("Failed to record session cost", "session_id", sessionID, "error", err)
+	}
+}
+
+// startOfDay returns the local midnight of the day of t.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckBudget(t *testing.T) {
+	t.Parallel()
+
+	env := testEnv(t)
+	a := &sessionAgent{sessions: env.sessions}
+
+	sess, err := env.sessions.Create(t.Context(), "budget")
+	require.NoError(t, err)
+	sess.Cost = 3
+	a.recordCost(t.Context(), sess.ID, 3)
+
+	// The cost of deleted sessions still counts for the day.
+	deleted, err := env.sessions.Create(t.Context(), "deleted")
+	require.NoError(t, err)
+	a.recordCost(t.Context(), deleted.ID, 2)
+	require.NoError(t, env.sessions.Delete(t.Context(), deleted.ID))
+
+	require.NoError(t, a.checkBudget(t.Context(), config.Budget{}, sess))
+	require.NoError(t, a.checkBudget(t.Context(), config.Budget{Session: 4, Daily: 6}, sess))
+
+	var budgetErr *BudgetError
+	err = a.checkBudget(t.Context(), config.Budget{Session: 3}, sess)
+	require.ErrorAs(t, err, &budgetErr)
+	require.Equal(t, BudgetUsage{Kind: config.BudgetSession, Limit: 3, Spent: 3}, budgetErr.BudgetUsage)
+
+	err = a.checkBudget(t.Context(), config.Budget{Daily: 5}, sess)
+	require.ErrorAs(t, err, &budgetErr)
+	require.Equal(t, BudgetUsage{Kind: config.BudgetDaily, Limit: 5, Spent: 5}, budgetErr.BudgetUsage)
+
+	ctx := WithRunBudget(t.Context(), 1)
+	require.NoError(t, a.checkBudget(ctx, config.Budget{}, sess))
+	a.recordCost(ctx, sess.ID, 1)
+	err = a.checkBudget(ctx, config.Budget{}, sess)
+	require.ErrorAs(t, err, &budgetErr)
+	require.Equal(t, config.BudgetRun, budgetErr.Kind)
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// bytesPerToken is a rough estimate of the number of bytes per token, used
+// to tell how much context pruning frees before the provider reports it.
+const bytesPerToken = 4
+
+// isContextLow reports whether the context window of the model is running
+// low for the session.
+func isContextLow(model Model, s session.Session) bool {
+	cw := int64(model.CatwalkCfg.ContextWindow)
+	remaining := cw - (s.CompletionTokens + s.PromptTokens)
+	var threshold int64
+	if cw > largeContextWindowThreshold {
+		threshold = largeContextWindowBuffer
+	} else {
+		threshold = int64(float64(cw) * smallContextWindowRatio)
+	}
+	return remaining <= threshold
+}
+
+// compact frees up context in a session. Old tool outputs are pruned first,
+// and the conversation is only summarized if that is not enough.
+func (a *sessionAgent) compact(ctx context.Context, sessionID string, model Model, opts fantasy.ProviderOptions) error {
+	if !a.compaction.DisablePruning {
+		currentSession, err := a.sessions.Get(ctx, sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to get session: %w", err)
+		}
+		msgs, err := a.getSessionMessages(ctx, currentSession)
+		if err != nil {
+			return err
+		}
+		pruned, freed := pruneToolResults(msgs, a.compaction)
+		for _, msg := range pruned {
+			if err := a.messages.Update(ctx, msg); err != nil {
+				return err
+			}
+		}
+		if len(pruned) > 0 {
+			currentSession.PromptTokens = max(0, currentSession.PromptTokens-freed)
+			if _, err := a.sessions.Save(ctx, currentSession); err != nil {
+				return err
+			}
+			slog.Debug("Pruned tool outputs", "session_id", sessionID, "messages", len(pruned), "tokens", freed)
+		}
+		if !isContextLow(model, currentSession) {
+			return nil
+		}
+	}
+	return a.Summarize(ctx, sessionID, opts)
+}
+
+// pruneToolResults marks the large outputs of the pruning tools as pruned,
+// except for the ones in the most recent turns, where a turn is a user
+// prompt or an agent step. It returns the messages it changed and an
+// estimate of the tokens freed.
+func pruneToolResults(msgs []message.Message, compaction config.Compaction) ([]message.Message, int64) {
+	keepTurns, minSize, tools := compaction.Policy()
+
+	// Find where the turns to keep start.
+	end := len(msgs)
+	for turns := 0; end > 0 && turns < keepTurns; {
+		end--
+		if msgs[end].Role != message.Tool {
+			turns++
+		}
+	}
+
+	var pruned []message.Message
+	var freed int64
+	for _, msg := range msgs[:end] {
+		if msg.Role != message.Tool {
+			continue
+		}
+		changed := false
+		parts := slices.Clone(msg.Parts)
+		for i, part := range parts {
+			result, ok := part.(message.ToolResult)
+			if !ok || result.Pruned || result.IsError || result.Data != "" {
+				continue
+			}
+			if len(result.Content) < minSize || !slices.Contains(tools, result.Name) {
+				continue
+			}
+			result.Pruned = true
+			parts[i] = result
+			freed += int64(len(result.Content) / bytesPerToken)
+			changed = true
+		}
+		if changed {
+			msg.Parts = parts
+			pruned = append(pruned, msg)
+		}
+	}
+	return pruned, freed
+}
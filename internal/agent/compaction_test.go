@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneToolResults(t *testing.T) {
+	t.Parallel()
+
+	large := strings.Repeat("x", 4096)
+	step := func(id, tool, content string) []message.Message {
+		return []message.Message{
+			{ID: id + "-call", Role: message.Assistant, Parts: []message.ContentPart{
+				message.ToolCall{ID: id, Name: tool, Finished: true},
+			}},
+			{ID: id + "-result", Role: message.Tool, Parts: []message.ContentPart{
+				message.ToolResult{ToolCallID: id, Name: tool, Content: content},
+			}},
+		}
+	}
+
+	var msgs []message.Message
+	msgs = append(msgs, message.Message{ID: "prompt", Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "hi"}}})
+	msgs = append(msgs, step("view", "view", large)...)
+	msgs = append(msgs, step("small", "grep", "main.go:1")...)
+	msgs = append(msgs, step("edit", "edit", large)...)
+	msgs = append(msgs, step("bash", "bash", large)...)
+	msgs = append(msgs, step("recent", "view", large)...)
+
+	keepTurns := 1
+	pruned, freed := pruneToolResults(msgs, config.Compaction{KeepTurns: &keepTurns})
+	require.Len(t, pruned, 2)
+	require.Equal(t, "view-result", pruned[0].ID)
+	require.Equal(t, "bash-result", pruned[1].ID)
+	require.Equal(t, int64(2*len(large)/bytesPerToken), freed)
+	for _, msg := range pruned {
+		require.True(t, msg.ToolResults()[0].Pruned)
+		require.Equal(t, large, msg.ToolResults()[0].Content)
+	}
+
+	// The original messages are left alone.
+	require.False(t, msgs[2].ToolResults()[0].Pruned)
+
+	// Pruned results are not pruned again.
+	pruned, _ = pruneToolResults(append(pruned, msgs[len(msgs)-2:]...), config.Compaction{KeepTurns: &keepTurns})
+	require.Empty(t, pruned)
+
+	pruned, _ = pruneToolResults(msgs, config.Compaction{KeepTurns: &keepTurns, Tools: []string{"edit"}})
+	require.Len(t, pruned, 1)
+	require.Equal(t, "edit-result", pruned[0].ID)
+}
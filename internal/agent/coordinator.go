@@ -14,21 +14,26 @@ import (
 	"os"
 	"slices"
 	"strings"
+	"sync"
 
 	"charm.land/catwalk/pkg/catwalk"
 	"charm.land/fantasy"
 	"github.com/charmbracelet/crush/internal/agent/hyper"
 	"github.com/charmbracelet/crush/internal/agent/prompt"
 	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/agent/tools/mcp"
 	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/filetracker"
 	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/hooks"
 	"github.com/charmbracelet/crush/internal/log"
 	"github.com/charmbracelet/crush/internal/lsp"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/oauth/copilot"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/worktree"
 	"golang.org/x/sync/errgroup"
 
 	"charm.land/fantasy/providers/anthropic"
@@ -44,8 +49,11 @@ import (
 )
 
 type Coordinator interface {
-	// INFO: (kujtim) this is not used yet we will use this when we have multiple agents
-	// SetMainAgent(string)
+	// SetMainAgent switches the agent that runs prompts to the configured
+	// agent with the given ID.
+	SetMainAgent(ctx context.Context, agentID string) error
+	// MainAgent returns the configuration of the agent that runs prompts.
+	MainAgent() config.Agent
 	Run(ctx context.Context, sessionID, prompt string, attachments ...message.Attachment) (*fantasy.AgentResult, error)
 	Cancel(sessionID string)
 	CancelAll()
@@ -57,6 +65,17 @@ type Coordinator interface {
 	Summarize(context.Context, string) error
 	Model() Model
 	UpdateModels(ctx context.Context) error
+	// SetPlanMode turns plan mode on or off for a session. In plan mode the
+	// agent only gets read-only tools and writes a plan with the todos tool.
+	SetPlanMode(sessionID string, enabled bool)
+	// PlanMode reports whether a session is in plan mode.
+	PlanMode(sessionID string) bool
+	// ApprovePlan turns plan mode off for a session and has the agent carry
+	// out the plan it wrote.
+	ApprovePlan(ctx context.Context, sessionID string) (*fantasy.AgentResult, error)
+	// BudgetUsage returns how much of the budgets that apply to a session
+	// was spent.
+	BudgetUsage(ctx context.Context, sessionID string) ([]BudgetUsage, error)
 }
 
 type coordinator struct {
@@ -67,9 +86,21 @@ type coordinator struct {
 	history     history.Service
 	filetracker filetracker.Service
 	lspManager  *lsp.Manager
+	hooks       *hooks.Runner
 
-	currentAgent SessionAgent
-	agents       map[string]SessionAgent
+	agentMu         sync.RWMutex
+	currentAgent    SessionAgent
+	currentAgentCfg config.Agent
+	agents          map[string]SessionAgent
+	// worktreeAgents are the copies of the main agents for the sessions that
+	// run in a worktree.
+	worktreeAgents map[worktreeAgentKey]SessionAgent
+
+	planSessions *csync.Map[string, bool]
+
+	// worktrees is nil when the project is not in a git repository.
+	worktrees  *worktree.Manager
+	worktreeMu sync.Mutex
 
 	readyWg errgroup.Group
 }
@@ -92,7 +123,16 @@ func NewCoordinator(
 		history:     history,
 		filetracker: filetracker,
 		lspManager:  lspManager,
+		hooks:       hooks.NewRunner(cfg.Hooks, cfg.WorkingDir()),
 		agents:      make(map[string]SessionAgent),
+
+		worktreeAgents: make(map[worktreeAgentKey]SessionAgent),
+
+		planSessions: csync.NewMap[string, bool](),
+	}
+
+	if worktrees, err := worktree.New(ctx, cfg.WorkingDir(), config.GlobalWorktreesDir()); err == nil {
+		c.worktrees = worktrees
 	}
 
 	agentCfg, ok := cfg.Agents[config.AgentCoder]
@@ -100,19 +140,195 @@ func NewCoordinator(
 		return nil, errors.New("coder agent not configured")
 	}
 
-	// TODO: make this dynamic when we support multiple agents
-	prompt, err := coderPrompt(prompt.WithWorkingDir(c.cfg.WorkingDir()))
+	agent, err := c.buildMainAgent(ctx, agentCfg, cfg.WorkingDir())
 	if err != nil {
 		return nil, err
 	}
+	c.currentAgent = agent
+	c.currentAgentCfg = agentCfg
+	c.agents[config.AgentCoder] = agent
+
+	mcp.SetSamplingFunc(c.sample)
+	return c, nil
+}
+
+// SetMainAgent implements Coordinator.
+func (c *coordinator) SetMainAgent(ctx context.Context, agentID string) error {
+	agentCfg, ok := c.cfg.Agents[agentID]
+	if !ok || !agentCfg.IsPrimary() {
+		return fmt.Errorf("agent %q not found, available agents: %s", agentID, strings.Join(c.mainAgentIDs(), ", "))
+	}
+
+	c.agentMu.Lock()
+	defer c.agentMu.Unlock()
+	if c.currentAgent.IsBusy() {
+		return errors.New("cannot switch agents while the current agent is busy")
+	}
+	agent, ok := c.agents[agentID]
+	if !ok {
+		var err error
+		agent, err = c.buildMainAgent(ctx, agentCfg, c.cfg.WorkingDir())
+		if err != nil {
+			return err
+		}
+		c.agents[agentID] = agent
+	}
+	c.currentAgent = agent
+	c.currentAgentCfg = agentCfg
+	return nil
+}
+
+// MainAgent implements Coordinator.
+func (c *coordinator) MainAgent() config.Agent {
+	c.agentMu.RLock()
+	defer c.agentMu.RUnlock()
+	return c.currentAgentCfg
+}
+
+// mainAgent returns the agent that runs prompts.
+func (c *coordinator) mainAgent() SessionAgent {
+	c.agentMu.RLock()
+	defer c.agentMu.RUnlock()
+	return c.currentAgent
+}
+
+// mainAgentIDs returns the sorted IDs of the agents that can run prompts.
+func (c *coordinator) mainAgentIDs() []string {
+	var ids []string
+	for id, agent := range c.cfg.Agents {
+		if agent.IsPrimary() {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
 
-	agent, err := c.buildAgent(ctx, prompt, agentCfg, false)
+// sessionAgents returns all the agents built so far.
+func (c *coordinator) sessionAgents() []SessionAgent {
+	c.agentMu.Lock()
+	defer c.agentMu.Unlock()
+	c.pruneWorktreeAgents()
+	agents := slices.Collect(maps.Values(c.agents))
+	return slices.AppendSeq(agents, maps.Values(c.worktreeAgents))
+}
+
+// worktreeAgentKey identifies the copy of a main agent for a worktree.
+type worktreeAgentKey struct {
+	agentID    string
+	workingDir string
+}
+
+// pruneWorktreeAgents forgets the idle agents of the worktrees that are gone,
+// as merged or discarded worktrees are, possibly by another process. It must
+// be called with agentMu held.
+func (c *coordinator) pruneWorktreeAgents() {
+	for key, agent := range c.worktreeAgents {
+		if agent.IsBusy() {
+			continue
+		}
+		if _, err := os.Stat(key.workingDir); errors.Is(err, os.ErrNotExist) {
+			slog.Debug("Removing agent of a removed worktree", "agent", key.agentID, "dir", key.workingDir)
+			delete(c.worktreeAgents, key)
+		}
+	}
+}
+
+func (c *coordinator) buildMainAgent(ctx context.Context, agentCfg config.Agent, workingDir string) (SessionAgent, error) {
+	p, err := c.agentPrompt(agentCfg, coderPrompt, workingDir)
 	if err != nil {
 		return nil, err
 	}
-	c.currentAgent = agent
-	c.agents[config.AgentCoder] = agent
-	return c, nil
+	return c.buildAgent(ctx, p, agentCfg, false, workingDir)
+}
+
+// sessionAgent returns the agent that runs the prompts of a session. Sessions
+// in a worktree get their own copy of the main agent, with tools that work in
+// the worktree.
+func (c *coordinator) sessionAgent(ctx context.Context, sessionID string) (SessionAgent, error) {
+	workingDir := c.sessionWorkingDir(ctx, sessionID)
+	if workingDir == c.cfg.WorkingDir() {
+		if err := c.UpdateModels(ctx); err != nil {
+			return nil, err
+		}
+		return c.mainAgent(), nil
+	}
+
+	c.agentMu.Lock()
+	c.pruneWorktreeAgents()
+	agentCfg := c.currentAgentCfg
+	key := worktreeAgentKey{agentID: agentCfg.ID, workingDir: workingDir}
+	agent, ok := c.worktreeAgents[key]
+	if !ok {
+		var err error
+		agent, err = c.buildMainAgent(ctx, agentCfg, workingDir)
+		if err != nil {
+			c.agentMu.Unlock()
+			return nil, err
+		}
+		c.worktreeAgents[key] = agent
+	}
+	c.agentMu.Unlock()
+
+	if !ok {
+		return agent, c.readyWg.Wait()
+	}
+	return agent, c.updateAgent(ctx, agent, agentCfg, workingDir)
+}
+
+// sessionWorkingDir returns the directory the tools of a session work in: the
+// worktree of the session if it has one, or the working directory of the
+// project. New sessions get a worktree when the worktree option is on.
+func (c *coordinator) sessionWorkingDir(ctx context.Context, sessionID string) string {
+	if c.worktrees == nil {
+		if c.cfg.Options.Worktree {
+			slog.Warn("Not in a git repository, running session in the working directory", "session_id", sessionID)
+		}
+		return c.cfg.WorkingDir()
+	}
+
+	c.worktreeMu.Lock()
+	defer c.worktreeMu.Unlock()
+
+	if wt, err := c.worktrees.Get(sessionID); err == nil {
+		return wt.WorkingDir
+	}
+	if !c.cfg.Options.Worktree {
+		return c.cfg.WorkingDir()
+	}
+	sess, err := c.sessions.Get(ctx, sessionID)
+	if err != nil || sess.MessageCount > 0 || sess.ParentSessionID != "" {
+		return c.cfg.WorkingDir()
+	}
+	wt, err := c.worktrees.Create(ctx, sessionID)
+	if err != nil {
+		slog.Warn("Failed to create worktree, running session in the working directory", "session_id", sessionID, "error", err)
+		return c.cfg.WorkingDir()
+	}
+	slog.Info("Created worktree for session", "session_id", sessionID, "path", wt.Path, "branch", wt.Branch)
+	return wt.WorkingDir
+}
+
+// agentPrompt returns the system prompt of an agent, falling back to
+// defaultPrompt for agents without their own.
+func (c *coordinator) agentPrompt(agentCfg config.Agent, defaultPrompt func(...prompt.Option) (*prompt.Prompt, error), workingDir string) (*prompt.Prompt, error) {
+	opts := []prompt.Option{
+		prompt.WithWorkingDir(workingDir),
+		prompt.WithContextPaths(agentCfg.ContextPaths),
+	}
+	var (
+		p   *prompt.Prompt
+		err error
+	)
+	if agentCfg.Prompt != "" {
+		p, err = prompt.NewPrompt(agentCfg.ID, agentCfg.Prompt, opts...)
+	} else {
+		p, err = defaultPrompt(opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("building prompt of agent %q: %w", agentCfg.ID, err)
+	}
+	return p, nil
 }
 
 // Run implements Coordinator.
@@ -122,15 +338,12 @@ func (c *coordinator) Run(ctx context.Context, sessionID string, prompt string,
 	}
 
 	// refresh models before each run
-	if err := c.UpdateModels(ctx); err != nil {
+	agent, err := c.sessionAgent(ctx, sessionID)
+	if err != nil {
 		return nil, fmt.Errorf("failed to update models: %w", err)
 	}
 
-	model := c.currentAgent.Model()
-	maxTokens := model.CatwalkCfg.DefaultMaxTokens
-	if model.ModelCfg.MaxTokens != 0 {
-		maxTokens = model.ModelCfg.MaxTokens
-	}
+	model := agent.Model()
 
 	if !model.CatwalkCfg.SupportsImages && attachments != nil {
 		// filter out image attachments
@@ -148,7 +361,7 @@ func (c *coordinator) Run(ctx context.Context, sessionID string, prompt string,
 		return nil, errors.New("model provider not configured")
 	}
 
-	mergedOptions, temp, topP, topK, freqPenalty, presPenalty := mergeCallOptions(model, providerCfg)
+	settings := modelCallSettings(model, providerCfg)
 
 	if providerCfg.OAuthToken != nil && providerCfg.OAuthToken.IsExpired() {
 		slog.Debug("Token needs to be refreshed", "provider", providerCfg.ID)
@@ -158,17 +371,19 @@ func (c *coordinator) Run(ctx context.Context, sessionID string, prompt string,
 	}
 
 	run := func() (*fantasy.AgentResult, error) {
-		return c.currentAgent.Run(ctx, SessionAgentCall{
+		return agent.Run(ctx, SessionAgentCall{
 			SessionID:        sessionID,
 			Prompt:           prompt,
 			Attachments:      attachments,
-			MaxOutputTokens:  maxTokens,
-			ProviderOptions:  mergedOptions,
-			Temperature:      temp,
-			TopP:             topP,
-			TopK:             topK,
-			FrequencyPenalty: freqPenalty,
-			PresencePenalty:  presPenalty,
+			MaxOutputTokens:  settings.maxOutputTokens,
+			ProviderOptions:  settings.providerOptions,
+			Temperature:      settings.temperature,
+			TopP:             settings.topP,
+			TopK:             settings.topK,
+			FrequencyPenalty: settings.frequencyPenalty,
+			PresencePenalty:  settings.presencePenalty,
+			PlanMode:         c.PlanMode(sessionID),
+			Budget:           c.cfg.Options.Budget,
 		})
 	}
 	result, originalErr := run()
@@ -350,8 +565,26 @@ func mergeCallOptions(model Model, cfg config.ProviderConfig) (fantasy.ProviderO
 	return modelOptions, temp, topP, topK, freqPenalty, presPenalty
 }
 
-func (c *coordinator) buildAgent(ctx context.Context, prompt *prompt.Prompt, agent config.Agent, isSubAgent bool) (SessionAgent, error) {
-	large, small, err := c.buildAgentModels(ctx, isSubAgent)
+// modelCallSettings returns the settings of the requests sent to model.
+func modelCallSettings(model Model, providerCfg config.ProviderConfig) callSettings {
+	maxTokens := model.CatwalkCfg.DefaultMaxTokens
+	if model.ModelCfg.MaxTokens != 0 {
+		maxTokens = model.ModelCfg.MaxTokens
+	}
+	options, temp, topP, topK, freqPenalty, presPenalty := mergeCallOptions(model, providerCfg)
+	return callSettings{
+		maxOutputTokens:  maxTokens,
+		providerOptions:  options,
+		temperature:      temp,
+		topP:             topP,
+		topK:             topK,
+		frequencyPenalty: freqPenalty,
+		presencePenalty:  presPenalty,
+	}
+}
+
+func (c *coordinator) buildAgent(ctx context.Context, prompt *prompt.Prompt, agent config.Agent, isSubAgent bool, workingDir string) (SessionAgent, error) {
+	large, small, err := c.buildAgentModels(ctx, agent, isSubAgent)
 	if err != nil {
 		return nil, err
 	}
@@ -364,10 +597,12 @@ func (c *coordinator) buildAgent(ctx context.Context, prompt *prompt.Prompt, age
 		"",
 		isSubAgent,
 		c.cfg.Options.DisableAutoSummarize,
+		c.cfg.Options.Compaction,
 		c.permissions.SkipRequests(),
 		c.sessions,
 		c.messages,
 		nil,
+		c.hooks,
 	})
 
 	c.readyWg.Go(func() error {
@@ -380,7 +615,7 @@ func (c *coordinator) buildAgent(ctx context.Context, prompt *prompt.Prompt, age
 	})
 
 	c.readyWg.Go(func() error {
-		tools, err := c.buildTools(ctx, agent)
+		tools, err := c.buildTools(ctx, agent, workingDir)
 		if err != nil {
 			return err
 		}
@@ -391,10 +626,23 @@ func (c *coordinator) buildAgent(ctx context.Context, prompt *prompt.Prompt, age
 	return result, nil
 }
 
-func (c *coordinator) buildTools(ctx context.Context, agent config.Agent) ([]fantasy.AgentTool, error) {
+// planModeTools are the tools available in plan mode: the read-only tools,
+// and todos to write the plan with.
+var planModeTools = []string{
+	tools.ViewToolName,
+	tools.LSToolName,
+	tools.GlobToolName,
+	tools.GrepToolName,
+	tools.ReferencesToolName,
+	tools.DiagnosticsToolName,
+	tools.FetchToolName,
+	tools.TodosToolName,
+}
+
+func (c *coordinator) buildTools(ctx context.Context, agent config.Agent, workingDir string) ([]fantasy.AgentTool, error) {
 	var allTools []fantasy.AgentTool
 	if slices.Contains(agent.AllowedTools, AgentToolName) {
-		agentTool, err := c.agentTool(ctx)
+		agentTool, err := c.agentTool(ctx, workingDir)
 		if err != nil {
 			return nil, err
 		}
@@ -409,37 +657,42 @@ func (c *coordinator) buildTools(ctx context.Context, agent config.Agent) ([]fan
 		allTools = append(allTools, agenticFetchTool)
 	}
 
+	// The LSP servers run in the working directory of the project, so the
+	// sessions in a worktree go without them.
+	lspManager := c.lspManager
+	if workingDir != c.cfg.WorkingDir() {
+		lspManager = nil
+	}
+
 	// Get the model name for the agent
 	modelName := ""
-	if modelCfg, ok := c.cfg.Models[agent.Model]; ok {
-		if model := c.cfg.GetModel(modelCfg.Provider, modelCfg.Model); model != nil {
-			modelName = model.Name
-		}
+	if model := c.cfg.GetAgentModel(agent); model != nil {
+		modelName = model.Name
 	}
 
 	allTools = append(allTools,
-		tools.NewBashTool(c.permissions, c.cfg.WorkingDir(), c.cfg.Options.Attribution, modelName),
+		tools.NewBashTool(c.permissions, workingDir, c.cfg.Options.Attribution, modelName),
 		tools.NewJobOutputTool(),
 		tools.NewJobKillTool(),
-		tools.NewDownloadTool(c.permissions, c.cfg.WorkingDir(), nil),
-		tools.NewEditTool(c.lspManager, c.permissions, c.history, c.filetracker, c.cfg.WorkingDir()),
-		tools.NewMultiEditTool(c.lspManager, c.permissions, c.history, c.filetracker, c.cfg.WorkingDir()),
-		tools.NewFetchTool(c.permissions, c.cfg.WorkingDir(), nil),
-		tools.NewGlobTool(c.cfg.WorkingDir()),
-		tools.NewGrepTool(c.cfg.WorkingDir(), c.cfg.Tools.Grep),
-		tools.NewLsTool(c.permissions, c.cfg.WorkingDir(), c.cfg.Tools.Ls),
+		tools.NewDownloadTool(c.permissions, workingDir, nil),
+		tools.NewEditTool(lspManager, c.permissions, c.history, c.filetracker, workingDir),
+		tools.NewMultiEditTool(lspManager, c.permissions, c.history, c.filetracker, workingDir),
+		tools.NewFetchTool(c.permissions, workingDir, nil),
+		tools.NewGlobTool(workingDir),
+		tools.NewGrepTool(workingDir, c.cfg.Tools.Grep),
+		tools.NewLsTool(c.permissions, workingDir, c.cfg.Tools.Ls),
 		tools.NewSourcegraphTool(nil),
 		tools.NewTodosTool(c.sessions),
-		tools.NewViewTool(c.lspManager, c.permissions, c.filetracker, c.cfg.WorkingDir(), c.cfg.Options.SkillsPaths...),
-		tools.NewWriteTool(c.lspManager, c.permissions, c.history, c.filetracker, c.cfg.WorkingDir()),
+		tools.NewViewTool(lspManager, c.permissions, c.filetracker, workingDir, c.cfg.Options.SkillsPaths...),
+		tools.NewWriteTool(lspManager, c.permissions, c.history, c.filetracker, workingDir),
 	)
 
 	// Add LSP tools if user has configured LSPs or auto_lsp is enabled (nil or true).
-	if len(c.cfg.LSP) > 0 || c.cfg.Options.AutoLSP == nil || *c.cfg.Options.AutoLSP {
-		allTools = append(allTools, tools.NewDiagnosticsTool(c.lspManager), tools.NewReferencesTool(c.lspManager), tools.NewLSPRestartTool(c.lspManager))
+	if lspManager != nil && (len(c.cfg.LSP) > 0 || c.cfg.Options.AutoLSP == nil || *c.cfg.Options.AutoLSP) {
+		allTools = append(allTools, tools.NewDiagnosticsTool(lspManager), tools.NewReferencesTool(lspManager, workingDir), tools.NewLSPRestartTool(lspManager))
 	}
 
-	if len(c.cfg.MCP) > 0 {
+	if len(mcp.Configs(c.cfg)) > 0 {
 		allTools = append(
 			allTools,
 			tools.NewListMCPResourcesTool(c.cfg, c.permissions),
@@ -454,7 +707,7 @@ func (c *coordinator) buildTools(ctx context.Context, agent config.Agent) ([]fan
 		}
 	}
 
-	for _, tool := range tools.GetMCPTools(c.permissions, c.cfg, c.cfg.WorkingDir()) {
+	for _, tool := range tools.GetMCPTools(c.permissions, c.cfg, workingDir) {
 		if agent.AllowedMCP == nil {
 			// No MCP restrictions
 			filteredTools = append(filteredTools, tool)
@@ -479,14 +732,18 @@ func (c *coordinator) buildTools(ctx context.Context, agent config.Agent) ([]fan
 	slices.SortFunc(filteredTools, func(a, b fantasy.AgentTool) int {
 		return strings.Compare(a.Info().Name, b.Info().Name)
 	})
+	for i, tool := range filteredTools {
+		filteredTools[i] = permissionErrorTool{hookTool{tool, c.hooks}}
+	}
 	return filteredTools, nil
 }
 
-// TODO: when we support multiple agents we need to change this so that we pass in the agent specific model config
-func (c *coordinator) buildAgentModels(ctx context.Context, isSubAgent bool) (Model, Model, error) {
-	largeModelCfg, ok := c.cfg.Models[config.SelectedModelTypeLarge]
+// buildAgentModels builds the models of an agent: the model the agent runs
+// on, and the small model.
+func (c *coordinator) buildAgentModels(ctx context.Context, agent config.Agent, isSubAgent bool) (Model, Model, error) {
+	largeModelCfg, ok := c.cfg.AgentModel(agent)
 	if !ok {
-		return Model{}, Model{}, errors.New("large model not selected")
+		return Model{}, Model{}, fmt.Errorf("model %q not selected", cmp.Or(agent.Model, config.SelectedModelTypeLarge))
 	}
 	smallModelCfg, ok := c.cfg.Models[config.SelectedModelTypeSmall]
 	if !ok {
@@ -559,13 +816,59 @@ func (c *coordinator) buildAgentModels(ctx context.Context, isSubAgent bool) (Mo
 			Model:      largeModel,
 			CatwalkCfg: *largeCatwalkModel,
 			ModelCfg:   largeModelCfg,
+			Retry:      largeProviderCfg.Retry,
+			Fallbacks:  c.buildFallbackModels(ctx, largeModelCfg.Fallbacks, isSubAgent),
 		}, Model{
 			Model:      smallModel,
 			CatwalkCfg: *smallCatwalkModel,
 			ModelCfg:   smallModelCfg,
+			Retry:      smallProviderCfg.Retry,
 		}, nil
 }
 
+// buildFallbackModels builds the fallback models of a model, skipping the
+// ones that can't be built.
+func (c *coordinator) buildFallbackModels(ctx context.Context, fallbacks []config.SelectedModel, isSubAgent bool) []Model {
+	var models []Model
+	for _, modelCfg := range fallbacks {
+		providerCfg, ok := c.cfg.Providers.Get(modelCfg.Provider)
+		if !ok {
+			slog.Warn("Skipping fallback model of an unknown provider", "provider", modelCfg.Provider, "model", modelCfg.Model)
+			continue
+		}
+		idx := slices.IndexFunc(providerCfg.Models, func(m catwalk.Model) bool {
+			return m.ID == modelCfg.Model
+		})
+		if idx == -1 {
+			slog.Warn("Skipping unknown fallback model", "provider", modelCfg.Provider, "model", modelCfg.Model)
+			continue
+		}
+		provider, err := c.buildProvider(providerCfg, modelCfg, isSubAgent)
+		if err != nil {
+			slog.Warn("Skipping fallback model", "provider", modelCfg.Provider, "model", modelCfg.Model, "error", err)
+			continue
+		}
+		modelID := modelCfg.Model
+		if modelCfg.Provider == openrouter.Name && isExactoSupported(modelID) {
+			modelID += ":exacto"
+		}
+		model, err := provider.LanguageModel(ctx, modelID)
+		if err != nil {
+			slog.Warn("Skipping fallback model", "provider", modelCfg.Provider, "model", modelCfg.Model, "error", err)
+			continue
+		}
+		fallback := Model{
+			Model:      model,
+			CatwalkCfg: providerCfg.Models[idx],
+			ModelCfg:   modelCfg,
+			Retry:      providerCfg.Retry,
+		}
+		fallback.settings = modelCallSettings(fallback, providerCfg)
+		models = append(models, fallback)
+	}
+	return models
+}
+
 func (c *coordinator) buildAnthropicProvider(baseURL, apiKey string, headers map[string]string) (fantasy.Provider, error) {
 	var opts []anthropic.Option
 
@@ -832,65 +1135,133 @@ func isExactoSupported(modelID string) bool {
 	return slices.Contains(supportedModels, modelID)
 }
 
+// Sessions may still be running or queued on an agent that is no longer the
+// main one, so the methods below look at all agents.
+
 func (c *coordinator) Cancel(sessionID string) {
-	c.currentAgent.Cancel(sessionID)
+	for _, agent := range c.sessionAgents() {
+		agent.Cancel(sessionID)
+	}
 }
 
 func (c *coordinator) CancelAll() {
-	c.currentAgent.CancelAll()
+	for _, agent := range c.sessionAgents() {
+		agent.CancelAll()
+	}
 }
 
 func (c *coordinator) ClearQueue(sessionID string) {
-	c.currentAgent.ClearQueue(sessionID)
+	for _, agent := range c.sessionAgents() {
+		agent.ClearQueue(sessionID)
+	}
 }
 
 func (c *coordinator) IsBusy() bool {
-	return c.currentAgent.IsBusy()
+	return slices.ContainsFunc(c.sessionAgents(), SessionAgent.IsBusy)
 }
 
 func (c *coordinator) IsSessionBusy(sessionID string) bool {
-	return c.currentAgent.IsSessionBusy(sessionID)
+	return slices.ContainsFunc(c.sessionAgents(), func(agent SessionAgent) bool {
+		return agent.IsSessionBusy(sessionID)
+	})
 }
 
 func (c *coordinator) Model() Model {
-	return c.currentAgent.Model()
+	return c.mainAgent().Model()
 }
 
 func (c *coordinator) UpdateModels(ctx context.Context) error {
-	// build the models again so we make sure we get the latest config
-	large, small, err := c.buildAgentModels(ctx, false)
+	c.agentMu.RLock()
+	agent, agentCfg := c.currentAgent, c.currentAgentCfg
+	c.agentMu.RUnlock()
+	return c.updateAgent(ctx, agent, agentCfg, c.cfg.WorkingDir())
+}
+
+// updateAgent builds the models and tools of an agent again so we make sure
+// they match the latest config.
+func (c *coordinator) updateAgent(ctx context.Context, agent SessionAgent, agentCfg config.Agent, workingDir string) error {
+	large, small, err := c.buildAgentModels(ctx, agentCfg, false)
 	if err != nil {
 		return err
 	}
-	c.currentAgent.SetModels(large, small)
-
-	agentCfg, ok := c.cfg.Agents[config.AgentCoder]
-	if !ok {
-		return errors.New("coder agent not configured")
-	}
+	agent.SetModels(large, small)
 
-	tools, err := c.buildTools(ctx, agentCfg)
+	tools, err := c.buildTools(ctx, agentCfg, workingDir)
 	if err != nil {
 		return err
 	}
-	c.currentAgent.SetTools(tools)
+	agent.SetTools(tools)
 	return nil
 }
 
 func (c *coordinator) QueuedPrompts(sessionID string) int {
-	return c.currentAgent.QueuedPrompts(sessionID)
+	var n int
+	for _, agent := range c.sessionAgents() {
+		n += agent.QueuedPrompts(sessionID)
+	}
+	return n
 }
 
 func (c *coordinator) QueuedPromptsList(sessionID string) []string {
-	return c.currentAgent.QueuedPromptsList(sessionID)
+	var prompts []string
+	for _, agent := range c.sessionAgents() {
+		prompts = append(prompts, agent.QueuedPromptsList(sessionID)...)
+	}
+	return prompts
 }
 
 func (c *coordinator) Summarize(ctx context.Context, sessionID string) error {
-	providerCfg, ok := c.cfg.Providers.Get(c.currentAgent.Model().ModelCfg.Provider)
+	agent := c.mainAgent()
+	providerCfg, ok := c.cfg.Providers.Get(agent.Model().ModelCfg.Provider)
 	if !ok {
 		return errors.New("model provider not configured")
 	}
-	return c.currentAgent.Summarize(ctx, sessionID, getProviderOptions(c.currentAgent.Model(), providerCfg))
+	return agent.Summarize(ctx, sessionID, getProviderOptions(agent.Model(), providerCfg))
+}
+
+func (c *coordinator) SetPlanMode(sessionID string, enabled bool) {
+	if enabled {
+		c.planSessions.Set(sessionID, true)
+	} else {
+		c.planSessions.Del(sessionID)
+	}
+}
+
+func (c *coordinator) PlanMode(sessionID string) bool {
+	_, ok := c.planSessions.Get(sessionID)
+	return ok
+}
+
+func (c *coordinator) ApprovePlan(ctx context.Context, sessionID string) (*fantasy.AgentResult, error) {
+	sess, err := c.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(sess.Todos) == 0 {
+		return nil, errors.New("the session has no plan to approve")
+	}
+	c.SetPlanMode(sessionID, false)
+	return c.Run(ctx, sessionID, approvedPlanPrompt(sess.Todos))
+}
+
+func (c *coordinator) BudgetUsage(ctx context.Context, sessionID string) ([]BudgetUsage, error) {
+	sess, err := c.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return budgetUsage(ctx, c.sessions, c.cfg.Options.Budget, sess)
+}
+
+// approvedPlanPrompt is the prompt that has the agent carry out the plan the
+// user approved.
+func approvedPlanPrompt(todos []session.Todo) string {
+	var sb strings.Builder
+	sb.WriteString("I approved your plan. Plan mode is off and all your tools are available again. Carry out the plan now, keeping the todos up to date as you go.\n\n<approved_plan>\n")
+	for i, todo := range todos {
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, todo.Content)
+	}
+	sb.WriteString("</approved_plan>")
+	return sb.String()
 }
 
 func (c *coordinator) isUnauthorized(err error) bool {
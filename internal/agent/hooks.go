@@ -0,0 +1,121 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/hooks"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// hookTool runs the PreToolUse and PostToolUse hooks around a tool.
+type hookTool struct {
+	fantasy.AgentTool
+	hooks *hooks.Runner
+}
+
+func (t hookTool) Run(ctx context.Context, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	in := hooks.Input{
+		SessionID: tools.GetSessionFromContext(ctx),
+		ToolName:  t.Info().Name,
+	}
+	if json.Valid([]byte(call.Input)) {
+		in.ToolInput = json.RawMessage(call.Input)
+	}
+
+	if t.hooks.Has(hooks.PreToolUse) {
+		in.Event = hooks.PreToolUse
+		pre := t.hooks.Run(ctx, in)
+		if pre.Blocked {
+			slog.Debug("Tool call blocked by hook", "tool", in.ToolName, "reason", pre.Reason)
+			return fantasy.NewTextErrorResponse((&hooks.BlockedError{Event: hooks.PreToolUse, Reason: pre.Reason}).Error()), nil
+		}
+		if pre.ToolInput != nil {
+			call.Input = string(pre.ToolInput)
+			in.ToolInput = pre.ToolInput
+		}
+	}
+
+	resp, err := t.AgentTool.Run(ctx, call)
+	if err != nil || !t.hooks.Has(hooks.PostToolUse) {
+		return resp, err
+	}
+
+	in.Event = hooks.PostToolUse
+	in.ToolResponse = &hooks.ToolResponse{Content: resp.Content, IsError: resp.IsError}
+	post := t.hooks.Run(ctx, in)
+	if post.Blocked {
+		resp.IsError = true
+		resp.Content += "\n\n" + (&hooks.BlockedError{Event: hooks.PostToolUse, Reason: post.Reason}).Error()
+	}
+	if post.AdditionalContext != "" {
+		resp.Content += "\n\n<hook_context>\n" + post.AdditionalContext + "\n</hook_context>"
+	}
+	return resp, nil
+}
+
+// runPromptHooks runs the SessionStart hooks for the first prompt of a
+// session and the UserPromptSubmit hooks for every prompt. The context they
+// add is attached to the prompt, so it is kept with the user message.
+func (a *sessionAgent) runPromptHooks(ctx context.Context, call SessionAgentCall) (SessionAgentCall, error) {
+	if a.hooks.Has(hooks.SessionStart) {
+		msgs, err := a.messages.List(ctx, call.SessionID)
+		if err != nil {
+			return call, fmt.Errorf("failed to get session messages: %w", err)
+		}
+		if len(msgs) == 0 {
+			start := a.hooks.Run(ctx, hooks.Input{Event: hooks.SessionStart, SessionID: call.SessionID})
+			call.Attachments = appendHookContext(call.Attachments, hooks.SessionStart, start.AdditionalContext)
+		}
+	}
+
+	submit := a.hooks.Run(ctx, hooks.Input{
+		Event:     hooks.UserPromptSubmit,
+		SessionID: call.SessionID,
+		Prompt:    call.Prompt,
+	})
+	if submit.Blocked {
+		return call, &hooks.BlockedError{Event: hooks.UserPromptSubmit, Reason: submit.Reason}
+	}
+	call.Attachments = appendHookContext(call.Attachments, hooks.UserPromptSubmit, submit.AdditionalContext)
+	call.promptHooksRan = true
+	return call, nil
+}
+
+func appendHookContext(attachments []message.Attachment, event hooks.Event, text string) []message.Attachment {
+	if text == "" {
+		return attachments
+	}
+	return append(attachments, message.Attachment{
+		FilePath: string(event) + " hook",
+		FileName: string(event) + " hook",
+		MimeType: "text/plain",
+		Content:  []byte(text),
+	})
+}
+
+// runStopHooks runs the Stop hooks once the agent is done with call. If a
+// hook blocks, the agent carries on with the reason as the next prompt.
+func (a *sessionAgent) runStopHooks(ctx context.Context, call SessionAgentCall) {
+	stop := a.hooks.Run(ctx, hooks.Input{
+		Event:          hooks.Stop,
+		SessionID:      call.SessionID,
+		StopHookActive: call.stopHookActive,
+	})
+	if !stop.Blocked {
+		return
+	}
+	slog.Debug("Stop blocked by hook, continuing", "session_id", call.SessionID, "reason", stop.Reason)
+	next := call
+	next.Prompt = cmp.Or(stop.Reason, "Continue.")
+	next.Attachments = nil
+	next.promptHooksRan = true
+	next.stopHookActive = true
+	existing, _ := a.messageQueue.Get(call.SessionID)
+	a.messageQueue.Set(call.SessionID, append([]SessionAgentCall{next}, existing...))
+}
@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type permissionDenialsAsToolErrorsKey struct{}
+
+// WithPermissionDenialsAsToolErrors returns a context in which denied
+// permission requests are reported to the model as tool errors instead of
+// ending the run. Non-interactive runs use it, as nobody is around to tell
+// the agent what to do instead.
+func WithPermissionDenialsAsToolErrors(ctx context.Context) context.Context {
+	return context.WithValue(ctx, permissionDenialsAsToolErrorsKey{}, true)
+}
+
+// permissionErrorTool turns the permission errors of a tool into tool
+// errors when the context asks for it.
+type permissionErrorTool struct {
+	fantasy.AgentTool
+}
+
+func (t permissionErrorTool) Run(ctx context.Context, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	resp, err := t.AgentTool.Run(ctx, call)
+	if err == nil || !errors.Is(err, permission.ErrorPermissionDenied) {
+		return resp, err
+	}
+	if asToolError, _ := ctx.Value(permissionDenialsAsToolErrorsKey{}).(bool); !asToolError {
+		return resp, err
+	}
+	slog.Debug("Reporting permission denial as a tool error", "tool", call.Name, "error", err)
+	return fantasy.NewTextErrorResponse("Permission denied: " + err.Error() + ". Do not retry the same call; find another way or explain what you need."), nil
+}
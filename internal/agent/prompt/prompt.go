@@ -24,6 +24,8 @@ type Prompt struct {
 	now        func() time.Time
 	platform   string
 	workingDir string
+	// contextPaths overrides the context paths of the config when set.
+	contextPaths []string
 }
 
 type PromptDat struct {
@@ -64,6 +66,14 @@ func WithWorkingDir(workingDir string) Option {
 	}
 }
 
+// WithContextPaths sets the paths of the context files to include instead of
+// the ones in the config.
+func WithContextPaths(paths []string) Option {
+	return func(p *Prompt) {
+		p.contextPaths = paths
+	}
+}
+
 func NewPrompt(name, promptTemplate string, opts ...Option) (*Prompt, error) {
 	p := &Prompt{
 		name:     name,
@@ -154,7 +164,11 @@ func (p *Prompt) promptData(ctx context.Context, provider, model string, cfg con
 
 	files := map[string][]ContextFile{}
 
-	for _, pth := range cfg.Options.ContextPaths {
+	contextPaths := cfg.Options.ContextPaths
+	if p.contextPaths != nil {
+		contextPaths = p.contextPaths
+	}
+	for _, pth := range contextPaths {
 		expanded := expandPath(pth, cfg)
 		pathKey := strings.ToLower(expanded)
 		if _, ok := files[pathKey]; ok {
@@ -0,0 +1,213 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"charm.land/fantasy"
+)
+
+// retryModel is a language model that retries failed requests according to
+// the retry policy of the provider of the model, and carries on with the
+// fallbacks of the model, in order, when it keeps failing. Once it falls
+// back, it sticks to the fallback.
+//
+// A request is only retried until the response starts streaming, so that
+// no partial response is ever thrown away.
+type retryModel struct {
+	models  []Model
+	current int
+
+	// onRetry is called before waiting to retry a request.
+	onRetry func(attempt int, err *fantasy.ProviderError, delay time.Duration)
+	// onResume is called when a request is sent again after waiting.
+	onResume func()
+	// onFallback is called when the model in use changes.
+	onFallback func(from, to Model, err error)
+}
+
+var _ fantasy.LanguageModel = (*retryModel)(nil)
+
+// callSettings are the settings of the requests sent to a model, which
+// depend on the model and on its provider.
+type callSettings struct {
+	maxOutputTokens  int64
+	providerOptions  fantasy.ProviderOptions
+	temperature      *float64
+	topP             *float64
+	topK             *int64
+	frequencyPenalty *float64
+	presencePenalty  *float64
+}
+
+// apply returns call with the settings of s.
+func (s callSettings) apply(call fantasy.Call) fantasy.Call {
+	call.MaxOutputTokens = &s.maxOutputTokens
+	call.ProviderOptions = s.providerOptions
+	call.Temperature = s.temperature
+	call.TopP = s.topP
+	call.TopK = s.topK
+	call.FrequencyPenalty = s.frequencyPenalty
+	call.PresencePenalty = s.presencePenalty
+	return call
+}
+
+func newRetryModel(model Model) *retryModel {
+	return &retryModel{models: append([]Model{model}, model.Fallbacks...)}
+}
+
+// active returns the model currently in use.
+func (m *retryModel) active() Model {
+	return m.models[m.current]
+}
+
+// Stream implements [fantasy.LanguageModel].
+func (m *retryModel) Stream(ctx context.Context, call fantasy.Call) (fantasy.StreamResponse, error) {
+	for {
+		model := m.active()
+		stream, err := m.stream(ctx, model, m.callFor(call))
+		if err == nil || !m.fallBack(ctx, model, err) {
+			return stream, err
+		}
+	}
+}
+
+// Generate implements [fantasy.LanguageModel].
+func (m *retryModel) Generate(ctx context.Context, call fantasy.Call) (*fantasy.Response, error) {
+	for {
+		model := m.active()
+		call := m.callFor(call)
+		resp, err := retry(ctx, m, model, func() (*fantasy.Response, error) {
+			return model.Model.Generate(ctx, call)
+		})
+		if err == nil || !m.fallBack(ctx, model, err) {
+			return resp, err
+		}
+	}
+}
+
+// GenerateObject implements [fantasy.LanguageModel].
+func (m *retryModel) GenerateObject(ctx context.Context, call fantasy.ObjectCall) (*fantasy.ObjectResponse, error) {
+	return m.active().Model.GenerateObject(ctx, call)
+}
+
+// StreamObject implements [fantasy.LanguageModel].
+func (m *retryModel) StreamObject(ctx context.Context, call fantasy.ObjectCall) (fantasy.ObjectStreamResponse, error) {
+	return m.active().Model.StreamObject(ctx, call)
+}
+
+// Provider implements [fantasy.LanguageModel].
+func (m *retryModel) Provider() string {
+	return m.active().Model.Provider()
+}
+
+// Model implements [fantasy.LanguageModel].
+func (m *retryModel) Model() string {
+	return m.active().Model.Model()
+}
+
+// callFor returns call with the settings of the model in use. Calls come
+// with the settings of the primary model, which don't suit its fallbacks.
+func (m *retryModel) callFor(call fantasy.Call) fantasy.Call {
+	if m.current == 0 {
+		return call
+	}
+	return m.active().settings.apply(call)
+}
+
+// stream starts streaming a response from model, retrying until the first
+// part of the response comes in.
+func (m *retryModel) stream(ctx context.Context, model Model, call fantasy.Call) (fantasy.StreamResponse, error) {
+	return retry(ctx, m, model, func() (fantasy.StreamResponse, error) {
+		stream, err := model.Model.Stream(ctx, call)
+		if err != nil {
+			return nil, err
+		}
+		next, stop := iter.Pull(stream)
+		first, ok := next()
+		if ok && first.Type == fantasy.StreamPartTypeError {
+			stop()
+			return nil, first.Error
+		}
+		return func(yield func(fantasy.StreamPart) bool) {
+			defer stop()
+			for part := first; ok; part, ok = next() {
+				if !yield(part) {
+					return
+				}
+			}
+		}, nil
+	})
+}
+
+// fallBack switches to the next model after model failed with err, and
+// reports whether there is one to switch to.
+func (m *retryModel) fallBack(ctx context.Context, model Model, err error) bool {
+	if ctx.Err() != nil || m.current == len(m.models)-1 {
+		return false
+	}
+	var providerErr *fantasy.ProviderError
+	if !errors.As(err, &providerErr) {
+		return false
+	}
+	// Errors in the request itself, like bad credentials, would fail the
+	// same way on the fallback.
+	if !model.Retry.Retryable(providerErr.StatusCode) && providerErr.StatusCode < 500 {
+		return false
+	}
+	m.current++
+	slog.Warn("Falling back to the next model", "from", model.ModelCfg.Model, "to", m.active().ModelCfg.Model, "error", err)
+	if m.onFallback != nil {
+		m.onFallback(model, m.active(), err)
+	}
+	return true
+}
+
+// retry calls fn until it succeeds, or fails with an error the retry policy
+// of model doesn't retry, or runs out of attempts.
+func retry[T any](ctx context.Context, m *retryModel, model Model, fn func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil {
+			return result, nil
+		}
+		var providerErr *fantasy.ProviderError
+		if attempt >= model.Retry.Attempts() || ctx.Err() != nil ||
+			!errors.As(err, &providerErr) || !model.Retry.Retryable(providerErr.StatusCode) {
+			return result, err
+		}
+
+		delay := model.Retry.Delay(attempt, retryAfter(providerErr))
+		slog.Debug("Retrying failed request", "model", model.ModelCfg.Model, "attempt", attempt, "delay", delay, "error", err)
+		if m.onRetry != nil {
+			m.onRetry(attempt, providerErr, delay)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+		if m.onResume != nil {
+			m.onResume()
+		}
+	}
+}
+
+// retryAfter returns the delay the provider asked for before retrying, if
+// any.
+func retryAfter(err *fantasy.ProviderError) time.Duration {
+	if ms, perr := strconv.ParseFloat(err.ResponseHeaders["retry-after-ms"], 64); perr == nil {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	if s, perr := strconv.ParseFloat(err.ResponseHeaders["retry-after"], 64); perr == nil {
+		return time.Duration(s * float64(time.Second))
+	}
+	if t, perr := time.Parse(time.RFC1123, err.ResponseHeaders["retry-after"]); perr == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// failingModel is a language model that fails with the given errors, one
+// per request, before it streams a response.
+type failingModel struct {
+	fantasy.LanguageModel
+	name     string
+	errs     []error
+	requests int
+	// call is the last call the model got.
+	call fantasy.Call
+}
+
+func (m *failingModel) Stream(_ context.Context, call fantasy.Call) (fantasy.StreamResponse, error) {
+	m.requests++
+	m.call = call
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return func(yield func(fantasy.StreamPart) bool) {
+			yield(fantasy.StreamPart{Type: fantasy.StreamPartTypeError, Error: err})
+		}, nil
+	}
+	return func(yield func(fantasy.StreamPart) bool) {
+		_ = yield(fantasy.StreamPart{Type: fantasy.StreamPartTypeTextDelta, Delta: m.name}) &&
+			yield(fantasy.StreamPart{Type: fantasy.StreamPartTypeFinish})
+	}, nil
+}
+
+func (m *failingModel) Model() string {
+	return m.name
+}
+
+func streamText(t *testing.T, stream fantasy.StreamResponse) string {
+	t.Helper()
+	var text string
+	for part := range stream {
+		text += part.Delta
+	}
+	return text
+}
+
+func TestRetryModel(t *testing.T) {
+	t.Parallel()
+
+	delay := 1
+	policy := config.RetryPolicy{InitialDelay: &delay}
+	overloaded := &fantasy.ProviderError{Title: "overloaded", StatusCode: 529}
+	unauthorized := &fantasy.ProviderError{Title: "unauthorized", StatusCode: 401}
+
+	t.Run("retries", func(t *testing.T) {
+		t.Parallel()
+
+		primary := &failingModel{name: "primary", errs: []error{overloaded, overloaded}}
+		model := newRetryModel(Model{Model: primary, Retry: policy})
+		var attempts []int
+		model.onRetry = func(attempt int, err *fantasy.ProviderError, _ time.Duration) {
+			require.Equal(t, overloaded, err)
+			attempts = append(attempts, attempt)
+		}
+
+		stream, err := model.Stream(t.Context(), fantasy.Call{})
+		require.NoError(t, err)
+		require.Equal(t, "primary", streamText(t, stream))
+		require.Equal(t, []int{1, 2}, attempts)
+		require.Equal(t, 3, primary.requests)
+	})
+
+	t.Run("falls back", func(t *testing.T) {
+		t.Parallel()
+
+		primary := &failingModel{name: "primary", errs: []error{overloaded, overloaded, overloaded}}
+		fallback := &failingModel{name: "fallback"}
+		model := newRetryModel(Model{
+			Model:     primary,
+			Retry:     policy,
+			Fallbacks: []Model{{Model: fallback, Retry: policy, settings: callSettings{maxOutputTokens: 1000}}},
+		})
+		var to Model
+		model.onFallback = func(_, m Model, err error) {
+			require.ErrorIs(t, err, overloaded)
+			to = m
+		}
+
+		maxTokens := int64(64000)
+		stream, err := model.Stream(t.Context(), fantasy.Call{MaxOutputTokens: &maxTokens})
+		require.NoError(t, err)
+		require.Equal(t, "fallback", streamText(t, stream))
+		require.Equal(t, fallback, to.Model)
+		require.Equal(t, "fallback", model.Model())
+
+		// The fallback gets its own settings rather than the primary's.
+		require.Equal(t, int64(64000), *primary.call.MaxOutputTokens)
+		require.Equal(t, int64(1000), *fallback.call.MaxOutputTokens)
+
+		// It sticks to the fallback.
+		stream, err = model.Stream(t.Context(), fantasy.Call{})
+		require.NoError(t, err)
+		require.Equal(t, "fallback", streamText(t, stream))
+		require.Equal(t, 3, primary.requests)
+		require.Equal(t, 2, fallback.requests)
+	})
+
+	t.Run("client errors", func(t *testing.T) {
+		t.Parallel()
+
+		primary := &failingModel{name: "primary", errs: []error{unauthorized}}
+		fallback := &failingModel{name: "fallback"}
+		model := newRetryModel(Model{
+			Model:     primary,
+			Retry:     policy,
+			Fallbacks: []Model{{Model: fallback, Retry: policy}},
+		})
+
+		_, err := model.Stream(t.Context(), fantasy.Call{})
+		require.ErrorIs(t, err, unauthorized)
+		require.Equal(t, 1, primary.requests)
+		require.Zero(t, fallback.requests)
+	})
+}
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// samplingSessionID returns the ID the sampling costs of an MCP server are
+// recorded under. No session has it: recorded costs don't need one, as they
+// outlive their sessions anyway, and counting them apart from the sessions
+// of the user gives the server a per-session budget of its own.
+func samplingSessionID(name string) string {
+	return "mcp:" + name
+}
+
+// sample answers the sampling requests of MCP servers with the small
+// model. What it costs counts against the daily budget, under a session of
+// the server's own.
+func (c *coordinator) sample(ctx context.Context, name string, call fantasy.Call) (*fantasy.Response, string, error) {
+	sessionID := samplingSessionID(name)
+	usage, err := budgetUsage(ctx, c.sessions, c.cfg.Options.Budget, session.Session{ID: sessionID})
+	if err != nil {
+		return nil, "", err
+	}
+	for _, u := range usage {
+		if u.Exceeded() {
+			return nil, "", &BudgetError{u}
+		}
+	}
+
+	_, small, err := c.buildAgentModels(ctx, config.Agent{}, true)
+	if err != nil {
+		return nil, "", fmt.Errorf("error building models: %w", err)
+	}
+	providerCfg, ok := c.cfg.Providers.Get(small.ModelCfg.Provider)
+	if !ok {
+		return nil, "", errors.New("small model provider not configured")
+	}
+
+	if providerCfg.SystemPromptPrefix != "" {
+		call.Prompt = append(fantasy.Prompt{fantasy.NewSystemMessage(providerCfg.SystemPromptPrefix)}, call.Prompt...)
+	}
+	maxTokens := small.CatwalkCfg.DefaultMaxTokens
+	if small.ModelCfg.MaxTokens != 0 {
+		maxTokens = small.ModelCfg.MaxTokens
+	}
+	if maxTokens > 0 && (call.MaxOutputTokens == nil || *call.MaxOutputTokens > maxTokens) {
+		call.MaxOutputTokens = &maxTokens
+	}
+	if call.Temperature == nil {
+		call.Temperature = small.ModelCfg.Temperature
+	}
+	call.ProviderOptions = getProviderOptions(small, providerCfg)
+
+	resp, err := small.Model.Generate(ctx, call)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cost := modelCost(small.CatwalkCfg, resp.Usage)
+	if run := runBudgetFrom(ctx); run != nil {
+		run.add(cost)
+	}
+	if err := c.sessions.RecordCost(ctx, sessionID, cost); err != nil {
+		slog.Error("Failed to record sampling cost", "name", name, "error", err)
+	}
+	return resp, small.ModelCfg.Model, nil
+}
@@ -1,9 +1,16 @@
-Launch a new agent that has access to the following tools: GlobTool, GrepTool, LS, View. When you are searching for a keyword or file and are not confident that you will find the right match on the first try, use the Agent tool to perform the search for you.
+Launch a new agent to perform a task autonomously. Each subagent type has its own instructions, model and tools; pick it with `subagent_type`, which defaults to `task`. When you are searching for a keyword or file and are not confident that you will find the right match on the first try, use the Agent tool to perform the search for you.
+
+<subagent_types>
+{{- range .Subagents}}
+- {{.ID}}: {{if .Description}}{{.Description}} {{end}}(tools: {{if .AllowedTools}}{{join .AllowedTools ", "}}{{else}}none{{end}})
+{{- end}}
+</subagent_types>
 
 <usage>
 - If you are searching for a keyword like "config" or "logger", or for questions like "which file does X?", the Agent tool is strongly recommended
 - If you want to read a specific file path, use the View or GlobTool tool instead of the Agent tool, to find the match more quickly
 - If you are searching for a specific class definition like "class Foo", use the GlobTool tool instead, to find the match more quickly
+- If a task matches the description of a subagent type, delegate it to that type
 </usage>
 
 <usage_notes>
@@ -11,5 +18,5 @@ Launch a new agent that has access to the following tools: GlobTool, GrepTool, L
 2. When the agent is done, it will return a single message back to you. The result returned by the agent is not visible to the user. To show the user the result, you should send a text message back to the user with a concise summary of the result.
 3. Each agent invocation is stateless. You will not be able to send additional messages to the agent, nor will the agent be able to communicate with you outside of its final report. Therefore, your prompt should contain a highly detailed task description for the agent to perform autonomously and you should specify exactly what information the agent should return back to you in its final and only message to you.
 4. The agent's outputs should generally be trusted
-5. IMPORTANT: The agent can not use Bash, Replace, Edit, so can not modify files. If you want to use these tools, use them directly instead of going through the agent.
+5. IMPORTANT: An agent can only use the tools listed for its type. If you need a tool its type lacks, such as Bash or Edit for the task type, use it directly instead of going through the agent.
 </usage_notes>
@@ -214,18 +214,22 @@ func NewBashTool(permissions permission.Service, workingDir string, attribution
 			if sessionID == "" {
 				return fantasy.ToolResponse{}, fmt.Errorf("session ID is required for executing shell command")
 			}
-			if !isSafeReadOnly {
-				p, err := permissions.Request(ctx,
-					permission.CreatePermissionRequest{
-						SessionID:   sessionID,
-						Path:        execWorkingDir,
-						ToolCallID:  call.ID,
-						ToolName:    BashToolName,
-						Action:      "execute",
-						Description: fmt.Sprintf("Execute command: %s", params.Command),
-						Params:      BashPermissionsParams(params),
-					},
-				)
+			permissionRequest := permission.CreatePermissionRequest{
+				SessionID:   sessionID,
+				Path:        execWorkingDir,
+				ToolCallID:  call.ID,
+				ToolName:    BashToolName,
+				Action:      "execute",
+				Description: fmt.Sprintf("Execute command: %s", params.Command),
+				Params:      BashPermissionsParams(params),
+			}
+			if isSafeReadOnly {
+				// Safe commands don't prompt, but deny rules still apply.
+				if err := permissions.CheckRules(permissionRequest); err != nil {
+					return fantasy.ToolResponse{}, err
+				}
+			} else {
+				p, err := permissions.Request(ctx, permissionRequest)
 				if err != nil {
 					return fantasy.ToolResponse{}, err
 				}
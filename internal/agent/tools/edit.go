@@ -160,8 +160,8 @@ func createNewFile(edit editContext, filePath, content string, call fantasy.Tool
 		return fantasy.ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
 	}
 
-	// File can't be in the history so we create a new file history
-	_, err = edit.files.Create(edit.ctx, sessionID, filePath, "")
+	// Record that the file is new, so rewinding deletes it
+	_, err = edit.files.CreateNew(edit.ctx, sessionID, filePath)
 	if err != nil {
 		// Log error but don't fail the operation
 		return fantasy.ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
@@ -291,9 +291,10 @@ func deleteContent(edit editContext, filePath, oldString string, replaceAll bool
 			// Log error but don't fail the operation
 			return fantasy.ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
 		}
-	}
-	if file.Content != oldContent {
-		// User manually changed the content; store an intermediate version
+	} else if file.Content != oldContent || file.MessageID != GetMessageFromContext(edit.ctx) {
+		// User manually changed the content, or this is the first change
+		// made from this message; store an intermediate version so the
+		// message can be rewound.
 		_, err = edit.files.CreateVersion(edit.ctx, sessionID, filePath, oldContent)
 		if err != nil {
 			slog.Error("Error creating file history version", "error", err)
@@ -422,9 +423,10 @@ func replaceContent(edit editContext, filePath, oldString, newString string, rep
 			// Log error but don't fail the operation
 			return fantasy.ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
 		}
-	}
-	if file.Content != oldContent {
-		// User manually changed the content; store an intermediate version
+	} else if file.Content != oldContent || file.MessageID != GetMessageFromContext(edit.ctx) {
+		// User manually changed the content, or this is the first change
+		// made from this message; store an intermediate version so the
+		// message can be rewound.
 		_, err = edit.files.CreateVersion(edit.ctx, sessionID, filePath, oldContent)
 		if err != nil {
 			slog.Debug("Error creating file history version", "error", err)
@@ -3,10 +3,13 @@ package tools
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
 	"charm.land/fantasy"
 	"github.com/charmbracelet/crush/internal/agent/tools/mcp"
 	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 )
 
@@ -27,6 +30,12 @@ func GetMCPTools(permissions permission.Service, cfg *config.Config, wd string)
 	return result
 }
 
+// MCPResponseMetadata carries the parts of the results of MCP tools made of
+// more than text to the agent, which moves them to the tool result.
+type MCPResponseMetadata struct {
+	Parts []message.ToolResultPart `json:"parts"`
+}
+
 // Tool is a tool from a MCP.
 type Tool struct {
 	mcpName         string
@@ -115,22 +124,27 @@ func (m *Tool) Run(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolRe
 		return fantasy.NewTextErrorResponse(err.Error()), nil
 	}
 
-	switch result.Type {
-	case "image", "media":
+	text := message.ToolResultText(result.Parts)
+	var response fantasy.ToolResponse
+	if i := slices.IndexFunc(result.Parts, message.ToolResultPart.IsMedia); i >= 0 {
 		if !GetSupportsImagesFromContext(ctx) {
 			modelName := GetModelNameFromContext(ctx)
 			return fantasy.NewTextErrorResponse(fmt.Sprintf("This model (%s) does not support image data.", modelName)), nil
 		}
 
-		var response fantasy.ToolResponse
-		if result.Type == "image" {
-			response = fantasy.NewImageResponse(result.Data, result.MediaType)
+		media := result.Parts[i]
+		if strings.HasPrefix(media.MIMEType, "image/") {
+			response = fantasy.NewImageResponse([]byte(media.Data), media.MIMEType)
 		} else {
-			response = fantasy.NewMediaResponse(result.Data, result.MediaType)
+			response = fantasy.NewMediaResponse([]byte(media.Data), media.MIMEType)
 		}
-		response.Content = result.Content
+		response.Content = text
+	} else {
+		response = fantasy.NewTextResponse(text)
+	}
+
+	if len(result.Parts) == 1 && result.Parts[0].Type == message.ToolResultPartText {
 		return response, nil
-	default:
-		return fantasy.NewTextResponse(result.Content), nil
 	}
+	return fantasy.WithResponseMetadata(response, MCPResponseMetadata{Parts: result.Parts}), nil
 }
@@ -0,0 +1,220 @@
+package mcp
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/google/uuid"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ElicitationField is a field of a form an MCP server asks the user to fill
+// in.
+type ElicitationField struct {
+	Name        string
+	Title       string
+	Description string
+	// Type is string, number, integer or boolean.
+	Type     string
+	Enum     []string
+	Required bool
+}
+
+// Hint describes the values the field takes, if they aren't free text.
+func (f ElicitationField) Hint() string {
+	switch {
+	case len(f.Enum) > 0:
+		return "one of " + strings.Join(f.Enum, ", ")
+	case f.Type == "boolean":
+		return "yes or no"
+	case f.Type == "number" || f.Type == "integer":
+		return "a number"
+	default:
+		return ""
+	}
+}
+
+func (f ElicitationField) parse(value string) (any, error) {
+	label := cmp.Or(f.Title, f.Name)
+	if len(f.Enum) > 0 && !slices.Contains(f.Enum, value) {
+		return nil, fmt.Errorf("%s must be one of %s", label, strings.Join(f.Enum, ", "))
+	}
+	switch f.Type {
+	case "number":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", label)
+		}
+		return v, nil
+	case "integer":
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a whole number", label)
+		}
+		return v, nil
+	case "boolean":
+		switch strings.ToLower(value) {
+		case "y", "yes", "true":
+			return true, nil
+		case "n", "no", "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%s must be yes or no", label)
+	default:
+		return value, nil
+	}
+}
+
+// ElicitationRequest is a form an MCP server asks the user to fill in.
+type ElicitationRequest struct {
+	ID string
+	// Name is the name of the MCP server.
+	Name    string
+	Message string
+	Fields  []ElicitationField
+}
+
+type pendingElicitation struct {
+	fields []ElicitationField
+	result chan *mcp.ElicitResult
+}
+
+var (
+	elicitations        = pubsub.NewBroker[ElicitationRequest]()
+	pendingElicitations = csync.NewMap[string, pendingElicitation]()
+)
+
+// ErrElicitationDone is returned when answering an elicitation request the
+// server no longer waits for.
+var ErrElicitationDone = errors.New("the MCP server no longer waits for an answer")
+
+// errNoElicitation is returned to the servers that ask for input when
+// nobody is around to give it, e.g. in non-interactive runs.
+var errNoElicitation = errors.New("crush can't ask the user for input in non-interactive mode")
+
+// SubscribeElicitations returns a channel for the forms MCP servers ask the
+// user to fill in. Servers can only ask while someone is subscribed.
+func SubscribeElicitations(ctx context.Context) <-chan pubsub.Event[ElicitationRequest] {
+	return elicitations.Subscribe(ctx)
+}
+
+// AcceptElicitation answers an elicitation request with the values the user
+// filled in, converted to the types of the form. It fails without answering
+// if a value is missing or invalid.
+func AcceptElicitation(id string, values map[string]string) error {
+	pending, ok := pendingElicitations.Get(id)
+	if !ok {
+		return ErrElicitationDone
+	}
+	content := map[string]any{}
+	for _, f := range pending.fields {
+		value := strings.TrimSpace(values[f.Name])
+		if value == "" {
+			if f.Required {
+				return fmt.Errorf("%s is required", cmp.Or(f.Title, f.Name))
+			}
+			continue
+		}
+		v, err := f.parse(value)
+		if err != nil {
+			return err
+		}
+		content[f.Name] = v
+	}
+	respondElicitation(pending, &mcp.ElicitResult{Action: "accept", Content: content})
+	return nil
+}
+
+// CancelElicitation answers an elicitation request the user dismissed.
+func CancelElicitation(id string) {
+	if pending, ok := pendingElicitations.Get(id); ok {
+		respondElicitation(pending, &mcp.ElicitResult{Action: "cancel"})
+	}
+}
+
+func respondElicitation(pending pendingElicitation, result *mcp.ElicitResult) {
+	select {
+	case pending.result <- result:
+	default:
+	}
+}
+
+// elicit asks the user to fill in the form of an MCP server, and waits for
+// the answer.
+func elicit(ctx context.Context, name string, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+	if elicitations.GetSubscriberCount() == 0 {
+		return nil, errNoElicitation
+	}
+	if req.Params.Mode == "url" {
+		return nil, errors.New("url elicitation is not supported")
+	}
+	fields, err := elicitationFields(req.Params.RequestedSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.NewString()
+	pending := pendingElicitation{
+		fields: fields,
+		result: make(chan *mcp.ElicitResult, 1),
+	}
+	pendingElicitations.Set(id, pending)
+	defer pendingElicitations.Del(id)
+
+	elicitations.Publish(pubsub.CreatedEvent, ElicitationRequest{
+		ID:      id,
+		Name:    name,
+		Message: req.Params.Message,
+		Fields:  fields,
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-pending.result:
+		return result, nil
+	}
+}
+
+// elicitationFields returns the fields of the requested schema of an
+// elicitation request: an object with properties of primitive types.
+func elicitationFields(schema any) ([]ElicitationField, error) {
+	var s struct {
+		Properties map[string]struct {
+			Type        string   `json:"type"`
+			Title       string   `json:"title"`
+			Description string   `json:"description"`
+			Enum        []string `json:"enum"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid requested schema: %w", err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid requested schema: %w", err)
+	}
+
+	var fields []ElicitationField
+	for _, name := range slices.Sorted(maps.Keys(s.Properties)) {
+		p := s.Properties[name]
+		fields = append(fields, ElicitationField{
+			Name:        name,
+			Title:       p.Title,
+			Description: p.Description,
+			Type:        p.Type,
+			Enum:        p.Enum,
+			Required:    slices.Contains(s.Required, name),
+		})
+	}
+	return fields, nil
+}
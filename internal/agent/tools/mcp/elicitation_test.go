@@ -0,0 +1,59 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElicit(t *testing.T) {
+	t.Parallel()
+
+	req := &mcp.ElicitRequest{Params: &mcp.ElicitParams{
+		Message: "Where to deploy?",
+		RequestedSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"env":     map[string]any{"type": "string", "enum": []string{"staging", "production"}},
+				"replica": map[string]any{"type": "integer"},
+				"notify":  map[string]any{"type": "boolean"},
+			},
+			"required": []string{"env"},
+		},
+	}}
+
+	// Nobody can answer without the TUI.
+	_, err := elicit(t.Context(), "deploy", req)
+	require.ErrorIs(t, err, errNoElicitation)
+
+	events := SubscribeElicitations(t.Context())
+	type answer struct {
+		result *mcp.ElicitResult
+		err    error
+	}
+	answers := make(chan answer, 1)
+	go func() {
+		result, err := elicit(t.Context(), "deploy", req)
+		answers <- answer{result, err}
+	}()
+
+	event := <-events
+	require.Equal(t, "deploy", event.Payload.Name)
+	require.Equal(t, "Where to deploy?", event.Payload.Message)
+	require.Len(t, event.Payload.Fields, 3)
+	require.Equal(t, "env", event.Payload.Fields[0].Name)
+	require.True(t, event.Payload.Fields[0].Required)
+
+	id := event.Payload.ID
+	require.Error(t, AcceptElicitation(id, map[string]string{"replica": "2"}))
+	require.Error(t, AcceptElicitation(id, map[string]string{"env": "dev"}))
+	require.Error(t, AcceptElicitation(id, map[string]string{"env": "staging", "replica": "two"}))
+	require.NoError(t, AcceptElicitation(id, map[string]string{"env": "staging", "replica": "2", "notify": "yes"}))
+
+	a := <-answers
+	require.NoError(t, a.err)
+	require.Equal(t, "accept", a.result.Action)
+	require.Equal(t, map[string]any{"env": "staging", "replica": int64(2), "notify": true}, a.result.Content)
+	require.ErrorIs(t, AcceptElicitation(id, nil), ErrElicitationDone)
+}
@@ -10,8 +10,10 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -68,6 +70,7 @@ const (
 	StateStarting
 	StateConnected
 	StateError
+	StateNeedsAuth
 )
 
 func (s State) String() string {
@@ -80,6 +83,8 @@ func (s State) String() string {
 		return "connected"
 	case StateError:
 		return "error"
+	case StateNeedsAuth:
+		return "needs auth"
 	default:
 		return "unknown"
 	}
@@ -159,6 +164,7 @@ func Close(ctx context.Context) error {
 	}
 	wg.Wait()
 	broker.Shutdown()
+	elicitations.Shutdown()
 	return nil
 }
 
@@ -167,7 +173,7 @@ func Initialize(ctx context.Context, permissions permission.Service, cfg *config
 	slog.Info("Initializing MCP clients")
 	var wg sync.WaitGroup
 	// Initialize states for all configured MCPs
-	for name, m := range cfg.MCP {
+	for name, m := range Configs(cfg) {
 		if m.Disabled {
 			updateState(name, StateDisabled, nil, nil, Counts{})
 			slog.Debug("Skipping disabled MCP", "name", name)
@@ -177,69 +183,78 @@ func Initialize(ctx context.Context, permissions permission.Service, cfg *config
 		// Set initial starting state
 		updateState(name, StateStarting, nil, nil, Counts{})
 
-		wg.Add(1)
-		go func(name string, m config.MCPConfig) {
-			defer func() {
-				wg.Done()
-				if r := recover(); r != nil {
-					var err error
-					switch v := r.(type) {
-					case error:
-						err = v
-					case string:
-						err = fmt.Errorf("panic: %s", v)
-					default:
-						err = fmt.Errorf("panic: %v", v)
-					}
-					updateState(name, StateError, err, nil, Counts{})
-					slog.Error("Panic in MCP client initialization", "error", err, "name", name)
-				}
-			}()
+		wg.Go(func() {
+			startClient(ctx, cfg, name, m)
+		})
+	}
+	wg.Wait()
+	initOnce.Do(func() { close(initDone) })
+}
 
-			// createSession handles its own timeout internally.
-			session, err := createSession(ctx, name, m, cfg.Resolver())
-			if err != nil {
-				return
+// startClient runs initClient, turning panics into errors of the client.
+func startClient(ctx context.Context, cfg *config.Config, name string, m config.MCPConfig) {
+	defer func() {
+		if r := recover(); r != nil {
+			var err error
+			switch v := r.(type) {
+			case error:
+				err = v
+			case string:
+				err = fmt.Errorf("panic: %s", v)
+			default:
+				err = fmt.Errorf("panic: %v", v)
 			}
+			updateState(name, StateError, err, nil, Counts{})
+			slog.Error("Panic in MCP client initialization", "error", err, "name", name)
+		}
+	}()
 
-			tools, err := getTools(ctx, session)
-			if err != nil {
-				slog.Error("Error listing tools", "error", err)
-				updateState(name, StateError, err, nil, Counts{})
-				session.Close()
-				return
-			}
+	initClient(ctx, cfg, name, m)
+}
 
-			prompts, err := getPrompts(ctx, session)
-			if err != nil {
-				slog.Error("Error listing prompts", "error", err)
-				updateState(name, StateError, err, nil, Counts{})
-				session.Close()
-				return
-			}
+// initClient connects to an MCP server and loads its tools, prompts and
+// resources.
+func initClient(ctx context.Context, cfg *config.Config, name string, m config.MCPConfig) {
+	// createSession handles its own timeout internally.
+	session, err := createSession(ctx, cfg, name, m)
+	if err != nil {
+		return
+	}
 
-			resources, err := getResources(ctx, session)
-			if err != nil {
-				slog.Error("Error listing resources", "error", err)
-				updateState(name, StateError, err, nil, Counts{})
-				session.Close()
-				return
-			}
+	tools, err := getTools(ctx, session)
+	if err != nil {
+		slog.Error("Error listing tools", "error", err)
+		updateState(name, StateError, err, nil, Counts{})
+		session.Close()
+		return
+	}
 
-			toolCount := updateTools(cfg, name, tools)
-			updatePrompts(name, prompts)
-			resourceCount := updateResources(name, resources)
-			sessions.Set(name, session)
+	prompts, err := getPrompts(ctx, session)
+	if err != nil {
+		slog.Error("Error listing prompts", "error", err)
+		updateState(name, StateError, err, nil, Counts{})
+		session.Close()
+		return
+	}
 
-			updateState(name, StateConnected, nil, session, Counts{
-				Tools:     toolCount,
-				Prompts:   len(prompts),
-				Resources: resourceCount,
-			})
-		}(name, m)
+	resources, err := getResources(ctx, session)
+	if err != nil {
+		slog.Error("Error listing resources", "error", err)
+		updateState(name, StateError, err, nil, Counts{})
+		session.Close()
+		return
 	}
-	wg.Wait()
-	initOnce.Do(func() { close(initDone) })
+
+	toolCount := updateTools(cfg, name, tools)
+	updatePrompts(name, prompts)
+	resourceCount := updateResources(name, resources)
+	sessions.Set(name, session)
+
+	updateState(name, StateConnected, nil, session, Counts{
+		Tools:     toolCount,
+		Prompts:   len(prompts),
+		Resources: resourceCount,
+	})
 }
 
 // WaitForInit blocks until MCP initialization is complete.
@@ -259,7 +274,7 @@ func getOrRenewClient(ctx context.Context, cfg *config.Config, name string) (*Cl
 		return nil, fmt.Errorf("mcp '%s' not available", name)
 	}
 
-	m := cfg.MCP[name]
+	m := Configs(cfg)[name]
 	state, _ := states.Get(name)
 
 	timeout := mcpTimeout(m)
@@ -271,7 +286,7 @@ func getOrRenewClient(ctx context.Context, cfg *config.Config, name string) (*Cl
 	}
 	updateState(name, StateError, maybeTimeoutErr(err, timeout), nil, state.Counts)
 
-	sess, err = createSession(ctx, name, m, cfg.Resolver())
+	sess, err = createSession(ctx, cfg, name, m)
 	if err != nil {
 		return nil, err
 	}
@@ -293,7 +308,7 @@ func updateState(name string, state State, err error, client *ClientSession, cou
 	switch state {
 	case StateConnected:
 		info.ConnectedAt = time.Now()
-	case StateError:
+	case StateError, StateNeedsAuth:
 		sessions.Del(name)
 	}
 	states.Set(name, info)
@@ -308,12 +323,12 @@ func updateState(name string, state State, err error, client *ClientSession, cou
 	})
 }
 
-func createSession(ctx context.Context, name string, m config.MCPConfig, resolver config.VariableResolver) (*ClientSession, error) {
+func createSession(ctx context.Context, cfg *config.Config, name string, m config.MCPConfig) (*ClientSession, error) {
 	timeout := mcpTimeout(m)
 	mcpCtx, cancel := context.WithCancel(ctx)
 	cancelTimer := time.AfterFunc(timeout, cancel)
 
-	transport, err := createTransport(mcpCtx, m, resolver)
+	transport, err := createTransport(mcpCtx, m, cfg.Resolver())
 	if err != nil {
 		updateState(name, StateError, err, nil, Counts{})
 		slog.Error("Error creating MCP client", "error", err, "name", name)
@@ -322,39 +337,59 @@ func createSession(ctx context.Context, name string, m config.MCPConfig, resolve
 		return nil, err
 	}
 
-	client := mcp.NewClient(
-		&mcp.Implementation{
-			Name:    "crush",
-			Version: version.Version,
-			Title:   "Crush",
+	opts := &mcp.ClientOptions{
+		ToolListChangedHandler: func(context.Context, *mcp.ToolListChangedRequest) {
+			broker.Publish(pubsub.UpdatedEvent, Event{
+				Type: EventToolsListChanged,
+				Name: name,
+			})
+		},
+		PromptListChangedHandler: func(context.Context, *mcp.PromptListChangedRequest) {
+			broker.Publish(pubsub.UpdatedEvent, Event{
+				Type: EventPromptsListChanged,
+				Name: name,
+			})
+		},
+		ResourceListChangedHandler: func(context.Context, *mcp.ResourceListChangedRequest) {
+			broker.Publish(pubsub.UpdatedEvent, Event{
+				Type: EventResourcesListChanged,
+				Name: name,
+			})
+		},
+		LoggingMessageHandler: func(ctx context.Context, req *mcp.LoggingMessageRequest) {
+			level := parseLevel(req.Params.Level)
+			slog.Log(ctx, level, "MCP log", "name", name, "logger", req.Params.Logger, "data", req.Params.Data)
 		},
-		&mcp.ClientOptions{
-			ToolListChangedHandler: func(context.Context, *mcp.ToolListChangedRequest) {
-				broker.Publish(pubsub.UpdatedEvent, Event{
-					Type: EventToolsListChanged,
-					Name: name,
-				})
-			},
-			PromptListChangedHandler: func(context.Context, *mcp.PromptListChangedRequest) {
-				broker.Publish(pubsub.UpdatedEvent, Event{
-					Type: EventPromptsListChanged,
-					Name: name,
-				})
-			},
-			ResourceListChangedHandler: func(context.Context, *mcp.ResourceListChangedRequest) {
-				broker.Publish(pubsub.UpdatedEvent, Event{
-					Type: EventResourcesListChanged,
-					Name: name,
-				})
-			},
-			LoggingMessageHandler: func(ctx context.Context, req *mcp.LoggingMessageRequest) {
-				level := parseLevel(req.Params.Level)
-				slog.Log(ctx, level, "MCP log", "name", name, "logger", req.Params.Logger, "data", req.Params.Data)
-			},
+		ElicitationHandler: func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			return elicit(ctx, name, req)
 		},
-	)
+	}
+	// Servers only get to sample, and spend tokens, when allowed to.
+	if m.Sampling {
+		opts.CreateMessageHandler = func(ctx context.Context, req *mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+			return createMessage(ctx, name, req)
+		}
+	}
+	client := mcp.NewClient(&mcp.Implementation{
+		Name:    "crush",
+		Version: version.Version,
+		Title:   "Crush",
+	}, opts)
+	// Servers get to work in the working directory.
+	client.AddRoots(&mcp.Root{
+		URI:  (&url.URL{Scheme: "file", Path: filepath.ToSlash(cfg.WorkingDir())}).String(),
+		Name: filepath.Base(cfg.WorkingDir()),
+	})
 
 	session, err := client.Connect(mcpCtx, transport, nil)
+	if challenge, ok := needsAuth(transport); err != nil && ok {
+		challenges.Set(name, challenge)
+		updateState(name, StateNeedsAuth, ErrNeedsAuth, nil, Counts{})
+		slog.Warn("MCP server requires authorization", "name", name)
+		cancel()
+		cancelTimer.Stop()
+		return nil, ErrNeedsAuth
+	}
 	if err != nil {
 		err = maybeStdioErr(err, transport)
 		updateState(name, StateError, maybeTimeoutErr(err, timeout), nil, Counts{})
@@ -417,9 +452,7 @@ func createTransport(ctx context.Context, m config.MCPConfig, resolver config.Va
 			return nil, fmt.Errorf("mcp http config requires a non-empty 'url' field")
 		}
 		client := &http.Client{
-			Transport: &headerRoundTripper{
-				headers: m.ResolvedHeaders(),
-			},
+			Transport: newAuthRoundTripper(m),
 		}
 		return &mcp.StreamableClientTransport{
 			Endpoint:   m.URL,
@@ -430,9 +463,7 @@ func createTransport(ctx context.Context, m config.MCPConfig, resolver config.Va
 			return nil, fmt.Errorf("mcp sse config requires a non-empty 'url' field")
 		}
 		client := &http.Client{
-			Transport: &headerRoundTripper{
-				headers: m.ResolvedHeaders(),
-			},
+			Transport: newAuthRoundTripper(m),
 		}
 		return &mcp.SSEClientTransport{
 			Endpoint:   m.URL,
@@ -443,17 +474,6 @@ func createTransport(ctx context.Context, m config.MCPConfig, resolver config.Va
 	}
 }
 
-type headerRoundTripper struct {
-	headers map[string]string
-}
-
-func (rt headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	for k, v := range rt.headers {
-		req.Header.Set(k, v)
-	}
-	return http.DefaultTransport.RoundTrip(req)
-}
-
 func mcpTimeout(m config.MCPConfig) time.Duration {
 	return time.Duration(cmp.Or(m.Timeout, 15)) * time.Second
 }
@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// configs holds the MCP servers of the configurations that were reconfigured.
+// They are kept here rather than in the configuration, which is read without
+// a lock.
+var configs = csync.NewMap[*config.Config, config.MCPs]()
+
+// Configs returns the MCP servers of cfg, as last reconfigured.
+func Configs(cfg *config.Config) config.MCPs {
+	if mcps, ok := configs.Get(cfg); ok {
+		return mcps
+	}
+	return cfg.MCP
+}
+
+// Reconfigure switches cfg to the MCP servers of mcps: it connects to the
+// servers that were added or enabled, disconnects the ones that were removed
+// or disabled, and reconnects the ones whose configuration changed. It
+// returns once the servers are connected or failed to.
+func Reconfigure(ctx context.Context, cfg *config.Config, mcps config.MCPs) {
+	old := Configs(cfg)
+	configs.Set(cfg, mcps)
+
+	for name := range old {
+		if _, ok := mcps[name]; ok {
+			continue
+		}
+		slog.Info("Removing MCP", "name", name)
+		disconnect(name)
+		states.Del(name)
+		broker.Publish(pubsub.UpdatedEvent, Event{
+			Type:  EventStateChanged,
+			Name:  name,
+			State: StateDisabled,
+		})
+	}
+
+	var wg sync.WaitGroup
+	for name, m := range mcps {
+		if prev, ok := old[name]; ok && reflect.DeepEqual(prev, m) {
+			continue
+		}
+		disconnect(name)
+		if m.Disabled {
+			slog.Info("Disabling MCP", "name", name)
+			updateState(name, StateDisabled, nil, nil, Counts{})
+			continue
+		}
+
+		slog.Info("Connecting to MCP", "name", name)
+		updateState(name, StateStarting, nil, nil, Counts{})
+		wg.Go(func() {
+			startClient(ctx, cfg, name, m)
+		})
+	}
+	wg.Wait()
+}
+
+// Probe connects to the MCP server name of cfg, even if it is disabled, and
+// returns its state along with all of its tools, including the disabled ones.
+func Probe(ctx context.Context, cfg *config.Config, name string) (ClientInfo, []*Tool, error) {
+	m, ok := Configs(cfg)[name]
+	if !ok {
+		return ClientInfo{}, nil, fmt.Errorf("mcp '%s' not found", name)
+	}
+
+	updateState(name, StateStarting, nil, nil, Counts{})
+	startClient(ctx, cfg, name, m)
+	info, _ := states.Get(name)
+	if info.State != StateConnected {
+		return info, nil, info.Error
+	}
+	tools, err := getTools(ctx, info.Client)
+	if err != nil {
+		return info, nil, err
+	}
+	return info, tools, nil
+}
+
+// disconnect closes the session of an MCP server, and forgets its tools,
+// prompts and resources.
+func disconnect(name string) {
+	if session, ok := sessions.Take(name); ok {
+		// Servers that get killed on the way out make Close fail.
+		if err := session.Close(); err != nil {
+			slog.Debug("Closed MCP client", "name", name, "error", err)
+		}
+	}
+	allTools.Del(name)
+	allPrompts.Del(name)
+	allResources.Del(name)
+	challenges.Del(name)
+}
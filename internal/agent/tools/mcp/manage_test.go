@@ -0,0 +1,37 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconfigure(t *testing.T) {
+	cfg := &config.Config{MCP: config.MCPs{
+		"reconfigure-removed": {Type: config.MCPStdio, Command: "removed", Disabled: true},
+		"reconfigure-kept":    {Type: config.MCPStdio, Command: "kept", Disabled: true},
+	}}
+	updateState("reconfigure-removed", StateDisabled, nil, nil, Counts{})
+	updateState("reconfigure-kept", StateDisabled, nil, nil, Counts{})
+	allTools.Set("reconfigure-removed", []*Tool{{Name: "tool"}})
+
+	mcps := config.MCPs{
+		"reconfigure-kept":  cfg.MCP["reconfigure-kept"],
+		"reconfigure-added": {Type: config.MCPStdio, Command: "added", Disabled: true},
+	}
+	Reconfigure(t.Context(), cfg, mcps)
+	require.Equal(t, mcps, Configs(cfg))
+
+	_, ok := GetState("reconfigure-removed")
+	require.False(t, ok)
+	_, ok = allTools.Get("reconfigure-removed")
+	require.False(t, ok)
+
+	state, ok := GetState("reconfigure-added")
+	require.True(t, ok)
+	require.Equal(t, StateDisabled, state.State)
+	state, ok = GetState("reconfigure-kept")
+	require.True(t, ok)
+	require.Equal(t, StateDisabled, state.State)
+}
@@ -0,0 +1,126 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/oauth/mcpauth"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ErrNeedsAuth is the error of MCP servers that have to be authorized
+// before Crush can connect to them.
+var ErrNeedsAuth = errors.New("authorization required")
+
+// challenges holds the WWW-Authenticate header of the servers that asked
+// for authorization, by name.
+var challenges = csync.NewMap[string, string]()
+
+// tokenStore keeps the OAuth credentials of remote MCP servers in the
+// global data directory.
+var tokenStore = sync.OnceValue(func() *mcpauth.Store {
+	return mcpauth.NewStore(filepath.Join(filepath.Dir(config.GlobalConfigData()), "mcp-oauth.json"))
+})
+
+// Authorize runs the OAuth authorization flow of a remote MCP server and
+// connects to it once authorized. open is called with the URL the user has
+// to visit.
+func Authorize(ctx context.Context, cfg *config.Config, name string, open func(string) error) error {
+	m, ok := Configs(cfg)[name]
+	if !ok {
+		return fmt.Errorf("mcp '%s' not found", name)
+	}
+	if m.Type != config.MCPHttp && m.Type != config.MCPSSE {
+		return fmt.Errorf("mcp '%s' is not a remote server", name)
+	}
+
+	challenge, _ := challenges.Get(name)
+	creds, err := mcpauth.Authorize(ctx, m.URL, challenge, open)
+	if err != nil {
+		return err
+	}
+	if err := tokenStore().Set(m.URL, *creds); err != nil {
+		return fmt.Errorf("failed to store token: %w", err)
+	}
+	challenges.Del(name)
+
+	updateState(name, StateStarting, nil, nil, Counts{})
+	initClient(ctx, cfg, name, m)
+	if state, _ := states.Get(name); state.State != StateConnected {
+		return state.Error
+	}
+	return nil
+}
+
+// authRoundTripper adds the configured headers and the OAuth access token of
+// a remote MCP server to its requests, and remembers whether the server
+// asked for authorization.
+type authRoundTripper struct {
+	url       string
+	headers   map[string]string
+	challenge atomic.Pointer[string]
+	// client refreshes expired access tokens.
+	client *http.Client
+}
+
+func newAuthRoundTripper(m config.MCPConfig) *authRoundTripper {
+	return &authRoundTripper{
+		url:     m.URL,
+		headers: m.ResolvedHeaders(),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (rt *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Round trippers must not modify the request they are given.
+	req = req.Clone(req.Context())
+	for k, v := range rt.headers {
+		req.Header.Set(k, v)
+	}
+	// Configured headers take precedence, so servers can keep using static
+	// API keys.
+	if req.Header.Get("Authorization") == "" {
+		if token, ok := tokenStore().AccessToken(req.Context(), rt.client, rt.url); ok {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		rt.challenge.Store(&challenge)
+	}
+	return resp, err
+}
+
+// needsAuth reports whether the server behind transport asked for
+// authorization, and the WWW-Authenticate header it sent.
+func needsAuth(transport mcp.Transport) (string, bool) {
+	var client *http.Client
+	switch t := transport.(type) {
+	case *mcp.StreamableClientTransport:
+		client = t.HTTPClient
+	case *mcp.SSEClientTransport:
+		client = t.HTTPClient
+	}
+	if client == nil {
+		return "", false
+	}
+	rt, ok := client.Transport.(*authRoundTripper)
+	if !ok {
+		return "", false
+	}
+	challenge := rt.challenge.Load()
+	if challenge == nil {
+		return "", false
+	}
+	return *challenge, true
+}
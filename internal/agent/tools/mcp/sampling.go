@@ -0,0 +1,90 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// SamplingFunc generates a message for a sampling request of the MCP server
+// name, and returns the response along with the model that generated it.
+type SamplingFunc func(ctx context.Context, name string, call fantasy.Call) (*fantasy.Response, string, error)
+
+var sampler = csync.NewValue[SamplingFunc](nil)
+
+// SetSamplingFunc sets the function that answers the sampling requests of
+// MCP servers.
+func SetSamplingFunc(fn SamplingFunc) {
+	sampler.Set(fn)
+}
+
+// createMessage answers a sampling request of an MCP server.
+func createMessage(ctx context.Context, name string, req *mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	sample := sampler.Get()
+	if sample == nil {
+		return nil, errors.New("sampling is not available")
+	}
+
+	params := req.Params
+	var call fantasy.Call
+	if params.SystemPrompt != "" {
+		call.Prompt = append(call.Prompt, fantasy.NewSystemMessage(params.SystemPrompt))
+	}
+	for _, msg := range params.Messages {
+		part, err := samplingPart(msg.Content)
+		if err != nil {
+			return nil, err
+		}
+		role := fantasy.MessageRoleUser
+		if msg.Role == "assistant" {
+			role = fantasy.MessageRoleAssistant
+		}
+		call.Prompt = append(call.Prompt, fantasy.Message{
+			Role:    role,
+			Content: []fantasy.MessagePart{part},
+		})
+	}
+	if params.MaxTokens > 0 {
+		maxTokens := params.MaxTokens
+		call.MaxOutputTokens = &maxTokens
+	}
+	if params.Temperature != 0 {
+		temperature := params.Temperature
+		call.Temperature = &temperature
+	}
+
+	slog.Debug("MCP sampling request", "name", name, "messages", len(params.Messages))
+	resp, model, err := sample(ctx, name, call)
+	if err != nil {
+		return nil, err
+	}
+	stopReason := "endTurn"
+	if resp.FinishReason == fantasy.FinishReasonLength {
+		stopReason = "maxTokens"
+	}
+	return &mcp.CreateMessageResult{
+		Content:    &mcp.TextContent{Text: resp.Content.Text()},
+		Model:      model,
+		Role:       "assistant",
+		StopReason: stopReason,
+	}, nil
+}
+
+// samplingPart converts the content of a sampling message to a message part.
+func samplingPart(content mcp.Content) (fantasy.MessagePart, error) {
+	switch c := content.(type) {
+	case *mcp.TextContent:
+		return fantasy.TextPart{Text: c.Text}, nil
+	case *mcp.ImageContent:
+		return fantasy.FilePart{Data: c.Data, MediaType: c.MIMEType}, nil
+	case *mcp.AudioContent:
+		return fantasy.FilePart{Data: c.Data, MediaType: c.MIMEType}, nil
+	default:
+		return nil, fmt.Errorf("unsupported sampling content: %T", content)
+	}
+}
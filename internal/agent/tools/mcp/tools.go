@@ -1,27 +1,27 @@
 package mcp
 
 import (
+	"cmp"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"iter"
 	"log/slog"
 	"slices"
-	"strings"
 
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/message"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 type Tool = mcp.Tool
 
-// ToolResult represents the result of running an MCP tool.
+// ToolResult represents the result of running an MCP tool: its content, in
+// order, followed by its structured content, if any.
 type ToolResult struct {
-	Type      string
-	Content   string
-	Data      []byte
-	MediaType string
+	Parts []message.ToolResultPart
 }
 
 var allTools = csync.NewMap[string, []*Tool]()
@@ -50,60 +50,68 @@ func RunTool(ctx context.Context, cfg *config.Config, name, toolName string, inp
 		return ToolResult{}, err
 	}
 
-	if len(result.Content) == 0 {
-		return ToolResult{Type: "text", Content: ""}, nil
-	}
-
-	var textParts []string
-	var imageData []byte
-	var imageMimeType string
-	var audioData []byte
-	var audioMimeType string
+	return ToolResult{Parts: toolResultParts(result)}, nil
+}
 
+// toolResultParts converts the content of the result of an MCP tool to
+// tool result parts.
+func toolResultParts(result *mcp.CallToolResult) []message.ToolResultPart {
+	var parts []message.ToolResultPart
 	for _, v := range result.Content {
 		switch content := v.(type) {
 		case *mcp.TextContent:
-			textParts = append(textParts, content.Text)
+			parts = append(parts, message.ToolResultPart{
+				Type: message.ToolResultPartText,
+				Text: content.Text,
+			})
 		case *mcp.ImageContent:
-			if imageData == nil {
-				imageData = content.Data
-				imageMimeType = content.MIMEType
-			}
+			parts = append(parts, message.ToolResultPart{
+				Type:     message.ToolResultPartImage,
+				Data:     base64.StdEncoding.EncodeToString(content.Data),
+				MIMEType: content.MIMEType,
+			})
 		case *mcp.AudioContent:
-			if audioData == nil {
-				audioData = content.Data
-				audioMimeType = content.MIMEType
+			parts = append(parts, message.ToolResultPart{
+				Type:     message.ToolResultPartAudio,
+				Data:     base64.StdEncoding.EncodeToString(content.Data),
+				MIMEType: content.MIMEType,
+			})
+		case *mcp.ResourceLink:
+			parts = append(parts, message.ToolResultPart{
+				Type:        message.ToolResultPartResourceLink,
+				URI:         content.URI,
+				Name:        cmp.Or(content.Title, content.Name),
+				Description: content.Description,
+				MIMEType:    content.MIMEType,
+			})
+		case *mcp.EmbeddedResource:
+			if content.Resource == nil {
+				continue
+			}
+			part := message.ToolResultPart{
+				Type:     message.ToolResultPartResource,
+				URI:      content.Resource.URI,
+				MIMEType: content.Resource.MIMEType,
+				Text:     content.Resource.Text,
+			}
+			if content.Resource.Blob != nil {
+				part.Data = base64.StdEncoding.EncodeToString(content.Resource.Blob)
 			}
+			parts = append(parts, part)
 		default:
-			textParts = append(textParts, fmt.Sprintf("%v", v))
+			slog.Warn("Unsupported MCP tool result content", "type", fmt.Sprintf("%T", v))
 		}
 	}
-
-	textContent := strings.Join(textParts, "\n")
-
-	// MCP SDK returns Data as already base64-encoded, so we use it directly.
-	if imageData != nil {
-		return ToolResult{
-			Type:      "image",
-			Content:   textContent,
-			Data:      imageData,
-			MediaType: imageMimeType,
-		}, nil
-	}
-
-	if audioData != nil {
-		return ToolResult{
-			Type:      "media",
-			Content:   textContent,
-			Data:      audioData,
-			MediaType: audioMimeType,
-		}, nil
+	if result.StructuredContent != nil {
+		data, err := json.Marshal(result.StructuredContent)
+		if err == nil {
+			parts = append(parts, message.ToolResultPart{
+				Type: message.ToolResultPartStructured,
+				Text: string(data),
+			})
+		}
 	}
-
-	return ToolResult{
-		Type:    "text",
-		Content: textContent,
-	}, nil
+	return parts
 }
 
 // RefreshTools gets the updated list of tools from the MCP and updates the
@@ -151,7 +159,7 @@ func updateTools(cfg *config.Config, name string, tools []*Tool) int {
 
 // filterDisabledTools removes tools that are disabled via config.
 func filterDisabledTools(cfg *config.Config, mcpName string, tools []*Tool) []*Tool {
-	mcpCfg, ok := cfg.MCP[mcpName]
+	mcpCfg, ok := Configs(cfg)[mcpName]
 	if !ok || len(mcpCfg.DisabledTools) == 0 {
 		return tools
 	}
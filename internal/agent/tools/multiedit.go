@@ -204,8 +204,8 @@ func processMultiEditWithCreation(edit editContext, params MultiEditParams, call
 		return fantasy.ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
 	}
 
-	// Update file history
-	_, err = edit.files.Create(edit.ctx, sessionID, params.FilePath, "")
+	// Update file history, recording that the file is new
+	_, err = edit.files.CreateNew(edit.ctx, sessionID, params.FilePath)
 	if err != nil {
 		return fantasy.ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
 	}
@@ -357,9 +357,10 @@ func processMultiEditExistingFile(edit editContext, params MultiEditParams, call
 		if err != nil {
 			return fantasy.ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
 		}
-	}
-	if file.Content != oldContent {
-		// User manually changed the content, store an intermediate version
+	} else if file.Content != oldContent || file.MessageID != GetMessageFromContext(edit.ctx) {
+		// User manually changed the content, or this is the first change
+		// made from this message; store an intermediate version so the
+		// message can be rewound.
 		_, err = edit.files.CreateVersion(edit.ctx, sessionID, params.FilePath, oldContent)
 		if err != nil {
 			slog.Error("Error creating file history version", "error", err)
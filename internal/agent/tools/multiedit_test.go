@@ -20,6 +20,18 @@ func (m *mockPermissionService) Request(ctx context.Context, req permission.Crea
 	return true, nil
 }
 
+func (m *mockPermissionService) CheckRules(req permission.CreatePermissionRequest) error {
+	return nil
+}
+
+func (m *mockPermissionService) ListSessionGrants(ctx context.Context, sessionID string) ([]permission.SessionGrant, error) {
+	return nil, nil
+}
+
+func (m *mockPermissionService) RevokeSessionGrant(ctx context.Context, sessionID, grantID string) error {
+	return nil
+}
+
 func (m *mockPermissionService) Grant(req permission.PermissionRequest) {}
 
 func (m *mockPermissionService) Deny(req permission.PermissionRequest) {}
@@ -28,6 +40,14 @@ func (m *mockPermissionService) GrantPersistent(req permission.PermissionRequest
 
 func (m *mockPermissionService) AutoApproveSession(sessionID string) {}
 
+func (m *mockPermissionService) ForgetSession(sessionID string) {}
+
+func (m *mockPermissionService) SetSessionMode(sessionID string, mode permission.SessionMode) {}
+
+func (m *mockPermissionService) SessionMode(sessionID string) permission.SessionMode {
+	return permission.SessionModePrompt
+}
+
 func (m *mockPermissionService) SetSkipRequests(skip bool) {}
 
 func (m *mockPermissionService) SkipRequests() bool {
@@ -50,6 +70,10 @@ func (m *mockHistoryService) CreateVersion(ctx context.Context, sessionID, path,
 	return history.File{}, nil
 }
 
+func (m *mockHistoryService) CreateNew(ctx context.Context, sessionID, path string) (history.File, error) {
+	return history.File{Path: path, IsNew: true}, nil
+}
+
 func (m *mockHistoryService) GetByPathAndSession(ctx context.Context, path, sessionID string) (history.File, error) {
 	return history.File{Path: path, Content: ""}, nil
 }
@@ -138,15 +138,22 @@ func NewWriteTool(
 
 			// Check if file exists in history
 			file, err := files.GetByPathAndSession(ctx, filePath, sessionID)
-			if err != nil {
+			if fileInfo == nil {
+				// Record that the file is new, so rewinding deletes it
+				_, err = files.CreateNew(ctx, sessionID, filePath)
+				if err != nil {
+					return fantasy.ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
+				}
+			} else if err != nil {
 				_, err = files.Create(ctx, sessionID, filePath, oldContent)
 				if err != nil {
 					// Log error but don't fail the operation
 					return fantasy.ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
 				}
-			}
-			if file.Content != oldContent {
-				// User manually changed the content; store an intermediate version
+			} else if file.Content != oldContent || file.MessageID != GetMessageFromContext(ctx) {
+				// User manually changed the content, or this is the first change
+				// made from this message; store an intermediate version so the
+				// message can be rewound.
 				_, err = files.CreateVersion(ctx, sessionID, filePath, oldContent)
 				if err != nil {
 					slog.Error("Error creating file history version", "error", err)
@@ -3,6 +3,7 @@
 package app
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"errors"
@@ -20,6 +21,7 @@ import (
 	"charm.land/lipgloss/v2"
 	"github.com/charmbracelet/crush/internal/agent"
 	"github.com/charmbracelet/crush/internal/agent/tools/mcp"
+	"github.com/charmbracelet/crush/internal/checkpoint"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/db"
 	"github.com/charmbracelet/crush/internal/event"
@@ -55,6 +57,7 @@ type App struct {
 	History     history.Service
 	Permissions permission.Service
 	FileTracker filetracker.Service
+	Checkpoints checkpoint.Service
 
 	AgentCoordinator agent.Coordinator
 
@@ -80,16 +83,19 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 	files := history.NewService(q, conn)
 	skipPermissionsRequests := cfg.Permissions != nil && cfg.Permissions.SkipRequests
 	var allowedTools []string
-	if cfg.Permissions != nil && cfg.Permissions.AllowedTools != nil {
+	var rules []permission.Rule
+	if cfg.Permissions != nil {
 		allowedTools = cfg.Permissions.AllowedTools
+		rules = cfg.Permissions.Rules
 	}
 
 	app := &App{
 		Sessions:    sessions,
 		Messages:    messages,
 		History:     files,
-		Permissions: permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools),
+		Permissions: permission.NewPermissionService(q, cfg.WorkingDir(), skipPermissionsRequests, allowedTools, rules),
 		FileTracker: filetracker.NewService(q),
+		Checkpoints: checkpoint.NewService(sessions, messages, files),
 		LSPManager:  lsp.NewManager(cfg),
 
 		globalCtx: ctx,
@@ -143,20 +149,64 @@ func (app *App) Config() *config.Config {
 	return app.config
 }
 
+// RunOptions configures a non-interactive run.
+type RunOptions struct {
+	Prompt string
+	// LargeModel and SmallModel override the configured models. They accept
+	// 'model' or 'provider/model'.
+	LargeModel string
+	SmallModel string
+	// HideSpinner hides the spinner shown while the agent is working.
+	HideSpinner bool
+	// OutputFormat defaults to [OutputFormatText].
+	OutputFormat OutputFormat
+	// SessionID runs the prompt in an existing session instead of a new one.
+	SessionID string
+	// Continue runs the prompt in the most recently updated session.
+	Continue bool
+	// PermissionMode defaults to [PermissionModeAuto].
+	PermissionMode PermissionMode
+	// Agent is the ID of the agent to run the prompt with. Defaults to the
+	// coder agent.
+	Agent string
+	// Plan runs the prompt in plan mode, and carries out the plan once it
+	// is approved on the terminal.
+	Plan bool
+	// MaxCost is the most the run can spend, in US dollars. Defaults to the
+	// run budget of the configuration.
+	MaxCost float64
+}
+
 // RunNonInteractive runs the application in non-interactive mode with the
 // given prompt, printing to stdout.
-func (app *App) RunNonInteractive(ctx context.Context, output io.Writer, prompt, largeModel, smallModel string, hideSpinner bool) error {
+func (app *App) RunNonInteractive(ctx context.Context, output io.Writer, opts RunOptions) error {
 	slog.Info("Running in non-interactive mode")
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	if largeModel != "" || smallModel != "" {
-		if err := app.overrideModelsForNonInteractive(ctx, largeModel, smallModel); err != nil {
+	prompt := opts.Prompt
+	outputFormat := opts.OutputFormat
+	if outputFormat == "" {
+		outputFormat = OutputFormatText
+	}
+	// The spinner would get in the way of tools parsing the output, and of
+	// permission prompts.
+	hideSpinner := opts.HideSpinner || outputFormat != OutputFormatText ||
+		opts.PermissionMode == PermissionModePromptStdin || opts.Plan
+
+	if opts.LargeModel != "" || opts.SmallModel != "" {
+		if err := app.overrideModelsForNonInteractive(ctx, opts.LargeModel, opts.SmallModel); err != nil {
 			return fmt.Errorf("failed to override models: %w", err)
 		}
 	}
 
+	if opts.Agent != "" {
+		if err := app.AgentCoordinator.SetMainAgent(ctx, opts.Agent); err != nil {
+			return err
+		}
+	}
+
 	var (
 		spinner   *format.Spinner
 		stdoutTTY bool
@@ -213,26 +263,38 @@ func (app *App) RunNonInteractive(ctx context.Context, output io.Writer, prompt,
 
 	defer stopSpinner()
 
-	const maxPromptLengthForTitle = 100
-	const titlePrefix = "Non-interactive: "
-	var titleSuffix string
+	sess, err := app.nonInteractiveSession(ctx, opts)
+	if err != nil {
+		return err
+	}
 
-	if len(prompt) > maxPromptLengthForTitle {
-		titleSuffix = prompt[:maxPromptLengthForTitle] + "..."
-	} else {
-		titleSuffix = prompt
+	// Nobody is around to tell the agent what to do after a denial, so let
+	// it carry on.
+	ctx = agent.WithPermissionDenialsAsToolErrors(ctx)
+
+	if maxCost := cmp.Or(opts.MaxCost, app.config.Options.Budget.Run); maxCost > 0 {
+		ctx = agent.WithRunBudget(ctx, maxCost)
+	}
+	app.Permissions.SetSessionMode(sess.ID, opts.PermissionMode.sessionMode())
+	if opts.PermissionMode == PermissionModePromptStdin {
+		go app.promptPermissions(ctx, app.Permissions.Subscribe(ctx))
 	}
-	title := titlePrefix + titleSuffix
 
-	sess, err := app.Sessions.Create(ctx, title)
-	if err != nil {
-		return fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+	run := func(ctx context.Context) (*fantasy.AgentResult, error) {
+		return app.AgentCoordinator.Run(ctx, sess.ID, prompt)
+	}
+	if opts.Plan {
+		run = func(ctx context.Context) (*fantasy.AgentResult, error) {
+			return app.runPlan(ctx, sess.ID, prompt)
+		}
 	}
-	slog.Info("Created session for non-interactive run", "session_id", sess.ID)
 
-	// Automatically approve all permission requests for this non-interactive
-	// session.
-	app.Permissions.AutoApproveSession(sess.ID)
+	// Subscribe before starting the agent so no message is missed.
+	messageEvents := app.Messages.Subscribe(ctx)
+
+	if outputFormat != OutputFormatText {
+		return app.runStructured(ctx, output, outputFormat, sess.ID, run, messageEvents, stopSpinner)
+	}
 
 	type response struct {
 		result *fantasy.AgentResult
@@ -240,8 +302,8 @@ func (app *App) RunNonInteractive(ctx context.Context, output io.Writer, prompt,
 	}
 	done := make(chan response, 1)
 
-	go func(ctx context.Context, sessionID, prompt string) {
-		result, err := app.AgentCoordinator.Run(ctx, sess.ID, prompt)
+	go func(ctx context.Context) {
+		result, err := run(ctx)
 		if err != nil {
 			done <- response{
 				err: fmt.Errorf("failed to start agent processing stream: %w", err),
@@ -250,9 +312,8 @@ func (app *App) RunNonInteractive(ctx context.Context, output io.Writer, prompt,
 		done <- response{
 			result: result,
 		}
-	}(ctx, sess.ID, prompt)
+	}(ctx)
 
-	messageEvents := app.Messages.Subscribe(ctx)
 	messageReadBytes := make(map[string]int)
 	var printed bool
 
@@ -319,6 +380,133 @@ func (app *App) RunNonInteractive(ctx context.Context, output io.Writer, prompt,
 	}
 }
 
+// nonInteractiveSession returns the session a non-interactive run should use:
+// the one asked for, or a new one titled after the prompt.
+func (app *App) nonInteractiveSession(ctx context.Context, opts RunOptions) (session.Session, error) {
+	switch {
+	case opts.SessionID != "":
+		sess, err := app.Sessions.Get(ctx, opts.SessionID)
+		if err != nil {
+			return session.Session{}, fmt.Errorf("session %s not found: %w", opts.SessionID, err)
+		}
+		slog.Info("Resuming session for non-interactive run", "session_id", sess.ID)
+		return sess, nil
+	case opts.Continue:
+		sessions, err := app.Sessions.List(ctx)
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		if len(sessions) == 0 {
+			return session.Session{}, errors.New("no session to continue")
+		}
+		// Sessions are listed most recently updated first.
+		slog.Info("Continuing session for non-interactive run", "session_id", sessions[0].ID)
+		return sessions[0], nil
+	}
+
+	const maxPromptLengthForTitle = 100
+	const titlePrefix = "Non-interactive: "
+	var titleSuffix string
+
+	if len(opts.Prompt) > maxPromptLengthForTitle {
+		titleSuffix = opts.Prompt[:maxPromptLengthForTitle] + "..."
+	} else {
+		titleSuffix = opts.Prompt
+	}
+	title := titlePrefix + titleSuffix
+
+	sess, err := app.Sessions.Create(ctx, title)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+	}
+	slog.Info("Created session for non-interactive run", "session_id", sess.ID)
+	return sess, nil
+}
+
+// runStructured runs the agent and writes its progress in one of the JSON
+// output formats.
+func (app *App) runStructured(
+	ctx context.Context,
+	output io.Writer,
+	format OutputFormat,
+	sessionID string,
+	run func(context.Context) (*fantasy.AgentResult, error),
+	messageEvents <-chan pubsub.Event[message.Message],
+	stopSpinner func(),
+) error {
+	out := newStructuredOutput(output, format, sessionID)
+
+	// Only report what this run adds to a resumed session.
+	existing, err := app.Messages.List(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to list messages: %w", err)
+	}
+	out.skip(existing)
+	// Nor what it cost before.
+	before, err := app.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+
+	done := make(chan error, 1)
+	var result *fantasy.AgentResult
+	go func() {
+		var err error
+		result, err = run(ctx)
+		done <- err
+	}()
+
+	var runErr error
+	var usage fantasy.Usage
+loop:
+	for {
+		select {
+		case runErr = <-done:
+			if result != nil {
+				usage = result.TotalUsage
+			}
+			break loop
+		case event := <-messageEvents:
+			stopSpinner()
+			if err := out.handle(event.Payload); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			runErr = ctx.Err()
+			break loop
+		}
+	}
+	stopSpinner()
+
+	// Events may have been dropped if we were too slow to read them, so
+	// catch up with what has been stored.
+	msgs, err := app.Messages.List(context.WithoutCancel(ctx), sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to list messages: %w", err)
+	}
+	for _, msg := range msgs {
+		if err := out.handle(msg); err != nil {
+			return err
+		}
+	}
+
+	sess, err := app.Sessions.Get(context.WithoutCancel(ctx), sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if errors.Is(runErr, context.Canceled) || errors.Is(runErr, agent.ErrRequestCancelled) {
+		slog.Debug("Non-interactive: agent processing cancelled", "session_id", sessionID)
+		runErr = agent.ErrRequestCancelled
+	}
+	if err := out.close(usage, sess.Cost-before.Cost, runErr); err != nil {
+		return err
+	}
+	if runErr != nil && !errors.Is(runErr, agent.ErrRequestCancelled) {
+		return fmt.Errorf("agent processing failed: %w", runErr)
+	}
+	return nil
+}
+
 func (app *App) UpdateAgentModel(ctx context.Context) error {
 	if app.AgentCoordinator == nil {
 		return fmt.Errorf("agent configuration is missing")
@@ -326,6 +514,14 @@ func (app *App) UpdateAgentModel(ctx context.Context) error {
 	return app.AgentCoordinator.UpdateModels(ctx)
 }
 
+// CurrentAgent returns the configuration of the agent that runs prompts.
+func (app *App) CurrentAgent() config.Agent {
+	if app.AgentCoordinator == nil {
+		return app.config.Agents[config.AgentCoder]
+	}
+	return app.AgentCoordinator.MainAgent()
+}
+
 // overrideModelsForNonInteractive parses the model strings and temporarily
 // overrides the model configurations, then rebuilds the agent.
 // Format: "model-name" (searches all providers) or "provider/model-name".
@@ -426,6 +622,9 @@ func (app *App) setupEvents() {
 	setupSubscriber(ctx, app.serviceEventsWG, "history", app.History.Subscribe, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "mcp", mcp.SubscribeEvents, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "lsp", SubscribeLSPEvents, app.events)
+	app.serviceEventsWG.Go(func() { app.handleMCPEvents(ctx) })
+	app.serviceEventsWG.Go(func() { app.watchMCPConfig(ctx) })
+	app.serviceEventsWG.Go(func() { app.forgetDeletedSessions(ctx) })
 	cleanupFunc := func(context.Context) error {
 		cancel()
 		app.serviceEventsWG.Wait()
@@ -521,6 +720,10 @@ func (app *App) Subscribe(program *tea.Program) {
 	})
 	defer app.tuiWG.Done()
 
+	// Only the TUI can show the forms of MCP servers, so they can't ask for
+	// input in non-interactive runs.
+	setupSubscriber(tuiCtx, app.tuiWG, "mcp-elicitations", mcp.SubscribeElicitations, app.events)
+
 	for {
 		select {
 		case <-tuiCtx.Done():
@@ -547,6 +750,13 @@ func (app *App) Shutdown() {
 		app.AgentCoordinator.CancelAll()
 	}
 
+	// Write what the agents streamed last before the database goes away.
+	flushCtx, cancelFlush := context.WithTimeout(app.globalCtx, 5*time.Second)
+	if err := app.Messages.Flush(flushCtx); err != nil {
+		slog.Error("Failed to write streamed messages on shutdown", "error", err)
+	}
+	cancelFlush()
+
 	// Now run remaining cleanup tasks in parallel.
 	var wg sync.WaitGroup
 
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/agent/tools/mcp"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// mcpConfigDebounce is how long the configuration files have to stay still
+// before the MCP servers are reconfigured, as editors save in several steps.
+const mcpConfigDebounce = 500 * time.Millisecond
+
+// handleMCPEvents keeps the tools of the agent in line with the MCP servers:
+// it reloads the tools of servers that changed them, and rebuilds the tools
+// of the agent when servers connect, disconnect or change their tools.
+func (app *App) handleMCPEvents(ctx context.Context) {
+	for event := range mcp.SubscribeEvents(ctx) {
+		switch event.Payload.Type {
+		case mcp.EventToolsListChanged:
+			// Publishes a state change once the tools are reloaded.
+			mcp.RefreshTools(ctx, app.config, event.Payload.Name)
+		case mcp.EventStateChanged:
+			if app.AgentCoordinator == nil {
+				continue
+			}
+			if err := app.AgentCoordinator.UpdateModels(ctx); err != nil {
+				slog.Warn("Failed to update agent tools", "error", err)
+			}
+		}
+	}
+}
+
+// watchMCPConfig connects, disconnects and reconnects MCP servers as their
+// configuration changes in the configuration files.
+func (app *App) watchMCPConfig(ctx context.Context) {
+	if err := mcp.WaitForInit(ctx); err != nil {
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("Failed to watch configuration files", "error", err)
+		return
+	}
+	defer watcher.Close()
+
+	workingDir := app.config.WorkingDir()
+	// The project configuration may not exist yet, e.g. until the first
+	// `crush mcp add`.
+	paths := append(config.ConfigPaths(workingDir), config.ProjectConfig(workingDir))
+	files := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		files[filepath.Clean(path)] = true
+		dir := filepath.Dir(path)
+		if err := watcher.Add(dir); err != nil {
+			slog.Debug("Not watching configuration directory", "dir", dir, "error", err)
+		}
+	}
+
+	var reload <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if files[filepath.Clean(event.Name)] {
+				reload = time.After(mcpConfigDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Debug("Configuration watcher error", "error", err)
+		case <-reload:
+			reload = nil
+			mcps, err := config.LoadMCP(workingDir)
+			if err != nil {
+				slog.Warn("Failed to reload MCP configuration", "error", err)
+				continue
+			}
+			mcp.Reconfigure(ctx, app.config, mcps)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// OutputFormat is the output format of non-interactive runs.
+type OutputFormat string
+
+const (
+	// OutputFormatText prints the assistant's answer as it streams in.
+	OutputFormatText OutputFormat = "text"
+	// OutputFormatJSON prints a single JSON object with every event and
+	// the summary of the run once it finishes.
+	OutputFormatJSON OutputFormat = "json"
+	// OutputFormatStreamJSON prints newline-delimited JSON events as the
+	// run progresses, ending with the summary of the run.
+	OutputFormatStreamJSON OutputFormat = "stream-json"
+)
+
+// OutputFormats lists the supported output formats.
+var OutputFormats = []OutputFormat{OutputFormatText, OutputFormatJSON, OutputFormatStreamJSON}
+
+// OutputEvent is a message part emitted by a structured non-interactive run.
+// Type is the kind of part (text, reasoning, tool_call, tool_result, finish,
+// image_url or binary) and Part holds it as stored in the message.
+type OutputEvent struct {
+	Type      string              `json:"type"`
+	SessionID string              `json:"session_id"`
+	MessageID string              `json:"message_id"`
+	Role      message.MessageRole `json:"role"`
+	Part      message.ContentPart `json:"part"`
+}
+
+// OutputResult is the summary of a structured non-interactive run. Tokens
+// and cost are those of the run, not of the whole session it resumed.
+type OutputResult struct {
+	Type             string               `json:"type"`
+	SessionID        string               `json:"session_id"`
+	IsError          bool                 `json:"is_error"`
+	Error            string               `json:"error,omitempty"`
+	Result           string               `json:"result"`
+	FinishReason     message.FinishReason `json:"finish_reason,omitempty"`
+	DurationMs       int64                `json:"duration_ms"`
+	PromptTokens     int64                `json:"prompt_tokens"`
+	CompletionTokens int64                `json:"completion_tokens"`
+	Cost             float64              `json:"cost"`
+	Events           []OutputEvent        `json:"events,omitempty"`
+}
+
+// structuredOutput writes the parts of the messages of a session as
+// [OutputEvent]s. Parts are written once, in order, as soon as they are
+// complete.
+type structuredOutput struct {
+	format    OutputFormat
+	w         io.Writer
+	sessionID string
+	started   time.Time
+
+	// written is the number of parts written for each message.
+	written map[string]int
+	events  []OutputEvent
+	result  string
+	finish  message.FinishReason
+}
+
+func newStructuredOutput(w io.Writer, format OutputFormat, sessionID string) *structuredOutput {
+	return &structuredOutput{
+		format:    format,
+		w:         w,
+		sessionID: sessionID,
+		started:   time.Now(),
+		written:   make(map[string]int),
+	}
+}
+
+// skip marks the given messages as already written.
+func (o *structuredOutput) skip(msgs []message.Message) {
+	for _, msg := range msgs {
+		o.written[msg.ID] = len(msg.Parts)
+	}
+}
+
+// handle writes the newly completed parts of msg.
+func (o *structuredOutput) handle(msg message.Message) error {
+	if msg.SessionID != o.sessionID {
+		return nil
+	}
+
+	// User and tool messages are complete when they are created.
+	complete := msg.Role != message.Assistant || msg.IsFinished()
+	for i := o.written[msg.ID]; i < len(msg.Parts); i++ {
+		part := msg.Parts[i]
+		if !complete {
+			// Tool calls say when their input is complete, other parts are
+			// complete once something follows them.
+			if tc, ok := part.(message.ToolCall); ok {
+				if !tc.Finished {
+					break
+				}
+			} else if i == len(msg.Parts)-1 {
+				break
+			}
+		}
+		if err := o.write(msg, part); err != nil {
+			return err
+		}
+		o.written[msg.ID] = i + 1
+	}
+
+	if msg.Role == message.Assistant && msg.IsFinished() {
+		if text := msg.Content().Text; text != "" {
+			o.result = text
+		}
+		o.finish = msg.FinishReason()
+	}
+	return nil
+}
+
+func (o *structuredOutput) write(msg message.Message, part message.ContentPart) error {
+	event := OutputEvent{
+		Type:      partType(part),
+		SessionID: msg.SessionID,
+		MessageID: msg.ID,
+		Role:      msg.Role,
+		Part:      part,
+	}
+	if o.format == OutputFormatJSON {
+		o.events = append(o.events, event)
+		return nil
+	}
+	return o.encode(event)
+}
+
+// close writes the summary of the run, given the tokens it used and what it
+// cost.
+func (o *structuredOutput) close(usage fantasy.Usage, cost float64, runErr error) error {
+	result := OutputResult{
+		Type:             "result",
+		SessionID:        o.sessionID,
+		Result:           o.result,
+		FinishReason:     o.finish,
+		DurationMs:       time.Since(o.started).Milliseconds(),
+		PromptTokens:     usage.InputTokens + usage.CacheCreationTokens + usage.CacheReadTokens,
+		CompletionTokens: usage.OutputTokens,
+		Cost:             cost,
+		Events:           o.events,
+	}
+	if runErr != nil {
+		result.IsError = true
+		result.Error = runErr.Error()
+	}
+	return o.encode(result)
+}
+
+func (o *structuredOutput) encode(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(o.w, "%s\n", data)
+	return err
+}
+
+func partType(part message.ContentPart) string {
+	switch part.(type) {
+	case message.ReasoningContent:
+		return "reasoning"
+	case message.TextContent:
+		return "text"
+	case message.ImageURLContent:
+		return "image_url"
+	case message.BinaryContent:
+		return "binary"
+	case message.ToolCall:
+		return "tool_call"
+	case message.ToolResult:
+		return "tool_result"
+	case message.Finish:
+		return "finish"
+	case message.Retry:
+		return "retry"
+	}
+	return "unknown"
+}
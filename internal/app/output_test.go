@@ -0,0 +1,103 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructuredOutput_StreamJSON(t *testing.T) {
+	var buf bytes.Buffer
+	out := newStructuredOutput(&buf, OutputFormatStreamJSON, "session")
+
+	user := message.Message{
+		ID:        "user",
+		Role:      message.User,
+		SessionID: "session",
+		Parts:     []message.ContentPart{message.TextContent{Text: "list files"}},
+	}
+	require.NoError(t, out.handle(user))
+
+	// Updates from other sessions, e.g. sub-agents, are ignored.
+	require.NoError(t, out.handle(message.Message{
+		ID:        "other",
+		Role:      message.User,
+		SessionID: "other",
+		Parts:     []message.ContentPart{message.TextContent{Text: "nope"}},
+	}))
+
+	assistant := message.Message{ID: "assistant", Role: message.Assistant, SessionID: "session"}
+	assistant.AppendContent("Let me")
+	require.NoError(t, out.handle(assistant))
+	assistant.AppendContent(" check.")
+	assistant.AddToolCall(message.ToolCall{ID: "call", Name: "ls"})
+	require.NoError(t, out.handle(assistant))
+	assistant.FinishToolCall("call")
+	require.NoError(t, out.handle(assistant))
+	assistant.AddFinish(message.FinishReasonToolUse, "", "")
+	require.NoError(t, out.handle(assistant))
+	// Seeing the same message again does not repeat its parts.
+	require.NoError(t, out.handle(assistant))
+
+	require.NoError(t, out.handle(message.Message{
+		ID:        "tool",
+		Role:      message.Tool,
+		SessionID: "session",
+		Parts:     []message.ContentPart{message.ToolResult{ToolCallID: "call", Name: "ls", Content: "main.go"}},
+	}))
+
+	final := message.Message{ID: "final", Role: message.Assistant, SessionID: "session"}
+	final.AppendContent("There is a main.go file.")
+	final.AddFinish(message.FinishReasonEndTurn, "", "")
+	require.NoError(t, out.handle(final))
+
+	require.NoError(t, out.close(fantasy.Usage{InputTokens: 4, CacheReadTokens: 6, OutputTokens: 5}, 0.5, nil))
+
+	var types []string
+	var last map[string]any
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		last = map[string]any{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &last))
+		types = append(types, last["type"].(string))
+	}
+	require.Equal(t, []string{
+		"text", "text", "tool_call", "finish", "tool_result", "text", "finish", "result",
+	}, types)
+	require.Equal(t, "There is a main.go file.", last["result"])
+	require.Equal(t, "end_turn", last["finish_reason"])
+	require.Equal(t, float64(10), last["prompt_tokens"])
+	require.Equal(t, float64(5), last["completion_tokens"])
+	require.Equal(t, 0.5, last["cost"])
+	require.Equal(t, false, last["is_error"])
+}
+
+func TestStructuredOutput_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	out := newStructuredOutput(&buf, OutputFormatJSON, "session")
+
+	msg := message.Message{ID: "assistant", Role: message.Assistant, SessionID: "session"}
+	msg.AppendContent("partial")
+	msg.AddFinish(message.FinishReasonError, "boom", "")
+	require.NoError(t, out.handle(msg))
+	require.Zero(t, buf.Len(), "json output is written once the run is over")
+
+	require.NoError(t, out.close(fantasy.Usage{}, 0, errors.New("boom")))
+
+	var result OutputResult
+	require.NoError(t, json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &struct {
+		*OutputResult
+		Events []json.RawMessage `json:"events"`
+	}{OutputResult: &result}))
+	require.True(t, result.IsError)
+	require.Equal(t, "boom", result.Error)
+	require.Equal(t, "partial", result.Result)
+	require.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("\n")))
+	require.Contains(t, buf.String(), `"events":[{"type":"text"`)
+}
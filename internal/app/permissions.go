@@ -0,0 +1,130 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// PermissionMode decides how the permission requests of non-interactive runs
+// are answered.
+type PermissionMode string
+
+const (
+	// PermissionModeAuto approves every request.
+	PermissionModeAuto PermissionMode = "auto"
+	// PermissionModeAllowlist approves the requests allowed by rules and the
+	// allowed tools list, and denies the rest.
+	PermissionModeAllowlist PermissionMode = "allowlist"
+	// PermissionModeDeny denies every request.
+	PermissionModeDeny PermissionMode = "deny"
+	// PermissionModePromptStdin asks for every request on the terminal.
+	PermissionModePromptStdin PermissionMode = "prompt-stdin"
+)
+
+// PermissionModes lists the supported permission modes.
+var PermissionModes = []PermissionMode{
+	PermissionModeAuto,
+	PermissionModeAllowlist,
+	PermissionModeDeny,
+	PermissionModePromptStdin,
+}
+
+func (m PermissionMode) sessionMode() permission.SessionMode {
+	switch m {
+	case PermissionModeAllowlist:
+		return permission.SessionModeAllowlist
+	case PermissionModeDeny:
+		return permission.SessionModeDeny
+	case PermissionModePromptStdin:
+		return permission.SessionModePrompt
+	}
+	return permission.SessionModeAuto
+}
+
+// forgetDeletedSessions drops the permission state of sessions as they are
+// deleted, until ctx is done.
+func (app *App) forgetDeletedSessions(ctx context.Context) {
+	for event := range app.Sessions.Subscribe(ctx) {
+		if event.Type == pubsub.DeletedEvent {
+			app.Permissions.ForgetSession(event.Payload.ID)
+		}
+	}
+}
+
+// promptPermissions answers permission requests by asking on the terminal
+// until ctx is done. Requests are denied when there is no terminal to ask
+// on, e.g. in CI.
+func (app *App) promptPermissions(ctx context.Context, requests <-chan pubsub.Event[permission.PermissionRequest]) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		slog.Warn("No terminal to ask for permissions on, denying all requests", "error", err)
+	} else {
+		defer tty.Close()
+	}
+	var r *bufio.Reader
+	if tty != nil {
+		r = bufio.NewReader(tty)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-requests:
+			if !ok {
+				return
+			}
+			req := event.Payload
+			if tty == nil {
+				app.Permissions.Deny(req)
+				continue
+			}
+			switch askPermission(tty, r, req) {
+			case 'y':
+				app.Permissions.Grant(req)
+			case 'a':
+				app.Permissions.GrantPersistent(req)
+			default:
+				app.Permissions.Deny(req)
+			}
+		}
+	}
+}
+
+// askPermission describes req on w and reads the answer from r: 'y' to
+// allow it, 'a' to allow it for the rest of the session or 'n' to deny it.
+// Reading nothing denies the request.
+func askPermission(w io.Writer, r *bufio.Reader, req permission.PermissionRequest) byte {
+	fmt.Fprintf(w, "\nPermission requested: %s (%s)\n", req.ToolName, req.Action)
+	if req.Description != "" {
+		fmt.Fprintf(w, "  %s\n", req.Description)
+	}
+	if req.Path != "" {
+		fmt.Fprintf(w, "  Path: %s\n", req.Path)
+	}
+	for {
+		fmt.Fprint(w, "Allow? [y]es, [a]llow for session, [n]o: ")
+		line, err := r.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		switch answer {
+		case "y", "yes":
+			return 'y'
+		case "a", "allow":
+			return 'a'
+		case "n", "no":
+			return 'n'
+		}
+		if err != nil {
+			fmt.Fprintln(w)
+			return 'n'
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAskPermission(t *testing.T) {
+	req := permission.PermissionRequest{
+		ToolName:    "bash",
+		Action:      "execute",
+		Description: "Execute command: go test ./...",
+		Path:        "/project",
+	}
+
+	for input, want := range map[string]byte{
+		"y\n":            'y',
+		"YES\n":          'y',
+		"a\n":            'a',
+		"n\n":            'n',
+		"maybe\nallow\n": 'a',
+		"":               'n',
+		"what\n":         'n',
+		"y":              'y',
+		"  no  \n":       'n',
+	} {
+		var out bytes.Buffer
+		got := askPermission(&out, bufio.NewReader(strings.NewReader(input)), req)
+		require.Equal(t, string(want), string(got), "input %q", input)
+		require.Contains(t, out.String(), "Permission requested: bash (execute)")
+		require.Contains(t, out.String(), "go test ./...")
+	}
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// runPlan runs prompt in plan mode. Once the agent has written a plan, it
+// asks on the terminal whether to carry it out. Without a terminal, the run
+// stops after planning.
+func (app *App) runPlan(ctx context.Context, sessionID, prompt string) (*fantasy.AgentResult, error) {
+	app.AgentCoordinator.SetPlanMode(sessionID, true)
+	result, err := app.AgentCoordinator.Run(ctx, sessionID, prompt)
+	if err != nil {
+		return result, err
+	}
+
+	sess, err := app.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return result, fmt.Errorf("failed to get session: %w", err)
+	}
+	if len(sess.Todos) == 0 {
+		slog.Warn("The agent wrote no plan to approve", "session_id", sessionID)
+		return result, nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		slog.Info("No terminal to approve the plan on, stopping after planning", "error", err)
+		return result, nil
+	}
+	defer tty.Close()
+	if !askPlanApproval(tty, bufio.NewReader(tty), sess.Todos) {
+		return result, nil
+	}
+	return app.AgentCoordinator.ApprovePlan(ctx, sessionID)
+}
+
+// askPlanApproval shows the plan on w and reads from r whether to carry it
+// out. Reading nothing rejects the plan.
+func askPlanApproval(w io.Writer, r *bufio.Reader, todos []session.Todo) bool {
+	fmt.Fprintln(w, "\nPlan:")
+	for i, todo := range todos {
+		fmt.Fprintf(w, "  %d. %s\n", i+1, todo.Content)
+	}
+	for {
+		fmt.Fprint(w, "Carry out this plan? [y]es, [n]o: ")
+		line, err := r.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+		if err != nil {
+			fmt.Fprintln(w)
+			return false
+		}
+	}
+}
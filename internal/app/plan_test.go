@@ -0,0 +1,33 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAskPlanApproval(t *testing.T) {
+	todos := []session.Todo{
+		{Content: "Add the flag", Status: session.TodoStatusPending},
+		{Content: "Write the tests", Status: session.TodoStatusPending},
+	}
+
+	for input, want := range map[string]bool{
+		"y\n":          true,
+		"YES\n":        true,
+		"n\n":          false,
+		"later\nyes\n": true,
+		"":             false,
+		"what\n":       false,
+		"y":            true,
+	} {
+		var out bytes.Buffer
+		got := askPlanApproval(&out, bufio.NewReader(strings.NewReader(input)), todos)
+		require.Equal(t, want, got, "input %q", input)
+		require.Contains(t, out.String(), "  1. Add the flag\n  2. Write the tests\n")
+	}
+}
@@ -0,0 +1,252 @@
+// Package checkpoint rewinds a session, and every file its agent touched, to
+// the state it was in right before a given user message.
+//
+// Each file version in [history.Service] is tagged with the assistant message
+// that produced it. Rewinding to a user message therefore means restoring
+// every file to the content it had before the first version written from that
+// message onwards, and then truncating the conversation at that message.
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// ErrNoCheckpoint is returned when there is no user message at or before the
+// requested message, so there is no turn to rewind to.
+var ErrNoCheckpoint = errors.New("no checkpoint at or before message")
+
+// ErrMessageNotFound is returned when the message does not belong to the
+// session.
+var ErrMessageNotFound = errors.New("message not found in session")
+
+// Checkpoint is a point in a session that can be rewound to.
+type Checkpoint struct {
+	SessionID string
+	MessageID string
+	Prompt    string
+	CreatedAt int64
+	// Files holds the paths modified by the agent from this checkpoint
+	// onwards.
+	Files []string
+}
+
+// RewindResult describes what a rewind changed.
+type RewindResult struct {
+	// Prompt is the text of the user message that was rewound, so callers
+	// can offer it for editing.
+	Prompt          string
+	RestoredFiles   []string
+	DeletedFiles    []string
+	RemovedMessages int
+}
+
+// Service lists and restores session checkpoints.
+type Service interface {
+	List(ctx context.Context, sessionID string) ([]Checkpoint, error)
+	Rewind(ctx context.Context, sessionID, messageID string) (RewindResult, error)
+}
+
+type service struct {
+	sessions session.Service
+	messages message.Service
+	history  history.Service
+}
+
+// NewService creates a new checkpoint service.
+func NewService(sessions session.Service, messages message.Service, history history.Service) Service {
+	return &service{
+		sessions: sessions,
+		messages: messages,
+		history:  history,
+	}
+}
+
+// List returns a checkpoint for every user message in the session, oldest
+// first.
+func (s *service) List(ctx context.Context, sessionID string) ([]Checkpoint, error) {
+	msgs, err := s.messages.List(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("listing messages: %w", err)
+	}
+	files, err := s.sessionFiles(ctx, sessionID, msgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoints []Checkpoint
+	for i, msg := range msgs {
+		if msg.Role != message.User {
+			continue
+		}
+		span := messageIDs(msgs[i:])
+		var paths []string
+		for _, f := range files {
+			if _, ok := span[f.MessageID]; ok && !slices.Contains(paths, f.Path) {
+				paths = append(paths, f.Path)
+			}
+		}
+		checkpoints = append(checkpoints, Checkpoint{
+			SessionID: sessionID,
+			MessageID: msg.ID,
+			Prompt:    msg.Content().Text,
+			CreatedAt: msg.CreatedAt,
+			Files:     paths,
+		})
+	}
+	return checkpoints, nil
+}
+
+// Rewind restores every file touched from the given user message onwards to
+// the content it had before that message, deletes files the agent created in
+// that span, and removes the message and everything after it from the
+// session. If messageID points to an assistant or tool message, the session is
+// rewound to the start of the turn it belongs to.
+//
+// The caller must make sure the session is not busy.
+func (s *service) Rewind(ctx context.Context, sessionID, messageID string) (RewindResult, error) {
+	msgs, err := s.messages.List(ctx, sessionID)
+	if err != nil {
+		return RewindResult{}, fmt.Errorf("listing messages: %w", err)
+	}
+	idx := slices.IndexFunc(msgs, func(m message.Message) bool {
+		return m.ID == messageID
+	})
+	if idx == -1 {
+		return RewindResult{}, ErrMessageNotFound
+	}
+	for idx >= 0 && msgs[idx].Role != message.User {
+		idx--
+	}
+	if idx < 0 {
+		return RewindResult{}, ErrNoCheckpoint
+	}
+
+	removed := msgs[idx:]
+	span := messageIDs(removed)
+	result := RewindResult{
+		Prompt: msgs[idx].Content().Text,
+	}
+
+	files, err := s.sessionFiles(ctx, sessionID, removed)
+	if err != nil {
+		return RewindResult{}, err
+	}
+
+	// Group versions by path, keeping their order.
+	var paths []string
+	versions := make(map[string][]history.File)
+	for _, f := range files {
+		if _, ok := versions[f.Path]; !ok {
+			paths = append(paths, f.Path)
+		}
+		versions[f.Path] = append(versions[f.Path], f)
+	}
+
+	for _, path := range paths {
+		fileVersions := versions[path]
+		first := slices.IndexFunc(fileVersions, func(f history.File) bool {
+			_, ok := span[f.MessageID]
+			return ok
+		})
+		if first == -1 {
+			continue
+		}
+
+		// The first version written in the span is always a snapshot of the
+		// file as it was before the span started, marked as new if the agent
+		// created the file.
+		snapshot := fileVersions[first]
+		if snapshot.IsNew {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return result, fmt.Errorf("deleting %s: %w", path, err)
+			}
+			result.DeletedFiles = append(result.DeletedFiles, path)
+		} else {
+			if err := os.WriteFile(path, []byte(snapshot.Content), 0o644); err != nil {
+				return result, fmt.Errorf("restoring %s: %w", path, err)
+			}
+			result.RestoredFiles = append(result.RestoredFiles, path)
+		}
+
+		for _, f := range fileVersions[first:] {
+			if err := s.history.Delete(ctx, f.ID); err != nil {
+				return result, fmt.Errorf("deleting file version: %w", err)
+			}
+		}
+	}
+
+	// Remove the messages newest first so the UI never shows a reply without
+	// the message that prompted it.
+	for _, msg := range slices.Backward(removed) {
+		for _, tc := range msg.ToolCalls() {
+			childID := s.sessions.CreateAgentToolSessionID(msg.ID, tc.ID)
+			if _, err := s.sessions.Get(ctx, childID); err != nil {
+				continue
+			}
+			if err := s.sessions.Delete(ctx, childID); err != nil {
+				slog.Warn("Failed to delete agent tool session", "session_id", childID, "error", err)
+			}
+		}
+		if err := s.messages.Delete(ctx, msg.ID); err != nil {
+			return result, fmt.Errorf("deleting message: %w", err)
+		}
+		result.RemovedMessages++
+	}
+
+	sess, err := s.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return result, fmt.Errorf("getting session: %w", err)
+	}
+	if _, ok := span[sess.SummaryMessageID]; ok {
+		sess.SummaryMessageID = ""
+		if _, err := s.sessions.Save(ctx, sess); err != nil {
+			return result, fmt.Errorf("saving session: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// sessionFiles returns the file versions of the session along with the ones
+// written by agent tool sub-sessions spawned from the given messages.
+func (s *service) sessionFiles(ctx context.Context, sessionID string, msgs []message.Message) ([]history.File, error) {
+	files, err := s.history.ListBySession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("listing session files: %w", err)
+	}
+	for _, msg := range msgs {
+		for _, tc := range msg.ToolCalls() {
+			childFiles, err := s.history.ListBySession(ctx, s.sessions.CreateAgentToolSessionID(msg.ID, tc.ID))
+			if err != nil {
+				return nil, fmt.Errorf("listing agent session files: %w", err)
+			}
+			// Versions from sub-sessions belong to the message that spawned
+			// them.
+			for i := range childFiles {
+				childFiles[i].MessageID = msg.ID
+			}
+			files = append(files, childFiles...)
+		}
+	}
+	slices.SortStableFunc(files, func(a, b history.File) int {
+		return int(a.CreatedAt - b.CreatedAt)
+	})
+	return files, nil
+}
+
+func messageIDs(msgs []message.Message) map[string]struct{} {
+	ids := make(map[string]struct{}, len(msgs))
+	for _, msg := range msgs {
+		ids[msg.ID] = struct{}{}
+	}
+	return ids
+}
@@ -0,0 +1,174 @@
+package checkpoint
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+type testEnv struct {
+	ctx      context.Context
+	dir      string
+	sessions session.Service
+	messages message.Service
+	history  history.Service
+	svc      Service
+}
+
+func setupTest(t *testing.T) *testEnv {
+	t.Helper()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	sessions := session.NewService(q, conn)
+	messages := message.NewService(q)
+	files := history.NewService(q, conn)
+	return &testEnv{
+		ctx:      t.Context(),
+		dir:      t.TempDir(),
+		sessions: sessions,
+		messages: messages,
+		history:  files,
+		svc:      NewService(sessions, messages, files),
+	}
+}
+
+func (e *testEnv) createMessage(t *testing.T, sessionID string, role message.MessageRole, text string) message.Message {
+	t.Helper()
+	msg, err := e.messages.Create(e.ctx, sessionID, message.CreateMessageParams{
+		Role:  role,
+		Parts: []message.ContentPart{message.TextContent{Text: text}},
+	})
+	require.NoError(t, err)
+	return msg
+}
+
+// writeFile mimics what the file tools do: snapshot the content before the
+// first change made from a message, then store the new content.
+func (e *testEnv) writeFile(t *testing.T, sessionID, messageID, path, content string) {
+	t.Helper()
+	ctx := history.WithMessageID(e.ctx, messageID)
+
+	oldContent := ""
+	bts, readErr := os.ReadFile(path)
+	if readErr == nil {
+		oldContent = string(bts)
+	}
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	file, err := e.history.GetByPathAndSession(ctx, path, sessionID)
+	if os.IsNotExist(readErr) {
+		_, err = e.history.CreateNew(ctx, sessionID, path)
+		require.NoError(t, err)
+	} else if err != nil {
+		_, err = e.history.Create(ctx, sessionID, path, oldContent)
+		require.NoError(t, err)
+	} else if file.Content != oldContent || file.MessageID != messageID {
+		_, err = e.history.CreateVersion(ctx, sessionID, path, oldContent)
+		require.NoError(t, err)
+	}
+	_, err = e.history.CreateVersion(ctx, sessionID, path, content)
+	require.NoError(t, err)
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	bts, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(bts)
+}
+
+func TestRewind(t *testing.T) {
+	env := setupTest(t)
+
+	sess, err := env.sessions.Create(env.ctx, "Test Session")
+	require.NoError(t, err)
+
+	existing := filepath.Join(env.dir, "existing.txt")
+	created := filepath.Join(env.dir, "created.txt")
+	empty := filepath.Join(env.dir, "empty.txt")
+	require.NoError(t, os.WriteFile(existing, []byte("original"), 0o644))
+	require.NoError(t, os.WriteFile(empty, nil, 0o644))
+
+	first := env.createMessage(t, sess.ID, message.User, "first prompt")
+	firstReply := env.createMessage(t, sess.ID, message.Assistant, "")
+	env.writeFile(t, sess.ID, firstReply.ID, existing, "first edit")
+	env.writeFile(t, sess.ID, firstReply.ID, created, "new file")
+	env.writeFile(t, sess.ID, firstReply.ID, empty, "no longer empty")
+
+	second := env.createMessage(t, sess.ID, message.User, "second prompt")
+	secondReply := env.createMessage(t, sess.ID, message.Assistant, "")
+	env.writeFile(t, sess.ID, secondReply.ID, existing, "second edit")
+
+	t.Run("lists a checkpoint per user message", func(t *testing.T) {
+		checkpoints, err := env.svc.List(env.ctx, sess.ID)
+		require.NoError(t, err)
+		require.Len(t, checkpoints, 2)
+		require.Equal(t, first.ID, checkpoints[0].MessageID)
+		require.Equal(t, "first prompt", checkpoints[0].Prompt)
+		require.ElementsMatch(t, []string{existing, created, empty}, checkpoints[0].Files)
+		require.Equal(t, second.ID, checkpoints[1].MessageID)
+		require.Equal(t, []string{existing}, checkpoints[1].Files)
+	})
+
+	t.Run("rewinds to the second prompt", func(t *testing.T) {
+		result, err := env.svc.Rewind(env.ctx, sess.ID, secondReply.ID)
+		require.NoError(t, err)
+		require.Equal(t, "second prompt", result.Prompt)
+		require.Equal(t, []string{existing}, result.RestoredFiles)
+		require.Empty(t, result.DeletedFiles)
+		require.Equal(t, 2, result.RemovedMessages)
+
+		require.Equal(t, "first edit", readFile(t, existing))
+		require.Equal(t, "new file", readFile(t, created))
+
+		msgs, err := env.messages.List(env.ctx, sess.ID)
+		require.NoError(t, err)
+		require.Len(t, msgs, 2)
+
+		latest, err := env.history.GetByPathAndSession(env.ctx, existing, sess.ID)
+		require.NoError(t, err)
+		require.Equal(t, "first edit", latest.Content)
+	})
+
+	t.Run("rewinds to the first prompt", func(t *testing.T) {
+		result, err := env.svc.Rewind(env.ctx, sess.ID, first.ID)
+		require.NoError(t, err)
+		require.Equal(t, "first prompt", result.Prompt)
+		require.ElementsMatch(t, []string{existing, empty}, result.RestoredFiles)
+		require.Equal(t, []string{created}, result.DeletedFiles)
+		require.Equal(t, 2, result.RemovedMessages)
+
+		require.Equal(t, "original", readFile(t, existing))
+		require.NoFileExists(t, created)
+		require.Empty(t, readFile(t, empty))
+
+		msgs, err := env.messages.List(env.ctx, sess.ID)
+		require.NoError(t, err)
+		require.Empty(t, msgs)
+
+		files, err := env.history.ListBySession(env.ctx, sess.ID)
+		require.NoError(t, err)
+		require.Empty(t, files)
+	})
+}
+
+func TestRewind_MessageNotFound(t *testing.T) {
+	env := setupTest(t)
+
+	sess, err := env.sessions.Create(env.ctx, "Test Session")
+	require.NoError(t, err)
+
+	_, err = env.svc.Rewind(env.ctx, sess.ID, "nonexistent")
+	require.ErrorIs(t, err, ErrMessageNotFound)
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"charm.land/lipgloss/v2"
+	"charm.land/lipgloss/v2/table"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the permission audit log",
+	Long: `Show every permission decision made for the agent in the current project:
+which tool asked to do what, whether it was allowed, and what decided it (the
+user, a session grant, the allowlist, yolo mode, auto-approval or a rule).`,
+	Example: `
+# Show the audit log of all sessions
+crush audit
+
+# Show the audit log of a single session
+crush audit --session 1f1b7c6e-2a4f-4c1e-9a53-1f8f0b5c2d3e
+
+# Output the audit log as JSON
+crush audit --json
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+		sessionID, _ := cmd.Flags().GetString("session")
+
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		entries, err := permission.ListAudit(cmd.Context(), db.New(conn), sessionID)
+		if err != nil {
+			return err
+		}
+
+		if jsonOutput {
+			output := struct {
+				Entries []permission.AuditEntry `json:"entries"`
+			}{Entries: entries}
+
+			data, err := json.Marshal(output)
+			if err != nil {
+				return err
+			}
+			cmd.Println(string(data))
+			return nil
+		}
+
+		if len(entries) == 0 {
+			cmd.Println("No permission decisions recorded yet.")
+			return nil
+		}
+
+		if term.IsTerminal(os.Stdout.Fd()) {
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 1)
+				}).
+				Headers("Time", "Session", "Tool", "Action", "Path", "Decision", "Source")
+
+			for _, e := range entries {
+				t.Row(
+					time.Unix(e.CreatedAt, 0).Local().Format("2006-01-02 15:04:05"),
+					shortID(e.SessionID),
+					e.ToolName,
+					e.Action,
+					e.Path,
+					auditDecision(e),
+					string(e.Source),
+				)
+			}
+			lipgloss.Println(t)
+			return nil
+		}
+
+		for _, e := range entries {
+			cmd.Printf(
+				"%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				time.Unix(e.CreatedAt, 0).Format(time.RFC3339),
+				e.SessionID,
+				e.ToolName,
+				e.Action,
+				e.Path,
+				auditDecision(e),
+				e.Source,
+			)
+		}
+		return nil
+	},
+}
+
+func init() {
+	auditCmd.Flags().String("session", "", "Only show decisions of the given session")
+	auditCmd.Flags().Bool("json", false, "Output as JSON")
+}
+
+func auditDecision(e permission.AuditEntry) string {
+	if e.Granted {
+		return "granted"
+	}
+	return "denied"
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
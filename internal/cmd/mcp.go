@@ -0,0 +1,494 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	"charm.land/lipgloss/v2"
+	"charm.land/lipgloss/v2/table"
+	"github.com/charmbracelet/crush/internal/agent/tools/mcp"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/event"
+	"github.com/charmbracelet/crush/internal/mcpserver"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Work with the Model Context Protocol",
+	Long: `Manage the MCP servers Crush connects to, and serve Crush to other agents and
+editors over the Model Context Protocol.
+
+Changes to the MCP servers of the configuration files apply to running
+instances of Crush right away.`,
+}
+
+var mcpServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve Crush as an MCP server",
+	Long: `Serve the built-in tools of Crush to other agents and editors over the Model
+Context Protocol: view, edit, multiedit, grep, glob, ls and, with LSPs,
+lsp_diagnostics and lsp_references.
+
+The server speaks over stdin and stdout by default. Use --http to serve the
+streamable HTTP transport instead. HTTP clients must send a bearer token: set
+it with --token (or CRUSH_SERVER_TOKEN), or use the one generated and printed
+on startup. Requests whose Host or Origin is not a loopback address are
+rejected.
+
+Tools run without asking for permission, as the client asks its user before
+calling them. With --task, the run_crush_task tool has the Crush agent carry
+out a task, and the permission requests of the agent are forwarded to the
+client. Clients that can't answer them only get what the permission rules and
+allowed tools allow.`,
+	Example: `
+# Serve over stdio, e.g. from the MCP configuration of an editor
+crush mcp serve
+
+# Also let clients hand tasks to the Crush agent
+crush mcp serve --task
+
+# Serve over HTTP
+crush mcp serve --http 127.0.0.1:7422
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		task, _ := cmd.Flags().GetBool("task")
+		addr, _ := cmd.Flags().GetString("http")
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv("CRUSH_SERVER_TOKEN")
+		}
+		generated := addr != "" && token == ""
+		if generated {
+			token = rand.Text()
+		}
+
+		ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, os.Kill)
+		defer cancel()
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		event.SetNonInteractive(true)
+		event.AppInitialized()
+
+		srv := mcpserver.New(app, mcpserver.Options{Task: task, Token: token})
+		if addr == "" {
+			return srv.ServeStdio(ctx)
+		}
+
+		ln, err := listen(ctx, addr, "")
+		if err != nil {
+			return err
+		}
+		defer ln.Close()
+
+		cmd.PrintErrf("Listening on %s\n", ln.Addr())
+		if generated {
+			cmd.PrintErrf("Token: %s\n", token)
+		}
+		return srv.Serve(ctx, ln)
+	},
+	PostRun: func(cmd *cobra.Command, args []string) {
+		event.AppExited()
+	},
+}
+
+var mcpListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the configured MCP servers",
+	Long:  "List the MCP servers of the configuration, along with the file that configures each of them.",
+	Example: `
+# List the MCP servers in a table
+crush mcp list
+
+# Output the MCP servers as JSON
+crush mcp list --json
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		mcps, err := config.LoadMCP(cwd)
+		if err != nil {
+			return err
+		}
+
+		if jsonOutput {
+			data, err := json.Marshal(struct {
+				MCP config.MCPs `json:"mcp"`
+			}{MCP: mcps})
+			if err != nil {
+				return err
+			}
+			cmd.Println(string(data))
+			return nil
+		}
+
+		if len(mcps) == 0 {
+			cmd.Println("No MCP servers configured.")
+			return nil
+		}
+
+		type row struct {
+			name, kind, target, status, source string
+		}
+		var rows []row
+		for _, m := range mcps.Sorted() {
+			r := row{
+				name:   m.Name,
+				kind:   string(cmp.Or(m.MCP.Type, config.MCPStdio)),
+				target: m.MCP.URL,
+				status: "enabled",
+			}
+			if r.target == "" {
+				r.target = strings.Join(append([]string{m.MCP.Command}, m.MCP.Args...), " ")
+			}
+			if m.MCP.Disabled {
+				r.status = "disabled"
+			}
+			if files := config.MCPConfigFiles(cwd, m.Name); len(files) > 0 {
+				r.source = files[len(files)-1]
+			}
+			rows = append(rows, r)
+		}
+
+		if term.IsTerminal(os.Stdout.Fd()) {
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 2)
+				}).
+				Headers("Name", "Type", "Command or URL", "Status", "Config")
+			for _, r := range rows {
+				t.Row(r.name, r.kind, r.target, r.status, r.source)
+			}
+			lipgloss.Println(t)
+			return nil
+		}
+
+		for _, r := range rows {
+			cmd.Printf("%s\t%s\t%s\t%s\t%s\n", r.name, r.kind, r.target, r.status, r.source)
+		}
+		return nil
+	},
+}
+
+var mcpAddCmd = &cobra.Command{
+	Use:   "add <name> <command or url> [args...]",
+	Short: "Add an MCP server",
+	Long: `Add an MCP server to the project configuration, or to the global one with
+--global.
+
+Servers given a URL use the streamable HTTP transport, unless --type says
+otherwise. Servers given a command run it, with the rest of the arguments,
+and talk to it over stdin and stdout. Flags go before the name.`,
+	Example: `
+# Add a server that runs a command
+crush mcp add filesystem npx -y @modelcontextprotocol/server-filesystem .
+
+# Add a remote server, for all projects
+crush mcp add --global --header "Authorization=Bearer $API_KEY" github https://api.githubcopilot.com/mcp/
+
+# Add a server that uses server-sent events
+crush mcp add --type sse events http://localhost:3000/sse
+  `,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		global, _ := cmd.Flags().GetBool("global")
+		kind, _ := cmd.Flags().GetString("type")
+		envs, _ := cmd.Flags().GetStringArray("env")
+		headers, _ := cmd.Flags().GetStringArray("header")
+		timeout, _ := cmd.Flags().GetInt("timeout")
+
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+
+		name, target := args[0], args[1]
+		isURL := strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+		if kind == "" {
+			kind = string(config.MCPStdio)
+			if isURL {
+				kind = string(config.MCPHttp)
+			}
+		}
+
+		m := config.MCPConfig{
+			Type:    config.MCPType(kind),
+			Timeout: timeout,
+		}
+		switch m.Type {
+		case config.MCPStdio:
+			m.Command = target
+			m.Args = args[2:]
+			if m.Env, err = parseKeyValues("env", envs); err != nil {
+				return err
+			}
+		case config.MCPHttp, config.MCPSSE:
+			if !isURL {
+				return fmt.Errorf("mcp %s servers need an http or https URL, got %q", m.Type, target)
+			}
+			if len(args) > 2 {
+				return fmt.Errorf("mcp %s servers take no arguments", m.Type)
+			}
+			m.URL = target
+			if m.Headers, err = parseKeyValues("header", headers); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown mcp type %q: use stdio, http or sse", kind)
+		}
+
+		path := config.ProjectConfig(cwd)
+		if global {
+			path = config.GlobalConfig()
+		}
+		if slices.Contains(config.MCPConfigFiles(cwd, name), path) {
+			return fmt.Errorf("mcp '%s' already exists in %s: remove it first", name, path)
+		}
+		if err := config.SetFileField(path, config.MCPField(name), m); err != nil {
+			return err
+		}
+		cmd.Printf("Added MCP server %q to %s\n", name, path)
+		return nil
+	},
+}
+
+var mcpRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove an MCP server",
+	Long: `Remove an MCP server from the configuration files that configure it, or only
+from the global ones with --global.`,
+	Example: `
+# Remove a server
+crush mcp remove filesystem
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		global, _ := cmd.Flags().GetBool("global")
+
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+
+		name := args[0]
+		files := config.MCPConfigFiles(cwd, name)
+		if global {
+			files = slices.DeleteFunc(files, func(path string) bool {
+				return path != config.GlobalConfig() && path != config.GlobalConfigData()
+			})
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("mcp '%s' not found", name)
+		}
+		for _, path := range files {
+			if err := config.RemoveFileField(path, config.MCPField(name)); err != nil {
+				return err
+			}
+			cmd.Printf("Removed MCP server %q from %s\n", name, path)
+		}
+		return nil
+	},
+}
+
+var mcpTestCmd = &cobra.Command{
+	Use:   "test <name>",
+	Short: "Check that an MCP server works",
+	Long:  "Connect to an MCP server, even a disabled one, and report what it offers.",
+	Example: `
+# Check a server
+crush mcp test filesystem
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, os.Kill)
+		defer cancel()
+
+		cfg, err := mcpConfig(cmd)
+		if err != nil {
+			return err
+		}
+		defer mcp.Close(context.Background())
+
+		name := args[0]
+		start := time.Now()
+		info, tools, err := probeMCP(ctx, cfg, name)
+		if err != nil {
+			return err
+		}
+		cmd.Printf(
+			"MCP server %q connected in %s: %d tools (%d enabled), %d prompts, %d resources\n",
+			name,
+			time.Since(start).Round(time.Millisecond),
+			len(tools),
+			info.Counts.Tools,
+			info.Counts.Prompts,
+			info.Counts.Resources,
+		)
+		return nil
+	},
+}
+
+var mcpToolsCmd = &cobra.Command{
+	Use:   "tools <name>",
+	Short: "List the tools of an MCP server",
+	Long:  "Connect to an MCP server, even a disabled one, and list its tools, including the disabled ones.",
+	Example: `
+# List the tools of a server
+crush mcp tools filesystem
+
+# Output the tools as JSON
+crush mcp tools filesystem --json
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+
+		ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, os.Kill)
+		defer cancel()
+
+		cfg, err := mcpConfig(cmd)
+		if err != nil {
+			return err
+		}
+		defer mcp.Close(context.Background())
+
+		name := args[0]
+		_, tools, err := probeMCP(ctx, cfg, name)
+		if err != nil {
+			return err
+		}
+		slices.SortFunc(tools, func(a, b *mcp.Tool) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+
+		if jsonOutput {
+			data, err := json.Marshal(struct {
+				Tools []*mcp.Tool `json:"tools"`
+			}{Tools: tools})
+			if err != nil {
+				return err
+			}
+			cmd.Println(string(data))
+			return nil
+		}
+
+		if len(tools) == 0 {
+			cmd.Printf("MCP server %q has no tools.\n", name)
+			return nil
+		}
+
+		disabled := cfg.MCP[name].DisabledTools
+		status := func(tool *mcp.Tool) string {
+			if slices.Contains(disabled, tool.Name) {
+				return "disabled"
+			}
+			return "enabled"
+		}
+		description := func(tool *mcp.Tool) string {
+			first, _, _ := strings.Cut(strings.TrimSpace(tool.Description), "\n")
+			return first
+		}
+
+		if term.IsTerminal(os.Stdout.Fd()) {
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 2)
+				}).
+				Headers("Tool", "Status", "Description")
+			for _, tool := range tools {
+				t.Row(tool.Name, status(tool), description(tool))
+			}
+			lipgloss.Println(t)
+			return nil
+		}
+
+		for _, tool := range tools {
+			cmd.Printf("%s\t%s\t%s\n", tool.Name, status(tool), description(tool))
+		}
+		return nil
+	},
+}
+
+// mcpConfig loads the configuration the MCP servers are started with.
+func mcpConfig(cmd *cobra.Command) (*config.Config, error) {
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	dataDir, _ := cmd.Flags().GetString("data-dir")
+	debug, _ := cmd.Flags().GetBool("debug")
+	return config.Init(cwd, dataDir, debug)
+}
+
+// probeMCP connects to an MCP server, explaining how to authorize it when it
+// asks to.
+func probeMCP(ctx context.Context, cfg *config.Config, name string) (mcp.ClientInfo, []*mcp.Tool, error) {
+	info, tools, err := mcp.Probe(ctx, cfg, name)
+	if errors.Is(err, mcp.ErrNeedsAuth) {
+		return info, nil, fmt.Errorf("mcp '%s' requires authorization: run crush and pick \"Authorize MCP: %s\" in the commands palette", name, name)
+	}
+	if err != nil {
+		return info, nil, fmt.Errorf("mcp '%s' failed: %w", name, err)
+	}
+	return info, tools, nil
+}
+
+// parseKeyValues parses the KEY=VALUE values of a flag.
+func parseKeyValues(flag string, values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]string, len(values))
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --%s %q: use KEY=VALUE", flag, v)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+func init() {
+	mcpServeCmd.Flags().Bool("task", false, "Publish the run_crush_task tool, which has the Crush agent carry out a task")
+	mcpServeCmd.Flags().String("http", "", "Address to serve the streamable HTTP transport on instead of stdio")
+	mcpServeCmd.Flags().String("token", "", "Bearer token HTTP clients must send (default a generated one)")
+
+	mcpListCmd.Flags().Bool("json", false, "Output as JSON")
+
+	// The arguments of the command of the server may look like flags.
+	mcpAddCmd.Flags().SetInterspersed(false)
+	mcpAddCmd.Flags().Bool("global", false, "Add the server to the global configuration")
+	mcpAddCmd.Flags().String("type", "", "Transport of the server: stdio, http or sse (default stdio, or http for URLs)")
+	mcpAddCmd.Flags().StringArray("env", nil, "Environment variable of stdio servers, as KEY=VALUE")
+	mcpAddCmd.Flags().StringArray("header", nil, "HTTP header of http and sse servers, as KEY=VALUE")
+	mcpAddCmd.Flags().Int("timeout", 0, "Timeout in seconds for connecting to the server (default 15)")
+
+	mcpRemoveCmd.Flags().Bool("global", false, "Only remove the server from the global configuration")
+
+	mcpToolsCmd.Flags().Bool("json", false, "Output as JSON")
+
+	mcpCmd.AddCommand(mcpServeCmd, mcpListCmd, mcpAddCmd, mcpRemoveCmd, mcpTestCmd, mcpToolsCmd)
+}
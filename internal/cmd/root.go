@@ -47,6 +47,11 @@ func init() {
 		schemaCmd,
 		loginCmd,
 		statsCmd,
+		sessionCmd,
+		auditCmd,
+		serveCmd,
+		worktreeCmd,
+		mcpCmd,
 	)
 }
 
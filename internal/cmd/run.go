@@ -6,9 +6,11 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 
 	"charm.land/log/v2"
+	"github.com/charmbracelet/crush/internal/app"
 	"github.com/charmbracelet/crush/internal/event"
 	"github.com/spf13/cobra"
 )
@@ -33,27 +35,72 @@ crush run --quiet "Generate a README for this project"
 
 # Run in verbose mode
 crush run --verbose "Generate a README for this project"
+
+# Print newline-delimited JSON events, e.g. for CI pipelines
+crush run --output-format stream-json "Fix the failing tests"
+
+# Follow up on the most recent session
+crush run --continue "Now add tests for it"
+
+# Only allow what the allowed tools and permission rules allow
+crush run --permission-mode allowlist "Fix the failing tests"
+
+# Plan first, and carry out the plan once you approve it
+crush run --plan "Migrate the config loader to the new API"
+
+# Make the changes in a git worktree, to merge later with 'crush worktree merge'
+crush run --worktree "Refactor the HTTP handlers"
+
+# Stop once the run has spent a dollar
+crush run --max-cost 1 "Fix the failing tests"
+
+# Run the prompt with a user-defined agent
+crush run --agent reviewer "Review the staged changes"
+
+# Follow up on a given session
+crush run --session 1f1b7c6e-2a4f-4c1e-9a53-1f8f0b5c2d3e "Now add tests for it"
   `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		quiet, _ := cmd.Flags().GetBool("quiet")
 		verbose, _ := cmd.Flags().GetBool("verbose")
 		largeModel, _ := cmd.Flags().GetString("model")
 		smallModel, _ := cmd.Flags().GetString("small-model")
+		outputFormat, _ := cmd.Flags().GetString("output-format")
+		sessionID, _ := cmd.Flags().GetString("session")
+		continueSession, _ := cmd.Flags().GetBool("continue")
+		permissionMode, _ := cmd.Flags().GetString("permission-mode")
+		agentID, _ := cmd.Flags().GetString("agent")
+		plan, _ := cmd.Flags().GetBool("plan")
+		useWorktree, _ := cmd.Flags().GetBool("worktree")
+		maxCost, _ := cmd.Flags().GetFloat64("max-cost")
+
+		format := app.OutputFormat(outputFormat)
+		if !slices.Contains(app.OutputFormats, format) {
+			return fmt.Errorf("invalid output format %q, expected one of %v", outputFormat, app.OutputFormats)
+		}
+		mode := app.PermissionMode(permissionMode)
+		if !slices.Contains(app.PermissionModes, mode) {
+			return fmt.Errorf("invalid permission mode %q, expected one of %v", permissionMode, app.PermissionModes)
+		}
 
 		// Cancel on SIGINT or SIGTERM.
 		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
 		defer cancel()
 
-		app, err := setupApp(cmd)
+		appInstance, err := setupApp(cmd)
 		if err != nil {
 			return err
 		}
-		defer app.Shutdown()
+		defer appInstance.Shutdown()
 
-		if !app.Config().IsConfigured() {
+		if !appInstance.Config().IsConfigured() {
 			return fmt.Errorf("no providers configured - please run 'crush' to set up a provider interactively")
 		}
 
+		if useWorktree {
+			appInstance.Config().Options.Worktree = true
+		}
+
 		if verbose {
 			slog.SetDefault(slog.New(log.New(os.Stderr)))
 		}
@@ -73,7 +120,19 @@ crush run --verbose "Generate a README for this project"
 		event.SetNonInteractive(true)
 		event.AppInitialized()
 
-		return app.RunNonInteractive(ctx, os.Stdout, prompt, largeModel, smallModel, quiet || verbose)
+		return appInstance.RunNonInteractive(ctx, os.Stdout, app.RunOptions{
+			Prompt:         prompt,
+			LargeModel:     largeModel,
+			SmallModel:     smallModel,
+			HideSpinner:    quiet || verbose,
+			OutputFormat:   format,
+			SessionID:      sessionID,
+			Continue:       continueSession,
+			PermissionMode: mode,
+			Agent:          agentID,
+			Plan:           plan,
+			MaxCost:        maxCost,
+		})
 	},
 	PostRun: func(cmd *cobra.Command, args []string) {
 		event.AppExited()
@@ -85,4 +144,13 @@ func init() {
 	runCmd.Flags().BoolP("verbose", "v", false, "Show logs")
 	runCmd.Flags().StringP("model", "m", "", "Model to use. Accepts 'model' or 'provider/model' to disambiguate models with the same name across providers")
 	runCmd.Flags().String("small-model", "", "Small model to use. If not provided, uses the default small model for the provider")
+	runCmd.Flags().String("output-format", string(app.OutputFormatText), "Output format: text, json or stream-json")
+	runCmd.Flags().String("session", "", "Run the prompt in an existing session")
+	runCmd.Flags().Bool("continue", false, "Run the prompt in the most recent session")
+	runCmd.Flags().String("permission-mode", string(app.PermissionModeAuto), "How to answer permission requests: auto, allowlist, deny or prompt-stdin")
+	runCmd.Flags().String("agent", "", "Agent to run the prompt with, as defined in crush.json or an agent file")
+	runCmd.Flags().Bool("plan", false, "Plan with read-only tools first, and carry out the plan once approved on the terminal")
+	runCmd.Flags().Bool("worktree", false, "Run a new session in its own git worktree instead of the working directory")
+	runCmd.Flags().Float64("max-cost", 0, "Stop once the run has spent this much, in US dollars")
+	runCmd.MarkFlagsMutuallyExclusive("session", "continue")
 }
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/charmbracelet/crush/internal/event"
+	"github.com/charmbracelet/crush/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the Crush API over HTTP",
+	Long: `Run Crush without the TUI and expose it over a local HTTP API, so editors
+and scripts can create sessions, send prompts, answer permission requests and
+follow the agent's progress through Server-Sent Events.
+
+The API listens on localhost by default. Use --socket to listen on a Unix
+socket instead. Clients must send a bearer token: set it with --token (or
+CRUSH_SERVER_TOKEN), or use the one generated and printed on startup.
+Requests whose Host or Origin is not a loopback address are rejected.
+
+Endpoints:
+  GET    /v1/sessions                  list sessions
+  POST   /v1/sessions                  create a session: {"title": "..."}
+  GET    /v1/sessions/{id}             get a session
+  DELETE /v1/sessions/{id}             delete a session
+  GET    /v1/sessions/{id}/messages    list the messages of a session
+  POST   /v1/sessions/{id}/prompt      send a prompt: {"prompt": "..."}
+  POST   /v1/sessions/{id}/cancel      cancel the running prompt
+  GET    /v1/permissions               list pending permission requests
+  POST   /v1/permissions/{id}          answer one: {"decision": "allow|allow_session|deny"}
+  GET    /v1/events                    stream events, optionally ?session_id=...`,
+	Example: `
+# Serve on the default address
+crush serve
+
+# Serve on a Unix socket
+crush serve --socket /tmp/crush.sock
+
+# Send a prompt and follow the events, with the printed token
+curl -H "Authorization: Bearer $TOKEN" -X POST localhost:7421/v1/sessions/$SESSION/prompt -d '{"prompt": "Explain this project"}'
+curl -H "Authorization: Bearer $TOKEN" -N localhost:7421/v1/events?session_id=$SESSION
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, _ := cmd.Flags().GetString("addr")
+		socket, _ := cmd.Flags().GetString("socket")
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv("CRUSH_SERVER_TOKEN")
+		}
+		generated := token == ""
+		if generated {
+			token = rand.Text()
+		}
+
+		ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, os.Kill)
+		defer cancel()
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		ln, err := listen(ctx, addr, socket)
+		if err != nil {
+			return err
+		}
+		defer ln.Close()
+
+		event.SetNonInteractive(true)
+		event.AppInitialized()
+
+		cmd.PrintErrf("Listening on %s\n", ln.Addr())
+		if generated {
+			cmd.PrintErrf("Token: %s\n", token)
+		}
+		return server.New(app, token).Serve(ctx, ln)
+	},
+	PostRun: func(cmd *cobra.Command, args []string) {
+		event.AppExited()
+	},
+}
+
+func init() {
+	serveCmd.Flags().String("addr", "127.0.0.1:7421", "Address to listen on")
+	serveCmd.Flags().String("socket", "", "Unix socket to listen on instead of a TCP address")
+	serveCmd.Flags().String("token", "", "Bearer token clients must send (default a generated one)")
+}
+
+func listen(ctx context.Context, addr, socket string) (net.Listener, error) {
+	var lc net.ListenConfig
+	if socket == "" {
+		ln, err := lc.Listen(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		return ln, nil
+	}
+
+	// Remove the socket left behind by a previous run.
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove %s: %w", socket, err)
+	}
+	ln, err := lc.Listen(ctx, "unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", socket, err)
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to set permissions on %s: %w", socket, err)
+	}
+	return ln, nil
+}
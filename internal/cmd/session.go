@@ -0,0 +1,459 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"charm.land/lipgloss/v2"
+	"charm.land/lipgloss/v2/table"
+	"github.com/charmbracelet/crush/internal/checkpoint"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/transcript"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+)
+
+var sessionCmd = &cobra.Command{
+	Use:     "session",
+	Aliases: []string{"sessions"},
+	Short:   "Manage sessions",
+	Long:    "Manage the sessions stored for the current project",
+}
+
+var sessionListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List sessions",
+	Long:  "List the sessions of the current project, most recently updated first",
+	Example: `
+# List sessions in a table
+crush session list
+
+# Output sessions as JSON
+crush session list --json
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		sessions, err := session.NewService(db.New(conn), conn).List(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+
+		if jsonOutput {
+			output := struct {
+				Sessions []session.Session `json:"sessions"`
+			}{Sessions: sessions}
+
+			data, err := json.Marshal(output)
+			if err != nil {
+				return err
+			}
+			cmd.Println(string(data))
+			return nil
+		}
+
+		if len(sessions) == 0 {
+			cmd.Println("No sessions yet.")
+			return nil
+		}
+
+		if term.IsTerminal(os.Stdout.Fd()) {
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 1)
+				}).
+				Headers("ID", "Title", "Messages", "Cost", "Updated")
+
+			for _, s := range sessions {
+				t.Row(
+					s.ID,
+					s.Title,
+					fmt.Sprint(s.MessageCount),
+					fmt.Sprintf("$%.2f", s.Cost),
+					time.Unix(s.UpdatedAt, 0).Local().Format("2006-01-02 15:04"),
+				)
+			}
+			lipgloss.Println(t)
+			return nil
+		}
+
+		for _, s := range sessions {
+			cmd.Printf("%s\t%s\t%d\t%.4f\t%s\n", s.ID, s.Title, s.MessageCount, s.Cost, time.Unix(s.UpdatedAt, 0).Format(time.RFC3339))
+		}
+		return nil
+	},
+}
+
+var sessionShowCmd = &cobra.Command{
+	Use:   "show <session>",
+	Short: "Show a session",
+	Long:  "Show the details and the messages of a session",
+	Example: `
+# Show a session
+crush session show 1f1b7c6e-2a4f-4c1e-9a53-1f8f0b5c2d3e
+
+# Output a session and its messages as JSON
+crush session show 1f1b7c6e-2a4f-4c1e-9a53-1f8f0b5c2d3e --json
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+		ctx := cmd.Context()
+
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		q := db.New(conn)
+		sess, err := session.NewService(q, conn).Get(ctx, args[0])
+		if err != nil {
+			return fmt.Errorf("session %s not found: %w", args[0], err)
+		}
+		msgs, err := message.NewService(q).List(ctx, sess.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list messages: %w", err)
+		}
+
+		if jsonOutput {
+			output := struct {
+				Session  session.Session   `json:"session"`
+				Messages []message.Message `json:"messages"`
+			}{Session: sess, Messages: msgs}
+
+			data, err := json.Marshal(output)
+			if err != nil {
+				return err
+			}
+			cmd.Println(string(data))
+			return nil
+		}
+
+		cmd.Printf("ID:       %s\n", sess.ID)
+		cmd.Printf("Title:    %s\n", sess.Title)
+		cmd.Printf("Created:  %s\n", time.Unix(sess.CreatedAt, 0).Local().Format(time.DateTime))
+		cmd.Printf("Updated:  %s\n", time.Unix(sess.UpdatedAt, 0).Local().Format(time.DateTime))
+		cmd.Printf("Messages: %d\n", sess.MessageCount)
+		cmd.Printf("Tokens:   %d prompt, %d completion\n", sess.PromptTokens, sess.CompletionTokens)
+		cmd.Printf("Cost:     $%.4f\n", sess.Cost)
+		for _, msg := range msgs {
+			cmd.Printf("\n[%s]\n%s\n", msg.Role, messageSummary(msg))
+		}
+		return nil
+	},
+}
+
+var sessionDeleteCmd = &cobra.Command{
+	Use:   "delete <session>...",
+	Short: "Delete sessions",
+	Long:  "Delete sessions along with their messages and file history",
+	Example: `
+# Delete a session
+crush session delete 1f1b7c6e-2a4f-4c1e-9a53-1f8f0b5c2d3e
+  `,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		sessions := session.NewService(db.New(conn), conn)
+		for _, id := range args {
+			if err := sessions.Delete(cmd.Context(), id); err != nil {
+				return fmt.Errorf("failed to delete session %s: %w", id, err)
+			}
+			cmd.Printf("deleted %s\n", id)
+		}
+		return nil
+	},
+}
+
+var sessionRewindCmd = &cobra.Command{
+	Use:   "rewind <session> <message>",
+	Short: "Rewind a session to a checkpoint",
+	Long: `Rewind a session to the point right before the given message.
+
+Every file the agent touched from that message onwards is restored to its
+previous content, files created by the agent are deleted, and the message and
+everything after it are removed from the session.
+
+Sessions the agent is still working on are not rewound. Use --force for a
+session left unfinished by a run that no longer exists, e.g. after a crash.`,
+	Example: `
+# Rewind a session to before a given message
+crush session rewind 1f1b7c6e-2a4f-4c1e-9a53-1f8f0b5c2d3e 9a8e0c1d-4b2f-4d3a-8c7e-6f5d4c3b2a1f
+  `,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		q := db.New(conn)
+		sessions := session.NewService(q, conn)
+		messages := message.NewService(q)
+		checkpoints := checkpoint.NewService(
+			sessions,
+			messages,
+			history.NewService(q, conn),
+		)
+
+		if _, err := sessions.Get(ctx, args[0]); err != nil {
+			return fmt.Errorf("session %s not found: %w", args[0], err)
+		}
+
+		force, _ := cmd.Flags().GetBool("force")
+		if !force {
+			msgs, err := messages.List(ctx, args[0])
+			if err != nil {
+				return fmt.Errorf("failed to get session messages: %w", err)
+			}
+			if sessionBusy(msgs) {
+				return fmt.Errorf("session %s is busy: wait for the agent to finish or cancel it first", args[0])
+			}
+		}
+
+		result, err := checkpoints.Rewind(ctx, args[0], args[1])
+		if err != nil {
+			return fmt.Errorf("failed to rewind session: %w", err)
+		}
+
+		for _, path := range result.RestoredFiles {
+			cmd.Printf("restored %s\n", path)
+		}
+		for _, path := range result.DeletedFiles {
+			cmd.Printf("deleted  %s\n", path)
+		}
+		cmd.Printf(
+			"Rewound session: %d file(s) restored, %d file(s) deleted, %d message(s) removed.\n",
+			len(result.RestoredFiles),
+			len(result.DeletedFiles),
+			result.RemovedMessages,
+		)
+		return nil
+	},
+}
+
+// sessionBusy reports whether the agent is working on a session, possibly in
+// another process, from its messages: the last assistant message is still
+// being generated, or is waiting for its tool calls to run.
+func sessionBusy(msgs []message.Message) bool {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		msg := msgs[i]
+		if msg.Role != message.Assistant {
+			continue
+		}
+		return !msg.IsFinished() || msg.FinishReason() == message.FinishReasonToolUse
+	}
+	return false
+}
+
+var sessionExportCmd = &cobra.Command{
+	Use:   "export <session>",
+	Short: "Export a session",
+	Long: `Export the full transcript of a session: its messages, tool calls and
+results, reasoning, file history, usage and the sessions of its sub-agents.
+
+The JSON form can be imported back with 'crush session import'. The Markdown
+and HTML forms are meant for sharing, e.g. in code reviews or bug reports.`,
+	Example: `
+# Export a session as JSON
+crush session export 1f1b7c6e-2a4f-4c1e-9a53-1f8f0b5c2d3e > session.json
+
+# Export a session as a web page
+crush session export 1f1b7c6e-2a4f-4c1e-9a53-1f8f0b5c2d3e --format html --output session.html
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		formatFlag, _ := cmd.Flags().GetString("format")
+		outputPath, _ := cmd.Flags().GetString("output")
+		ctx := cmd.Context()
+
+		format := transcript.Format(formatFlag)
+		if !slices.Contains(transcript.Formats, format) {
+			return fmt.Errorf("invalid format %q, expected one of %v", formatFlag, transcript.Formats)
+		}
+
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		q := db.New(conn)
+		t, err := transcript.Export(
+			ctx,
+			session.NewService(q, conn),
+			message.NewService(q),
+			history.NewService(q, conn),
+			cwd,
+			args[0],
+		)
+		if err != nil {
+			return fmt.Errorf("failed to export session %s: %w", args[0], err)
+		}
+
+		w := cmd.OutOrStdout()
+		if outputPath != "" {
+			f, err := os.Create(outputPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		switch format {
+		case transcript.FormatMarkdown:
+			err = transcript.Markdown(w, t)
+		case transcript.FormatHTML:
+			err = transcript.HTML(w, t)
+		default:
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(t)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write transcript: %w", err)
+		}
+		return nil
+	},
+}
+
+var sessionImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import a session",
+	Long: `Import a session exported with 'crush session export' as JSON into the
+current project. The transcript is read from the given file, or from stdin.
+
+The files the session touched are moved from the exported project to the
+current one, so the session can be rewound here.`,
+	Example: `
+# Import a session from a file
+crush session import session.json
+
+# Import a session from stdin
+cat session.json | crush session import
+  `,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r := cmd.InOrStdin()
+		if len(args) == 1 && args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		var t transcript.Transcript
+		if err := json.NewDecoder(r).Decode(&t); err != nil {
+			return fmt.Errorf("failed to read transcript: %w", err)
+		}
+
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		sess, err := transcript.Import(cmd.Context(), conn, cwd, t)
+		if err != nil {
+			return fmt.Errorf("failed to import session: %w", err)
+		}
+		cmd.Printf("imported %s as %s\n", sess.Title, sess.ID)
+		return nil
+	},
+}
+
+func init() {
+	sessionListCmd.Flags().Bool("json", false, "Output as JSON")
+	sessionRewindCmd.Flags().Bool("force", false, "Rewind even if the session looks busy")
+	sessionShowCmd.Flags().Bool("json", false, "Output as JSON")
+	sessionExportCmd.Flags().StringP("format", "f", string(transcript.FormatJSON), "Export format: json, markdown or html")
+	sessionExportCmd.Flags().StringP("output", "o", "", "Write to the given file instead of stdout")
+	sessionCmd.AddCommand(
+		sessionListCmd,
+		sessionShowCmd,
+		sessionDeleteCmd,
+		sessionRewindCmd,
+		sessionExportCmd,
+		sessionImportCmd,
+	)
+}
+
+// messageSummary renders the text of a message and a line per tool call or
+// tool result.
+func messageSummary(msg message.Message) string {
+	var lines []string
+	if text := strings.TrimSpace(msg.Content().Text); text != "" {
+		lines = append(lines, text)
+	}
+	for _, tc := range msg.ToolCalls() {
+		lines = append(lines, fmt.Sprintf("-> %s %s", tc.Name, tc.Input))
+	}
+	for _, tr := range msg.ToolResults() {
+		status := "ok"
+		if tr.IsError {
+			status = "error"
+		}
+		lines = append(lines, fmt.Sprintf("<- %s (%s)", tr.Name, status))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// connectDB opens the database of the project in the current working
+// directory without starting the rest of the application.
+func connectDB(cmd *cobra.Command) (*sql.DB, error) {
+	debug, _ := cmd.Flags().GetBool("debug")
+	dataDir, _ := cmd.Flags().GetString("data-dir")
+
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.Init(cwd, dataDir, debug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize config: %w", err)
+	}
+
+	conn, err := db.Connect(cmd.Context(), cfg.Options.DataDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return conn, nil
+}
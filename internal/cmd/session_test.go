@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionBusy(t *testing.T) {
+	assistant := func(reason message.FinishReason) message.Message {
+		msg := message.Message{Role: message.Assistant}
+		if reason != "" {
+			msg.AddFinish(reason, "", "")
+		}
+		return msg
+	}
+	user := message.Message{Role: message.User}
+	tool := message.Message{Role: message.Tool}
+
+	tests := []struct {
+		name string
+		msgs []message.Message
+		want bool
+	}{
+		{name: "empty"},
+		{name: "waiting for the first answer", msgs: []message.Message{user}},
+		{name: "finished", msgs: []message.Message{user, assistant(message.FinishReasonEndTurn)}},
+		{name: "canceled", msgs: []message.Message{user, assistant(message.FinishReasonCanceled), tool}},
+		{name: "generating", msgs: []message.Message{user, assistant("")}, want: true},
+		{name: "running tools", msgs: []message.Message{user, assistant(message.FinishReasonToolUse)}, want: true},
+		{name: "between steps", msgs: []message.Message{user, assistant(message.FinishReasonToolUse), tool}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, sessionBusy(tt.msgs))
+		})
+	}
+}
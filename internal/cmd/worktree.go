@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"charm.land/lipgloss/v2"
+	"charm.land/lipgloss/v2/table"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/worktree"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+)
+
+var worktreeCmd = &cobra.Command{
+	Use:     "worktree",
+	Aliases: []string{"worktrees"},
+	Short:   "Manage session worktrees",
+	Long: `Manage the git worktrees of the sessions of the current project.
+
+Sessions started with the worktree option on, or with 'crush run --worktree',
+make their changes in a git worktree of their own, on a branch named after the
+session. Once a session is done, merge its changes into the current branch or
+discard them.`,
+}
+
+var worktreeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List session worktrees",
+	Long:  "List the sessions of the current project that have a worktree",
+	Example: `
+# List session worktrees
+crush worktree list
+
+# Output session worktrees as JSON
+crush worktree list --json
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+
+		worktrees, err := worktreeManager(cmd)
+		if err != nil {
+			return err
+		}
+		list, err := worktrees.List(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to list worktrees: %w", err)
+		}
+
+		if jsonOutput {
+			output := struct {
+				Worktrees []worktree.Worktree `json:"worktrees"`
+			}{Worktrees: list}
+
+			data, err := json.Marshal(output)
+			if err != nil {
+				return err
+			}
+			cmd.Println(string(data))
+			return nil
+		}
+
+		if len(list) == 0 {
+			cmd.Println("No session worktrees.")
+			return nil
+		}
+
+		if term.IsTerminal(os.Stdout.Fd()) {
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 1)
+				}).
+				Headers("Session", "Branch", "Path")
+
+			for _, wt := range list {
+				t.Row(wt.SessionID, wt.Branch, wt.Path)
+			}
+			lipgloss.Println(t)
+			return nil
+		}
+
+		for _, wt := range list {
+			cmd.Printf("%s\t%s\t%s\n", wt.SessionID, wt.Branch, wt.Path)
+		}
+		return nil
+	},
+}
+
+var worktreeDiffCmd = &cobra.Command{
+	Use:   "diff <session>",
+	Short: "Show the changes of a session worktree",
+	Long:  "Show the changes made in the worktree of a session since it branched off, including the ones not committed yet",
+	Example: `
+# Review the changes of a session
+crush worktree diff 1f1b7c6e-2a4f-4c1e-9a53-1f8f0b5c2d3e
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		worktrees, err := worktreeManager(cmd)
+		if err != nil {
+			return err
+		}
+		diff, err := worktrees.Diff(cmd.Context(), args[0])
+		if err != nil {
+			return fmt.Errorf("failed to diff worktree of session %s: %w", args[0], err)
+		}
+		if diff != "" {
+			cmd.Println(diff)
+		}
+		return nil
+	},
+}
+
+var worktreeMergeCmd = &cobra.Command{
+	Use:   "merge <session>",
+	Short: "Merge a session worktree",
+	Long: `Commit the pending changes in the worktree of a session, merge its branch
+into the current branch of the project and remove the worktree.
+
+If the merge fails, e.g. because of conflicts, it is aborted and the worktree is
+kept so that you can sort it out by hand.`,
+	Example: `
+# Merge the changes of a session
+crush worktree merge 1f1b7c6e-2a4f-4c1e-9a53-1f8f0b5c2d3e
+
+# Merge with a given commit message for the pending changes
+crush worktree merge 1f1b7c6e-2a4f-4c1e-9a53-1f8f0b5c2d3e -m "Refactor the HTTP handlers"
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		message, _ := cmd.Flags().GetString("message")
+		if message == "" {
+			message = fmt.Sprintf("Changes from crush session %s", args[0])
+		}
+
+		worktrees, err := worktreeManager(cmd)
+		if err != nil {
+			return err
+		}
+		if err := worktrees.Merge(cmd.Context(), args[0], message); err != nil {
+			return fmt.Errorf("failed to merge worktree of session %s: %w", args[0], err)
+		}
+		cmd.Printf("merged %s\n", args[0])
+		return nil
+	},
+}
+
+var worktreeDiscardCmd = &cobra.Command{
+	Use:   "discard <session>...",
+	Short: "Discard session worktrees",
+	Long:  "Remove the worktrees of sessions and delete their branches, along with any changes that were not merged",
+	Example: `
+# Throw away the changes of a session
+crush worktree discard 1f1b7c6e-2a4f-4c1e-9a53-1f8f0b5c2d3e
+  `,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		worktrees, err := worktreeManager(cmd)
+		if err != nil {
+			return err
+		}
+		for _, id := range args {
+			if err := worktrees.Discard(cmd.Context(), id); err != nil {
+				return fmt.Errorf("failed to discard worktree of session %s: %w", id, err)
+			}
+			cmd.Printf("discarded %s\n", id)
+		}
+		return nil
+	},
+}
+
+func init() {
+	worktreeListCmd.Flags().Bool("json", false, "Output as JSON")
+	worktreeMergeCmd.Flags().StringP("message", "m", "", "Commit message for the changes not committed yet")
+	worktreeCmd.AddCommand(
+		worktreeListCmd,
+		worktreeDiffCmd,
+		worktreeMergeCmd,
+		worktreeDiscardCmd,
+	)
+}
+
+// worktreeManager returns the manager of the session worktrees of the
+// project in the current working directory.
+func worktreeManager(cmd *cobra.Command) (*worktree.Manager, error) {
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	worktrees, err := worktree.New(cmd.Context(), cwd, config.GlobalWorktreesDir())
+	if errors.Is(err, worktree.ErrNotRepository) {
+		return nil, fmt.Errorf("%s is not in a git repository", cwd)
+	}
+	return worktrees, err
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/home"
+	"gopkg.in/yaml.v3"
+)
+
+// agentFrontmatter is the frontmatter of an agent defined in a markdown
+// file. The body of the file is the system prompt of the agent.
+type agentFrontmatter struct {
+	Name         string              `yaml:"name"`
+	Description  string              `yaml:"description"`
+	Disabled     bool                `yaml:"disabled"`
+	Mode         AgentMode           `yaml:"mode"`
+	Model        string              `yaml:"model"`
+	AllowedTools []string            `yaml:"allowed_tools"`
+	AllowedMCP   map[string][]string `yaml:"allowed_mcp"`
+	ContextPaths []string            `yaml:"context_paths"`
+}
+
+// GlobalAgentsDir returns the default directory for agents defined in
+// markdown files.
+func GlobalAgentsDir() string {
+	if crushAgents := os.Getenv("CRUSH_AGENTS_DIR"); crushAgents != "" {
+		return crushAgents
+	}
+	configBase := filepath.Join(home.Dir(), ".config")
+	if xdgConfigHome := os.Getenv("XDG_CONFIG_HOME"); xdgConfigHome != "" {
+		configBase = xdgConfigHome
+	} else if runtime.GOOS == "windows" {
+		configBase = cmp.Or(
+			os.Getenv("LOCALAPPDATA"),
+			filepath.Join(os.Getenv("USERPROFILE"), "AppData", "Local"),
+		)
+	}
+	return filepath.Join(configBase, appName, "agents")
+}
+
+// loadAgentFiles loads the agents defined in the markdown files of the given
+// directories, keyed by file name without extension. Relative directories
+// are resolved against workingDir. Agents in later directories take
+// precedence.
+func loadAgentFiles(workingDir string, dirs []string) map[string]Agent {
+	agents := make(map[string]Agent)
+	for _, dir := range dirs {
+		dir = home.Long(dir)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workingDir, dir)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				slog.Warn("Failed to read agents directory", "path", dir, "error", err)
+			}
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			agent, err := parseAgentFile(path)
+			if err != nil {
+				slog.Warn("Failed to parse agent file", "path", path, "error", err)
+				continue
+			}
+			agents[strings.TrimSuffix(entry.Name(), ".md")] = agent
+		}
+	}
+	return agents
+}
+
+func parseAgentFile(path string) (Agent, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Agent{}, err
+	}
+
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	if !strings.HasPrefix(text, "---\n") {
+		return Agent{}, errors.New("no YAML frontmatter found")
+	}
+	frontmatter, body, ok := strings.Cut(strings.TrimPrefix(text, "---\n"), "\n---")
+	if !ok {
+		return Agent{}, errors.New("unclosed frontmatter")
+	}
+
+	var fm agentFrontmatter
+	if err := yaml.Unmarshal([]byte(frontmatter), &fm); err != nil {
+		return Agent{}, fmt.Errorf("parsing frontmatter: %w", err)
+	}
+	return Agent{
+		Name:         fm.Name,
+		Description:  fm.Description,
+		Disabled:     fm.Disabled,
+		Mode:         fm.Mode,
+		Model:        SelectedModelType(fm.Model),
+		Prompt:       strings.TrimSpace(body),
+		AllowedTools: fm.AllowedTools,
+		AllowedMCP:   fm.AllowedMCP,
+		ContextPaths: fm.ContextPaths,
+		Path:         path,
+	}, nil
+}
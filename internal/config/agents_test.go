@@ -0,0 +1,151 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeAgentFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestParseAgentFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := writeAgentFile(t, dir, "reviewer.md", `---
+name: Reviewer
+description: Reviews changes
+model: openai/gpt-4o
+allowed_tools: [view, grep]
+allowed_mcp:
+  github: [get_pull_request]
+---
+
+You review code in {{.WorkingDir}}.
+`)
+
+	agent, err := parseAgentFile(path)
+	require.NoError(t, err)
+	require.Equal(t, Agent{
+		Name:         "Reviewer",
+		Description:  "Reviews changes",
+		Model:        "openai/gpt-4o",
+		Prompt:       "You review code in {{.WorkingDir}}.",
+		AllowedTools: []string{"view", "grep"},
+		AllowedMCP:   map[string][]string{"github": {"get_pull_request"}},
+		Path:         path,
+	}, agent)
+
+	_, err = parseAgentFile(writeAgentFile(t, dir, "plain.md", "Just a prompt"))
+	require.ErrorContains(t, err, "no YAML frontmatter")
+
+	_, err = parseAgentFile(writeAgentFile(t, dir, "unclosed.md", "---\nname: x\n"))
+	require.ErrorContains(t, err, "unclosed frontmatter")
+}
+
+func TestConfig_setupAgentsWithCustomAgents(t *testing.T) {
+	workingDir := t.TempDir()
+	agentsDir := filepath.Join(workingDir, defaultProjectAgentsDir)
+	writeAgentFile(t, agentsDir, "reviewer.md", "---\nname: File Reviewer\n---\nFrom file.")
+	writeAgentFile(t, agentsDir, "docs.md", "---\nallowed_tools: [view, edit]\n---\nWrite docs.")
+	writeAgentFile(t, agentsDir, "coder.md", "---\nname: Not the coder\n---\n")
+
+	cfg := &Config{
+		Options: &Options{
+			DisabledTools: []string{"edit"},
+			ContextPaths:  []string{"AGENTS.md"},
+			AgentsPaths:   []string{defaultProjectAgentsDir},
+		},
+		CustomAgents: map[string]Agent{
+			"reviewer": {Name: "Reviewer", Model: SelectedModelTypeSmall},
+			"disabled": {Disabled: true},
+		},
+		workingDir: workingDir,
+	}
+	cfg.SetupAgents()
+
+	require.Len(t, cfg.Agents, 4)
+	require.Equal(t, "Coder", cfg.Agents[AgentCoder].Name)
+
+	reviewer := cfg.Agents["reviewer"]
+	require.Equal(t, "reviewer", reviewer.ID)
+	require.Equal(t, "Reviewer", reviewer.Name)
+	require.Equal(t, SelectedModelTypeSmall, reviewer.Model)
+	require.Empty(t, reviewer.Prompt)
+	require.Equal(t, cfg.Agents[AgentCoder].AllowedTools, reviewer.AllowedTools)
+	require.Equal(t, cfg.Agents[AgentTask].AllowedTools, reviewer.SubagentTools)
+	require.Equal(t, []string{"AGENTS.md"}, reviewer.ContextPaths)
+
+	docs := cfg.Agents["docs"]
+	require.Equal(t, "docs", docs.Name)
+	require.Equal(t, SelectedModelTypeLarge, docs.Model)
+	require.Equal(t, "Write docs.", docs.Prompt)
+	require.Equal(t, []string{"view"}, docs.AllowedTools)
+	require.Equal(t, []string{"view"}, docs.SubagentTools)
+	require.Equal(t, filepath.Join(agentsDir, "docs.md"), docs.Path)
+}
+
+func TestConfig_AgentModel(t *testing.T) {
+	t.Parallel()
+
+	large := SelectedModel{Provider: "anthropic", Model: "claude"}
+	small := SelectedModel{Provider: "openai", Model: "gpt-4o-mini"}
+	cfg := &Config{Models: map[SelectedModelType]SelectedModel{
+		SelectedModelTypeLarge: large,
+		SelectedModelTypeSmall: small,
+	}}
+
+	for _, tt := range []struct {
+		model SelectedModelType
+		want  SelectedModel
+		ok    bool
+	}{
+		{"", large, true},
+		{SelectedModelTypeLarge, large, true},
+		{SelectedModelTypeSmall, small, true},
+		{"openai/gpt-4o", SelectedModel{Provider: "openai", Model: "gpt-4o"}, true},
+		{"gpt-4o", SelectedModel{}, false},
+	} {
+		got, ok := cfg.AgentModel(Agent{Model: tt.model})
+		require.Equal(t, tt.ok, ok, tt.model)
+		require.Equal(t, tt.want, got, tt.model)
+	}
+}
+
+func TestConfig_Subagents(t *testing.T) {
+	cfg := &Config{
+		Options: &Options{},
+		CustomAgents: map[string]Agent{
+			"tester":   {Mode: AgentModeSubagent, AllowedTools: []string{"bash", "view"}},
+			"explorer": {},
+			"planner":  {Mode: AgentModePrimary},
+			"unknown":  {Mode: "sometimes"},
+		},
+		workingDir: t.TempDir(),
+	}
+	cfg.SetupAgents()
+
+	var ids []string
+	for _, agent := range cfg.Subagents() {
+		ids = append(ids, agent.ID)
+	}
+	require.Equal(t, []string{AgentTask, "explorer", "tester"}, ids)
+
+	// Subagents only get more than the read-only tools if they list them.
+	subagents := cfg.Subagents()
+	require.Equal(t, cfg.Agents[AgentTask].AllowedTools, subagents[1].AllowedTools)
+	require.NotContains(t, subagents[1].AllowedTools, "bash")
+	require.Equal(t, []string{"bash", "view"}, subagents[2].AllowedTools)
+	require.NotContains(t, cfg.Agents, "unknown")
+	require.True(t, cfg.Agents["planner"].IsPrimary())
+	require.False(t, cfg.Agents["tester"].IsPrimary())
+	require.False(t, cfg.Agents[AgentTask].IsPrimary())
+}
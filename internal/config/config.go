@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"maps"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
@@ -18,9 +19,11 @@ import (
 	hyperp "github.com/charmbracelet/crush/internal/agent/hyper"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/env"
+	"github.com/charmbracelet/crush/internal/hooks"
 	"github.com/charmbracelet/crush/internal/oauth"
 	"github.com/charmbracelet/crush/internal/oauth/copilot"
 	"github.com/charmbracelet/crush/internal/oauth/hyper"
+	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/invopop/jsonschema"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -30,6 +33,9 @@ const (
 	appName              = "crush"
 	defaultDataDirectory = ".crush"
 	defaultInitializeAs  = "AGENTS.md"
+	// defaultProjectAgentsDir holds the agents defined in markdown files
+	// for a project, relative to the working directory.
+	defaultProjectAgentsDir = ".crush/agents"
 )
 
 var defaultContextPaths = []string{
@@ -68,6 +74,16 @@ const (
 	AgentTask  string = "task"
 )
 
+// AgentMode tells whether an agent runs prompts, runs as a subagent through
+// the agent tool, or both.
+type AgentMode string
+
+const (
+	AgentModeAll      AgentMode = "all"
+	AgentModePrimary  AgentMode = "primary"
+	AgentModeSubagent AgentMode = "subagent"
+)
+
 type SelectedModel struct {
 	// The model id as used by the provider API.
 	// Required.
@@ -92,6 +108,9 @@ type SelectedModel struct {
 
 	// Override provider specific options.
 	ProviderOptions map[string]any `json:"provider_options,omitempty" jsonschema:"description=Additional provider-specific options for the model"`
+
+	// Models to carry on with, in order, when this one keeps failing.
+	Fallbacks []SelectedModel `json:"fallbacks,omitempty" jsonschema:"description=Models to fall back to in order when this model keeps failing; fallbacks of fallbacks are ignored"`
 }
 
 type ProviderConfig struct {
@@ -122,6 +141,9 @@ type ProviderConfig struct {
 
 	ProviderOptions map[string]any `json:"provider_options,omitempty" jsonschema:"description=Additional provider-specific options for this provider"`
 
+	// How failed requests to the provider are retried.
+	Retry RetryPolicy `json:"retry,omitzero" jsonschema:"description=How failed requests to the provider are retried"`
+
 	// Used to pass extra parameters to the provider.
 	ExtraParams map[string]string `json:"-"`
 
@@ -129,6 +151,48 @@ type ProviderConfig struct {
 	Models []catwalk.Model `json:"models,omitempty" jsonschema:"description=List of models available from this provider"`
 }
 
+// DefaultRetryStatusCodes are the HTTP status codes of the failed requests
+// that are retried when [RetryPolicy.StatusCodes] is not set.
+var DefaultRetryStatusCodes = []int{408, 409, 429, 500, 502, 503, 504, 529}
+
+// RetryPolicy defines how failed requests to a provider are retried. The
+// delay between attempts grows exponentially, unless the provider says how
+// long to wait.
+type RetryPolicy struct {
+	MaxAttempts   *int     `json:"max_attempts,omitempty" jsonschema:"description=Maximum number of attempts of a request including the first one,default=3,example=5"`
+	InitialDelay  *int     `json:"initial_delay,omitempty" jsonschema:"description=Delay in milliseconds before the first retry,default=2000,example=1000"`
+	MaxDelay      *int     `json:"max_delay,omitempty" jsonschema:"description=Maximum delay in milliseconds between attempts,default=60000,example=30000"`
+	BackoffFactor *float64 `json:"backoff_factor,omitempty" jsonschema:"description=Factor the delay is multiplied by after each retry,default=2,example=1.5"`
+	StatusCodes   []int    `json:"status_codes,omitempty" jsonschema:"description=HTTP status codes of the failed requests to retry,example=429,example=529"`
+}
+
+// Attempts returns the maximum number of attempts of a request.
+func (r RetryPolicy) Attempts() int {
+	return max(1, ptrValOr(r.MaxAttempts, 3))
+}
+
+// Retryable reports whether a request that failed with the given HTTP
+// status code should be retried.
+func (r RetryPolicy) Retryable(statusCode int) bool {
+	codes := r.StatusCodes
+	if len(codes) == 0 {
+		codes = DefaultRetryStatusCodes
+	}
+	return slices.Contains(codes, statusCode)
+}
+
+// Delay returns how long to wait before retrying after the given failed
+// attempt. retryAfter is the delay asked for by the provider, if any, and
+// wins as long as it's within the maximum delay.
+func (r RetryPolicy) Delay(attempt int, retryAfter time.Duration) time.Duration {
+	maxDelay := time.Duration(ptrValOr(r.MaxDelay, 60_000)) * time.Millisecond
+	if retryAfter > 0 && retryAfter <= maxDelay {
+		return retryAfter
+	}
+	delay := float64(ptrValOr(r.InitialDelay, 2000)) * math.Pow(ptrValOr(r.BackoffFactor, 2), float64(attempt-1))
+	return min(time.Duration(delay)*time.Millisecond, maxDelay)
+}
+
 // ToProvider converts the [ProviderConfig] to a [catwalk.Provider].
 func (pc *ProviderConfig) ToProvider() catwalk.Provider {
 	// Convert config provider to provider.Provider format
@@ -181,7 +245,13 @@ type MCPConfig struct {
 	DisabledTools []string          `json:"disabled_tools,omitempty" jsonschema:"description=List of tools from this MCP server to disable,example=get-library-doc"`
 	Timeout       int               `json:"timeout,omitempty" jsonschema:"description=Timeout in seconds for MCP server connections,default=15,example=30,example=60,example=120"`
 
-	// TODO: maybe make it possible to get the value from the env
+	// Sampling lets the server have the small model generate messages, at
+	// the cost of the tokens it uses.
+	Sampling bool `json:"sampling,omitempty" jsonschema:"description=Whether this MCP server may have the small model generate messages,default=false"`
+
+	// Header values may reference environment variables, like
+	// "Bearer $API_KEY". Servers that use OAuth don't need any: Crush
+	// authorizes with them itself.
 	Headers map[string]string `json:"headers,omitempty" jsonschema:"description=HTTP headers for HTTP/SSE MCP servers"`
 }
 
@@ -217,9 +287,70 @@ func (c Completions) Limits() (depth, items int) {
 	return ptrValOr(c.MaxDepth, 0), ptrValOr(c.MaxItems, 0)
 }
 
+// Compaction defines how the context of a session is compacted when it runs
+// low. Old outputs of the pruning tools are elided first, and the
+// conversation is only summarized when that doesn't free enough space.
+type Compaction struct {
+	DisablePruning bool     `json:"disable_pruning,omitempty" jsonschema:"description=Summarize the conversation right away instead of pruning old tool outputs first,default=false"`
+	KeepTurns      *int     `json:"keep_turns,omitempty" jsonschema:"description=Number of most recent turns (user prompts or agent steps) whose tool outputs are never pruned,default=6,example=10"`
+	MinSize        *int     `json:"min_size,omitempty" jsonschema:"description=Minimum size in bytes of the tool outputs to prune,default=2048,example=4096"`
+	Tools          []string `json:"tools,omitempty" jsonschema:"description=Tools whose outputs can be pruned,example=view,example=bash"`
+}
+
+// DefaultPruningTools are the tools whose outputs are pruned when
+// [Compaction.Tools] is not set: the ones that read files, search or run
+// commands, which are cheap to run again.
+var DefaultPruningTools = []string{"bash", "job_output", "view", "grep", "glob", "ls", "fetch", "web_fetch", "sourcegraph"}
+
+// Policy returns the pruning policy with the defaults applied.
+func (c Compaction) Policy() (keepTurns, minSize int, tools []string) {
+	tools = c.Tools
+	if len(tools) == 0 {
+		tools = DefaultPruningTools
+	}
+	return ptrValOr(c.KeepTurns, 6), ptrValOr(c.MinSize, 2048), tools
+}
+
+// BudgetKind is the scope of a budget.
+type BudgetKind string
+
+const (
+	BudgetSession BudgetKind = "session"
+	BudgetDaily   BudgetKind = "daily"
+	BudgetRun     BudgetKind = "run"
+)
+
+// Budget defines limits, in US dollars, on what the agent spends. The agent
+// stops once it reaches one, until the limit is raised.
+type Budget struct {
+	Session float64  `json:"session,omitempty" jsonschema:"description=Maximum cost of a session in US dollars,example=5"`
+	Daily   float64  `json:"daily,omitempty" jsonschema:"description=Maximum cost of all the sessions of the project in a day in US dollars,example=20"`
+	Run     float64  `json:"run,omitempty" jsonschema:"description=Maximum cost of a non-interactive run in US dollars,example=1"`
+	WarnAt  *float64 `json:"warn_at,omitempty" jsonschema:"description=Fraction of a budget at which to warn that it is running out,default=0.8,minimum=0,maximum=1,example=0.5"`
+}
+
+// Limit returns the limit of the given budget, or 0 if there is none.
+func (b Budget) Limit(kind BudgetKind) float64 {
+	switch kind {
+	case BudgetSession:
+		return b.Session
+	case BudgetDaily:
+		return b.Daily
+	case BudgetRun:
+		return b.Run
+	}
+	return 0
+}
+
+// WarnThreshold returns the fraction of a budget at which to warn.
+func (b Budget) WarnThreshold() float64 {
+	return ptrValOr(b.WarnAt, 0.8)
+}
+
 type Permissions struct {
-	AllowedTools []string `json:"allowed_tools,omitempty" jsonschema:"description=List of tools that don't require permission prompts,example=bash,example=view"` // Tools that don't require permission prompts
-	SkipRequests bool     `json:"-"`                                                                                                                              // Automatically accept all permissions (YOLO mode)
+	AllowedTools []string          `json:"allowed_tools,omitempty" jsonschema:"description=List of tools that don't require permission prompts,example=bash,example=view"` // Tools that don't require permission prompts
+	Rules        []permission.Rule `json:"rules,omitempty" jsonschema:"description=Ordered allow/ask/deny rules matched against tool requests; deny wins over ask and ask wins over allow"`
+	SkipRequests bool              `json:"-"` // Automatically accept all permissions (YOLO mode)
 }
 
 type TrailerStyle string
@@ -248,6 +379,7 @@ func (Attribution) JSONSchemaExtend(schema *jsonschema.Schema) {
 type Options struct {
 	ContextPaths              []string     `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the AI,example=.cursorrules,example=CRUSH.md"`
 	SkillsPaths               []string     `json:"skills_paths,omitempty" jsonschema:"description=Paths to directories containing Agent Skills (folders with SKILL.md files),example=~/.config/crush/skills,example=./skills"`
+	AgentsPaths               []string     `json:"agents_paths,omitempty" jsonschema:"description=Paths to directories containing agents defined in markdown files with frontmatter,example=~/.config/crush/agents,example=.crush/agents"`
 	TUI                       *TUIOptions  `json:"tui,omitempty" jsonschema:"description=Terminal user interface options"`
 	Debug                     bool         `json:"debug,omitempty" jsonschema:"description=Enable debug logging,default=false"`
 	DebugLSP                  bool         `json:"debug_lsp,omitempty" jsonschema:"description=Enable debug logging for LSP servers,default=false"`
@@ -261,6 +393,9 @@ type Options struct {
 	InitializeAs              string       `json:"initialize_as,omitempty" jsonschema:"description=Name of the context file to create/update during project initialization,default=AGENTS.md,example=AGENTS.md,example=CRUSH.md,example=CLAUDE.md,example=docs/LLMs.md"`
 	AutoLSP                   *bool        `json:"auto_lsp,omitempty" jsonschema:"description=Automatically setup LSPs based on root markers,default=true"`
 	Progress                  *bool        `json:"progress,omitempty" jsonschema:"description=Show indeterminate progress updates during long operations,default=true"`
+	Worktree                  bool         `json:"worktree,omitempty" jsonschema:"description=Run each new session in its own git worktree instead of the working directory,default=false"`
+	Compaction                Compaction   `json:"compaction,omitzero" jsonschema:"description=How the context is compacted when it runs low"`
+	Budget                    Budget       `json:"budget,omitzero" jsonschema:"description=Limits on what the agent spends"`
 }
 
 type MCPs map[string]MCPConfig
@@ -286,6 +421,9 @@ func (m MCPs) Sorted() []MCP {
 
 type LSPs map[string]LSPConfig
 
+// Hooks holds the hooks to run for each event.
+type Hooks map[hooks.Event][]hooks.Hook
+
 type LSP struct {
 	Name string    `json:"name"`
 	LSP  LSPConfig `json:"lsp"`
@@ -315,38 +453,65 @@ func (m MCPConfig) ResolvedEnv() []string {
 
 func (m MCPConfig) ResolvedHeaders() map[string]string {
 	resolver := NewShellVariableResolver(env.New())
+	headers := make(map[string]string, len(m.Headers))
 	for e, v := range m.Headers {
-		var err error
-		m.Headers[e], err = resolver.ResolveValue(v)
+		resolved, err := resolver.ResolveValue(v)
 		if err != nil {
 			slog.Error("Error resolving header variable", "error", err, "variable", e, "value", v)
-			continue
+			resolved = v
 		}
+		headers[e] = resolved
 	}
-	return m.Headers
+	return headers
 }
 
 type Agent struct {
-	ID          string `json:"id,omitempty"`
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
+	ID          string `json:"-"`
+	Name        string `json:"name,omitempty" jsonschema:"description=Display name of the agent,example=Reviewer"`
+	Description string `json:"description,omitempty" jsonschema:"description=What the agent is for,example=Reviews changes for bugs and style issues"`
 	// This is the id of the system prompt used by the agent
-	Disabled bool `json:"disabled,omitempty"`
+	Disabled bool `json:"disabled,omitempty" jsonschema:"description=Whether this agent is disabled,default=false"`
+	// Whether the agent runs prompts, is a subagent of the agent tool, or
+	// both.
+	Mode AgentMode `json:"mode,omitempty" jsonschema:"description=primary to run prompts; subagent to be delegated to through the agent tool; all for both,enum=all,enum=primary,enum=subagent,default=all"`
+
+	// The model type to use for the agent, or a specific model as
+	// provider/model.
+	Model SelectedModelType `json:"model,omitempty" jsonschema:"description=The model to use for this agent: large or small for the selected models or provider/model for a specific one,default=large,example=large,example=small,example=openai/gpt-4o"`
 
-	Model SelectedModelType `json:"model" jsonschema:"required,description=The model type to use for this agent,enum=large,enum=small,default=large"`
+	// The system prompt template of the agent. If empty, the coder prompt is
+	// used.
+	Prompt string `json:"prompt,omitempty" jsonschema:"description=System prompt of the agent as a Go template; defaults to the prompt of the coder agent"`
 
 	// The available tools for the agent
 	//  if this is nil, all tools are available
-	AllowedTools []string `json:"allowed_tools,omitempty"`
+	AllowedTools []string `json:"allowed_tools,omitempty" jsonschema:"description=Built-in tools available to the agent; all tools if unset, or the read-only tools of the task agent when it runs as a subagent,example=view,example=grep"`
+	// SubagentTools are the tools of the agent when the agent tool delegates
+	// to it: its allowed tools if it lists them, or the read-only tools of
+	// the task agent otherwise.
+	SubagentTools []string `json:"-"`
 
 	// this tells us which MCPs are available for this agent
 	//  if this is empty all mcps are available
 	//  the string array is the list of tools from the AllowedMCP the agent has available
 	//  if the string array is nil, all tools from the AllowedMCP are available
-	AllowedMCP map[string][]string `json:"allowed_mcp,omitempty"`
+	AllowedMCP map[string][]string `json:"allowed_mcp,omitempty" jsonschema:"description=MCP servers available to the agent mapped to the tools allowed from each; all servers if unset and all tools of a server if its list is empty"`
 
 	// Overrides the context paths for this agent
-	ContextPaths []string `json:"context_paths,omitempty"`
+	ContextPaths []string `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the agent; defaults to options.context_paths"`
+
+	// Path is the markdown file the agent was defined in, if any.
+	Path string `json:"-"`
+}
+
+// IsPrimary reports whether the agent can run prompts.
+func (a Agent) IsPrimary() bool {
+	return a.Mode != AgentModeSubagent
+}
+
+// IsSubagent reports whether the agent tool can delegate to the agent.
+func (a Agent) IsSubagent() bool {
+	return a.Mode != AgentModePrimary
 }
 
 type Tools struct {
@@ -396,6 +561,11 @@ type Config struct {
 
 	Tools Tools `json:"tools,omitzero" jsonschema:"description=Tool configurations"`
 
+	Hooks Hooks `json:"hooks,omitempty" jsonschema:"description=Shell commands run on agent events; each receives the event as JSON on stdin and may block it or add context"`
+
+	// User-defined agents, as configured. See Agents for the resolved set.
+	CustomAgents map[string]Agent `json:"agents,omitempty" jsonschema:"description=User-defined agents with their own prompts, models and tools"`
+
 	Agents map[string]Agent `json:"-"`
 
 	// Internal
@@ -479,6 +649,24 @@ func (c *Config) SetCompactMode(enabled bool) error {
 	return c.SetConfigField("options.tui.compact_mode", enabled)
 }
 
+// SetBudget sets the limit of a budget and saves it.
+func (c *Config) SetBudget(kind BudgetKind, limit float64) error {
+	if c.Options == nil {
+		c.Options = &Options{}
+	}
+	switch kind {
+	case BudgetSession:
+		c.Options.Budget.Session = limit
+	case BudgetDaily:
+		c.Options.Budget.Daily = limit
+	case BudgetRun:
+		c.Options.Budget.Run = limit
+	default:
+		return fmt.Errorf("unknown budget %q", kind)
+	}
+	return c.SetConfigField(fmt.Sprintf("options.budget.%s", kind), limit)
+}
+
 func (c *Config) Resolve(key string) (string, error) {
 	if c.resolver == nil {
 		return "", fmt.Errorf("no variable resolver configured")
@@ -487,7 +675,13 @@ func (c *Config) Resolve(key string) (string, error) {
 }
 
 func (c *Config) UpdatePreferredModel(modelType SelectedModelType, model SelectedModel) error {
-	c.Models[modelType] = model
+	selected := model
+	if selected.Fallbacks == nil {
+		// Keep the fallbacks from the configuration, without saving them
+		// along with the selection.
+		selected.Fallbacks = c.Models[modelType].Fallbacks
+	}
+	c.Models[modelType] = selected
 	if err := c.SetConfigField(fmt.Sprintf("models.%s", modelType), model); err != nil {
 		return fmt.Errorf("failed to update preferred model: %w", err)
 	}
@@ -506,7 +700,17 @@ func (c *Config) HasConfigField(key string) bool {
 }
 
 func (c *Config) SetConfigField(key string, value any) error {
-	data, err := os.ReadFile(c.dataConfigDir)
+	return SetFileField(c.dataConfigDir, key, value)
+}
+
+func (c *Config) RemoveConfigField(key string) error {
+	return RemoveFileField(c.dataConfigDir, key)
+}
+
+// SetFileField sets the field at key of the configuration file at path,
+// creating the file if needed.
+func SetFileField(path, key string, value any) error {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			data = []byte("{}")
@@ -519,17 +723,13 @@ func (c *Config) SetConfigField(key string, value any) error {
 	if err != nil {
 		return fmt.Errorf("failed to set config field %s: %w", key, err)
 	}
-	if err := os.MkdirAll(filepath.Dir(c.dataConfigDir), 0o755); err != nil {
-		return fmt.Errorf("failed to create config directory %q: %w", c.dataConfigDir, err)
-	}
-	if err := os.WriteFile(c.dataConfigDir, []byte(newValue), 0o600); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
-	}
-	return nil
+	return writeConfigFile(path, newValue)
 }
 
-func (c *Config) RemoveConfigField(key string) error {
-	data, err := os.ReadFile(c.dataConfigDir)
+// RemoveFileField removes the field at key from the configuration file at
+// path.
+func RemoveFileField(path, key string) error {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
@@ -538,10 +738,14 @@ func (c *Config) RemoveConfigField(key string) error {
 	if err != nil {
 		return fmt.Errorf("failed to delete config field %s: %w", key, err)
 	}
-	if err := os.MkdirAll(filepath.Dir(c.dataConfigDir), 0o755); err != nil {
-		return fmt.Errorf("failed to create config directory %q: %w", c.dataConfigDir, err)
+	return writeConfigFile(path, newValue)
+}
+
+func writeConfigFile(path, data string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory %q: %w", path, err)
 	}
-	if err := os.WriteFile(c.dataConfigDir, []byte(newValue), 0o600); err != nil {
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 	return nil
@@ -760,25 +964,105 @@ func (c *Config) SetupAgents() {
 			ID:           AgentCoder,
 			Name:         "Coder",
 			Description:  "An agent that helps with executing coding tasks.",
+			Mode:         AgentModePrimary,
 			Model:        SelectedModelTypeLarge,
 			ContextPaths: c.Options.ContextPaths,
 			AllowedTools: allowedTools,
 		},
 
 		AgentTask: {
-			ID:           AgentTask,
-			Name:         "Task",
-			Description:  "An agent that helps with searching for context and finding implementation details.",
-			Model:        SelectedModelTypeLarge,
-			ContextPaths: c.Options.ContextPaths,
-			AllowedTools: resolveReadOnlyTools(allowedTools),
+			ID:            AgentTask,
+			Name:          "Task",
+			Description:   "An agent that helps with searching for context and finding implementation details.",
+			Mode:          AgentModeSubagent,
+			Model:         SelectedModelTypeLarge,
+			ContextPaths:  c.Options.ContextPaths,
+			AllowedTools:  resolveReadOnlyTools(allowedTools),
+			SubagentTools: resolveReadOnlyTools(allowedTools),
 			// NO MCPs or LSPs by default
 			AllowedMCP: map[string][]string{},
 		},
 	}
+
+	// Agents in crush.json take precedence over agents in markdown files.
+	custom := loadAgentFiles(c.workingDir, c.Options.AgentsPaths)
+	maps.Copy(custom, c.CustomAgents)
+	for id, agent := range custom {
+		if _, ok := agents[id]; ok {
+			slog.Warn("Ignoring user-defined agent with the name of a built-in agent", "agent", id)
+			continue
+		}
+		if agent.Disabled {
+			continue
+		}
+		agent.ID = id
+		agent.Name = cmp.Or(agent.Name, id)
+		agent.Mode = cmp.Or(agent.Mode, AgentModeAll)
+		if !slices.Contains([]AgentMode{AgentModeAll, AgentModePrimary, AgentModeSubagent}, agent.Mode) {
+			slog.Warn("Ignoring user-defined agent with an unknown mode", "agent", id, "mode", agent.Mode)
+			continue
+		}
+		agent.Model = cmp.Or(agent.Model, SelectedModelTypeLarge)
+		if agent.AllowedTools == nil {
+			agent.AllowedTools = allowedTools
+			agent.SubagentTools = resolveReadOnlyTools(allowedTools)
+		} else {
+			agent.AllowedTools = resolveAllowedTools(agent.AllowedTools, c.Options.DisabledTools)
+			agent.SubagentTools = agent.AllowedTools
+		}
+		if agent.ContextPaths == nil {
+			agent.ContextPaths = c.Options.ContextPaths
+		}
+		agents[id] = agent
+	}
 	c.Agents = agents
 }
 
+// Subagents returns the agents the agent tool can delegate to, with their
+// tools as subagents, the task agent first and the others sorted by ID.
+func (c *Config) Subagents() []Agent {
+	var agents []Agent
+	for _, agent := range c.Agents {
+		if agent.IsSubagent() {
+			agent.AllowedTools = agent.SubagentTools
+			agents = append(agents, agent)
+		}
+	}
+	slices.SortFunc(agents, func(a, b Agent) int {
+		switch {
+		case a.ID == AgentTask:
+			return -1
+		case b.ID == AgentTask:
+			return 1
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return agents
+}
+
+// AgentModel returns the model the agent runs on.
+func (c *Config) AgentModel(agent Agent) (SelectedModel, bool) {
+	switch agent.Model {
+	case "", SelectedModelTypeLarge, SelectedModelTypeSmall:
+		model, ok := c.Models[cmp.Or(agent.Model, SelectedModelTypeLarge)]
+		return model, ok
+	}
+	provider, model, ok := strings.Cut(string(agent.Model), "/")
+	if !ok || provider == "" || model == "" {
+		return SelectedModel{}, false
+	}
+	return SelectedModel{Provider: provider, Model: model}, true
+}
+
+// GetAgentModel returns the catwalk model the agent runs on.
+func (c *Config) GetAgentModel(agent Agent) *catwalk.Model {
+	model, ok := c.AgentModel(agent)
+	if !ok {
+		return nil
+	}
+	return c.GetModel(model.Provider, model.Model)
+}
+
 func (c *Config) Resolver() VariableResolver {
 	return c.resolver
 }
@@ -22,6 +22,7 @@ import (
 	"github.com/charmbracelet/crush/internal/env"
 	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/home"
+	"github.com/charmbracelet/crush/internal/hooks"
 	"github.com/charmbracelet/crush/internal/log"
 	powernapConfig "github.com/charmbracelet/x/powernap/pkg/config"
 	"github.com/qjebbs/go-jsons"
@@ -37,6 +38,9 @@ func Load(workingDir, dataDir string, debug bool) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config from paths %v: %w", configPaths, err)
 	}
+	if err := hooks.Validate(cfg.Hooks); err != nil {
+		return nil, fmt.Errorf("invalid hooks config: %w", err)
+	}
 
 	cfg.dataConfigDir = GlobalConfigData()
 
@@ -202,6 +206,7 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 			SystemPromptPrefix: config.SystemPromptPrefix,
 			ExtraHeaders:       headers,
 			ExtraBody:          config.ExtraBody,
+			Retry:              config.Retry,
 			ExtraParams:        make(map[string]string),
 			Models:             p.Models,
 		}
@@ -387,6 +392,14 @@ func (c *Config) setDefaults(workingDir, dataDir string) {
 		}
 	}
 
+	// Add the default agents directories if not already present. Project
+	// agents come last so they take precedence over global ones.
+	for _, dir := range []string{GlobalAgentsDir(), defaultProjectAgentsDir} {
+		if !slices.Contains(c.Options.AgentsPaths, dir) {
+			c.Options.AgentsPaths = append(c.Options.AgentsPaths, dir)
+		}
+	}
+
 	if str, ok := os.LookupEnv("CRUSH_DISABLE_PROVIDER_AUTO_UPDATE"); ok {
 		c.Options.DisableProviderAutoUpdate, _ = strconv.ParseBool(str)
 	}
@@ -770,6 +783,13 @@ func isInsideWorktree() bool {
 	return err == nil && strings.TrimSpace(string(bts)) == "true"
 }
 
+// GlobalWorktreesDir returns the directory the git worktrees of sessions are
+// created in. It is kept out of the project so that file walks in a
+// worktree don't skip it as part of the data directory.
+func GlobalWorktreesDir() string {
+	return filepath.Join(filepath.Dir(GlobalConfigData()), "worktrees")
+}
+
 // GlobalSkillsDirs returns the default directories for Agent Skills.
 // Skills in these directories are auto-discovered and their files can be read
 // without permission prompts.
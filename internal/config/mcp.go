@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// ConfigPaths returns the paths of the configuration files for workingDir,
+// from the lowest to the highest priority. The global ones may not exist.
+func ConfigPaths(workingDir string) []string {
+	return lookupConfigs(workingDir)
+}
+
+// ProjectConfig returns the path of the project configuration file that takes
+// precedence for workingDir, or of a new crush.json in workingDir if there is
+// none.
+func ProjectConfig(workingDir string) string {
+	paths := lookupConfigs(workingDir)
+	if len(paths) > 2 {
+		return paths[len(paths)-1]
+	}
+	return filepath.Join(workingDir, fmt.Sprintf("%s.json", appName))
+}
+
+// LoadMCP reads the MCP servers from the configuration files for workingDir,
+// merged as [Load] does.
+func LoadMCP(workingDir string) (MCPs, error) {
+	cfg, err := loadFromConfigPaths(lookupConfigs(workingDir))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MCP == nil {
+		return MCPs{}, nil
+	}
+	return cfg.MCP, nil
+}
+
+var fieldKeyReplacer = strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`)
+
+// MCPField returns the key of the configuration of the MCP server name, for
+// [SetFileField] and [RemoveFileField].
+func MCPField(name string) string {
+	return "mcp." + fieldKeyReplacer.Replace(name)
+}
+
+// MCPConfigFiles returns the configuration files for workingDir that
+// configure the MCP server name, from the lowest to the highest priority.
+func MCPConfigFiles(workingDir, name string) []string {
+	var files []string
+	for _, path := range lookupConfigs(workingDir) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if gjson.GetBytes(data, MCPField(name)).Exists() {
+			files = append(files, path)
+		}
+	}
+	return files
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMCPConfigFiles(t *testing.T) {
+	t.Setenv("CRUSH_GLOBAL_CONFIG", t.TempDir())
+	t.Setenv("CRUSH_GLOBAL_DATA", t.TempDir())
+	workingDir := t.TempDir()
+
+	project := ProjectConfig(workingDir)
+	require.Equal(t, filepath.Join(workingDir, "crush.json"), project)
+
+	server := MCPConfig{Type: MCPStdio, Command: "server", Args: []string{"--stdio"}}
+	require.NoError(t, SetFileField(project, MCPField("docs.v2"), server))
+	require.NoError(t, SetFileField(GlobalConfig(), MCPField("docs.v2"), MCPConfig{Type: MCPStdio, Command: "global-server"}))
+
+	// The project configuration takes precedence over the global one.
+	mcps, err := LoadMCP(workingDir)
+	require.NoError(t, err)
+	require.Equal(t, MCPs{"docs.v2": server}, mcps)
+	require.Equal(t, []string{GlobalConfig(), project}, MCPConfigFiles(workingDir, "docs.v2"))
+	require.Empty(t, MCPConfigFiles(workingDir, "docs"))
+
+	require.NoError(t, RemoveFileField(project, MCPField("docs.v2")))
+	require.Equal(t, []string{GlobalConfig()}, MCPConfigFiles(workingDir, "docs.v2"))
+	data, err := os.ReadFile(project)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"mcp": {}}`, string(data))
+
+	// The configuration closest to the working directory takes precedence.
+	subDir := filepath.Join(workingDir, "sub")
+	require.NoError(t, os.Mkdir(subDir, 0o755))
+	hidden := filepath.Join(subDir, ".crush.json")
+	require.NoError(t, os.WriteFile(hidden, []byte("{}"), 0o600))
+	require.Equal(t, hidden, ProjectConfig(subDir))
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("defaults", func(t *testing.T) {
+		t.Parallel()
+
+		var policy RetryPolicy
+		require.Equal(t, 3, policy.Attempts())
+		require.True(t, policy.Retryable(429))
+		require.True(t, policy.Retryable(529))
+		require.False(t, policy.Retryable(401))
+		require.Equal(t, 2*time.Second, policy.Delay(1, 0))
+		require.Equal(t, 4*time.Second, policy.Delay(2, 0))
+		require.Equal(t, time.Minute, policy.Delay(10, 0))
+		require.Equal(t, 5*time.Second, policy.Delay(1, 5*time.Second))
+		require.Equal(t, 2*time.Second, policy.Delay(1, time.Hour))
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		t.Parallel()
+
+		attempts, initialDelay, maxDelay, factor := 0, 100, 1000, 3.0
+		policy := RetryPolicy{
+			MaxAttempts:   &attempts,
+			InitialDelay:  &initialDelay,
+			MaxDelay:      &maxDelay,
+			BackoffFactor: &factor,
+			StatusCodes:   []int{500},
+		}
+		require.Equal(t, 1, policy.Attempts())
+		require.True(t, policy.Retryable(500))
+		require.False(t, policy.Retryable(429))
+		require.Equal(t, 300*time.Millisecond, policy.Delay(2, 0))
+		require.Equal(t, time.Second, policy.Delay(4, 0))
+	})
+}
@@ -30,6 +30,12 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.createMessageStmt, err = db.PrepareContext(ctx, createMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateMessage: %w", err)
 	}
+	if q.createPermissionAuditStmt, err = db.PrepareContext(ctx, createPermissionAudit); err != nil {
+		return nil, fmt.Errorf("error preparing query CreatePermissionAudit: %w", err)
+	}
+	if q.createPermissionGrantStmt, err = db.PrepareContext(ctx, createPermissionGrant); err != nil {
+		return nil, fmt.Errorf("error preparing query CreatePermissionGrant: %w", err)
+	}
 	if q.createSessionStmt, err = db.PrepareContext(ctx, createSession); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateSession: %w", err)
 	}
@@ -39,6 +45,9 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.deleteMessageStmt, err = db.PrepareContext(ctx, deleteMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteMessage: %w", err)
 	}
+	if q.deletePermissionGrantStmt, err = db.PrepareContext(ctx, deletePermissionGrant); err != nil {
+		return nil, fmt.Errorf("error preparing query DeletePermissionGrant: %w", err)
+	}
 	if q.deleteSessionStmt, err = db.PrepareContext(ctx, deleteSession); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteSession: %w", err)
 	}
@@ -48,9 +57,18 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.deleteSessionMessagesStmt, err = db.PrepareContext(ctx, deleteSessionMessages); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteSessionMessages: %w", err)
 	}
+	if q.forkReadFilesStmt, err = db.PrepareContext(ctx, forkReadFiles); err != nil {
+		return nil, fmt.Errorf("error preparing query ForkReadFiles: %w", err)
+	}
+	if q.forkSessionStmt, err = db.PrepareContext(ctx, forkSession); err != nil {
+		return nil, fmt.Errorf("error preparing query ForkSession: %w", err)
+	}
 	if q.getAverageResponseTimeStmt, err = db.PrepareContext(ctx, getAverageResponseTime); err != nil {
 		return nil, fmt.Errorf("error preparing query GetAverageResponseTime: %w", err)
 	}
+	if q.getCostSinceStmt, err = db.PrepareContext(ctx, getCostSince); err != nil {
+		return nil, fmt.Errorf("error preparing query GetCostSince: %w", err)
+	}
 	if q.getFileStmt, err = db.PrepareContext(ctx, getFile); err != nil {
 		return nil, fmt.Errorf("error preparing query GetFile: %w", err)
 	}
@@ -90,6 +108,15 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.getUsageByModelStmt, err = db.PrepareContext(ctx, getUsageByModel); err != nil {
 		return nil, fmt.Errorf("error preparing query GetUsageByModel: %w", err)
 	}
+	if q.importFileStmt, err = db.PrepareContext(ctx, importFile); err != nil {
+		return nil, fmt.Errorf("error preparing query ImportFile: %w", err)
+	}
+	if q.importMessageStmt, err = db.PrepareContext(ctx, importMessage); err != nil {
+		return nil, fmt.Errorf("error preparing query ImportMessage: %w", err)
+	}
+	if q.importSessionStmt, err = db.PrepareContext(ctx, importSession); err != nil {
+		return nil, fmt.Errorf("error preparing query ImportSession: %w", err)
+	}
 	if q.listAllUserMessagesStmt, err = db.PrepareContext(ctx, listAllUserMessages); err != nil {
 		return nil, fmt.Errorf("error preparing query ListAllUserMessages: %w", err)
 	}
@@ -108,6 +135,15 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.listNewFilesStmt, err = db.PrepareContext(ctx, listNewFiles); err != nil {
 		return nil, fmt.Errorf("error preparing query ListNewFiles: %w", err)
 	}
+	if q.listPermissionAuditStmt, err = db.PrepareContext(ctx, listPermissionAudit); err != nil {
+		return nil, fmt.Errorf("error preparing query ListPermissionAudit: %w", err)
+	}
+	if q.listPermissionAuditBySessionStmt, err = db.PrepareContext(ctx, listPermissionAuditBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListPermissionAuditBySession: %w", err)
+	}
+	if q.listPermissionGrantsBySessionStmt, err = db.PrepareContext(ctx, listPermissionGrantsBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListPermissionGrantsBySession: %w", err)
+	}
 	if q.listSessionReadFilesStmt, err = db.PrepareContext(ctx, listSessionReadFiles); err != nil {
 		return nil, fmt.Errorf("error preparing query ListSessionReadFiles: %w", err)
 	}
@@ -120,6 +156,9 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.recordFileReadStmt, err = db.PrepareContext(ctx, recordFileRead); err != nil {
 		return nil, fmt.Errorf("error preparing query RecordFileRead: %w", err)
 	}
+	if q.recordSessionCostStmt, err = db.PrepareContext(ctx, recordSessionCost); err != nil {
+		return nil, fmt.Errorf("error preparing query RecordSessionCost: %w", err)
+	}
 	if q.updateMessageStmt, err = db.PrepareContext(ctx, updateMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateMessage: %w", err)
 	}
@@ -144,6 +183,16 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing createMessageStmt: %w", cerr)
 		}
 	}
+	if q.createPermissionAuditStmt != nil {
+		if cerr := q.createPermissionAuditStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createPermissionAuditStmt: %w", cerr)
+		}
+	}
+	if q.createPermissionGrantStmt != nil {
+		if cerr := q.createPermissionGrantStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createPermissionGrantStmt: %w", cerr)
+		}
+	}
 	if q.createSessionStmt != nil {
 		if cerr := q.createSessionStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing createSessionStmt: %w", cerr)
@@ -159,6 +208,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing deleteMessageStmt: %w", cerr)
 		}
 	}
+	if q.deletePermissionGrantStmt != nil {
+		if cerr := q.deletePermissionGrantStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deletePermissionGrantStmt: %w", cerr)
+		}
+	}
 	if q.deleteSessionStmt != nil {
 		if cerr := q.deleteSessionStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteSessionStmt: %w", cerr)
@@ -174,11 +228,26 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing deleteSessionMessagesStmt: %w", cerr)
 		}
 	}
+	if q.forkReadFilesStmt != nil {
+		if cerr := q.forkReadFilesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing forkReadFilesStmt: %w", cerr)
+		}
+	}
+	if q.forkSessionStmt != nil {
+		if cerr := q.forkSessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing forkSessionStmt: %w", cerr)
+		}
+	}
 	if q.getAverageResponseTimeStmt != nil {
 		if cerr := q.getAverageResponseTimeStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getAverageResponseTimeStmt: %w", cerr)
 		}
 	}
+	if q.getCostSinceStmt != nil {
+		if cerr := q.getCostSinceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getCostSinceStmt: %w", cerr)
+		}
+	}
 	if q.getFileStmt != nil {
 		if cerr := q.getFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getFileStmt: %w", cerr)
@@ -244,6 +313,21 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getUsageByModelStmt: %w", cerr)
 		}
 	}
+	if q.importFileStmt != nil {
+		if cerr := q.importFileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing importFileStmt: %w", cerr)
+		}
+	}
+	if q.importMessageStmt != nil {
+		if cerr := q.importMessageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing importMessageStmt: %w", cerr)
+		}
+	}
+	if q.importSessionStmt != nil {
+		if cerr := q.importSessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing importSessionStmt: %w", cerr)
+		}
+	}
 	if q.listAllUserMessagesStmt != nil {
 		if cerr := q.listAllUserMessagesStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listAllUserMessagesStmt: %w", cerr)
@@ -274,6 +358,21 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listNewFilesStmt: %w", cerr)
 		}
 	}
+	if q.listPermissionAuditStmt != nil {
+		if cerr := q.listPermissionAuditStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listPermissionAuditStmt: %w", cerr)
+		}
+	}
+	if q.listPermissionAuditBySessionStmt != nil {
+		if cerr := q.listPermissionAuditBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listPermissionAuditBySessionStmt: %w", cerr)
+		}
+	}
+	if q.listPermissionGrantsBySessionStmt != nil {
+		if cerr := q.listPermissionGrantsBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listPermissionGrantsBySessionStmt: %w", cerr)
+		}
+	}
 	if q.listSessionReadFilesStmt != nil {
 		if cerr := q.listSessionReadFilesStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listSessionReadFilesStmt: %w", cerr)
@@ -294,6 +393,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing recordFileReadStmt: %w", cerr)
 		}
 	}
+	if q.recordSessionCostStmt != nil {
+		if cerr := q.recordSessionCostStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing recordSessionCostStmt: %w", cerr)
+		}
+	}
 	if q.updateMessageStmt != nil {
 		if cerr := q.updateMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateMessageStmt: %w", cerr)
@@ -346,83 +450,109 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 }
 
 type Queries struct {
-	db                             DBTX
-	tx                             *sql.Tx
-	createFileStmt                 *sql.Stmt
-	createMessageStmt              *sql.Stmt
-	createSessionStmt              *sql.Stmt
-	deleteFileStmt                 *sql.Stmt
-	deleteMessageStmt              *sql.Stmt
-	deleteSessionStmt              *sql.Stmt
-	deleteSessionFilesStmt         *sql.Stmt
-	deleteSessionMessagesStmt      *sql.Stmt
-	getAverageResponseTimeStmt     *sql.Stmt
-	getFileStmt                    *sql.Stmt
-	getFileByPathAndSessionStmt    *sql.Stmt
-	getFileReadStmt                *sql.Stmt
-	getHourDayHeatmapStmt          *sql.Stmt
-	getMessageStmt                 *sql.Stmt
-	getRecentActivityStmt          *sql.Stmt
-	getSessionByIDStmt             *sql.Stmt
-	getToolUsageStmt               *sql.Stmt
-	getTotalStatsStmt              *sql.Stmt
-	getUsageByDayStmt              *sql.Stmt
-	getUsageByDayOfWeekStmt        *sql.Stmt
-	getUsageByHourStmt             *sql.Stmt
-	getUsageByModelStmt            *sql.Stmt
-	listAllUserMessagesStmt        *sql.Stmt
-	listFilesByPathStmt            *sql.Stmt
-	listFilesBySessionStmt         *sql.Stmt
-	listLatestSessionFilesStmt     *sql.Stmt
-	listMessagesBySessionStmt      *sql.Stmt
-	listNewFilesStmt               *sql.Stmt
-	listSessionReadFilesStmt       *sql.Stmt
-	listSessionsStmt               *sql.Stmt
-	listUserMessagesBySessionStmt  *sql.Stmt
-	recordFileReadStmt             *sql.Stmt
-	updateMessageStmt              *sql.Stmt
-	updateSessionStmt              *sql.Stmt
-	updateSessionTitleAndUsageStmt *sql.Stmt
+	db                                DBTX
+	tx                                *sql.Tx
+	createFileStmt                    *sql.Stmt
+	createMessageStmt                 *sql.Stmt
+	createPermissionAuditStmt         *sql.Stmt
+	createPermissionGrantStmt         *sql.Stmt
+	createSessionStmt                 *sql.Stmt
+	deleteFileStmt                    *sql.Stmt
+	deleteMessageStmt                 *sql.Stmt
+	deletePermissionGrantStmt         *sql.Stmt
+	deleteSessionStmt                 *sql.Stmt
+	deleteSessionFilesStmt            *sql.Stmt
+	deleteSessionMessagesStmt         *sql.Stmt
+	forkReadFilesStmt                 *sql.Stmt
+	forkSessionStmt                   *sql.Stmt
+	getAverageResponseTimeStmt        *sql.Stmt
+	getCostSinceStmt                  *sql.Stmt
+	getFileStmt                       *sql.Stmt
+	getFileByPathAndSessionStmt       *sql.Stmt
+	getFileReadStmt                   *sql.Stmt
+	getHourDayHeatmapStmt             *sql.Stmt
+	getMessageStmt                    *sql.Stmt
+	getRecentActivityStmt             *sql.Stmt
+	getSessionByIDStmt                *sql.Stmt
+	getToolUsageStmt                  *sql.Stmt
+	getTotalStatsStmt                 *sql.Stmt
+	getUsageByDayStmt                 *sql.Stmt
+	getUsageByDayOfWeekStmt           *sql.Stmt
+	getUsageByHourStmt                *sql.Stmt
+	getUsageByModelStmt               *sql.Stmt
+	importFileStmt                    *sql.Stmt
+	importMessageStmt                 *sql.Stmt
+	importSessionStmt                 *sql.Stmt
+	listAllUserMessagesStmt           *sql.Stmt
+	listFilesByPathStmt               *sql.Stmt
+	listFilesBySessionStmt            *sql.Stmt
+	listLatestSessionFilesStmt        *sql.Stmt
+	listMessagesBySessionStmt         *sql.Stmt
+	listNewFilesStmt                  *sql.Stmt
+	listPermissionAuditStmt           *sql.Stmt
+	listPermissionAuditBySessionStmt  *sql.Stmt
+	listPermissionGrantsBySessionStmt *sql.Stmt
+	listSessionReadFilesStmt          *sql.Stmt
+	listSessionsStmt                  *sql.Stmt
+	listUserMessagesBySessionStmt     *sql.Stmt
+	recordFileReadStmt                *sql.Stmt
+	recordSessionCostStmt             *sql.Stmt
+	updateMessageStmt                 *sql.Stmt
+	updateSessionStmt                 *sql.Stmt
+	updateSessionTitleAndUsageStmt    *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                             tx,
-		tx:                             tx,
-		createFileStmt:                 q.createFileStmt,
-		createMessageStmt:              q.createMessageStmt,
-		createSessionStmt:              q.createSessionStmt,
-		deleteFileStmt:                 q.deleteFileStmt,
-		deleteMessageStmt:              q.deleteMessageStmt,
-		deleteSessionStmt:              q.deleteSessionStmt,
-		deleteSessionFilesStmt:         q.deleteSessionFilesStmt,
-		deleteSessionMessagesStmt:      q.deleteSessionMessagesStmt,
-		getAverageResponseTimeStmt:     q.getAverageResponseTimeStmt,
-		getFileStmt:                    q.getFileStmt,
-		getFileByPathAndSessionStmt:    q.getFileByPathAndSessionStmt,
-		getFileReadStmt:                q.getFileReadStmt,
-		getHourDayHeatmapStmt:          q.getHourDayHeatmapStmt,
-		getMessageStmt:                 q.getMessageStmt,
-		getRecentActivityStmt:          q.getRecentActivityStmt,
-		getSessionByIDStmt:             q.getSessionByIDStmt,
-		getToolUsageStmt:               q.getToolUsageStmt,
-		getTotalStatsStmt:              q.getTotalStatsStmt,
-		getUsageByDayStmt:              q.getUsageByDayStmt,
-		getUsageByDayOfWeekStmt:        q.getUsageByDayOfWeekStmt,
-		getUsageByHourStmt:             q.getUsageByHourStmt,
-		getUsageByModelStmt:            q.getUsageByModelStmt,
-		listAllUserMessagesStmt:        q.listAllUserMessagesStmt,
-		listFilesByPathStmt:            q.listFilesByPathStmt,
-		listFilesBySessionStmt:         q.listFilesBySessionStmt,
-		listLatestSessionFilesStmt:     q.listLatestSessionFilesStmt,
-		listMessagesBySessionStmt:      q.listMessagesBySessionStmt,
-		listNewFilesStmt:               q.listNewFilesStmt,
-		listSessionReadFilesStmt:       q.listSessionReadFilesStmt,
-		listSessionsStmt:               q.listSessionsStmt,
-		listUserMessagesBySessionStmt:  q.listUserMessagesBySessionStmt,
-		recordFileReadStmt:             q.recordFileReadStmt,
-		updateMessageStmt:              q.updateMessageStmt,
-		updateSessionStmt:              q.updateSessionStmt,
-		updateSessionTitleAndUsageStmt: q.updateSessionTitleAndUsageStmt,
+		db:                                tx,
+		tx:                                tx,
+		createFileStmt:                    q.createFileStmt,
+		createMessageStmt:                 q.createMessageStmt,
+		createPermissionAuditStmt:         q.createPermissionAuditStmt,
+		createPermissionGrantStmt:         q.createPermissionGrantStmt,
+		createSessionStmt:                 q.createSessionStmt,
+		deleteFileStmt:                    q.deleteFileStmt,
+		deleteMessageStmt:                 q.deleteMessageStmt,
+		deletePermissionGrantStmt:         q.deletePermissionGrantStmt,
+		deleteSessionStmt:                 q.deleteSessionStmt,
+		deleteSessionFilesStmt:            q.deleteSessionFilesStmt,
+		deleteSessionMessagesStmt:         q.deleteSessionMessagesStmt,
+		forkReadFilesStmt:                 q.forkReadFilesStmt,
+		forkSessionStmt:                   q.forkSessionStmt,
+		getAverageResponseTimeStmt:        q.getAverageResponseTimeStmt,
+		getCostSinceStmt:                  q.getCostSinceStmt,
+		getFileStmt:                       q.getFileStmt,
+		getFileByPathAndSessionStmt:       q.getFileByPathAndSessionStmt,
+		getFileReadStmt:                   q.getFileReadStmt,
+		getHourDayHeatmapStmt:             q.getHourDayHeatmapStmt,
+		getMessageStmt:                    q.getMessageStmt,
+		getRecentActivityStmt:             q.getRecentActivityStmt,
+		getSessionByIDStmt:                q.getSessionByIDStmt,
+		getToolUsageStmt:                  q.getToolUsageStmt,
+		getTotalStatsStmt:                 q.getTotalStatsStmt,
+		getUsageByDayStmt:                 q.getUsageByDayStmt,
+		getUsageByDayOfWeekStmt:           q.getUsageByDayOfWeekStmt,
+		getUsageByHourStmt:                q.getUsageByHourStmt,
+		getUsageByModelStmt:               q.getUsageByModelStmt,
+		importFileStmt:                    q.importFileStmt,
+		importMessageStmt:                 q.importMessageStmt,
+		importSessionStmt:                 q.importSessionStmt,
+		listAllUserMessagesStmt:           q.listAllUserMessagesStmt,
+		listFilesByPathStmt:               q.listFilesByPathStmt,
+		listFilesBySessionStmt:            q.listFilesBySessionStmt,
+		listLatestSessionFilesStmt:        q.listLatestSessionFilesStmt,
+		listMessagesBySessionStmt:         q.listMessagesBySessionStmt,
+		listNewFilesStmt:                  q.listNewFilesStmt,
+		listPermissionAuditStmt:           q.listPermissionAuditStmt,
+		listPermissionAuditBySessionStmt:  q.listPermissionAuditBySessionStmt,
+		listPermissionGrantsBySessionStmt: q.listPermissionGrantsBySessionStmt,
+		listSessionReadFilesStmt:          q.listSessionReadFilesStmt,
+		listSessionsStmt:                  q.listSessionsStmt,
+		listUserMessagesBySessionStmt:     q.listUserMessagesBySessionStmt,
+		recordFileReadStmt:                q.recordFileReadStmt,
+		recordSessionCostStmt:             q.recordSessionCostStmt,
+		updateMessageStmt:                 q.updateMessageStmt,
+		updateSessionStmt:                 q.updateSessionStmt,
+		updateSessionTitleAndUsageStmt:    q.updateSessionTitleAndUsageStmt,
 	}
 }
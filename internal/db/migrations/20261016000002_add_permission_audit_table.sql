@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Entries are kept when their session is deleted, so there is no foreign key
+-- on session_id.
+CREATE TABLE IF NOT EXISTS permission_audit (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    tool_call_id TEXT NOT NULL,
+    tool_name TEXT NOT NULL,
+    action TEXT NOT NULL,
+    path TEXT NOT NULL,
+    params TEXT NOT NULL,
+    granted INTEGER NOT NULL,
+    source TEXT NOT NULL,
+    matched_rule TEXT NOT NULL,
+    created_at INTEGER NOT NULL  -- Unix timestamp in seconds
+);
+
+CREATE INDEX IF NOT EXISTS idx_permission_audit_session_id ON permission_audit (session_id);
+CREATE INDEX IF NOT EXISTS idx_permission_audit_created_at ON permission_audit (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_permission_audit_created_at;
+DROP INDEX IF EXISTS idx_permission_audit_session_id;
+DROP TABLE IF EXISTS permission_audit;
+-- +goose StatementEnd
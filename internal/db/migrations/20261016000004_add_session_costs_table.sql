@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Costs are kept when their session is deleted, as the money is spent all
+-- the same, so there is no foreign key on session_id.
+CREATE TABLE IF NOT EXISTS session_costs (
+    session_id TEXT NOT NULL,
+    cost REAL NOT NULL,
+    created_at INTEGER NOT NULL  -- Unix timestamp in seconds
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_costs_created_at ON session_costs (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_session_costs_created_at;
+DROP TABLE IF EXISTS session_costs;
+-- +goose StatementEnd
@@ -9,13 +9,15 @@ import (
 )
 
 type File struct {
-	ID        string `json:"id"`
-	SessionID string `json:"session_id"`
-	Path      string `json:"path"`
-	Content   string `json:"content"`
-	Version   int64  `json:"version"`
-	CreatedAt int64  `json:"created_at"`
-	UpdatedAt int64  `json:"updated_at"`
+	ID        string         `json:"id"`
+	SessionID string         `json:"session_id"`
+	Path      string         `json:"path"`
+	Content   string         `json:"content"`
+	Version   int64          `json:"version"`
+	CreatedAt int64          `json:"created_at"`
+	UpdatedAt int64          `json:"updated_at"`
+	MessageID sql.NullString `json:"message_id"`
+	IsNew     int64          `json:"is_new"`
 }
 
 type Message struct {
@@ -31,6 +33,29 @@ type Message struct {
 	IsSummaryMessage int64          `json:"is_summary_message"`
 }
 
+type PermissionAudit struct {
+	ID          string `json:"id"`
+	SessionID   string `json:"session_id"`
+	ToolCallID  string `json:"tool_call_id"`
+	ToolName    string `json:"tool_name"`
+	Action      string `json:"action"`
+	Path        string `json:"path"`
+	Params      string `json:"params"`
+	Granted     int64  `json:"granted"`
+	Source      string `json:"source"`
+	MatchedRule string `json:"matched_rule"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+type PermissionGrant struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	ToolName  string `json:"tool_name"`
+	Action    string `json:"action"`
+	Path      string `json:"path"`
+	CreatedAt int64  `json:"created_at"`
+}
+
 type ReadFile struct {
 	SessionID string `json:"session_id"`
 	Path      string `json:"path"`
@@ -49,4 +74,11 @@ type Session struct {
 	CreatedAt        int64          `json:"created_at"`
 	SummaryMessageID sql.NullString `json:"summary_message_id"`
 	Todos            sql.NullString `json:"todos"`
+	ForkMessageID    sql.NullString `json:"fork_message_id"`
+}
+
+type SessionCost struct {
+	SessionID string  `json:"session_id"`
+	Cost      float64 `json:"cost"`
+	CreatedAt int64   `json:"created_at"` // Unix timestamp in seconds
 }
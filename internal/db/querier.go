@@ -11,13 +11,19 @@ import (
 type Querier interface {
 	CreateFile(ctx context.Context, arg CreateFileParams) (File, error)
 	CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error)
+	CreatePermissionAudit(ctx context.Context, arg CreatePermissionAuditParams) error
+	CreatePermissionGrant(ctx context.Context, arg CreatePermissionGrantParams) (PermissionGrant, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
 	DeleteFile(ctx context.Context, id string) error
 	DeleteMessage(ctx context.Context, id string) error
+	DeletePermissionGrant(ctx context.Context, id string) error
 	DeleteSession(ctx context.Context, id string) error
 	DeleteSessionFiles(ctx context.Context, sessionID string) error
 	DeleteSessionMessages(ctx context.Context, sessionID string) error
+	ForkReadFiles(ctx context.Context, arg ForkReadFilesParams) error
+	ForkSession(ctx context.Context, arg ForkSessionParams) (Session, error)
 	GetAverageResponseTime(ctx context.Context) (int64, error)
+	GetCostSince(ctx context.Context, createdAt int64) (float64, error)
 	GetFile(ctx context.Context, id string) (File, error)
 	GetFileByPathAndSession(ctx context.Context, arg GetFileByPathAndSessionParams) (File, error)
 	GetFileRead(ctx context.Context, arg GetFileReadParams) (ReadFile, error)
@@ -31,16 +37,23 @@ type Querier interface {
 	GetUsageByDayOfWeek(ctx context.Context) ([]GetUsageByDayOfWeekRow, error)
 	GetUsageByHour(ctx context.Context) ([]GetUsageByHourRow, error)
 	GetUsageByModel(ctx context.Context) ([]GetUsageByModelRow, error)
+	ImportFile(ctx context.Context, arg ImportFileParams) error
+	ImportMessage(ctx context.Context, arg ImportMessageParams) error
+	ImportSession(ctx context.Context, arg ImportSessionParams) error
 	ListAllUserMessages(ctx context.Context) ([]Message, error)
 	ListFilesByPath(ctx context.Context, path string) ([]File, error)
 	ListFilesBySession(ctx context.Context, sessionID string) ([]File, error)
 	ListLatestSessionFiles(ctx context.Context, sessionID string) ([]File, error)
 	ListMessagesBySession(ctx context.Context, sessionID string) ([]Message, error)
 	ListNewFiles(ctx context.Context) ([]File, error)
+	ListPermissionAudit(ctx context.Context) ([]PermissionAudit, error)
+	ListPermissionAuditBySession(ctx context.Context, sessionID string) ([]PermissionAudit, error)
+	ListPermissionGrantsBySession(ctx context.Context, sessionID string) ([]PermissionGrant, error)
 	ListSessionReadFiles(ctx context.Context, sessionID string) ([]ReadFile, error)
 	ListSessions(ctx context.Context) ([]Session, error)
 	ListUserMessagesBySession(ctx context.Context, sessionID string) ([]Message, error)
 	RecordFileRead(ctx context.Context, arg RecordFileReadParams) error
+	RecordSessionCost(ctx context.Context, arg RecordSessionCostParams) error
 	UpdateMessage(ctx context.Context, arg UpdateMessageParams) error
 	UpdateSession(ctx context.Context, arg UpdateSessionParams) (Session, error)
 	UpdateSessionTitleAndUsage(ctx context.Context, arg UpdateSessionTitleAndUsageParams) error
@@ -9,6 +9,30 @@ import (
 	"context"
 )
 
+const forkReadFiles = `-- name: ForkReadFiles :exec
+INSERT INTO read_files (
+    session_id,
+    path,
+    read_at
+)
+SELECT
+    ?,
+    path,
+    read_at
+FROM read_files
+WHERE session_id = ?
+`
+
+type ForkReadFilesParams struct {
+	ForkSessionID string `json:"fork_session_id"`
+	SessionID     string `json:"session_id"`
+}
+
+func (q *Queries) ForkReadFiles(ctx context.Context, arg ForkReadFilesParams) error {
+	_, err := q.exec(ctx, q.forkReadFilesStmt, forkReadFiles, arg.ForkSessionID, arg.SessionID)
+	return err
+}
+
 const getFileRead = `-- name: GetFileRead :one
 SELECT session_id, path, read_at FROM read_files
 WHERE session_id = ? AND path = ? LIMIT 1
@@ -33,7 +33,7 @@ INSERT INTO sessions (
     null,
     strftime('%s', 'now'),
     strftime('%s', 'now')
-) RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, todos
+) RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, todos, fork_message_id
 `
 
 type CreateSessionParams struct {
@@ -69,6 +69,7 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (S
 		&i.CreatedAt,
 		&i.SummaryMessageID,
 		&i.Todos,
+		&i.ForkMessageID,
 	)
 	return i, err
 }
@@ -83,8 +84,63 @@ func (q *Queries) DeleteSession(ctx context.Context, id string) error {
 	return err
 }
 
+const forkSession = `-- name: ForkSession :one
+INSERT INTO sessions (
+    id,
+    parent_session_id,
+    fork_message_id,
+    title,
+    message_count,
+    prompt_tokens,
+    completion_tokens,
+    cost,
+    summary_message_id,
+    todos,
+    updated_at,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, 0, 0, 0, 0.0, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+) RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, todos, fork_message_id
+`
+
+type ForkSessionParams struct {
+	ID               string         `json:"id"`
+	ParentSessionID  sql.NullString `json:"parent_session_id"`
+	ForkMessageID    sql.NullString `json:"fork_message_id"`
+	Title            string         `json:"title"`
+	SummaryMessageID sql.NullString `json:"summary_message_id"`
+	Todos            sql.NullString `json:"todos"`
+}
+
+func (q *Queries) ForkSession(ctx context.Context, arg ForkSessionParams) (Session, error) {
+	row := q.queryRow(ctx, q.forkSessionStmt, forkSession,
+		arg.ID,
+		arg.ParentSessionID,
+		arg.ForkMessageID,
+		arg.Title,
+		arg.SummaryMessageID,
+		arg.Todos,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.ParentSessionID,
+		&i.Title,
+		&i.MessageCount,
+		&i.PromptTokens,
+		&i.CompletionTokens,
+		&i.Cost,
+		&i.UpdatedAt,
+		&i.CreatedAt,
+		&i.SummaryMessageID,
+		&i.Todos,
+		&i.ForkMessageID,
+	)
+	return i, err
+}
+
 const getSessionByID = `-- name: GetSessionByID :one
-SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, todos
+SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, todos, fork_message_id
 FROM sessions
 WHERE id = ? LIMIT 1
 `
@@ -104,14 +160,62 @@ func (q *Queries) GetSessionByID(ctx context.Context, id string) (Session, error
 		&i.CreatedAt,
 		&i.SummaryMessageID,
 		&i.Todos,
+		&i.ForkMessageID,
 	)
 	return i, err
 }
 
+const importSession = `-- name: ImportSession :exec
+INSERT INTO sessions (
+    id,
+    parent_session_id,
+    title,
+    message_count,
+    prompt_tokens,
+    completion_tokens,
+    cost,
+    summary_message_id,
+    todos,
+    updated_at,
+    created_at
+) VALUES (
+    ?, ?, ?, 0, ?, ?, ?, ?, ?, ?, ?
+)
+`
+
+type ImportSessionParams struct {
+	ID               string         `json:"id"`
+	ParentSessionID  sql.NullString `json:"parent_session_id"`
+	Title            string         `json:"title"`
+	PromptTokens     int64          `json:"prompt_tokens"`
+	CompletionTokens int64          `json:"completion_tokens"`
+	Cost             float64        `json:"cost"`
+	SummaryMessageID sql.NullString `json:"summary_message_id"`
+	Todos            sql.NullString `json:"todos"`
+	UpdatedAt        int64          `json:"updated_at"`
+	CreatedAt        int64          `json:"created_at"`
+}
+
+func (q *Queries) ImportSession(ctx context.Context, arg ImportSessionParams) error {
+	_, err := q.exec(ctx, q.importSessionStmt, importSession,
+		arg.ID,
+		arg.ParentSessionID,
+		arg.Title,
+		arg.PromptTokens,
+		arg.CompletionTokens,
+		arg.Cost,
+		arg.SummaryMessageID,
+		arg.Todos,
+		arg.UpdatedAt,
+		arg.CreatedAt,
+	)
+	return err
+}
+
 const listSessions = `-- name: ListSessions :many
-SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, todos
+SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, todos, fork_message_id
 FROM sessions
-WHERE parent_session_id is NULL
+WHERE parent_session_id is NULL OR fork_message_id IS NOT NULL
 ORDER BY updated_at DESC
 `
 
@@ -136,6 +240,7 @@ func (q *Queries) ListSessions(ctx context.Context) ([]Session, error) {
 			&i.CreatedAt,
 			&i.SummaryMessageID,
 			&i.Todos,
+			&i.ForkMessageID,
 		); err != nil {
 			return nil, err
 		}
@@ -160,7 +265,7 @@ SET
     cost = ?,
     todos = ?
 WHERE id = ?
-RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, todos
+RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, todos, fork_message_id
 `
 
 type UpdateSessionParams struct {
@@ -196,6 +301,7 @@ func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) (S
 		&i.CreatedAt,
 		&i.SummaryMessageID,
 		&i.Todos,
+		&i.ForkMessageID,
 	)
 	return i, err
 }
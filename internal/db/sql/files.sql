@@ -29,13 +29,30 @@ INSERT INTO files (
     path,
     content,
     version,
+    message_id,
+    is_new,
     created_at,
     updated_at
 ) VALUES (
-    ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+    ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
 )
 RETURNING *;
 
+-- name: ImportFile :exec
+INSERT INTO files (
+    id,
+    session_id,
+    path,
+    content,
+    version,
+    message_id,
+    is_new,
+    created_at,
+    updated_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?
+);
+
 -- name: DeleteFile :exec
 DELETE FROM files
 WHERE id = ?;
@@ -0,0 +1,27 @@
+-- name: CreatePermissionAudit :exec
+INSERT INTO permission_audit (
+    id,
+    session_id,
+    tool_call_id,
+    tool_name,
+    action,
+    path,
+    params,
+    granted,
+    source,
+    matched_rule,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now')
+);
+
+-- name: ListPermissionAudit :many
+SELECT *
+FROM permission_audit
+ORDER BY created_at ASC, rowid ASC;
+
+-- name: ListPermissionAuditBySession :many
+SELECT *
+FROM permission_audit
+WHERE session_id = ?
+ORDER BY created_at ASC, rowid ASC;
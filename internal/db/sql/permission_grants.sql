@@ -0,0 +1,23 @@
+-- name: CreatePermissionGrant :one
+INSERT INTO permission_grants (
+    id,
+    session_id,
+    tool_name,
+    action,
+    path,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, strftime('%s', 'now')
+) ON CONFLICT(session_id, tool_name, action, path) DO UPDATE SET
+    created_at = excluded.created_at
+RETURNING *;
+
+-- name: ListPermissionGrantsBySession :many
+SELECT *
+FROM permission_grants
+WHERE session_id = ?
+ORDER BY created_at ASC;
+
+-- name: DeletePermissionGrant :exec
+DELETE FROM permission_grants
+WHERE id = ?;
@@ -10,6 +10,19 @@ INSERT INTO read_files (
 ) ON CONFLICT(path, session_id) DO UPDATE SET
     read_at = excluded.read_at;
 
+-- name: ForkReadFiles :exec
+INSERT INTO read_files (
+    session_id,
+    path,
+    read_at
+)
+SELECT
+    sqlc.arg(fork_session_id),
+    path,
+    read_at
+FROM read_files
+WHERE session_id = sqlc.arg(session_id);
+
 -- name: GetFileRead :one
 SELECT * FROM read_files
 WHERE session_id = ? AND path = ? LIMIT 1;
@@ -0,0 +1,13 @@
+-- name: RecordSessionCost :exec
+INSERT INTO session_costs (
+    session_id,
+    cost,
+    created_at
+) VALUES (
+    ?, ?, strftime('%s', 'now')
+);
+
+-- name: GetCostSince :one
+SELECT CAST(COALESCE(SUM(cost), 0) AS REAL) AS cost
+FROM session_costs
+WHERE created_at >= ?;
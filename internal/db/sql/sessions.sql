@@ -23,6 +23,41 @@ INSERT INTO sessions (
     strftime('%s', 'now')
 ) RETURNING *;
 
+-- name: ImportSession :exec
+INSERT INTO sessions (
+    id,
+    parent_session_id,
+    title,
+    message_count,
+    prompt_tokens,
+    completion_tokens,
+    cost,
+    summary_message_id,
+    todos,
+    updated_at,
+    created_at
+) VALUES (
+    ?, ?, ?, 0, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: ForkSession :one
+INSERT INTO sessions (
+    id,
+    parent_session_id,
+    fork_message_id,
+    title,
+    message_count,
+    prompt_tokens,
+    completion_tokens,
+    cost,
+    summary_message_id,
+    todos,
+    updated_at,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, 0, 0, 0, 0.0, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+) RETURNING *;
+
 -- name: GetSessionByID :one
 SELECT *
 FROM sessions
@@ -31,7 +66,7 @@ WHERE id = ? LIMIT 1;
 -- name: ListSessions :many
 SELECT *
 FROM sessions
-WHERE parent_session_id is NULL
+WHERE parent_session_id is NULL OR fork_message_id IS NOT NULL
 ORDER BY updated_at DESC;
 
 -- name: UpdateSession :one
@@ -15,12 +15,30 @@ const (
 	InitialVersion = 0
 )
 
+type messageIDContextKey struct{}
+
+// WithMessageID returns a copy of ctx that attributes file versions created
+// with it to the given message. This is what ties file history to a point in
+// the conversation so it can later be rewound.
+func WithMessageID(ctx context.Context, messageID string) context.Context {
+	return context.WithValue(ctx, messageIDContextKey{}, messageID)
+}
+
+func messageIDFromContext(ctx context.Context) string {
+	messageID, _ := ctx.Value(messageIDContextKey{}).(string)
+	return messageID
+}
+
 type File struct {
 	ID        string
 	SessionID string
+	MessageID string
 	Path      string
 	Content   string
 	Version   int64
+	// IsNew tells whether the file did not exist before this version, which
+	// is then empty.
+	IsNew     bool
 	CreatedAt int64
 	UpdatedAt int64
 }
@@ -33,6 +51,10 @@ type Service interface {
 	// CreateVersion creates a new version of a file.
 	CreateVersion(ctx context.Context, sessionID, path, content string) (File, error)
 
+	// CreateNew records that a file did not exist yet, as an empty version
+	// marked as new, before the first version of its content.
+	CreateNew(ctx context.Context, sessionID, path string) (File, error)
+
 	Get(ctx context.Context, id string) (File, error)
 	GetByPathAndSession(ctx context.Context, path, sessionID string) (File, error)
 	ListBySession(ctx context.Context, sessionID string) ([]File, error)
@@ -56,13 +78,21 @@ func NewService(q *db.Queries, db *sql.DB) Service {
 }
 
 func (s *service) Create(ctx context.Context, sessionID, path, content string) (File, error) {
-	return s.createWithVersion(ctx, sessionID, path, content, InitialVersion)
+	return s.createWithVersion(ctx, sessionID, path, content, InitialVersion, false)
 }
 
 // CreateVersion creates a new version of a file with auto-incremented version
 // number. If no previous versions exist for the path, it creates the initial
 // version. The provided content is stored as the new version.
 func (s *service) CreateVersion(ctx context.Context, sessionID, path, content string) (File, error) {
+	return s.createNextVersion(ctx, sessionID, path, content, false)
+}
+
+func (s *service) CreateNew(ctx context.Context, sessionID, path string) (File, error) {
+	return s.createNextVersion(ctx, sessionID, path, "", true)
+}
+
+func (s *service) createNextVersion(ctx context.Context, sessionID, path, content string, isNew bool) (File, error) {
 	// Get the latest version for this path
 	files, err := s.q.ListFilesByPath(ctx, path)
 	if err != nil {
@@ -71,21 +101,22 @@ func (s *service) CreateVersion(ctx context.Context, sessionID, path, content st
 
 	if len(files) == 0 {
 		// No previous versions, create initial
-		return s.Create(ctx, sessionID, path, content)
+		return s.createWithVersion(ctx, sessionID, path, content, InitialVersion, isNew)
 	}
 
 	// Get the latest version
 	latestFile := files[0] // Files are ordered by version DESC, created_at DESC
 	nextVersion := latestFile.Version + 1
 
-	return s.createWithVersion(ctx, sessionID, path, content, nextVersion)
+	return s.createWithVersion(ctx, sessionID, path, content, nextVersion, isNew)
 }
 
-func (s *service) createWithVersion(ctx context.Context, sessionID, path, content string, version int64) (File, error) {
+func (s *service) createWithVersion(ctx context.Context, sessionID, path, content string, version int64, isNew bool) (File, error) {
 	// Maximum number of retries for transaction conflicts
 	const maxRetries = 3
 	var file File
 	var err error
+	messageID := messageIDFromContext(ctx)
 
 	// Retry loop for transaction conflicts
 	for attempt := range maxRetries {
@@ -105,6 +136,8 @@ func (s *service) createWithVersion(ctx context.Context, sessionID, path, conten
 			Path:      path,
 			Content:   content,
 			Version:   version,
+			MessageID: sql.NullString{String: messageID, Valid: messageID != ""},
+			IsNew:     boolToInt(isNew),
 		})
 		if txErr != nil {
 			// Rollback the transaction
@@ -208,10 +241,19 @@ func (s *service) fromDBItem(item db.File) File {
 	return File{
 		ID:        item.ID,
 		SessionID: item.SessionID,
+		MessageID: item.MessageID.String,
 		Path:      item.Path,
 		Content:   item.Content,
 		Version:   item.Version,
+		IsNew:     item.IsNew != 0,
 		CreatedAt: item.CreatedAt,
 		UpdatedAt: item.UpdatedAt,
 	}
 }
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,269 @@
+// Package hooks runs user-defined shell commands at points of the agent
+// loop, so teams can add their own policy without changing the tools.
+//
+// Hooks are configured in the hooks section of crush.json, by event. Each
+// hook receives the event as JSON on stdin and answers through its exit code
+// and output:
+//
+//   - Exit code 0 lets the agent carry on. If stdout is a JSON object it is
+//     read as an [Output]; otherwise, for UserPromptSubmit and SessionStart,
+//     stdout is added to the conversation as extra context.
+//   - Exit code 2 blocks the action, with stderr as the reason.
+//   - Any other exit code, a timeout or output that cannot be read is a
+//     failure. Failed PreToolUse hooks block the tool call, so a broken
+//     policy never lets a call through; other failed hooks are logged and
+//     otherwise ignored.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/shell"
+)
+
+// Event is a point of the agent loop hooks can run on.
+type Event string
+
+const (
+	// PreToolUse runs before a tool call. It can block the call or rewrite
+	// its input.
+	PreToolUse Event = "PreToolUse"
+	// PostToolUse runs after a tool call. It can flag the result as an error
+	// or add context to it.
+	PostToolUse Event = "PostToolUse"
+	// UserPromptSubmit runs before a prompt is sent. It can reject the prompt
+	// or add context to it.
+	UserPromptSubmit Event = "UserPromptSubmit"
+	// Stop runs when the agent is done. Blocking it makes the agent carry on,
+	// with the reason as the next prompt.
+	Stop Event = "Stop"
+	// SessionStart runs before the first prompt of a session. It can add
+	// context to the session.
+	SessionStart Event = "SessionStart"
+)
+
+// Events lists the supported events.
+var Events = []Event{PreToolUse, PostToolUse, UserPromptSubmit, Stop, SessionStart}
+
+const (
+	// DefaultTimeout is how long a hook may run when no timeout is set.
+	DefaultTimeout = 60 * time.Second
+
+	// blockExitCode is the exit code hooks use to block an action.
+	blockExitCode = 2
+)
+
+// Hook is a command run on an event.
+type Hook struct {
+	Matcher string `json:"matcher,omitempty" jsonschema:"description=Tool name glob the hook applies to for PreToolUse and PostToolUse; alternatives are separated by |; empty matches every tool,example=edit|multiedit|write,example=mcp_*"`
+	Command string `json:"command" jsonschema:"required,description=Shell command to run; it receives the event as JSON on stdin,example=gofmt -w \"$(jq -r .tool_input.file_path)\""`
+	Timeout int    `json:"timeout,omitempty" jsonschema:"description=Timeout in seconds for the command,default=60,example=10"`
+}
+
+// Validate reports unknown events, hooks without a command and invalid
+// matchers in the hooks configured for each event.
+func Validate(config map[Event][]Hook) error {
+	for event, hooks := range config {
+		if !slices.Contains(Events, event) {
+			return fmt.Errorf("unknown hook event %q", event)
+		}
+		for i, h := range hooks {
+			if strings.TrimSpace(h.Command) == "" {
+				return fmt.Errorf("hook %d of %s has no command", i, event)
+			}
+			for pattern := range strings.SplitSeq(h.Matcher, "|") {
+				if _, err := filepath.Match(strings.TrimSpace(pattern), ""); err != nil {
+					return fmt.Errorf("hook %d of %s: invalid matcher %q: %w", i, event, h.Matcher, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (h Hook) matches(toolName string) bool {
+	if h.Matcher == "" || toolName == "" {
+		return true
+	}
+	for pattern := range strings.SplitSeq(h.Matcher, "|") {
+		if ok, _ := filepath.Match(strings.TrimSpace(pattern), toolName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Input is the JSON payload hooks receive on stdin.
+type Input struct {
+	Event      Event  `json:"hook_event_name"`
+	SessionID  string `json:"session_id"`
+	WorkingDir string `json:"cwd"`
+
+	// ToolName and ToolInput are set for PreToolUse and PostToolUse.
+	ToolName  string          `json:"tool_name,omitempty"`
+	ToolInput json.RawMessage `json:"tool_input,omitempty"`
+	// ToolResponse is set for PostToolUse.
+	ToolResponse *ToolResponse `json:"tool_response,omitempty"`
+
+	// Prompt is set for UserPromptSubmit.
+	Prompt string `json:"prompt,omitempty"`
+
+	// StopHookActive is set for Stop when the agent is already carrying on
+	// because of a Stop hook, so hooks can avoid blocking forever.
+	StopHookActive bool `json:"stop_hook_active,omitempty"`
+}
+
+// ToolResponse is the result of a tool call as hooks see it.
+type ToolResponse struct {
+	Content string `json:"content"`
+	IsError bool   `json:"is_error"`
+}
+
+// Output is the JSON object hooks may print on stdout.
+type Output struct {
+	// Decision is "block" to block the action.
+	Decision string `json:"decision,omitempty"`
+	// Reason explains a block. It is shown to the model, or to the user for
+	// UserPromptSubmit.
+	Reason string `json:"reason,omitempty"`
+	// ToolInput replaces the input of the tool call, for PreToolUse.
+	ToolInput json.RawMessage `json:"tool_input,omitempty"`
+	// AdditionalContext is added to the conversation.
+	AdditionalContext string `json:"additional_context,omitempty"`
+}
+
+// Result is the combined outcome of the hooks run for an event.
+type Result struct {
+	Blocked bool
+	Reason  string
+	// ToolInput is the rewritten tool input, if any hook rewrote it.
+	ToolInput json.RawMessage
+	// AdditionalContext is the context added by every hook, in order.
+	AdditionalContext string
+}
+
+// BlockedError is returned when a hook blocks an action that has no other
+// way to report it.
+type BlockedError struct {
+	Event  Event
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("blocked by %s hook", e.Event)
+	}
+	return fmt.Sprintf("blocked by %s hook: %s", e.Event, e.Reason)
+}
+
+// Runner runs the configured hooks.
+type Runner struct {
+	hooks      map[Event][]Hook
+	workingDir string
+}
+
+// NewRunner returns a runner for the given hooks, run in workingDir.
+func NewRunner(hooks map[Event][]Hook, workingDir string) *Runner {
+	return &Runner{hooks: hooks, workingDir: workingDir}
+}
+
+// Has reports whether any hook is configured for event.
+func (r *Runner) Has(event Event) bool {
+	return r != nil && len(r.hooks[event]) > 0
+}
+
+// Run runs the hooks of in.Event that match in.ToolName, in order, and
+// combines their outcome. Running stops at the first hook that blocks. A
+// PreToolUse hook that rewrites the tool input passes the new input on to
+// the next hook. A PreToolUse hook that fails blocks the call with the
+// error as the reason; other hooks that fail are logged and ignored.
+func (r *Runner) Run(ctx context.Context, in Input) Result {
+	var result Result
+	if !r.Has(in.Event) {
+		return result
+	}
+	in.WorkingDir = r.workingDir
+
+	var contexts []string
+	for _, h := range r.hooks[in.Event] {
+		if !h.matches(in.ToolName) {
+			continue
+		}
+		out, err := r.run(ctx, h, in)
+		if err != nil {
+			slog.Warn("Hook failed", "event", in.Event, "command", h.Command, "error", err)
+			if in.Event == PreToolUse {
+				result.Blocked = true
+				result.Reason = fmt.Sprintf("hook %q failed: %v", h.Command, err)
+				break
+			}
+			continue
+		}
+		if out.AdditionalContext != "" {
+			contexts = append(contexts, out.AdditionalContext)
+		}
+		if len(out.ToolInput) > 0 && in.Event == PreToolUse {
+			in.ToolInput = out.ToolInput
+			result.ToolInput = out.ToolInput
+		}
+		if out.Decision == "block" {
+			result.Blocked = true
+			result.Reason = out.Reason
+			break
+		}
+	}
+	result.AdditionalContext = strings.Join(contexts, "\n\n")
+	return result
+}
+
+func (r *Runner) run(ctx context.Context, h Hook, in Input) (Output, error) {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return Output{}, err
+	}
+
+	timeout := DefaultTimeout
+	if h.Timeout > 0 {
+		timeout = time.Duration(h.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	sh := shell.NewShell(&shell.Options{
+		WorkingDir: r.workingDir,
+		Env: append(
+			os.Environ(),
+			"CRUSH_PROJECT_DIR="+r.workingDir,
+			"CRUSH_HOOK_EVENT="+string(in.Event),
+		),
+	})
+	stdout, stderr, err := sh.ExecInput(ctx, h.Command, bytes.NewReader(payload))
+	switch code := shell.ExitCode(err); {
+	case code == blockExitCode:
+		return Output{Decision: "block", Reason: strings.TrimSpace(stderr)}, nil
+	case err != nil:
+		return Output{}, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr))
+	}
+
+	stdout = strings.TrimSpace(stdout)
+	if strings.HasPrefix(stdout, "{") {
+		var out Output
+		if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+			return Output{}, fmt.Errorf("invalid output: %w", err)
+		}
+		return out, nil
+	}
+	if in.Event == UserPromptSubmit || in.Event == SessionStart {
+		return Output{AdditionalContext: stdout}, nil
+	}
+	return Output{}, nil
+}
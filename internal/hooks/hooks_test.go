@@ -0,0 +1,153 @@
+package hooks
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunner_Run(t *testing.T) {
+	t.Parallel()
+
+	toolInput := json.RawMessage(`{"file_path":"main.go"}`)
+
+	tests := []struct {
+		name  string
+		hooks map[Event][]Hook
+		in    Input
+		want  Result
+	}{
+		{
+			name: "no hooks",
+			in:   Input{Event: PreToolUse, ToolName: "edit"},
+		},
+		{
+			name:  "exit code 2 blocks",
+			hooks: map[Event][]Hook{PreToolUse: {{Command: "echo generated file >&2; exit 2"}}},
+			in:    Input{Event: PreToolUse, ToolName: "edit", ToolInput: toolInput},
+			want:  Result{Blocked: true, Reason: "generated file"},
+		},
+		{
+			name:  "matcher skips other tools",
+			hooks: map[Event][]Hook{PreToolUse: {{Matcher: "edit|write", Command: "exit 2"}}},
+			in:    Input{Event: PreToolUse, ToolName: "bash"},
+		},
+		{
+			name:  "matcher globs",
+			hooks: map[Event][]Hook{PreToolUse: {{Matcher: "bash | mcp_*", Command: "exit 2"}}},
+			in:    Input{Event: PreToolUse, ToolName: "mcp_github_create_issue"},
+			want:  Result{Blocked: true},
+		},
+		{
+			name:  "failing hooks are ignored",
+			hooks: map[Event][]Hook{PostToolUse: {{Command: "exit 1"}, {Command: "echo '{not json'"}}},
+			in:    Input{Event: PostToolUse, ToolName: "edit"},
+		},
+		{
+			name:  "failing PreToolUse hooks block",
+			hooks: map[Event][]Hook{PreToolUse: {{Command: "echo broken >&2; exit 1"}, {Command: "echo never run"}}},
+			in:    Input{Event: PreToolUse, ToolName: "edit"},
+			want:  Result{Blocked: true, Reason: `hook "echo broken >&2; exit 1" failed: exit status 1: broken`},
+		},
+		{
+			name:  "invalid PreToolUse output blocks",
+			hooks: map[Event][]Hook{PreToolUse: {{Command: "echo '{not json'"}}},
+			in:    Input{Event: PreToolUse, ToolName: "edit"},
+			want:  Result{Blocked: true, Reason: `hook "echo '{not json'" failed: invalid output: invalid character 'n' looking for beginning of object key string`},
+		},
+		{
+			name: "JSON output rewrites input and blocks",
+			hooks: map[Event][]Hook{PreToolUse: {
+				{Command: `echo '{"tool_input":{"file_path":"other.go"}}'`},
+				{Command: `echo '{"decision":"block","reason":"nope","additional_context":"why"}'`},
+				{Command: "echo never run >&2; exit 2"},
+			}},
+			in: Input{Event: PreToolUse, ToolName: "edit", ToolInput: toolInput},
+			want: Result{
+				Blocked:           true,
+				Reason:            "nope",
+				ToolInput:         json.RawMessage(`{"file_path":"other.go"}`),
+				AdditionalContext: "why",
+			},
+		},
+		{
+			name:  "plain output is context for prompts",
+			hooks: map[Event][]Hook{UserPromptSubmit: {{Command: "echo one"}, {Command: "echo two"}}},
+			in:    Input{Event: UserPromptSubmit, Prompt: "hi"},
+			want:  Result{AdditionalContext: "one\n\ntwo"},
+		},
+		{
+			name:  "plain output is ignored for tools",
+			hooks: map[Event][]Hook{PostToolUse: {{Command: "echo formatted"}}},
+			in:    Input{Event: PostToolUse, ToolName: "edit"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := NewRunner(tt.hooks, t.TempDir())
+			require.Equal(t, tt.want, r.Run(t.Context(), tt.in))
+		})
+	}
+}
+
+func TestRunner_Timeout(t *testing.T) {
+	t.Parallel()
+
+	r := NewRunner(map[Event][]Hook{
+		PreToolUse:  {{Command: "sleep 10", Timeout: 1}},
+		PostToolUse: {{Command: "sleep 10", Timeout: 1}},
+	}, t.TempDir())
+
+	pre := r.Run(t.Context(), Input{Event: PreToolUse, ToolName: "edit"})
+	require.True(t, pre.Blocked)
+	require.Contains(t, pre.Reason, "sleep 10")
+
+	post := r.Run(t.Context(), Input{Event: PostToolUse, ToolName: "edit"})
+	require.False(t, post.Blocked)
+}
+
+func TestRunner_Payload(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	r := NewRunner(map[Event][]Hook{
+		PostToolUse: {{Command: `cat > payload.json; echo "$CRUSH_HOOK_EVENT" > event`}},
+	}, dir)
+	r.Run(t.Context(), Input{
+		Event:        PostToolUse,
+		SessionID:    "session",
+		ToolName:     "edit",
+		ToolInput:    json.RawMessage(`{"file_path":"main.go"}`),
+		ToolResponse: &ToolResponse{Content: "done"},
+	})
+
+	data, err := os.ReadFile(filepath.Join(dir, "payload.json"))
+	require.NoError(t, err)
+	var in Input
+	require.NoError(t, json.Unmarshal(data, &in))
+	require.Equal(t, PostToolUse, in.Event)
+	require.Equal(t, "session", in.SessionID)
+	require.Equal(t, dir, in.WorkingDir)
+	require.JSONEq(t, `{"file_path":"main.go"}`, string(in.ToolInput))
+	require.Equal(t, &ToolResponse{Content: "done"}, in.ToolResponse)
+
+	event, err := os.ReadFile(filepath.Join(dir, "event"))
+	require.NoError(t, err)
+	require.Equal(t, "PostToolUse\n", string(event))
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, Validate(map[Event][]Hook{
+		PreToolUse: {{Matcher: "edit|write", Command: "true"}},
+		Stop:       {{Command: "true"}},
+	}))
+	require.ErrorContains(t, Validate(map[Event][]Hook{"PreEdit": {{Command: "true"}}}), `unknown hook event "PreEdit"`)
+	require.ErrorContains(t, Validate(map[Event][]Hook{Stop: {{Command: " "}}}), "has no command")
+	require.ErrorContains(t, Validate(map[Event][]Hook{PreToolUse: {{Matcher: "[", Command: "true"}}}), "invalid matcher")
+}
@@ -0,0 +1,242 @@
+// Package mcpserver exposes Crush as an MCP server, so other agents and
+// editors can use its tools.
+//
+// The built-in tools run in a Crush session of their own for each client,
+// without asking for permission: the client asks its user before calling a
+// tool. The run_crush_task tool has the Crush agent carry out a task, and
+// forwards the permission requests of the agent to the client.
+package mcpserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/server"
+	"github.com/charmbracelet/crush/internal/version"
+	"github.com/google/uuid"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Options configures the server.
+type Options struct {
+	// Task publishes the run_crush_task tool, which has the Crush agent
+	// carry out a task.
+	Task bool
+	// Token is the bearer token HTTP clients must send, if not empty.
+	Token string
+}
+
+// Server serves the tools of an [app.App] over MCP.
+type Server struct {
+	app    *app.App
+	server *mcp.Server
+	token  string
+
+	// sessions holds the Crush session the built-in tools run in, by MCP
+	// session ID.
+	sessions *csync.Map[string, string]
+	// tasks holds the client running a task, by the session of the task.
+	tasks *csync.Map[string, *mcp.ServerSession]
+}
+
+// New creates a server for the given app.
+func New(app *app.App, opts Options) *Server {
+	s := &Server{
+		app: app,
+		server: mcp.NewServer(&mcp.Implementation{
+			Name:    "crush",
+			Title:   "Crush",
+			Version: version.Version,
+		}, nil),
+		token:    opts.Token,
+		sessions: csync.NewMap[string, string](),
+		tasks:    csync.NewMap[string, *mcp.ServerSession](),
+	}
+	for _, tool := range s.builtinTools() {
+		s.addTool(tool)
+	}
+	if opts.Task && app.AgentCoordinator != nil {
+		mcp.AddTool(s.server, &mcp.Tool{
+			Name:        TaskToolName,
+			Description: taskToolDescription,
+		}, s.runTask)
+	}
+	return s
+}
+
+// ServeStdio serves a client over stdin and stdout until ctx is done or
+// the client disconnects.
+func (s *Server) ServeStdio(ctx context.Context) error {
+	go s.forwardPermissions(ctx, s.app.Permissions.Subscribe(ctx))
+	return s.server.Run(ctx, &mcp.StdioTransport{})
+}
+
+// Serve serves clients over the streamable HTTP transport on ln until ctx
+// is done.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go s.forwardPermissions(ctx, s.app.Permissions.Subscribe(ctx))
+
+	handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server {
+		return s.server
+	}, nil)
+	srv := &http.Server{
+		Handler:           s.authorize(handler),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// authorize rejects the requests that don't come from a local client, and
+// the ones without the bearer token, if any.
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := server.CheckLocal(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if s.token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// builtinTools builds the built-in tools the server publishes, the way the
+// agent gets them.
+func (s *Server) builtinTools() []fantasy.AgentTool {
+	cfg := s.app.Config()
+	workingDir := cfg.WorkingDir()
+	all := []fantasy.AgentTool{
+		tools.NewViewTool(s.app.LSPManager, s.app.Permissions, s.app.FileTracker, workingDir, cfg.Options.SkillsPaths...),
+		tools.NewEditTool(s.app.LSPManager, s.app.Permissions, s.app.History, s.app.FileTracker, workingDir),
+		tools.NewMultiEditTool(s.app.LSPManager, s.app.Permissions, s.app.History, s.app.FileTracker, workingDir),
+		tools.NewGrepTool(workingDir, cfg.Tools.Grep),
+		tools.NewGlobTool(workingDir),
+		tools.NewLsTool(s.app.Permissions, workingDir, cfg.Tools.Ls),
+	}
+	if len(cfg.LSP) > 0 || cfg.Options.AutoLSP == nil || *cfg.Options.AutoLSP {
+		all = append(all, tools.NewDiagnosticsTool(s.app.LSPManager), tools.NewReferencesTool(s.app.LSPManager, workingDir))
+	}
+	return slices.DeleteFunc(all, func(tool fantasy.AgentTool) bool {
+		return slices.Contains(cfg.Options.DisabledTools, tool.Info().Name)
+	})
+}
+
+// addTool publishes a built-in tool.
+func (s *Server) addTool(tool fantasy.AgentTool) {
+	info := tool.Info()
+	required := info.Required
+	if required == nil {
+		required = []string{}
+	}
+	s.server.AddTool(&mcp.Tool{
+		Name:        info.Name,
+		Description: info.Description,
+		InputSchema: map[string]any{
+			"type":       "object",
+			"properties": info.Parameters,
+			"required":   required,
+		},
+	}, func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sessionID, err := s.toolSession(ctx, req.Session)
+		if err != nil {
+			return nil, err
+		}
+		ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
+		ctx = context.WithValue(ctx, tools.MessageIDContextKey, uuid.NewString())
+
+		input := "{}"
+		if len(req.Params.Arguments) > 0 {
+			input = string(req.Params.Arguments)
+		}
+		resp, err := tool.Run(ctx, fantasy.ToolCall{
+			ID:    uuid.NewString(),
+			Name:  info.Name,
+			Input: input,
+		})
+		if err != nil {
+			return toolError(err), nil
+		}
+		return toolResult(resp), nil
+	})
+}
+
+// toolSession returns the Crush session the built-in tools run in for an
+// MCP session, creating it on first use.
+func (s *Server) toolSession(ctx context.Context, ss *mcp.ServerSession) (string, error) {
+	key := ss.ID()
+	if id, ok := s.sessions.Get(key); ok {
+		return id, nil
+	}
+	title := "MCP"
+	if params := ss.InitializeParams(); params != nil && params.ClientInfo != nil {
+		title = fmt.Sprintf("MCP: %s", params.ClientInfo.Name)
+	}
+	sess, err := s.app.Sessions.Create(ctx, title)
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+	// The client already asked its user before calling the tool.
+	s.app.Permissions.SetSessionMode(sess.ID, permission.SessionModeAuto)
+	s.sessions.Set(key, sess.ID)
+	slog.Debug("Created session for MCP client", "session_id", sess.ID, "title", title)
+	return sess.ID, nil
+}
+
+// toolResult converts the response of a built-in tool to an MCP result.
+func toolResult(resp fantasy.ToolResponse) *mcp.CallToolResult {
+	result := &mcp.CallToolResult{IsError: resp.IsError}
+	if resp.Type == "image" {
+		// Tools return images base64-encoded, the way providers take them,
+		// while the SDK encodes them itself.
+		data, err := base64.StdEncoding.DecodeString(string(resp.Data))
+		if err != nil {
+			data = resp.Data
+		}
+		result.Content = append(result.Content, &mcp.ImageContent{Data: data, MIMEType: resp.MediaType})
+	}
+	if resp.Content != "" || len(result.Content) == 0 {
+		result.Content = append(result.Content, &mcp.TextContent{Text: resp.Content})
+	}
+	return result
+}
+
+// toolError reports an error as a tool error, so the model can see it.
+func toolError(err error) *mcp.CallToolResult {
+	if errors.Is(err, permission.ErrorPermissionDenied) {
+		err = errors.New("permission denied")
+	}
+	return &mcp.CallToolResult{
+		IsError: true,
+		Content: []mcp.Content{&mcp.TextContent{Text: err.Error()}},
+	}
+}
@@ -0,0 +1,157 @@
+package mcpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/server"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTest(t *testing.T, opts *mcp.ClientOptions) (*Server, *mcp.ClientSession) {
+	t.Helper()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	s := &Server{
+		app: &app.App{
+			Sessions:    session.NewService(q, conn),
+			Permissions: permission.NewPermissionService(q, t.TempDir(), false, nil, nil),
+		},
+		server:   mcp.NewServer(&mcp.Implementation{Name: "crush", Version: "test"}, nil),
+		sessions: csync.NewMap[string, string](),
+		tasks:    csync.NewMap[string, *mcp.ServerSession](),
+	}
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	_, err = s.server.Connect(t.Context(), serverTransport, nil)
+	require.NoError(t, err)
+	client := mcp.NewClient(&mcp.Implementation{Name: "test", Version: "test"}, opts)
+	cs, err := client.Connect(t.Context(), clientTransport, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { cs.Close() })
+	return s, cs
+}
+
+func TestTools(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644))
+
+	s, cs := setupTest(t, nil)
+	s.addTool(tools.NewGlobTool(dir))
+
+	list, err := cs.ListTools(t.Context(), nil)
+	require.NoError(t, err)
+	require.Len(t, list.Tools, 1)
+	require.Equal(t, tools.GlobToolName, list.Tools[0].Name)
+
+	result, err := cs.CallTool(t.Context(), &mcp.CallToolParams{
+		Name:      tools.GlobToolName,
+		Arguments: map[string]any{"pattern": "*.go"},
+	})
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	require.Contains(t, result.Content[0].(*mcp.TextContent).Text, "main.go")
+
+	// The tools of a client run in a session of their own, without asking
+	// for permission.
+	sessionID, ok := s.sessions.Get("")
+	require.True(t, ok)
+	require.Equal(t, permission.SessionModeAuto, s.app.Permissions.SessionMode(sessionID))
+}
+
+func TestAskPermission(t *testing.T) {
+	t.Parallel()
+
+	var decision server.Decision
+	s, _ := setupTest(t, &mcp.ClientOptions{
+		ElicitationHandler: func(context.Context, *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			return &mcp.ElicitResult{Action: "accept", Content: map[string]any{"decision": string(decision)}}, nil
+		},
+	})
+	go s.forwardPermissions(t.Context(), s.app.Permissions.Subscribe(t.Context()))
+
+	var ss *mcp.ServerSession
+	for ss = range s.server.Sessions() {
+		break
+	}
+	require.True(t, canElicit(ss))
+
+	task, err := s.app.Sessions.Create(t.Context(), "task")
+	require.NoError(t, err)
+	s.tasks.Set(task.ID, ss)
+	other, err := s.app.Sessions.Create(t.Context(), "other")
+	require.NoError(t, err)
+
+	request := func(sessionID string) bool {
+		granted, _ := s.app.Permissions.Request(t.Context(), permission.CreatePermissionRequest{
+			SessionID:  sessionID,
+			ToolCallID: sessionID + string(decision),
+			ToolName:   tools.BashToolName,
+			Action:     "execute",
+			Path:       t.TempDir(),
+		})
+		return granted
+	}
+
+	decision = server.DecisionAllow
+	require.True(t, request(task.ID))
+	decision = server.DecisionDeny
+	require.False(t, request(task.ID))
+
+	// Nobody can answer for sessions that don't run a task.
+	decision = server.DecisionAllow
+	require.False(t, request(other.ID))
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{token: "secret"}
+	handler := s.authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tt := range []struct {
+		name   string
+		host   string
+		origin string
+		token  string
+		status int
+	}{
+		{name: "authorized", host: "127.0.0.1:7422", token: "secret", status: http.StatusOK},
+		{name: "missing token", host: "127.0.0.1:7422", status: http.StatusUnauthorized},
+		{name: "remote host", host: "evil.example", token: "secret", status: http.StatusForbidden},
+		{name: "remote origin", host: "localhost:7422", origin: "https://evil.example", token: "secret", status: http.StatusForbidden},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Host = tt.host
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
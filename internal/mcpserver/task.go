@@ -0,0 +1,165 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/agent"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/server"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// TaskToolName is the name of the tool that has the Crush agent carry out a
+// task.
+const TaskToolName = "run_crush_task"
+
+const taskToolDescription = `Has the Crush coding agent carry out a task in the project with its own tools, and returns its final answer.
+Pass the session_id of a previous task to follow up on it.`
+
+type taskInput struct {
+	Prompt    string `json:"prompt" jsonschema:"The task to carry out"`
+	SessionID string `json:"session_id,omitempty" jsonschema:"The session of a previous task to follow up on"`
+}
+
+type taskOutput struct {
+	SessionID string `json:"session_id" jsonschema:"The session the task ran in"`
+	Response  string `json:"response" jsonschema:"The final answer of the agent"`
+}
+
+// permissionSchema is the form the client fills in to answer a permission
+// request of the agent.
+var permissionSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"decision": map[string]any{
+			"type":    "string",
+			"title":   "Decision",
+			"enum":    []string{string(server.DecisionAllow), string(server.DecisionAllowSession), string(server.DecisionDeny)},
+			"default": string(server.DecisionAllow),
+		},
+	},
+	"required": []string{"decision"},
+}
+
+func (s *Server) runTask(ctx context.Context, req *mcp.CallToolRequest, in taskInput) (*mcp.CallToolResult, taskOutput, error) {
+	if strings.TrimSpace(in.Prompt) == "" {
+		return nil, taskOutput{}, errors.New("prompt is required")
+	}
+
+	sessionID := in.SessionID
+	if sessionID == "" {
+		sess, err := s.app.Sessions.Create(ctx, "MCP Task")
+		if err != nil {
+			return nil, taskOutput{}, fmt.Errorf("failed to create session: %w", err)
+		}
+		sessionID = sess.ID
+	} else if _, err := s.app.Sessions.Get(ctx, sessionID); err != nil {
+		return nil, taskOutput{}, fmt.Errorf("session %s not found", sessionID)
+	}
+	if s.app.AgentCoordinator.IsSessionBusy(sessionID) {
+		return nil, taskOutput{}, fmt.Errorf("session %s is busy", sessionID)
+	}
+
+	if canElicit(req.Session) {
+		s.app.Permissions.SetSessionMode(sessionID, permission.SessionModePrompt)
+		s.tasks.Set(sessionID, req.Session)
+		defer s.tasks.Del(sessionID)
+	} else {
+		// Nobody can be asked, so only what the rules allow runs.
+		s.app.Permissions.SetSessionMode(sessionID, permission.SessionModeAllowlist)
+	}
+
+	result, err := s.app.AgentCoordinator.Run(agent.WithPermissionDenialsAsToolErrors(ctx), sessionID, in.Prompt)
+	if err != nil {
+		return nil, taskOutput{}, err
+	}
+	out := taskOutput{SessionID: sessionID}
+	if result != nil {
+		out.Response = result.Response.Content.Text()
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: out.Response}},
+	}, out, nil
+}
+
+// canElicit reports whether the client can fill in forms for the server.
+func canElicit(ss *mcp.ServerSession) bool {
+	params := ss.InitializeParams()
+	return params != nil && params.Capabilities != nil && params.Capabilities.Elicitation != nil
+}
+
+// forwardPermissions asks the clients that run tasks to answer the
+// permission requests of the agent until ctx is done. Requests that no
+// client can answer are denied. Callers subscribe to the requests before
+// starting it, so none is missed in the meantime.
+func (s *Server) forwardPermissions(ctx context.Context, requests <-chan pubsub.Event[permission.PermissionRequest]) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-requests:
+			if !ok {
+				return
+			}
+			go s.askPermission(ctx, event.Payload)
+		}
+	}
+}
+
+// askPermission asks the client running the task a permission request
+// belongs to whether to allow it.
+func (s *Server) askPermission(ctx context.Context, req permission.PermissionRequest) {
+	ss, ok := s.taskClient(ctx, req.SessionID)
+	if !ok {
+		s.app.Permissions.Deny(req)
+		return
+	}
+
+	message := fmt.Sprintf("Crush asks for permission to use %s (%s).", req.ToolName, req.Action)
+	if req.Description != "" {
+		message += "\n\n" + req.Description
+	}
+	if req.Path != "" {
+		message += "\n\nPath: " + req.Path
+	}
+	result, err := ss.Elicit(ctx, &mcp.ElicitParams{
+		Message:         message,
+		RequestedSchema: permissionSchema,
+	})
+	if err != nil {
+		slog.Error("Failed to ask the MCP client for permission", "tool", req.ToolName, "error", err)
+		s.app.Permissions.Deny(req)
+		return
+	}
+	if result.Action != "accept" {
+		s.app.Permissions.Deny(req)
+		return
+	}
+	switch result.Content["decision"] {
+	case string(server.DecisionAllow):
+		s.app.Permissions.Grant(req)
+	case string(server.DecisionAllowSession):
+		s.app.Permissions.GrantPersistent(req)
+	default:
+		s.app.Permissions.Deny(req)
+	}
+}
+
+// taskClient returns the client running the task of a session. Sub-agents
+// run in sessions of their own, so their requests belong to the task of
+// their parent session.
+func (s *Server) taskClient(ctx context.Context, sessionID string) (*mcp.ServerSession, bool) {
+	if ss, ok := s.tasks.Get(sessionID); ok {
+		return ss, true
+	}
+	sess, err := s.app.Sessions.Get(ctx, sessionID)
+	if err != nil || sess.ParentSessionID == "" {
+		return nil, false
+	}
+	return s.taskClient(ctx, sess.ParentSessionID)
+}
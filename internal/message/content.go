@@ -1,6 +1,7 @@
 package message
 
 import (
+	"cmp"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -112,10 +113,131 @@ type ToolResult struct {
 	MIMEType   string `json:"mime_type"`
 	Metadata   string `json:"metadata"`
 	IsError    bool   `json:"is_error"`
+	// Pruned is set when the content was elided from the context of the
+	// agent to save space. The content is kept for the user to see, but the
+	// agent gets a placeholder instead.
+	Pruned bool `json:"pruned,omitempty"`
+	// Parts holds the content of results made of more than text, such as
+	// the results of MCP tools, in order. Content and Data then hold the
+	// text of the parts and their first media.
+	Parts []ToolResultPart `json:"parts,omitempty"`
 }
 
 func (ToolResult) isPart() {}
 
+// ExtraMedia returns the media of the parts of the result that doesn't fit in
+// the tool result sent to the provider, which only holds the first one.
+func (r ToolResult) ExtraMedia() []fantasy.FilePart {
+	if r.IsError || r.Pruned {
+		return nil
+	}
+	var files []fantasy.FilePart
+	first := true
+	for _, part := range r.Parts {
+		if !part.IsMedia() {
+			continue
+		}
+		if first {
+			first = false
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(part.Data)
+		if err != nil {
+			continue
+		}
+		files = append(files, fantasy.FilePart{
+			Data:      data,
+			MediaType: part.MIMEType,
+			Filename:  fmt.Sprintf("tool-result-%s-%d", r.ToolCallID, len(files)+1),
+		})
+	}
+	return files
+}
+
+// ToolMediaMessage returns the user message that carries the extra media of
+// tool results to the provider, after the tool message.
+func ToolMediaMessage(files []fantasy.FilePart) fantasy.Message {
+	return fantasy.NewUserMessage("Here is the rest of the media content from the tool results:", files...)
+}
+
+type ToolResultPartType string
+
+const (
+	ToolResultPartText         ToolResultPartType = "text"
+	ToolResultPartImage        ToolResultPartType = "image"
+	ToolResultPartAudio        ToolResultPartType = "audio"
+	ToolResultPartResource     ToolResultPartType = "resource"
+	ToolResultPartResourceLink ToolResultPartType = "resource_link"
+	// ToolResultPartStructured holds the structured content of a result, as
+	// JSON in Text.
+	ToolResultPartStructured ToolResultPartType = "structured"
+)
+
+// ToolResultPart is a typed part of a tool result.
+type ToolResultPart struct {
+	Type ToolResultPartType `json:"type"`
+	Text string             `json:"text,omitempty"`
+	// Data is base64 encoded, like the data of [ToolResult].
+	Data        string `json:"data,omitempty"`
+	MIMEType    string `json:"mime_type,omitempty"`
+	URI         string `json:"uri,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// IsMedia reports whether the part is an image or audio the model gets as a
+// file rather than as text.
+func (p ToolResultPart) IsMedia() bool {
+	return p.Data != "" && (strings.HasPrefix(p.MIMEType, "image/") || strings.HasPrefix(p.MIMEType, "audio/"))
+}
+
+// ToolResultText returns the text the model gets for the parts of a tool
+// result. Structured content is left out when there is text, which servers
+// are expected to fill with the same JSON.
+func ToolResultText(parts []ToolResultPart) string {
+	hasText := slices.ContainsFunc(parts, func(p ToolResultPart) bool {
+		return p.Type == ToolResultPartText
+	})
+	var texts []string
+	for _, p := range parts {
+		switch {
+		case p.IsMedia():
+		case p.Type == ToolResultPartText:
+			texts = append(texts, p.Text)
+		case p.Type == ToolResultPartStructured:
+			if !hasText {
+				texts = append(texts, p.Text)
+			}
+		case p.Type == ToolResultPartResourceLink:
+			text := fmt.Sprintf("Resource link: %s <%s>", cmp.Or(p.Name, p.URI), p.URI)
+			if p.Description != "" {
+				text += "\n" + p.Description
+			}
+			texts = append(texts, text)
+		case p.Type == ToolResultPartResource && p.Data != "":
+			texts = append(texts, fmt.Sprintf("Resource %s (%s, binary)", p.URI, cmp.Or(p.MIMEType, "unknown type")))
+		case p.Type == ToolResultPartResource:
+			texts = append(texts, fmt.Sprintf("Resource %s:\n%s", p.URI, p.Text))
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// prunedToolResultFormat is what the agent gets in place of the content of
+// pruned tool results.
+const prunedToolResultFormat = "[The output of this %s call was elided to save context. Run the tool again if you need it.]"
+
+// Retry is set on an assistant message while a failed request to the
+// provider waits to be retried.
+type Retry struct {
+	Attempt int    `json:"attempt"`
+	Reason  string `json:"reason"`
+	// Until is when the request is retried, in Unix milliseconds.
+	Until int64 `json:"until"`
+}
+
+func (Retry) isPart() {}
+
 type Finish struct {
 	Reason  FinishReason `json:"reason"`
 	Time    int64        `json:"time"`
@@ -213,6 +335,16 @@ func (m *Message) FinishPart() *Finish {
 	return nil
 }
 
+// RetryPart returns the pending retry of the message, if any.
+func (m *Message) RetryPart() *Retry {
+	for _, part := range m.Parts {
+		if c, ok := part.(Retry); ok {
+			return &c
+		}
+	}
+	return nil
+}
+
 func (m *Message) FinishReason() FinishReason {
 	for _, part := range m.Parts {
 		if c, ok := part.(Finish); ok {
@@ -428,6 +560,20 @@ func (m *Message) AddFinish(reason FinishReason, message, details string) {
 	m.Parts = append(m.Parts, Finish{Reason: reason, Time: time.Now().Unix(), Message: message, Details: details})
 }
 
+// SetRetry records that the request for the message is retried at until.
+func (m *Message) SetRetry(attempt int, reason string, until time.Time) {
+	m.ClearRetry()
+	m.Parts = append(m.Parts, Retry{Attempt: attempt, Reason: reason, Until: until.UnixMilli()})
+}
+
+// ClearRetry removes the pending retry of the message, if any.
+func (m *Message) ClearRetry() {
+	m.Parts = slices.DeleteFunc(m.Parts, func(part ContentPart) bool {
+		_, ok := part.(Retry)
+		return ok
+	})
+}
+
 func (m *Message) AddImageURL(url, detail string) {
 	m.Parts = append(m.Parts, ImageURLContent{URL: url, Detail: detail})
 }
@@ -535,12 +681,18 @@ func (m *Message) ToAIMessage() []fantasy.Message {
 		})
 	case Tool:
 		var parts []fantasy.MessagePart
+		var media []fantasy.FilePart
 		for _, result := range m.ToolResults() {
+			media = append(media, result.ExtraMedia()...)
 			var content fantasy.ToolResultOutputContent
 			if result.IsError {
 				content = fantasy.ToolResultOutputContentError{
 					Error: errors.New(result.Content),
 				}
+			} else if result.Pruned {
+				content = fantasy.ToolResultOutputContentText{
+					Text: fmt.Sprintf(prunedToolResultFormat, result.Name),
+				}
 			} else if result.Data != "" {
 				content = fantasy.ToolResultOutputContentMedia{
 					Data:      result.Data,
@@ -560,6 +712,9 @@ func (m *Message) ToAIMessage() []fantasy.Message {
 			Role:    fantasy.MessageRoleTool,
 			Content: parts,
 		})
+		if len(media) > 0 {
+			messages = append(messages, ToolMediaMessage(media))
+		}
 	}
 	return messages
 }
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+
+	"charm.land/fantasy"
+	"github.com/stretchr/testify/require"
 )
 
 func makeTestAttachments(n int, contentSize int) []Attachment {
@@ -43,3 +46,68 @@ func BenchmarkPromptWithTextAttachments(b *testing.B) {
 		})
 	}
 }
+
+func TestToAIMessage_prunedToolResult(t *testing.T) {
+	t.Parallel()
+
+	msg := Message{
+		Role: Tool,
+		Parts: []ContentPart{
+			ToolResult{ToolCallID: "call", Name: "view", Content: "package main", Pruned: true},
+		},
+	}
+	messages := msg.ToAIMessage()
+	require.Len(t, messages, 1)
+	part, ok := messages[0].Content[0].(fantasy.ToolResultPart)
+	require.True(t, ok)
+	require.Equal(t, "call", part.ToolCallID)
+	require.Equal(t, fantasy.ToolResultOutputContentText{Text: fmt.Sprintf(prunedToolResultFormat, "view")}, part.Output)
+}
+
+func TestToAIMessage_toolResultParts(t *testing.T) {
+	t.Parallel()
+
+	parts := []ToolResultPart{
+		{Type: ToolResultPartText, Text: `{"temperature":21}`},
+		{Type: ToolResultPartImage, Data: "Zmlyc3Q=", MIMEType: "image/png"},
+		{Type: ToolResultPartResourceLink, URI: "file:///report.md", Name: "Report"},
+		{Type: ToolResultPartImage, Data: "c2Vjb25k", MIMEType: "image/jpeg"},
+		{Type: ToolResultPartStructured, Text: `{"temperature":21}`},
+	}
+	require.Equal(t, "{\"temperature\":21}\nResource link: Report <file:///report.md>", ToolResultText(parts))
+
+	msg := Message{
+		Role: Tool,
+		Parts: []ContentPart{
+			ToolResult{
+				ToolCallID: "call",
+				Name:       "mcp_weather_forecast",
+				Content:    ToolResultText(parts),
+				Data:       parts[1].Data,
+				MIMEType:   parts[1].MIMEType,
+				Parts:      parts,
+			},
+		},
+	}
+	messages := msg.ToAIMessage()
+	require.Len(t, messages, 2)
+	part, ok := messages[0].Content[0].(fantasy.ToolResultPart)
+	require.True(t, ok)
+	require.Equal(t, fantasy.ToolResultOutputContentMedia{Data: "Zmlyc3Q=", MediaType: "image/png"}, part.Output)
+
+	require.Equal(t, fantasy.MessageRoleUser, messages[1].Role)
+	file, ok := messages[1].Content[1].(fantasy.FilePart)
+	require.True(t, ok)
+	require.Equal(t, []byte("second"), file.Data)
+	require.Equal(t, "image/jpeg", file.MediaType)
+}
+
+func TestToolResultText_structuredOnly(t *testing.T) {
+	t.Parallel()
+
+	parts := []ToolResultPart{
+		{Type: ToolResultPartResource, URI: "file:///notes.txt", Text: "hello"},
+		{Type: ToolResultPartStructured, Text: `{"ok":true}`},
+	}
+	require.Equal(t, "Resource file:///notes.txt:\nhello\n{\"ok\":true}", ToolResultText(parts))
+}
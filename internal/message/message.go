@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/crush/internal/db"
@@ -24,6 +25,15 @@ type Service interface {
 	pubsub.Subscriber[Message]
 	Create(ctx context.Context, sessionID string, params CreateMessageParams) (Message, error)
 	Update(ctx context.Context, message Message) error
+	// UpdateStreaming publishes message right away but delays writing it to
+	// the database, so that streamed deltas don't each cost a write. The
+	// latest version is written at most [StreamFlushInterval] later, or
+	// earlier by [Service.Update], [Service.Flush] or reading the message
+	// back.
+	UpdateStreaming(ctx context.Context, message Message) error
+	// Flush writes every message updated by [Service.UpdateStreaming] that
+	// isn't written yet.
+	Flush(ctx context.Context) error
 	Get(ctx context.Context, id string) (Message, error)
 	List(ctx context.Context, sessionID string) ([]Message, error)
 	ListUserMessages(ctx context.Context, sessionID string) ([]Message, error)
@@ -35,12 +45,21 @@ type Service interface {
 type service struct {
 	*pubsub.Broker[Message]
 	q db.Querier
+
+	flushInterval time.Duration
+	// writeMu orders database writes of messages, so a delayed write never
+	// overwrites a newer one.
+	writeMu   sync.Mutex
+	pendingMu sync.Mutex
+	pending   map[string]*pendingWrite
 }
 
 func NewService(q db.Querier) Service {
 	return &service{
-		Broker: pubsub.NewBroker[Message](),
-		q:      q,
+		Broker:        pubsub.NewBroker[Message](),
+		q:             q,
+		flushInterval: StreamFlushInterval,
+		pending:       make(map[string]*pendingWrite),
 	}
 }
 
@@ -49,6 +68,9 @@ func (s *service) Delete(ctx context.Context, id string) error {
 	if err != nil {
 		return err
 	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.takePending(id)
 	err = s.q.DeleteMessage(ctx, message.ID)
 	if err != nil {
 		return err
@@ -112,20 +134,11 @@ func (s *service) DeleteSessionMessages(ctx context.Context, sessionID string) e
 }
 
 func (s *service) Update(ctx context.Context, message Message) error {
-	parts, err := marshalParts(message.Parts)
-	if err != nil {
-		return err
-	}
-	finishedAt := sql.NullInt64{}
-	if f := message.FinishPart(); f != nil {
-		finishedAt.Int64 = f.Time
-		finishedAt.Valid = true
-	}
-	err = s.q.UpdateMessage(ctx, db.UpdateMessageParams{
-		ID:         message.ID,
-		Parts:      string(parts),
-		FinishedAt: finishedAt,
-	})
+	s.writeMu.Lock()
+	// This write supersedes any delayed one.
+	s.takePending(message.ID)
+	err := s.write(ctx, message)
+	s.writeMu.Unlock()
 	if err != nil {
 		return err
 	}
@@ -137,6 +150,9 @@ func (s *service) Update(ctx context.Context, message Message) error {
 }
 
 func (s *service) Get(ctx context.Context, id string) (Message, error) {
+	if err := s.flushMessage(ctx, id); err != nil {
+		return Message{}, err
+	}
 	dbMessage, err := s.q.GetMessage(ctx, id)
 	if err != nil {
 		return Message{}, err
@@ -145,6 +161,9 @@ func (s *service) Get(ctx context.Context, id string) (Message, error) {
 }
 
 func (s *service) List(ctx context.Context, sessionID string) ([]Message, error) {
+	if err := s.flushSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
 	dbMessages, err := s.q.ListMessagesBySession(ctx, sessionID)
 	if err != nil {
 		return nil, err
@@ -160,6 +179,9 @@ func (s *service) List(ctx context.Context, sessionID string) ([]Message, error)
 }
 
 func (s *service) ListUserMessages(ctx context.Context, sessionID string) ([]Message, error) {
+	if err := s.flushSession(ctx, sessionID); err != nil {
+		return nil, err
+	}
 	dbMessages, err := s.q.ListUserMessagesBySession(ctx, sessionID)
 	if err != nil {
 		return nil, err
@@ -217,6 +239,7 @@ const (
 	toolCallType   partType = "tool_call"
 	toolResultType partType = "tool_result"
 	finishType     partType = "finish"
+	retryType      partType = "retry"
 )
 
 type partWrapper struct {
@@ -224,6 +247,66 @@ type partWrapper struct {
 	Data ContentPart `json:"data"`
 }
 
+// jsonMessage is the JSON representation of a [Message]. Parts are wrapped
+// with their type, as they are stored in the database.
+type jsonMessage struct {
+	ID               string          `json:"id"`
+	Role             MessageRole     `json:"role"`
+	SessionID        string          `json:"session_id"`
+	Parts            json.RawMessage `json:"parts"`
+	Model            string          `json:"model,omitempty"`
+	Provider         string          `json:"provider,omitempty"`
+	CreatedAt        int64           `json:"created_at"`
+	UpdatedAt        int64           `json:"updated_at"`
+	IsSummaryMessage bool            `json:"is_summary_message,omitempty"`
+}
+
+// MarshalJSON implements [json.Marshaler].
+func (m Message) MarshalJSON() ([]byte, error) {
+	parts, err := marshalParts(m.Parts)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonMessage{
+		ID:               m.ID,
+		Role:             m.Role,
+		SessionID:        m.SessionID,
+		Parts:            parts,
+		Model:            m.Model,
+		Provider:         m.Provider,
+		CreatedAt:        m.CreatedAt,
+		UpdatedAt:        m.UpdatedAt,
+		IsSummaryMessage: m.IsSummaryMessage,
+	})
+}
+
+// UnmarshalJSON implements [json.Unmarshaler].
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var jm jsonMessage
+	if err := json.Unmarshal(data, &jm); err != nil {
+		return err
+	}
+	var parts []ContentPart
+	if len(jm.Parts) > 0 && string(jm.Parts) != "null" {
+		var err error
+		if parts, err = unmarshalParts(jm.Parts); err != nil {
+			return err
+		}
+	}
+	*m = Message{
+		ID:               jm.ID,
+		Role:             jm.Role,
+		SessionID:        jm.SessionID,
+		Parts:            parts,
+		Model:            jm.Model,
+		Provider:         jm.Provider,
+		CreatedAt:        jm.CreatedAt,
+		UpdatedAt:        jm.UpdatedAt,
+		IsSummaryMessage: jm.IsSummaryMessage,
+	}
+	return nil
+}
+
 func marshalParts(parts []ContentPart) ([]byte, error) {
 	wrappedParts := make([]partWrapper, len(parts))
 
@@ -245,6 +328,8 @@ func marshalParts(parts []ContentPart) ([]byte, error) {
 			typ = toolResultType
 		case Finish:
 			typ = finishType
+		case Retry:
+			typ = retryType
 		default:
 			return nil, fmt.Errorf("unknown part type: %T", part)
 		}
@@ -319,6 +404,12 @@ func unmarshalParts(data []byte) ([]ContentPart, error) {
 				return nil, err
 			}
 			parts = append(parts, part)
+		case retryType:
+			part := Retry{}
+			if err := json.Unmarshal(wrapper.Data, &part); err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
 		default:
 			return nil, fmt.Errorf("unknown part type: %s", wrapper.Type)
 		}
@@ -0,0 +1,35 @@
+package message
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageJSON(t *testing.T) {
+	msg := Message{
+		ID:        "msg",
+		Role:      Assistant,
+		SessionID: "session",
+		Parts: []ContentPart{
+			ReasoningContent{Thinking: "hmm"},
+			TextContent{Text: "hello"},
+			ToolCall{ID: "call", Name: "bash", Input: `{"command":"ls"}`, Finished: true},
+			Finish{Reason: FinishReasonToolUse, Time: 42},
+		},
+		Model:     "model",
+		Provider:  "provider",
+		CreatedAt: 1,
+		UpdatedAt: 2,
+	}
+
+	data, err := json.Marshal(msg)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"session_id":"session"`)
+	require.Contains(t, string(data), `{"type":"text","data":{"text":"hello"}}`)
+
+	var decoded Message
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, msg, decoded)
+}
@@ -0,0 +1,116 @@
+package message
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// StreamFlushInterval is how long [Service.UpdateStreaming] may hold a
+// message before writing it to the database. It bounds what is lost if the
+// process dies mid-stream.
+const StreamFlushInterval = 250 * time.Millisecond
+
+// pendingWrite is the latest version of a message that is published but not
+// written yet.
+type pendingWrite struct {
+	message Message
+	timer   *time.Timer
+}
+
+func (s *service) UpdateStreaming(ctx context.Context, message Message) error {
+	message.UpdatedAt = time.Now().Unix()
+	// Clone the message before publishing to avoid race conditions with
+	// concurrent modifications to the Parts slice. The clone is never
+	// modified, so the pending write can share it.
+	clone := message.Clone()
+	s.Publish(pubsub.UpdatedEvent, clone)
+
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+	if p, ok := s.pending[message.ID]; ok {
+		p.message = clone
+		return nil
+	}
+	// The write is not pushed back by later updates, so a long stream is
+	// still written every interval.
+	s.pending[message.ID] = &pendingWrite{
+		message: clone,
+		timer: time.AfterFunc(s.flushInterval, func() {
+			if err := s.flushMessage(context.Background(), message.ID); err != nil {
+				slog.Error("Failed to write streamed message", "message_id", message.ID, "error", err)
+			}
+		}),
+	}
+	return nil
+}
+
+func (s *service) Flush(ctx context.Context) error {
+	return s.flushWhere(ctx, func(Message) bool { return true })
+}
+
+func (s *service) flushMessage(ctx context.Context, id string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if p, ok := s.takePending(id); ok {
+		return s.write(ctx, p.message)
+	}
+	return nil
+}
+
+func (s *service) flushSession(ctx context.Context, sessionID string) error {
+	return s.flushWhere(ctx, func(m Message) bool { return m.SessionID == sessionID })
+}
+
+func (s *service) flushWhere(ctx context.Context, match func(Message) bool) error {
+	s.pendingMu.Lock()
+	var ids []string
+	for id, p := range s.pending {
+		if match(p.message) {
+			ids = append(ids, id)
+		}
+	}
+	s.pendingMu.Unlock()
+
+	var errs []error
+	for _, id := range ids {
+		errs = append(errs, s.flushMessage(ctx, id))
+	}
+	return errors.Join(errs...)
+}
+
+// takePending removes the pending write of a message, if any, and stops its
+// timer. The caller must hold writeMu.
+func (s *service) takePending(id string) (*pendingWrite, bool) {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+	p, ok := s.pending[id]
+	if ok {
+		p.timer.Stop()
+		delete(s.pending, id)
+	}
+	return p, ok
+}
+
+// write writes message to the database. The caller must hold writeMu.
+func (s *service) write(ctx context.Context, message Message) error {
+	parts, err := marshalParts(message.Parts)
+	if err != nil {
+		return err
+	}
+	finishedAt := sql.NullInt64{}
+	if f := message.FinishPart(); f != nil {
+		finishedAt.Int64 = f.Time
+		finishedAt.Valid = true
+	}
+	return s.q.UpdateMessage(ctx, db.UpdateMessageParams{
+		ID:         message.ID,
+		Parts:      string(parts),
+		FinishedAt: finishedAt,
+	})
+}
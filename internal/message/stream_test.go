@@ -0,0 +1,164 @@
+package message
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+// countingQuerier counts the message writes that reach the database.
+type countingQuerier struct {
+	db.Querier
+	writes atomic.Int64
+}
+
+func (q *countingQuerier) UpdateMessage(ctx context.Context, arg db.UpdateMessageParams) error {
+	q.writes.Add(1)
+	return q.Querier.UpdateMessage(ctx, arg)
+}
+
+func setupStreamTest(tb testing.TB, flushInterval time.Duration) (*service, *countingQuerier, Message) {
+	tb.Helper()
+
+	conn, err := db.Connect(tb.Context(), tb.TempDir())
+	require.NoError(tb, err)
+	tb.Cleanup(func() { conn.Close() })
+
+	q := &countingQuerier{Querier: db.New(conn)}
+	_, err = q.CreateSession(tb.Context(), db.CreateSessionParams{ID: "session", Title: "Session"})
+	require.NoError(tb, err)
+
+	s := NewService(q).(*service)
+	s.flushInterval = flushInterval
+	msg, err := s.Create(tb.Context(), "session", CreateMessageParams{Role: Assistant})
+	require.NoError(tb, err)
+	return s, q, msg
+}
+
+// stored returns the message as written in the database, without flushing.
+func stored(t *testing.T, q db.Querier, id string) *Message {
+	t.Helper()
+	item, err := q.GetMessage(t.Context(), id)
+	require.NoError(t, err)
+	parts, err := unmarshalParts([]byte(item.Parts))
+	require.NoError(t, err)
+	return &Message{Parts: parts}
+}
+
+func TestUpdateStreaming(t *testing.T) {
+	s, q, msg := setupStreamTest(t, time.Hour)
+	events := s.Subscribe(t.Context())
+
+	for _, text := range []string{"Hello", ", ", "world"} {
+		msg.AppendContent(text)
+		require.NoError(t, s.UpdateStreaming(t.Context(), msg))
+	}
+
+	// Every delta is published right away...
+	for _, want := range []string{"Hello", "Hello, ", "Hello, world"} {
+		event := <-events
+		require.Equal(t, pubsub.UpdatedEvent, event.Type)
+		require.Equal(t, want, event.Payload.Content().Text)
+	}
+	// ...but nothing is written yet.
+	require.Zero(t, q.writes.Load())
+	require.Empty(t, stored(t, q, msg.ID).Content().Text)
+
+	// Reading the message back writes the latest version once.
+	got, err := s.Get(t.Context(), msg.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Hello, world", got.Content().Text)
+	require.Equal(t, int64(1), q.writes.Load())
+
+	list, err := s.List(t.Context(), "session")
+	require.NoError(t, err)
+	require.Equal(t, "Hello, world", list[0].Content().Text)
+	require.Equal(t, int64(1), q.writes.Load())
+}
+
+func TestUpdateStreaming_FlushInterval(t *testing.T) {
+	s, q, msg := setupStreamTest(t, 10*time.Millisecond)
+
+	msg.AppendContent("Hello")
+	require.NoError(t, s.UpdateStreaming(t.Context(), msg))
+	require.Eventually(t, func() bool {
+		return q.writes.Load() == 1
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, "Hello", stored(t, q, msg.ID).Content().Text)
+}
+
+func TestUpdateStreaming_UpdateSupersedes(t *testing.T) {
+	s, q, msg := setupStreamTest(t, 20*time.Millisecond)
+
+	msg.AppendContent("Hello")
+	require.NoError(t, s.UpdateStreaming(t.Context(), msg))
+	msg.AppendContent(", world")
+	msg.AddFinish(FinishReasonEndTurn, "", "")
+	require.NoError(t, s.Update(t.Context(), msg))
+
+	// The delayed write is dropped, so it can't overwrite the final one.
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int64(1), q.writes.Load())
+	got := stored(t, q, msg.ID)
+	require.Equal(t, "Hello, world", got.Content().Text)
+	require.NotNil(t, got.FinishPart())
+}
+
+func TestFlush(t *testing.T) {
+	s, q, msg := setupStreamTest(t, time.Hour)
+	other, err := s.Create(t.Context(), "session", CreateMessageParams{Role: Assistant})
+	require.NoError(t, err)
+
+	msg.AppendContent("one")
+	require.NoError(t, s.UpdateStreaming(t.Context(), msg))
+	other.AppendReasoningContent("two")
+	require.NoError(t, s.UpdateStreaming(t.Context(), other))
+
+	require.NoError(t, s.Flush(t.Context()))
+	require.Equal(t, int64(2), q.writes.Load())
+	require.Equal(t, "one", stored(t, q, msg.ID).Content().Text)
+	require.Equal(t, "two", stored(t, q, other.ID).ReasoningContent().Thinking)
+
+	// Nothing is left to write.
+	require.NoError(t, s.Flush(t.Context()))
+	require.Equal(t, int64(2), q.writes.Load())
+}
+
+const benchmarkDeltas = 1000
+
+// BenchmarkStreamingUpdates streams a response of benchmarkDeltas deltas the
+// way the agent did before and after write coalescing, and reports how many
+// database writes each takes.
+func BenchmarkStreamingUpdates(b *testing.B) {
+	for _, bm := range []struct {
+		name   string
+		update func(*service, context.Context, Message) error
+	}{
+		{"Update", (*service).Update},
+		{"UpdateStreaming", (*service).UpdateStreaming},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			s, q, msg := setupStreamTest(b, StreamFlushInterval)
+			b.ReportAllocs()
+			for b.Loop() {
+				msg.Parts = nil
+				for range benchmarkDeltas {
+					msg.AppendContent("token ")
+					if err := bm.update(s, b.Context(), msg); err != nil {
+						b.Fatal(err)
+					}
+				}
+				msg.AddFinish(FinishReasonEndTurn, "", "")
+				if err := s.Update(b.Context(), msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(q.writes.Load())/float64(b.N), "writes/op")
+		})
+	}
+}
@@ -0,0 +1,405 @@
+// Package mcpauth implements the MCP authorization flow for remote MCP
+// servers: metadata discovery, dynamic client registration, and the
+// authorization code grant with PKCE and a loopback redirect.
+package mcpauth
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/oauth"
+)
+
+// Metadata is what the client needs to know about the authorization server
+// of an MCP server.
+type Metadata struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	RegistrationEndpoint  string `json:"registration_endpoint,omitempty"`
+	// Resource is the resource indicator of the MCP server.
+	Resource string `json:"-"`
+	// Scope is the scope to request, if any.
+	Scope string `json:"-"`
+}
+
+// Credentials are the client registration and token of an MCP server.
+type Credentials struct {
+	ClientID     string      `json:"client_id"`
+	ClientSecret string      `json:"client_secret,omitempty"`
+	TokenURL     string      `json:"token_url"`
+	Resource     string      `json:"resource,omitempty"`
+	Token        oauth.Token `json:"token"`
+}
+
+// Expired reports whether the access token has expired. Tokens without a
+// lifetime never do.
+func (c Credentials) Expired() bool {
+	return c.Token.ExpiresIn > 0 && c.Token.IsExpired()
+}
+
+// Authorize runs the authorization flow for the MCP server at serverURL.
+// challenge is the WWW-Authenticate header of its 401 response, if any.
+// open is called with the URL the user has to visit; the flow completes once
+// the browser is redirected back to the loopback listener.
+func Authorize(ctx context.Context, serverURL, challenge string, open func(string) error) (*Credentials, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	meta, err := Discover(ctx, client, serverURL, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("listen for the redirect: %w", err)
+	}
+	defer ln.Close()
+	redirectURI := fmt.Sprintf("http://%s/callback", ln.Addr())
+
+	creds, err := Register(ctx, client, meta, redirectURI)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier := randomString()
+	state := randomString()
+	challengeSum := sha256.Sum256([]byte(verifier))
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", creds.ClientID)
+	params.Set("redirect_uri", redirectURI)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challengeSum[:]))
+	params.Set("code_challenge_method", "S256")
+	params.Set("state", state)
+	params.Set("resource", meta.Resource)
+	if meta.Scope != "" {
+		params.Set("scope", meta.Scope)
+	}
+	authURL := meta.AuthorizationEndpoint
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + params.Encode()
+	} else {
+		authURL += "?" + params.Encode()
+	}
+
+	code, err := waitForCode(ctx, ln, state, func() error { return open(authURL) })
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("code_verifier", verifier)
+	if err := requestToken(ctx, client, creds, form); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// Refresh exchanges the refresh token of creds for a new access token.
+func Refresh(ctx context.Context, client *http.Client, creds *Credentials) error {
+	if creds.Token.RefreshToken == "" {
+		return errors.New("no refresh token")
+	}
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", creds.Token.RefreshToken)
+	return requestToken(ctx, client, creds, form)
+}
+
+// Discover finds the authorization server of the MCP server at serverURL
+// through its protected resource metadata, and fetches the metadata of the
+// authorization server. Servers that publish neither get the default
+// endpoints of the 2025-03-26 revision of the specification.
+func Discover(ctx context.Context, client *http.Client, serverURL, challenge string) (*Metadata, error) {
+	server, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server url: %w", err)
+	}
+	server.Fragment = ""
+	origin := server.Scheme + "://" + server.Host
+
+	var resource struct {
+		Resource             string   `json:"resource"`
+		AuthorizationServers []string `json:"authorization_servers"`
+		ScopesSupported      []string `json:"scopes_supported"`
+	}
+	resourceURLs := []string{origin + "/.well-known/oauth-protected-resource"}
+	if path := strings.TrimSuffix(server.Path, "/"); path != "" {
+		resourceURLs = slices.Insert(resourceURLs, 0, origin+"/.well-known/oauth-protected-resource"+path)
+	}
+	if u := challengeParam(challenge, "resource_metadata"); u != "" {
+		resourceURLs = []string{u}
+	}
+	issuer := origin
+	if getJSON(ctx, client, resourceURLs, &resource) {
+		// Otherwise a server could have Crush authorize with the server of
+		// another resource and collect its tokens (RFC 9728, section 3.3).
+		if !matchesResource(resource.Resource, server) {
+			return nil, fmt.Errorf("protected resource metadata is for %q, not %q", resource.Resource, server)
+		}
+		if len(resource.AuthorizationServers) > 0 {
+			issuer = strings.TrimSuffix(resource.AuthorizationServers[0], "/")
+		}
+	}
+
+	meta := &Metadata{
+		Resource: cmp.Or(resource.Resource, server.String()),
+		Scope:    cmp.Or(challengeParam(challenge, "scope"), strings.Join(resource.ScopesSupported, " ")),
+	}
+	issuerURL, err := url.Parse(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authorization server: %w", err)
+	}
+	issuerOrigin := issuerURL.Scheme + "://" + issuerURL.Host
+	metaURLs := []string{
+		issuerOrigin + "/.well-known/oauth-authorization-server",
+		issuerOrigin + "/.well-known/openid-configuration",
+	}
+	if path := strings.TrimSuffix(issuerURL.Path, "/"); path != "" {
+		metaURLs = []string{
+			issuerOrigin + "/.well-known/oauth-authorization-server" + path,
+			issuerOrigin + "/.well-known/openid-configuration" + path,
+			issuerOrigin + path + "/.well-known/openid-configuration",
+		}
+	}
+	if !getJSON(ctx, client, metaURLs, meta) || meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" {
+		meta.AuthorizationEndpoint = issuerOrigin + "/authorize"
+		meta.TokenEndpoint = issuerOrigin + "/token"
+		meta.RegistrationEndpoint = issuerOrigin + "/register"
+	}
+	return meta, nil
+}
+
+// matchesResource reports whether the resource of protected resource
+// metadata is the MCP server at server or, as servers may publish the
+// metadata of their whole origin, one of its parents on the same origin.
+func matchesResource(resource string, server *url.URL) bool {
+	u, err := url.Parse(resource)
+	if err != nil || !strings.EqualFold(u.Scheme, server.Scheme) || !strings.EqualFold(u.Host, server.Host) {
+		return false
+	}
+	parent := strings.TrimSuffix(u.Path, "/")
+	path := strings.TrimSuffix(server.Path, "/")
+	return path == parent || strings.HasPrefix(path, parent+"/")
+}
+
+// Register registers Crush as a public client of the authorization server.
+func Register(ctx context.Context, client *http.Client, meta *Metadata, redirectURI string) (*Credentials, error) {
+	if meta.RegistrationEndpoint == "" {
+		return nil, errors.New("the authorization server does not support dynamic client registration")
+	}
+	body, err := json.Marshal(map[string]any{
+		"client_name":                "Crush",
+		"redirect_uris":              []string{redirectURI},
+		"grant_types":                []string{"authorization_code", "refresh_token"},
+		"response_types":             []string{"code"},
+		"token_endpoint_auth_method": "none",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.RegistrationEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "crush")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("client registration failed: status %d, body %q", resp.StatusCode, string(data))
+	}
+
+	var result struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	if result.ClientID == "" {
+		return nil, errors.New("client registration returned no client id")
+	}
+	return &Credentials{
+		ClientID:     result.ClientID,
+		ClientSecret: result.ClientSecret,
+		TokenURL:     meta.TokenEndpoint,
+		Resource:     meta.Resource,
+	}, nil
+}
+
+// requestToken requests a token from the token endpoint of creds with the
+// given grant and stores it in creds.
+func requestToken(ctx context.Context, client *http.Client, creds *Credentials, form url.Values) error {
+	form.Set("client_id", creds.ClientID)
+	if creds.ClientSecret != "" {
+		form.Set("client_secret", creds.ClientSecret)
+	}
+	if creds.Resource != "" {
+		form.Set("resource", creds.Resource)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, creds.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "crush")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token request failed: status %d, body %q", resp.StatusCode, string(body))
+	}
+
+	var token oauth.Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return fmt.Errorf("unmarshal response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return errors.New("token response has no access token")
+	}
+	// The refresh token is kept when the server doesn't rotate it.
+	token.RefreshToken = cmp.Or(token.RefreshToken, creds.Token.RefreshToken)
+	token.SetExpiresAt()
+	creds.Token = token
+	return nil
+}
+
+// waitForCode serves the redirect of the authorization server on ln and
+// returns the authorization code it carries. open is called once the
+// listener is ready.
+func waitForCode(ctx context.Context, ln net.Listener, state string, open func() error) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	srv := &http.Server{
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/callback" {
+				http.NotFound(w, r)
+				return
+			}
+			query := r.URL.Query()
+			// Callbacks that are not for this flow, e.g. from a stale
+			// browser tab, must not end it.
+			if query.Get("state") != state {
+				http.Error(w, "authorization failed: state mismatch", http.StatusBadRequest)
+				return
+			}
+			var res result
+			switch {
+			case query.Get("error") != "":
+				res.err = fmt.Errorf("authorization failed: %s", cmp.Or(query.Get("error_description"), query.Get("error")))
+			case query.Get("code") == "":
+				res.err = errors.New("authorization failed: no code")
+			default:
+				res.code = query.Get("code")
+			}
+			if res.err != nil {
+				http.Error(w, res.err.Error(), http.StatusBadRequest)
+			} else {
+				fmt.Fprintln(w, "Crush is now authorized. You can close this window.")
+			}
+			select {
+			case results <- res:
+			default:
+			}
+		}),
+	}
+	go srv.Serve(ln) //nolint:errcheck
+	defer srv.Close()
+
+	if err := open(); err != nil {
+		return "", fmt.Errorf("open browser: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return "", fmt.Errorf("authorization timed out: %w", ctx.Err())
+	case res := <-results:
+		return res.code, res.err
+	}
+}
+
+// getJSON decodes the first of urls that answers with JSON into v.
+func getJSON(ctx context.Context, client *http.Client, urls []string, v any) bool {
+	for _, u := range urls {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			continue
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("User-Agent", "crush")
+		resp, err := client.Do(req)
+		if err != nil {
+			continue
+		}
+		ok := resp.StatusCode == http.StatusOK &&
+			json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v) == nil
+		resp.Body.Close()
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+var challengeParamRe = regexp.MustCompile(`([a-zA-Z_]+)=(?:"([^"]*)"|([^\s,]+))`)
+
+// challengeParam returns a parameter of a WWW-Authenticate header.
+func challengeParam(header, name string) string {
+	for _, m := range challengeParamRe.FindAllStringSubmatch(header, -1) {
+		if strings.EqualFold(m[1], name) {
+			return cmp.Or(m[2], m[3])
+		}
+	}
+	return ""
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
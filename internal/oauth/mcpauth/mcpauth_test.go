@@ -0,0 +1,199 @@
+package mcpauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newAuthServer serves an MCP server that requires authorization, along
+// with its authorization server.
+func newAuthServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	var challenge string
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/.well-known/oauth-protected-resource/mcp", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"resource":              srv.URL + "/mcp",
+			"authorization_servers": []string{srv.URL},
+			"scopes_supported":      []string{"tools"},
+		})
+	})
+	mux.HandleFunc("/.well-known/oauth-authorization-server", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"authorization_endpoint": srv.URL + "/oauth/authorize",
+			"token_endpoint":         srv.URL + "/oauth/token",
+			"registration_endpoint":  srv.URL + "/oauth/register",
+		})
+	})
+	mux.HandleFunc("/oauth/register", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"client_id": "crush-client"})
+	})
+	mux.HandleFunc("/oauth/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("client_id") != "crush-client" || query.Get("code_challenge_method") != "S256" ||
+			query.Get("resource") != srv.URL+"/mcp" || query.Get("scope") != "tools" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		challenge = query.Get("code_challenge")
+		redirect, _ := url.Parse(query.Get("redirect_uri"))
+		redirect.RawQuery = url.Values{"code": {"the-code"}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			if r.Form.Get("code") != "the-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"access_token":  "access-1",
+				"refresh_token": "refresh-1",
+				"expires_in":    3600,
+			})
+		case "refresh_token":
+			if r.Form.Get("refresh_token") != "refresh-1" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"access_token": "access-2",
+				"expires_in":   3600,
+			})
+		}
+	})
+	return srv
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Parallel()
+
+	srv := newAuthServer(t)
+	serverURL := srv.URL + "/mcp"
+	challenge := `Bearer resource_metadata="` + srv.URL + `/.well-known/oauth-protected-resource/mcp"`
+
+	// The browser follows the redirect back to the loopback listener.
+	open := func(u string) error {
+		resp, err := http.Get(u)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	creds, err := Authorize(t.Context(), serverURL, challenge, open)
+	require.NoError(t, err)
+	require.Equal(t, "crush-client", creds.ClientID)
+	require.Equal(t, "access-1", creds.Token.AccessToken)
+	require.Equal(t, "refresh-1", creds.Token.RefreshToken)
+
+	store := NewStore(filepath.Join(t.TempDir(), "mcp-oauth.json"))
+	require.NoError(t, store.Set(serverURL, *creds))
+	token, ok := store.AccessToken(t.Context(), srv.Client(), serverURL)
+	require.True(t, ok)
+	require.Equal(t, "access-1", token)
+
+	// Expired tokens get refreshed, keeping the refresh token the server
+	// didn't rotate.
+	creds.Token.ExpiresAt = time.Now().Unix() - 1
+	require.NoError(t, store.Set(serverURL, *creds))
+	token, ok = store.AccessToken(t.Context(), srv.Client(), serverURL)
+	require.True(t, ok)
+	require.Equal(t, "access-2", token)
+	stored, ok := store.Get(serverURL)
+	require.True(t, ok)
+	require.Equal(t, "refresh-1", stored.Token.RefreshToken)
+
+	_, ok = store.AccessToken(t.Context(), srv.Client(), srv.URL+"/other")
+	require.False(t, ok)
+}
+
+func TestDiscover(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		resource func(srv *httptest.Server) string
+		err      string
+	}{
+		{name: "same resource", resource: func(srv *httptest.Server) string { return srv.URL + "/mcp" }},
+		{name: "parent resource", resource: func(srv *httptest.Server) string { return srv.URL + "/" }},
+		{name: "sibling resource", resource: func(srv *httptest.Server) string { return srv.URL + "/mcpx" }, err: "protected resource metadata is for"},
+		{name: "other origin", resource: func(*httptest.Server) string { return "https://example.com/mcp" }, err: "protected resource metadata is for"},
+		{name: "no resource", resource: func(*httptest.Server) string { return "" }, err: "protected resource metadata is for"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := http.NewServeMux()
+			srv := httptest.NewServer(mux)
+			t.Cleanup(srv.Close)
+			mux.HandleFunc("/.well-known/oauth-protected-resource/mcp", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string]any{
+					"resource":              tt.resource(srv),
+					"authorization_servers": []string{srv.URL + "/auth"},
+				})
+			})
+
+			meta, err := Discover(t.Context(), srv.Client(), srv.URL+"/mcp", "")
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, srv.URL+"/token", meta.TokenEndpoint)
+		})
+	}
+}
+
+func TestChallengeParam(t *testing.T) {
+	t.Parallel()
+
+	header := `Bearer error="invalid_token", resource_metadata="https://example.com/.well-known/oauth-protected-resource", scope=read`
+	require.Equal(t, "https://example.com/.well-known/oauth-protected-resource", challengeParam(header, "resource_metadata"))
+	require.Equal(t, "read", challengeParam(header, "scope"))
+	require.Empty(t, challengeParam(header, "realm"))
+}
+
+func TestWaitForCode(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	callback := "http://" + ln.Addr().String() + "/callback?"
+
+	// A callback for another flow doesn't end this one.
+	open := func() error {
+		for _, query := range []url.Values{
+			{"code": {"stale-code"}, "state": {"other-state"}},
+			{"code": {"the-code"}, "state": {"the-state"}},
+		} {
+			resp, err := http.Get(callback + query.Encode())
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+		}
+		return nil
+	}
+	code, err := waitForCode(t.Context(), ln, "the-state", open)
+	require.NoError(t, err)
+	require.Equal(t, "the-code", code)
+}
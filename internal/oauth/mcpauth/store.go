@@ -0,0 +1,150 @@
+package mcpauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Store keeps the credentials of MCP servers in a JSON file, by server URL.
+// The file is only read again when it changes, e.g. when another instance
+// of Crush authorizes a server.
+type Store struct {
+	path string
+	mu   sync.Mutex
+
+	// cache holds the content of the file as of modTime.
+	cache   map[string]Credentials
+	modTime time.Time
+}
+
+// NewStore returns a store backed by the file at path.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Get returns the credentials of the MCP server at serverURL.
+func (s *Store) Get(serverURL string) (Credentials, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return Credentials{}, false
+	}
+	creds, ok := all[serverURL]
+	return creds, ok
+}
+
+// Set stores the credentials of the MCP server at serverURL.
+func (s *Store) Set(serverURL string, creds Credentials) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set(serverURL, creds)
+}
+
+// Delete forgets the credentials of the MCP server at serverURL.
+func (s *Store) Delete(serverURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := all[serverURL]; !ok {
+		return nil
+	}
+	delete(all, serverURL)
+	return s.save(all)
+}
+
+// AccessToken returns the access token for the MCP server at serverURL,
+// refreshing it first if it expired. It returns false if there is no
+// usable token, and the server has to be authorized again.
+func (s *Store) AccessToken(ctx context.Context, client *http.Client, serverURL string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return "", false
+	}
+	creds, ok := all[serverURL]
+	if !ok || creds.Token.AccessToken == "" {
+		return "", false
+	}
+	if !creds.Expired() {
+		return creds.Token.AccessToken, true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := Refresh(ctx, client, &creds); err != nil {
+		return "", false
+	}
+	if err := s.set(serverURL, creds); err != nil {
+		return "", false
+	}
+	return creds.Token.AccessToken, true
+}
+
+func (s *Store) set(serverURL string, creds Credentials) error {
+	all, err := s.load()
+	if err != nil {
+		return err
+	}
+	all[serverURL] = creds
+	return s.save(all)
+}
+
+// load returns the credentials in the file. The map is shared with the
+// cache, so callers that change it must save it.
+func (s *Store) load() (map[string]Credentials, error) {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.cache, s.modTime = nil, time.Time{}
+		return map[string]Credentials{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.cache != nil && info.ModTime().Equal(s.modTime) {
+		return s.cache, nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	all := map[string]Credentials{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", s.path, err)
+	}
+	s.cache, s.modTime = all, info.ModTime()
+	return all, nil
+}
+
+func (s *Store) save(all map[string]Credentials) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, data, 0o600); err != nil {
+		s.cache = nil
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.cache, s.modTime = all, info.ModTime()
+	} else {
+		s.cache = nil
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package permission
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/google/uuid"
+)
+
+// DecisionSource tells what decided a permission request.
+type DecisionSource string
+
+const (
+	// SourceUser means the user answered the permission prompt.
+	SourceUser DecisionSource = "user"
+	// SourceSessionGrant means the user allowed the same request earlier for
+	// the rest of the session.
+	SourceSessionGrant DecisionSource = "session_grant"
+	// SourceAllowlist means the tool is in the allowed tools list.
+	SourceAllowlist DecisionSource = "allowlist"
+	// SourceYolo means permission requests are skipped altogether.
+	SourceYolo DecisionSource = "yolo"
+	// SourceAutoApprove means every request of the session is approved, as
+	// for non-interactive runs.
+	SourceAutoApprove DecisionSource = "auto_approve"
+	// SourceRule means a permission rule matched the request.
+	SourceRule DecisionSource = "rule"
+	// SourceSessionMode means the mode of the session denied the request.
+	SourceSessionMode DecisionSource = "session_mode"
+)
+
+// AuditEntry is a permission decision as recorded in the audit log.
+type AuditEntry struct {
+	ID          string          `json:"id"`
+	SessionID   string          `json:"session_id"`
+	ToolCallID  string          `json:"tool_call_id"`
+	ToolName    string          `json:"tool_name"`
+	Action      string          `json:"action"`
+	Path        string          `json:"path"`
+	Params      json.RawMessage `json:"params,omitempty"`
+	Granted     bool            `json:"granted"`
+	Source      DecisionSource  `json:"source"`
+	MatchedRule string          `json:"matched_rule,omitempty"`
+	CreatedAt   int64           `json:"created_at"`
+}
+
+// ListAudit returns the audit log, oldest first. If sessionID is not empty,
+// only the decisions of that session are returned.
+func ListAudit(ctx context.Context, q db.Querier, sessionID string) ([]AuditEntry, error) {
+	var (
+		rows []db.PermissionAudit
+		err  error
+	)
+	if sessionID != "" {
+		rows, err = q.ListPermissionAuditBySession(ctx, sessionID)
+	} else {
+		rows, err = q.ListPermissionAudit(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]AuditEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = AuditEntry{
+			ID:          row.ID,
+			SessionID:   row.SessionID,
+			ToolCallID:  row.ToolCallID,
+			ToolName:    row.ToolName,
+			Action:      row.Action,
+			Path:        row.Path,
+			Granted:     row.Granted == 1,
+			Source:      DecisionSource(row.Source),
+			MatchedRule: row.MatchedRule,
+			CreatedAt:   row.CreatedAt,
+		}
+		if row.Params != "" {
+			entries[i].Params = json.RawMessage(row.Params)
+		}
+	}
+	return entries, nil
+}
+
+// audit records a permission decision. Failing to record it is logged but
+// does not change the decision.
+func (s *permissionService) audit(ctx context.Context, opts CreatePermissionRequest, granted bool, source DecisionSource, rule string) {
+	if s.q == nil {
+		return
+	}
+
+	var params string
+	if opts.Params != nil {
+		if bts, err := json.Marshal(opts.Params); err == nil {
+			params = string(bts)
+		}
+	}
+	var grantedValue int64
+	if granted {
+		grantedValue = 1
+	}
+
+	// The decision has been made at this point, so record it even if the
+	// request has been cancelled in the meantime.
+	err := s.q.CreatePermissionAudit(context.WithoutCancel(ctx), db.CreatePermissionAuditParams{
+		ID:          uuid.New().String(),
+		SessionID:   opts.SessionID,
+		ToolCallID:  opts.ToolCallID,
+		ToolName:    opts.ToolName,
+		Action:      opts.Action,
+		Path:        opts.Path,
+		Params:      params,
+		Granted:     grantedValue,
+		Source:      string(source),
+		MatchedRule: rule,
+	})
+	if err != nil {
+		slog.Error("Failed to record permission decision", "tool", opts.ToolName, "error", err)
+	}
+}
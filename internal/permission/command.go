@@ -0,0 +1,249 @@
+package permission
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// maxScriptDepth limits how deep scripts given to shells, as in `bash -c`,
+// are looked into.
+const maxScriptDepth = 4
+
+// commandLine is what rules see of a bash command: the simple commands it
+// runs, wherever they are in the command line.
+type commandLine struct {
+	// commands are the simple commands, as their name followed by their
+	// arguments. Commands run through a path or a wrapper, such as
+	// `/bin/rm` or `env rm`, are there both as they are written and as the
+	// program they run, so that allow rules have to cover both and deny
+	// rules can match either. They include the commands run by
+	// substitutions and by the scripts given to shells.
+	commands []string
+	// dynamic is set when the command line does more than running commands
+	// with the arguments it spells out: it substitutes commands, redirects
+	// output to files or runs commands only known once it runs. Allow rules
+	// never match such command lines.
+	dynamic bool
+}
+
+// parseCommandLine parses a bash command the way the bash tool runs it.
+func parseCommandLine(command string) (commandLine, bool) {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return commandLine{}, false
+	}
+	var line commandLine
+	line.add(file, 0)
+	return line, true
+}
+
+func (l *commandLine) add(node syntax.Node, depth int) {
+	syntax.Walk(node, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.CmdSubst, *syntax.ProcSubst:
+			l.dynamic = true
+		case *syntax.Redirect:
+			if writesFile(node) {
+				l.dynamic = true
+			}
+		case *syntax.CallExpr:
+			l.addCall(node, depth)
+		}
+		return true
+	})
+}
+
+// commandWord is a word of a simple command.
+type commandWord struct {
+	// text is the value of the word if it is literal, or its source
+	// otherwise.
+	text    string
+	literal bool
+}
+
+func (l *commandLine) addCall(call *syntax.CallExpr, depth int) {
+	// Calls with no arguments only assign variables, and their values are
+	// walked on their own.
+	if len(call.Args) == 0 {
+		return
+	}
+	words := make([]commandWord, 0, len(call.Args))
+	for _, word := range call.Args {
+		text, literal := literalWord(word)
+		if !literal {
+			text = wordSource(word)
+		}
+		words = append(words, commandWord{text: text, literal: literal})
+	}
+	for {
+		l.commands = append(l.commands, joinWords(words))
+		unwrapped, ok := unwrapCommand(words)
+		if !ok {
+			break
+		}
+		words = unwrapped
+	}
+	if !words[0].literal {
+		l.dynamic = true
+	}
+	name := words[0].text
+	if base := path.Base(name); base != name {
+		name = base
+		words = append([]commandWord{{text: name, literal: true}}, words[1:]...)
+		l.commands = append(l.commands, joinWords(words))
+	}
+
+	var script commandWord
+	switch {
+	case name == "eval":
+		script.literal = true
+		for i, word := range words[1:] {
+			if i > 0 {
+				script.text += " "
+			}
+			script.text += word.text
+			script.literal = script.literal && word.literal
+		}
+	case isShell(name):
+		var ok bool
+		if script, ok = shellScript(words[1:]); !ok {
+			return
+		}
+	default:
+		return
+	}
+	if !script.literal || depth >= maxScriptDepth {
+		l.dynamic = true
+		return
+	}
+	file, err := syntax.NewParser().Parse(strings.NewReader(script.text), "")
+	if err != nil {
+		l.dynamic = true
+		return
+	}
+	l.add(file, depth+1)
+}
+
+// commandWrappers run the command that follows them, unless they are given
+// options.
+var commandWrappers = []string{"builtin", "command", "exec", "nohup", "sudo"}
+
+// unwrapCommand returns the command run by a wrapper, such as env or exec.
+func unwrapCommand(words []commandWord) ([]commandWord, bool) {
+	if len(words) < 2 {
+		return nil, false
+	}
+	switch name := path.Base(words[0].text); {
+	case name == "env":
+		words = words[1:]
+		for len(words) > 0 && (strings.HasPrefix(words[0].text, "-") || strings.Contains(words[0].text, "=")) {
+			words = words[1:]
+		}
+		return words, len(words) > 0
+	case slices.Contains(commandWrappers, name) && !strings.HasPrefix(words[1].text, "-"):
+		return words[1:], true
+	}
+	return nil, false
+}
+
+func joinWords(words []commandWord) string {
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = word.text
+	}
+	return strings.Join(texts, " ")
+}
+
+func isShell(name string) bool {
+	switch name {
+	case "sh", "bash", "dash", "zsh", "ksh":
+		return true
+	}
+	return false
+}
+
+// shellScript returns the script given to a shell with -c, if any.
+func shellScript(args []commandWord) (commandWord, bool) {
+	for i, arg := range args[:max(0, len(args)-1)] {
+		if strings.HasPrefix(arg.text, "-") && !strings.HasPrefix(arg.text, "--") && strings.Contains(arg.text, "c") {
+			return args[i+1], true
+		}
+	}
+	return commandWord{}, false
+}
+
+// writesFile reports whether a redirection writes to a file other than
+// /dev/null.
+func writesFile(redirect *syntax.Redirect) bool {
+	target, literal := literalWord(redirect.Word)
+	switch redirect.Op {
+	case syntax.RdrOut, syntax.AppOut, syntax.RdrInOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll:
+		return !literal || target != "/dev/null"
+	case syntax.DplOut:
+		// Duplicating a file descriptor, as in 2>&1, writes no file.
+		return !literal || strings.Trim(target, "0123456789-") != ""
+	}
+	return false
+}
+
+// literalWord returns the value of a word made of literal text and quotes
+// only.
+func literalWord(word *syntax.Word) (string, bool) {
+	if word == nil {
+		return "", false
+	}
+	var b strings.Builder
+	for _, part := range word.Parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			b.WriteString(unescape(part.Value, ""))
+		case *syntax.SglQuoted:
+			if part.Dollar {
+				return "", false
+			}
+			b.WriteString(part.Value)
+		case *syntax.DblQuoted:
+			if part.Dollar {
+				return "", false
+			}
+			for _, part := range part.Parts {
+				lit, ok := part.(*syntax.Lit)
+				if !ok {
+					return "", false
+				}
+				b.WriteString(unescape(lit.Value, "$`\"\\\n"))
+			}
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// unescape removes the backslashes that escape a character. If escapable is
+// not empty, only the backslashes before one of its characters escape it, as
+// in double quotes.
+func unescape(s, escapable string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (escapable == "" || strings.IndexByte(escapable, s[i+1]) >= 0) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func wordSource(word *syntax.Word) string {
+	var b strings.Builder
+	if err := syntax.NewPrinter().Print(&b, word); err != nil {
+		return ""
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package permission
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// SessionMode decides what happens to the permission requests of a session
+// that are not settled by rules, the allowlist or session grants.
+type SessionMode string
+
+const (
+	// SessionModePrompt asks for every request. This is the default.
+	SessionModePrompt SessionMode = "prompt"
+	// SessionModeAuto approves every request.
+	SessionModeAuto SessionMode = "auto"
+	// SessionModeAllowlist denies every request that would otherwise be
+	// asked for.
+	SessionModeAllowlist SessionMode = "allowlist"
+	// SessionModeDeny denies every request, including the ones of allowed
+	// tools and rules.
+	SessionModeDeny SessionMode = "deny"
+)
+
+// SessionModeDeniedError is returned by [Service.Request] when the mode of
+// the session denies the request.
+type SessionModeDeniedError struct {
+	Mode SessionMode
+}
+
+func (e *SessionModeDeniedError) Error() string {
+	return "permission denied by " + string(e.Mode) + " mode"
+}
+
+func (e *SessionModeDeniedError) Unwrap() error {
+	return ErrorPermissionDenied
+}
+
+// SetSessionMode sets the mode of the given session.
+func (s *permissionService) SetSessionMode(sessionID string, mode SessionMode) {
+	s.sessionModesMu.Lock()
+	defer s.sessionModesMu.Unlock()
+	if mode == SessionModePrompt {
+		delete(s.sessionModes, sessionID)
+		return
+	}
+	s.sessionModes[sessionID] = mode
+}
+
+// SessionMode returns the mode of the given session.
+func (s *permissionService) SessionMode(sessionID string) SessionMode {
+	s.sessionModesMu.RLock()
+	defer s.sessionModesMu.RUnlock()
+	if mode, ok := s.sessionModes[sessionID]; ok {
+		return mode
+	}
+	return SessionModePrompt
+}
+
+// AutoApproveSession approves every request of the given session.
+func (s *permissionService) AutoApproveSession(sessionID string) {
+	s.SetSessionMode(sessionID, SessionModeAuto)
+}
+
+func (s *permissionService) denyByMode(ctx context.Context, opts CreatePermissionRequest, mode SessionMode) error {
+	slog.Info("Permission denied by session mode", "tool", opts.ToolName, "mode", mode)
+	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		ToolCallID: opts.ToolCallID,
+		Denied:     true,
+	})
+	s.audit(ctx, opts, false, SourceSessionMode, "")
+	return &SessionModeDeniedError{Mode: mode}
+}
@@ -3,12 +3,14 @@ package permission
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"slices"
 	"sync"
 
 	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/db"
 	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/google/uuid"
 )
@@ -29,6 +31,10 @@ type PermissionNotification struct {
 	ToolCallID string `json:"tool_call_id"`
 	Granted    bool   `json:"granted"`
 	Denied     bool   `json:"denied"`
+	// Canceled is set when the request was given up before being answered,
+	// as when the tool call is canceled.
+	Canceled    bool   `json:"canceled,omitempty"`
+	MatchedRule string `json:"matched_rule,omitempty"`
 }
 
 type PermissionRequest struct {
@@ -40,6 +46,25 @@ type PermissionRequest struct {
 	Action      string `json:"action"`
 	Params      any    `json:"params"`
 	Path        string `json:"path"`
+	MatchedRule string `json:"matched_rule,omitempty"`
+}
+
+// SessionGrant is a permission the user allowed for the rest of a session.
+// Grants are stored in the database, so they survive restarts.
+type SessionGrant struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	ToolName  string `json:"tool_name"`
+	Action    string `json:"action"`
+	Path      string `json:"path"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func (g SessionGrant) matches(permission PermissionRequest) bool {
+	return g.ToolName == permission.ToolName &&
+		g.Action == permission.Action &&
+		g.SessionID == permission.SessionID &&
+		g.Path == permission.Path
 }
 
 type Service interface {
@@ -48,24 +73,32 @@ type Service interface {
 	Grant(permission PermissionRequest)
 	Deny(permission PermissionRequest)
 	Request(ctx context.Context, opts CreatePermissionRequest) (bool, error)
+	CheckRules(opts CreatePermissionRequest) error
 	AutoApproveSession(sessionID string)
+	SetSessionMode(sessionID string, mode SessionMode)
+	SessionMode(sessionID string) SessionMode
 	SetSkipRequests(skip bool)
 	SkipRequests() bool
 	SubscribeNotifications(ctx context.Context) <-chan pubsub.Event[PermissionNotification]
+	ListSessionGrants(ctx context.Context, sessionID string) ([]SessionGrant, error)
+	RevokeSessionGrant(ctx context.Context, sessionID, grantID string) error
+	ForgetSession(sessionID string)
 }
 
 type permissionService struct {
 	*pubsub.Broker[PermissionRequest]
 
-	notificationBroker    *pubsub.Broker[PermissionNotification]
-	workingDir            string
-	sessionPermissions    []PermissionRequest
-	sessionPermissionsMu  sync.RWMutex
-	pendingRequests       *csync.Map[string, chan bool]
-	autoApproveSessions   map[string]bool
-	autoApproveSessionsMu sync.RWMutex
-	skip                  bool
-	allowedTools          []string
+	notificationBroker *pubsub.Broker[PermissionNotification]
+	q                  db.Querier
+	workingDir         string
+	sessionGrants      map[string][]SessionGrant
+	sessionGrantsMu    sync.Mutex
+	pendingRequests    *csync.Map[string, chan bool]
+	sessionModes       map[string]SessionMode
+	sessionModesMu     sync.RWMutex
+	skip               bool
+	allowedTools       []string
+	policy             policy
 
 	// used to make sure we only process one request at a time
 	requestMu       sync.Mutex
@@ -83,9 +116,7 @@ func (s *permissionService) GrantPersistent(permission PermissionRequest) {
 		respCh <- true
 	}
 
-	s.sessionPermissionsMu.Lock()
-	s.sessionPermissions = append(s.sessionPermissions, permission)
-	s.sessionPermissionsMu.Unlock()
+	s.saveSessionGrant(permission)
 
 	s.activeRequestMu.Lock()
 	if s.activeRequest != nil && s.activeRequest.ID == permission.ID {
@@ -130,7 +161,18 @@ func (s *permissionService) Deny(permission PermissionRequest) {
 }
 
 func (s *permissionService) Request(ctx context.Context, opts CreatePermissionRequest) (bool, error) {
+	rule, matched := s.policy.evaluate(opts)
+	if matched && rule.Action == RuleDeny {
+		return false, s.denyByRule(ctx, opts, rule)
+	}
+
+	mode := s.SessionMode(opts.SessionID)
+	if mode == SessionModeDeny {
+		return false, s.denyByMode(ctx, opts, mode)
+	}
+
 	if s.skip {
+		s.audit(ctx, opts, true, SourceYolo, "")
 		return true, nil
 	}
 
@@ -141,21 +183,31 @@ func (s *permissionService) Request(ctx context.Context, opts CreatePermissionRe
 	s.requestMu.Lock()
 	defer s.requestMu.Unlock()
 
-	// Check if the tool/action combination is in the allowlist
-	commandKey := opts.ToolName + ":" + opts.Action
-	if slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName) {
+	if matched && rule.Action == RuleAllow {
+		slog.Debug("Permission granted by rule", "tool", opts.ToolName, "rule", rule.String())
+		s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+			ToolCallID:  opts.ToolCallID,
+			Granted:     true,
+			MatchedRule: rule.String(),
+		})
+		s.audit(ctx, opts, true, SourceRule, rule.String())
 		return true, nil
 	}
 
-	s.autoApproveSessionsMu.RLock()
-	autoApprove := s.autoApproveSessions[opts.SessionID]
-	s.autoApproveSessionsMu.RUnlock()
+	// Check if the tool/action combination is in the allowlist, unless an ask
+	// rule explicitly requires a prompt.
+	commandKey := opts.ToolName + ":" + opts.Action
+	if !matched && (slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName)) {
+		s.audit(ctx, opts, true, SourceAllowlist, "")
+		return true, nil
+	}
 
-	if autoApprove {
+	if mode == SessionModeAuto {
 		s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
 			ToolCallID: opts.ToolCallID,
 			Granted:    true,
 		})
+		s.audit(ctx, opts, true, SourceAutoApprove, "")
 		return true, nil
 	}
 
@@ -182,19 +234,28 @@ func (s *permissionService) Request(ctx context.Context, opts CreatePermissionRe
 		Action:      opts.Action,
 		Params:      opts.Params,
 	}
+	if matched {
+		permission.MatchedRule = rule.String()
+	}
 
-	s.sessionPermissionsMu.RLock()
-	for _, p := range s.sessionPermissions {
-		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
-			s.sessionPermissionsMu.RUnlock()
+	grants, err := s.ListSessionGrants(ctx, permission.SessionID)
+	if err != nil {
+		slog.Error("Failed to load session permission grants", "session", permission.SessionID, "error", err)
+	}
+	for _, g := range grants {
+		if g.matches(permission) {
 			s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
 				ToolCallID: opts.ToolCallID,
 				Granted:    true,
 			})
+			s.audit(ctx, opts, true, SourceSessionGrant, "")
 			return true, nil
 		}
 	}
-	s.sessionPermissionsMu.RUnlock()
+
+	if mode == SessionModeAllowlist {
+		return false, s.denyByMode(ctx, opts, mode)
+	}
 
 	s.activeRequestMu.Lock()
 	s.activeRequest = &permission
@@ -209,16 +270,142 @@ func (s *permissionService) Request(ctx context.Context, opts CreatePermissionRe
 
 	select {
 	case <-ctx.Done():
+		s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+			ToolCallID: opts.ToolCallID,
+			Canceled:   true,
+		})
 		return false, ctx.Err()
 	case granted := <-respCh:
+		s.audit(ctx, opts, granted, SourceUser, permission.MatchedRule)
 		return granted, nil
 	}
 }
 
-func (s *permissionService) AutoApproveSession(sessionID string) {
-	s.autoApproveSessionsMu.Lock()
-	s.autoApproveSessions[sessionID] = true
-	s.autoApproveSessionsMu.Unlock()
+// CheckRules returns a [RuleDeniedError] if a deny rule matches the request.
+// Tools use it for operations they would otherwise run without asking, so
+// deny rules apply to them too.
+func (s *permissionService) CheckRules(opts CreatePermissionRequest) error {
+	if rule, matched := s.policy.evaluate(opts); matched && rule.Action == RuleDeny {
+		return s.denyByRule(context.Background(), opts, rule)
+	}
+	return nil
+}
+
+func (s *permissionService) denyByRule(ctx context.Context, opts CreatePermissionRequest, rule Rule) error {
+	slog.Info("Permission denied by rule", "tool", opts.ToolName, "rule", rule.String())
+	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		ToolCallID:  opts.ToolCallID,
+		Denied:      true,
+		MatchedRule: rule.String(),
+	})
+	s.audit(ctx, opts, false, SourceRule, rule.String())
+	return &RuleDeniedError{Rule: rule}
+}
+
+// ListSessionGrants returns the permissions granted for the rest of the
+// given session.
+func (s *permissionService) ListSessionGrants(ctx context.Context, sessionID string) ([]SessionGrant, error) {
+	s.sessionGrantsMu.Lock()
+	defer s.sessionGrantsMu.Unlock()
+
+	return s.loadSessionGrants(ctx, sessionID)
+}
+
+// RevokeSessionGrant removes a session grant, so the permission is asked for
+// again the next time it is needed.
+func (s *permissionService) RevokeSessionGrant(ctx context.Context, sessionID, grantID string) error {
+	s.sessionGrantsMu.Lock()
+	defer s.sessionGrantsMu.Unlock()
+
+	if s.q != nil {
+		if err := s.q.DeletePermissionGrant(ctx, grantID); err != nil {
+			return err
+		}
+	}
+	if grants, ok := s.sessionGrants[sessionID]; ok {
+		s.sessionGrants[sessionID] = slices.DeleteFunc(grants, func(g SessionGrant) bool {
+			return g.ID == grantID
+		})
+	}
+	return nil
+}
+
+// ForgetSession drops the mode and the unsaved grants of a deleted session.
+func (s *permissionService) ForgetSession(sessionID string) {
+	s.sessionModesMu.Lock()
+	delete(s.sessionModes, sessionID)
+	s.sessionModesMu.Unlock()
+
+	s.sessionGrantsMu.Lock()
+	delete(s.sessionGrants, sessionID)
+	s.sessionGrantsMu.Unlock()
+}
+
+// loadSessionGrants must be called with sessionGrantsMu held. Saved grants
+// are read from the database every time, as other processes sharing it, such
+// as `crush serve`, may grant and revoke them too. Only the grants that could
+// not be saved are kept in memory.
+func (s *permissionService) loadSessionGrants(ctx context.Context, sessionID string) ([]SessionGrant, error) {
+	grants := slices.Clone(s.sessionGrants[sessionID])
+	if s.q == nil {
+		return grants, nil
+	}
+	dbGrants, err := s.q.ListPermissionGrantsBySession(ctx, sessionID)
+	if err != nil {
+		return grants, err
+	}
+	for _, g := range dbGrants {
+		grants = append(grants, fromDBGrant(g))
+	}
+	return grants, nil
+}
+
+func (s *permissionService) saveSessionGrant(permission PermissionRequest) {
+	s.sessionGrantsMu.Lock()
+	defer s.sessionGrantsMu.Unlock()
+
+	ctx := context.Background()
+	grants, err := s.loadSessionGrants(ctx, permission.SessionID)
+	if err != nil {
+		slog.Error("Failed to load session permission grants", "session", permission.SessionID, "error", err)
+	}
+	if slices.ContainsFunc(grants, func(g SessionGrant) bool { return g.matches(permission) }) {
+		return
+	}
+
+	grant := SessionGrant{
+		ID:        uuid.New().String(),
+		SessionID: permission.SessionID,
+		ToolName:  permission.ToolName,
+		Action:    permission.Action,
+		Path:      permission.Path,
+	}
+	if s.q != nil {
+		_, err = s.q.CreatePermissionGrant(ctx, db.CreatePermissionGrantParams{
+			ID:        grant.ID,
+			SessionID: grant.SessionID,
+			ToolName:  grant.ToolName,
+			Action:    grant.Action,
+			Path:      grant.Path,
+		})
+		if err == nil {
+			return
+		}
+		// Keep the grant in memory so it still applies to this run.
+		slog.Error("Failed to save session permission grant", "session", permission.SessionID, "error", err)
+	}
+	s.sessionGrants[permission.SessionID] = append(s.sessionGrants[permission.SessionID], grant)
+}
+
+func fromDBGrant(g db.PermissionGrant) SessionGrant {
+	return SessionGrant{
+		ID:        g.ID,
+		SessionID: g.SessionID,
+		ToolName:  g.ToolName,
+		Action:    g.Action,
+		Path:      g.Path,
+		CreatedAt: g.CreatedAt,
+	}
 }
 
 func (s *permissionService) SubscribeNotifications(ctx context.Context) <-chan pubsub.Event[PermissionNotification] {
@@ -233,15 +420,19 @@ func (s *permissionService) SkipRequests() bool {
 	return s.skip
 }
 
-func NewPermissionService(workingDir string, skip bool, allowedTools []string) Service {
+// NewPermissionService creates a permission service. Session grants are
+// persisted through q; a nil q keeps them in memory only.
+func NewPermissionService(q db.Querier, workingDir string, skip bool, allowedTools []string, rules []Rule) Service {
 	return &permissionService{
-		Broker:              pubsub.NewBroker[PermissionRequest](),
-		notificationBroker:  pubsub.NewBroker[PermissionNotification](),
-		workingDir:          workingDir,
-		sessionPermissions:  make([]PermissionRequest, 0),
-		autoApproveSessions: make(map[string]bool),
-		skip:                skip,
-		allowedTools:        allowedTools,
-		pendingRequests:     csync.NewMap[string, chan bool](),
+		Broker:             pubsub.NewBroker[PermissionRequest](),
+		notificationBroker: pubsub.NewBroker[PermissionNotification](),
+		q:                  q,
+		workingDir:         workingDir,
+		sessionGrants:      make(map[string][]SessionGrant),
+		sessionModes:       make(map[string]SessionMode),
+		skip:               skip,
+		allowedTools:       allowedTools,
+		policy:             policy{workingDir: workingDir, rules: rules},
+		pendingRequests:    csync.NewMap[string, chan bool](),
 	}
 }
@@ -4,6 +4,8 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/session"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -55,7 +57,7 @@ func TestPermissionService_AllowedCommands(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service := NewPermissionService("/tmp", false, tt.allowedTools)
+			service := NewPermissionService(nil, "/tmp", false, tt.allowedTools, nil)
 
 			// Create a channel to capture the permission request
 			// Since we're testing the allowlist logic, we need to simulate the request
@@ -80,7 +82,7 @@ func TestPermissionService_AllowedCommands(t *testing.T) {
 }
 
 func TestPermissionService_SkipMode(t *testing.T) {
-	service := NewPermissionService("/tmp", true, []string{})
+	service := NewPermissionService(nil, "/tmp", true, []string{}, nil)
 
 	result, err := service.Request(t.Context(), CreatePermissionRequest{
 		SessionID:   "test-session",
@@ -97,9 +99,74 @@ func TestPermissionService_SkipMode(t *testing.T) {
 	}
 }
 
+func TestPermissionService_SessionModes(t *testing.T) {
+	rules := []Rule{{Action: RuleAllow, Tool: "view"}}
+	request := func(tool string) CreatePermissionRequest {
+		return CreatePermissionRequest{
+			SessionID: "session",
+			ToolName:  tool,
+			Action:    "execute",
+			Path:      "/tmp",
+		}
+	}
+
+	t.Run("defaults to prompt", func(t *testing.T) {
+		service := NewPermissionService(nil, "/tmp", false, nil, nil)
+		require.Equal(t, SessionModePrompt, service.SessionMode("session"))
+	})
+
+	t.Run("auto approves everything", func(t *testing.T) {
+		service := NewPermissionService(nil, "/tmp", false, nil, nil)
+		service.SetSessionMode("session", SessionModeAuto)
+		granted, err := service.Request(t.Context(), request("bash"))
+		require.NoError(t, err)
+		require.True(t, granted)
+	})
+
+	t.Run("allowlist denies instead of prompting", func(t *testing.T) {
+		service := NewPermissionService(nil, "/tmp", false, []string{"ls"}, rules)
+		service.SetSessionMode("session", SessionModeAllowlist)
+
+		for _, tool := range []string{"ls", "view"} {
+			granted, err := service.Request(t.Context(), request(tool))
+			require.NoError(t, err)
+			require.True(t, granted, tool)
+		}
+
+		granted, err := service.Request(t.Context(), request("bash"))
+		require.False(t, granted)
+		require.ErrorIs(t, err, ErrorPermissionDenied)
+		var modeErr *SessionModeDeniedError
+		require.ErrorAs(t, err, &modeErr)
+		require.Equal(t, SessionModeAllowlist, modeErr.Mode)
+	})
+
+	t.Run("deny denies everything", func(t *testing.T) {
+		service := NewPermissionService(nil, "/tmp", true, []string{"ls"}, rules)
+		service.SetSessionMode("session", SessionModeDeny)
+		for _, tool := range []string{"ls", "view", "bash"} {
+			granted, err := service.Request(t.Context(), request(tool))
+			require.False(t, granted, tool)
+			require.ErrorIs(t, err, ErrorPermissionDenied)
+		}
+
+		// Other sessions are not affected.
+		granted, err := service.Request(t.Context(), CreatePermissionRequest{SessionID: "other", ToolName: "bash"})
+		require.NoError(t, err)
+		require.True(t, granted)
+	})
+
+	t.Run("forgotten with the session", func(t *testing.T) {
+		service := NewPermissionService(nil, "/tmp", false, nil, nil)
+		service.SetSessionMode("session", SessionModeDeny)
+		service.ForgetSession("session")
+		require.Equal(t, SessionModePrompt, service.SessionMode("session"))
+	})
+}
+
 func TestPermissionService_SequentialProperties(t *testing.T) {
 	t.Run("Sequential permission requests with persistent grants", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService(nil, "/tmp", false, []string{}, nil)
 
 		req1 := CreatePermissionRequest{
 			SessionID:   "session1",
@@ -144,7 +211,7 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.True(t, result2, "Second request should be auto-approved")
 	})
 	t.Run("Sequential requests with temporary grants", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService(nil, "/tmp", false, []string{}, nil)
 
 		req := CreatePermissionRequest{
 			SessionID:   "session2",
@@ -184,7 +251,7 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.False(t, result2, "Second request should be denied")
 	})
 	t.Run("Concurrent requests with different outcomes", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService(nil, "/tmp", false, []string{}, nil)
 
 		events := service.Subscribe(t.Context())
 
@@ -251,3 +318,159 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.True(t, result, "Repeated request should be auto-approved due to persistent permission")
 	})
 }
+
+func TestPermissionService_PersistentGrants(t *testing.T) {
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	sess, err := session.NewService(q, conn).Create(t.Context(), "Test Session")
+	require.NoError(t, err)
+
+	req := CreatePermissionRequest{
+		SessionID: sess.ID,
+		ToolName:  "bash",
+		Action:    "execute",
+		Params:    map[string]string{"command": "make"},
+		Path:      "/tmp",
+	}
+
+	service := NewPermissionService(q, "/tmp", false, nil, nil)
+	events := service.Subscribe(t.Context())
+	go func() {
+		event := <-events
+		service.GrantPersistent(event.Payload)
+	}()
+	granted, err := service.Request(t.Context(), req)
+	require.NoError(t, err)
+	require.True(t, granted)
+
+	// A new service, as after a restart, picks the grant up from the
+	// database and does not prompt again.
+	restarted := NewPermissionService(q, "/tmp", false, nil, nil)
+	granted, err = restarted.Request(t.Context(), req)
+	require.NoError(t, err)
+	require.True(t, granted)
+
+	grants, err := restarted.ListSessionGrants(t.Context(), sess.ID)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	require.Equal(t, "bash", grants[0].ToolName)
+	require.Equal(t, "execute", grants[0].Action)
+	require.Equal(t, "/tmp", grants[0].Path)
+
+	require.NoError(t, restarted.RevokeSessionGrant(t.Context(), sess.ID, grants[0].ID))
+
+	grants, err = restarted.ListSessionGrants(t.Context(), sess.ID)
+	require.NoError(t, err)
+	require.Empty(t, grants)
+
+	dbGrants, err := q.ListPermissionGrantsBySession(t.Context(), sess.ID)
+	require.NoError(t, err)
+	require.Empty(t, dbGrants)
+
+	// The service that granted it, as another process sharing the database
+	// would, asks again once the grant is revoked.
+	go func() {
+		event := <-events
+		service.Deny(event.Payload)
+	}()
+	granted, err = service.Request(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, granted)
+}
+
+func TestPermissionService_RevokeSessionGrant(t *testing.T) {
+	service := NewPermissionService(nil, "/tmp", false, nil, nil)
+	req := CreatePermissionRequest{
+		SessionID: "session",
+		ToolName:  "bash",
+		Action:    "execute",
+		Params:    map[string]string{"command": "make"},
+		Path:      "/tmp",
+	}
+
+	events := service.Subscribe(t.Context())
+	go func() {
+		event := <-events
+		service.GrantPersistent(event.Payload)
+	}()
+	granted, err := service.Request(t.Context(), req)
+	require.NoError(t, err)
+	require.True(t, granted)
+
+	grants, err := service.ListSessionGrants(t.Context(), "session")
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	require.NoError(t, service.RevokeSessionGrant(t.Context(), "session", grants[0].ID))
+
+	go func() {
+		event := <-events
+		service.Deny(event.Payload)
+	}()
+	granted, err = service.Request(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, granted)
+}
+
+func TestPermissionService_Audit(t *testing.T) {
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+
+	service := NewPermissionService(q, "/tmp", false, []string{"view"}, []Rule{
+		{Action: RuleDeny, Tool: "bash", Command: "rm"},
+	})
+
+	granted, err := service.Request(t.Context(), CreatePermissionRequest{
+		SessionID: "session1",
+		ToolName:  "view",
+		Action:    "read",
+		Path:      "/tmp/file.txt",
+	})
+	require.NoError(t, err)
+	require.True(t, granted)
+
+	_, err = service.Request(t.Context(), CreatePermissionRequest{
+		SessionID: "session2",
+		ToolName:  "bash",
+		Action:    "execute",
+		Params:    map[string]string{"command": "rm -rf /tmp/foo"},
+	})
+	require.ErrorIs(t, err, ErrorPermissionDenied)
+
+	events := service.Subscribe(t.Context())
+	go func() {
+		event := <-events
+		service.Deny(event.Payload)
+	}()
+	granted, err = service.Request(t.Context(), CreatePermissionRequest{
+		SessionID: "session2",
+		ToolName:  "write",
+		Action:    "write",
+		Path:      "/tmp/file.txt",
+	})
+	require.NoError(t, err)
+	require.False(t, granted)
+
+	entries, err := ListAudit(t.Context(), q, "")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	require.True(t, entries[0].Granted)
+	require.Equal(t, SourceAllowlist, entries[0].Source)
+
+	require.False(t, entries[1].Granted)
+	require.Equal(t, SourceRule, entries[1].Source)
+	require.Equal(t, `deny tool="bash" command="rm"`, entries[1].MatchedRule)
+	require.JSONEq(t, `{"command":"rm -rf /tmp/foo"}`, string(entries[1].Params))
+
+	require.False(t, entries[2].Granted)
+	require.Equal(t, SourceUser, entries[2].Source)
+
+	entries, err = ListAudit(t.Context(), q, "session2")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
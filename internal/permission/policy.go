@@ -0,0 +1,292 @@
+package permission
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// RuleAction is what a policy rule does with the requests it matches.
+type RuleAction string
+
+const (
+	// RuleAllow grants matching requests without prompting.
+	RuleAllow RuleAction = "allow"
+	// RuleAsk always prompts for matching requests, even if an allow rule or
+	// the allowed tools list would grant them.
+	RuleAsk RuleAction = "ask"
+	// RuleDeny rejects matching requests. Deny rules take precedence over
+	// everything else, including auto-approved sessions and yolo mode.
+	RuleDeny RuleAction = "deny"
+)
+
+// Location restricts a rule to paths inside or outside the working directory.
+type Location string
+
+const (
+	LocationInside  Location = "inside"
+	LocationOutside Location = "outside"
+)
+
+// Rule is a single entry of the permission policy. Every non-empty matcher
+// must match for the rule to apply.
+type Rule struct {
+	Action   RuleAction `json:"action" jsonschema:"description=What to do with matching requests,enum=allow,enum=ask,enum=deny"`
+	Tool     string     `json:"tool,omitempty" jsonschema:"description=Tool name or glob to match,example=bash,example=mcp_*"`
+	Command  string     `json:"command,omitempty" jsonschema:"description=Bash command prefix or glob to match,example=git status,example=rm -rf *"`
+	Path     string     `json:"path,omitempty" jsonschema:"description=File path glob to match; relative globs are resolved against the working directory,example=src/**,example=**/*.env"`
+	Location Location   `json:"location,omitempty" jsonschema:"description=Only match paths inside or outside the working directory,enum=inside,enum=outside"`
+	Host     string     `json:"host,omitempty" jsonschema:"description=URL host or glob to match for fetch and download tools,example=github.com,example=*.example.com"`
+}
+
+// String returns a compact description of the rule, used to record which rule
+// decided a request.
+func (r Rule) String() string {
+	var b strings.Builder
+	b.WriteString(string(r.Action))
+	for _, m := range []struct{ key, value string }{
+		{"tool", r.Tool},
+		{"command", r.Command},
+		{"path", r.Path},
+		{"location", string(r.Location)},
+		{"host", r.Host},
+	} {
+		if m.value != "" {
+			fmt.Fprintf(&b, " %s=%q", m.key, m.value)
+		}
+	}
+	return b.String()
+}
+
+// RuleDeniedError is returned by [Service.Request] when a deny rule matches
+// the request.
+type RuleDeniedError struct {
+	Rule Rule
+}
+
+func (e *RuleDeniedError) Error() string {
+	return "permission denied by rule: " + e.Rule.String()
+}
+
+func (e *RuleDeniedError) Unwrap() error {
+	return ErrorPermissionDenied
+}
+
+// requestTarget holds the parameters rules can match on. They are extracted
+// from the request params by their JSON names, which all tools share.
+type requestTarget struct {
+	Command  string `json:"command"`
+	FilePath string `json:"file_path"`
+	Path     string `json:"path"`
+	URL      string `json:"url"`
+}
+
+// policy evaluates permission requests against an ordered list of rules.
+type policy struct {
+	workingDir string
+	rules      []Rule
+}
+
+// evaluate returns the rule that decides the request, if any. Deny rules win
+// over ask rules, which win over allow rules; within the same action the
+// first matching rule is returned.
+func (p policy) evaluate(opts CreatePermissionRequest) (Rule, bool) {
+	if len(p.rules) == 0 {
+		return Rule{}, false
+	}
+	target := p.target(opts)
+
+	var ask, allow *Rule
+	for i := range p.rules {
+		rule := &p.rules[i]
+		if !p.matches(*rule, opts.ToolName, target) {
+			continue
+		}
+		switch rule.Action {
+		case RuleDeny:
+			return *rule, true
+		case RuleAsk:
+			if ask == nil {
+				ask = rule
+			}
+		case RuleAllow:
+			if allow == nil {
+				allow = rule
+			}
+		}
+	}
+	switch {
+	case ask != nil:
+		return *ask, true
+	case allow != nil:
+		return *allow, true
+	}
+	return Rule{}, false
+}
+
+func (p policy) target(opts CreatePermissionRequest) requestTarget {
+	var target requestTarget
+	if opts.Params != nil {
+		if bts, err := json.Marshal(opts.Params); err == nil {
+			_ = json.Unmarshal(bts, &target)
+		}
+	}
+	path := target.FilePath
+	if path == "" {
+		path = target.Path
+	}
+	if path == "" {
+		path = opts.Path
+	}
+	if path != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(p.workingDir, path)
+	}
+	target.Path = filepath.Clean(path)
+	return target
+}
+
+func (p policy) matches(rule Rule, toolName string, target requestTarget) bool {
+	if rule.Tool != "" && !globMatch(rule.Tool, toolName) {
+		return false
+	}
+	if rule.Command != "" && !matchCommand(rule.Action, rule.Command, target.Command) {
+		return false
+	}
+	if rule.Path != "" && !p.matchPath(rule.Path, target.Path) {
+		return false
+	}
+	if rule.Location != "" && !p.matchLocation(rule.Location, target.Path) {
+		return false
+	}
+	if rule.Host != "" && !matchHost(rule.Host, target.URL) {
+		return false
+	}
+	return true
+}
+
+// matchCommand matches a bash command against a rule pattern. The command is
+// parsed into the simple commands it runs, named after the program they run
+// whatever its path: allow rules must match every one of them and never match
+// commands that substitute commands or write files through redirections,
+// while ask and deny rules match if the whole command or any of its simple
+// commands does, so neither chaining, wrapping nor nesting commands can be
+// used to sneak past a rule.
+func matchCommand(action RuleAction, pattern, command string) bool {
+	line, ok := parseCommandLine(command)
+	if !ok {
+		// The bash tool fails to run what does not parse, but deny rules
+		// still get a say.
+		line = commandLine{commands: commandParts(command), dynamic: true}
+	}
+	if len(line.commands) == 0 {
+		return false
+	}
+	pattern = resolvePattern(pattern)
+	match := func(part string) bool {
+		return matchCommandPart(pattern, part)
+	}
+	if action == RuleAllow {
+		return !line.dynamic && !slices.ContainsFunc(line.commands, func(part string) bool {
+			return !match(part)
+		})
+	}
+	return match(strings.TrimSpace(command)) || slices.ContainsFunc(line.commands, match)
+}
+
+// resolvePattern names the program of a command pattern without its path, as
+// the commands it is matched against are.
+func resolvePattern(pattern string) string {
+	name, args, _ := strings.Cut(pattern, " ")
+	if !strings.Contains(name, "/") {
+		return pattern
+	}
+	return strings.TrimSpace(path.Base(name) + " " + args)
+}
+
+// matchCommandPart matches a single command against a glob if the pattern
+// has wildcards, or as a whole-word prefix otherwise. Unlike path globs, *
+// matches any character, including slashes.
+func matchCommandPart(pattern, command string) bool {
+	if !strings.ContainsAny(pattern, "*?") {
+		return command == pattern || strings.HasPrefix(command, pattern+" ")
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	return err == nil && re.MatchString(command)
+}
+
+// commandParts splits a command line on the shell control operators, for
+// the commands that do not parse.
+func commandParts(command string) []string {
+	fields := strings.FieldsFunc(command, func(r rune) bool {
+		return r == '&' || r == '|' || r == ';' || r == '\n'
+	})
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			parts = append(parts, f)
+		}
+	}
+	return parts
+}
+
+func (p policy) matchPath(pattern, path string) bool {
+	if path == "" || path == "." {
+		return false
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(p.workingDir, pattern)
+	}
+	ok, _ := doublestar.PathMatch(pattern, path)
+	return ok
+}
+
+func (p policy) matchLocation(location Location, path string) bool {
+	if path == "" || path == "." {
+		return false
+	}
+	rel, err := filepath.Rel(p.workingDir, path)
+	inside := err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	switch location {
+	case LocationInside:
+		return inside
+	case LocationOutside:
+		return !inside
+	}
+	return false
+}
+
+func matchHost(pattern, rawURL string) bool {
+	if rawURL == "" {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	return globMatch(strings.ToLower(pattern), strings.ToLower(u.Hostname()))
+}
+
+func globMatch(pattern, s string) bool {
+	ok, _ := filepath.Match(pattern, s)
+	return ok
+}
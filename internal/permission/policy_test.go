@@ -0,0 +1,477 @@
+package permission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Evaluate(t *testing.T) {
+	type bashParams struct {
+		Command string `json:"command"`
+	}
+	type fileParams struct {
+		FilePath string `json:"file_path"`
+	}
+	type fetchParams struct {
+		URL string `json:"url"`
+	}
+
+	tests := []struct {
+		name    string
+		rules   []Rule
+		request CreatePermissionRequest
+		matched bool
+		action  RuleAction
+	}{
+		{
+			name:    "no rules",
+			request: CreatePermissionRequest{ToolName: "bash"},
+		},
+		{
+			name:    "tool name",
+			rules:   []Rule{{Action: RuleAllow, Tool: "view"}},
+			request: CreatePermissionRequest{ToolName: "view"},
+			matched: true,
+			action:  RuleAllow,
+		},
+		{
+			name:    "tool glob",
+			rules:   []Rule{{Action: RuleDeny, Tool: "mcp_*"}},
+			request: CreatePermissionRequest{ToolName: "mcp_github_create_issue"},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:    "other tool",
+			rules:   []Rule{{Action: RuleAllow, Tool: "view"}},
+			request: CreatePermissionRequest{ToolName: "bash"},
+		},
+		{
+			name:  "bash prefix",
+			rules: []Rule{{Action: RuleAllow, Tool: "bash", Command: "git status"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "git status --short"},
+			},
+			matched: true,
+			action:  RuleAllow,
+		},
+		{
+			name:  "bash prefix is word based",
+			rules: []Rule{{Action: RuleAllow, Tool: "bash", Command: "git status"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "git statusx"},
+			},
+		},
+		{
+			name:  "bash allow does not cover chained commands",
+			rules: []Rule{{Action: RuleAllow, Tool: "bash", Command: "git status"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "git status && rm -rf /"},
+			},
+		},
+		{
+			name:  "bash deny matches chained commands",
+			rules: []Rule{{Action: RuleDeny, Tool: "bash", Command: "rm -rf *"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "git status && rm -rf /"},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "bash allow covers quoted arguments",
+			rules: []Rule{{Action: RuleAllow, Tool: "bash", Command: "git commit*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: `git commit -m "fix: the build"`},
+			},
+			matched: true,
+			action:  RuleAllow,
+		},
+		{
+			name:  "bash allow does not cover command substitutions",
+			rules: []Rule{{Action: RuleAllow, Tool: "bash", Command: "git status*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "git status $(rm -rf ~)"},
+			},
+		},
+		{
+			name:  "bash allow does not cover backticks",
+			rules: []Rule{{Action: RuleAllow, Tool: "bash", Command: "git status*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "git status `rm -rf ~`"},
+			},
+		},
+		{
+			name:  "bash allow does not cover process substitutions",
+			rules: []Rule{{Action: RuleAllow, Tool: "bash", Command: "diff*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "diff <(rm -rf ~) file"},
+			},
+		},
+		{
+			name:  "bash allow does not cover output redirects",
+			rules: []Rule{{Action: RuleAllow, Tool: "bash", Command: "git status*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "git status > ~/.bashrc"},
+			},
+		},
+		{
+			name:  "bash allow covers redirects that write no file",
+			rules: []Rule{{Action: RuleAllow, Tool: "bash", Command: "git status*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "git status 2>&1 >/dev/null"},
+			},
+			matched: true,
+			action:  RuleAllow,
+		},
+		{
+			name:  "bash allow does not cover other paths",
+			rules: []Rule{{Action: RuleAllow, Tool: "bash", Command: "make*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "./make install"},
+			},
+		},
+		{
+			name:  "bash allow does not cover wrapped commands",
+			rules: []Rule{{Action: RuleAllow, Tool: "bash", Command: "rm*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "sudo rm -rf /"},
+			},
+		},
+		{
+			name:  "bash allow does not cover shell scripts",
+			rules: []Rule{{Action: RuleAllow, Tool: "bash", Command: "ls*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "bash -c 'ls; rm -rf ~'"},
+			},
+		},
+		{
+			name:  "bash deny matches env prefixes",
+			rules: []Rule{{Action: RuleDeny, Tool: "bash", Command: "rm*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "FOO=1 rm -rf /"},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "bash deny matches env",
+			rules: []Rule{{Action: RuleDeny, Tool: "bash", Command: "rm*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "env -i FOO=1 rm -rf /"},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "bash deny matches absolute paths",
+			rules: []Rule{{Action: RuleDeny, Tool: "bash", Command: "rm*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "/bin/rm -rf /"},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "bash deny matches patterns with paths",
+			rules: []Rule{{Action: RuleDeny, Tool: "bash", Command: "/usr/bin/rm*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "rm -rf /"},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "bash deny matches shell scripts",
+			rules: []Rule{{Action: RuleDeny, Tool: "bash", Command: "rm*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "bash -c 'cd / && rm -rf *'"},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "bash deny matches nested shell scripts",
+			rules: []Rule{{Action: RuleDeny, Tool: "bash", Command: "rm*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: `sh -ec "bash -c 'rm -rf /'"`},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "bash deny matches eval",
+			rules: []Rule{{Action: RuleDeny, Tool: "bash", Command: "rm*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "eval 'rm -rf /'"},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "bash deny matches command substitutions",
+			rules: []Rule{{Action: RuleDeny, Tool: "bash", Command: "rm*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "echo $(rm -rf /)"},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "bash deny matches escaped names",
+			rules: []Rule{{Action: RuleDeny, Tool: "bash", Command: "rm*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: `r\m -rf /`},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "bash glob spans slashes",
+			rules: []Rule{{Action: RuleAsk, Tool: "bash", Command: "curl *example.com/*"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "curl https://example.com/install.sh"},
+			},
+			matched: true,
+			action:  RuleAsk,
+		},
+		{
+			name:  "deny beats allow regardless of order",
+			rules: []Rule{{Action: RuleAllow, Tool: "bash"}, {Action: RuleDeny, Tool: "bash", Command: "git push"}},
+			request: CreatePermissionRequest{
+				ToolName: "bash",
+				Params:   bashParams{Command: "git push --force"},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "ask beats allow",
+			rules: []Rule{{Action: RuleAllow, Tool: "edit"}, {Action: RuleAsk, Path: "**/*.go"}},
+			request: CreatePermissionRequest{
+				ToolName: "edit",
+				Params:   fileParams{FilePath: "/project/main.go"},
+			},
+			matched: true,
+			action:  RuleAsk,
+		},
+		{
+			name:  "relative path glob",
+			rules: []Rule{{Action: RuleAllow, Tool: "edit", Path: "src/**"}},
+			request: CreatePermissionRequest{
+				ToolName: "edit",
+				Params:   fileParams{FilePath: "/project/src/pkg/file.go"},
+			},
+			matched: true,
+			action:  RuleAllow,
+		},
+		{
+			name:  "relative path glob outside",
+			rules: []Rule{{Action: RuleAllow, Tool: "edit", Path: "src/**"}},
+			request: CreatePermissionRequest{
+				ToolName: "edit",
+				Params:   fileParams{FilePath: "/project/docs/readme.md"},
+			},
+		},
+		{
+			name:  "relative request path",
+			rules: []Rule{{Action: RuleDeny, Path: "**/.env"}},
+			request: CreatePermissionRequest{
+				ToolName: "view",
+				Params:   fileParams{FilePath: "config/.env"},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "outside working dir",
+			rules: []Rule{{Action: RuleDeny, Tool: "write", Location: LocationOutside}},
+			request: CreatePermissionRequest{
+				ToolName: "write",
+				Params:   fileParams{FilePath: "/etc/hosts"},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "inside working dir is not outside",
+			rules: []Rule{{Action: RuleDeny, Tool: "write", Location: LocationOutside}},
+			request: CreatePermissionRequest{
+				ToolName: "write",
+				Params:   fileParams{FilePath: "/project/main.go"},
+			},
+		},
+		{
+			name:  "sibling directory is outside",
+			rules: []Rule{{Action: RuleAsk, Location: LocationOutside}},
+			request: CreatePermissionRequest{
+				ToolName: "ls",
+				Path:     "/project-other",
+			},
+			matched: true,
+			action:  RuleAsk,
+		},
+		{
+			name:  "inside working dir",
+			rules: []Rule{{Action: RuleAllow, Tool: "ls", Location: LocationInside}},
+			request: CreatePermissionRequest{
+				ToolName: "ls",
+				Path:     "/project/internal",
+			},
+			matched: true,
+			action:  RuleAllow,
+		},
+		{
+			name:  "url host",
+			rules: []Rule{{Action: RuleAllow, Host: "github.com"}},
+			request: CreatePermissionRequest{
+				ToolName: "fetch",
+				Params:   fetchParams{URL: "https://github.com/charmbracelet/crush"},
+			},
+			matched: true,
+			action:  RuleAllow,
+		},
+		{
+			name:  "url host glob",
+			rules: []Rule{{Action: RuleDeny, Host: "*.example.com"}},
+			request: CreatePermissionRequest{
+				ToolName: "download",
+				Params:   fetchParams{URL: "https://files.Example.com/archive.zip"},
+			},
+			matched: true,
+			action:  RuleDeny,
+		},
+		{
+			name:  "other url host",
+			rules: []Rule{{Action: RuleAllow, Host: "github.com"}},
+			request: CreatePermissionRequest{
+				ToolName: "fetch",
+				Params:   fetchParams{URL: "https://evil.com/github.com"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := policy{workingDir: "/project", rules: tt.rules}
+			rule, matched := p.evaluate(tt.request)
+			require.Equal(t, tt.matched, matched)
+			if tt.matched {
+				require.Equal(t, tt.action, rule.Action)
+			}
+		})
+	}
+}
+
+func TestPermissionService_Rules(t *testing.T) {
+	rules := []Rule{
+		{Action: RuleAllow, Tool: "view"},
+		{Action: RuleDeny, Tool: "bash", Command: "rm"},
+	}
+
+	t.Run("deny beats skip mode", func(t *testing.T) {
+		service := NewPermissionService(nil, "/tmp", true, nil, rules)
+		granted, err := service.Request(t.Context(), CreatePermissionRequest{
+			SessionID: "session",
+			ToolName:  "bash",
+			Action:    "execute",
+			Params:    map[string]string{"command": "rm -rf /tmp/foo"},
+		})
+		require.False(t, granted)
+		require.ErrorIs(t, err, ErrorPermissionDenied)
+
+		var deniedErr *RuleDeniedError
+		require.ErrorAs(t, err, &deniedErr)
+		require.Equal(t, rules[1], deniedErr.Rule)
+	})
+
+	t.Run("deny beats auto approved sessions", func(t *testing.T) {
+		service := NewPermissionService(nil, "/tmp", false, nil, rules)
+		service.AutoApproveSession("session")
+		granted, err := service.Request(t.Context(), CreatePermissionRequest{
+			SessionID: "session",
+			ToolName:  "bash",
+			Action:    "execute",
+			Params:    map[string]string{"command": "rm file"},
+		})
+		require.False(t, granted)
+		require.ErrorIs(t, err, ErrorPermissionDenied)
+	})
+
+	t.Run("deny beats allowed tools", func(t *testing.T) {
+		service := NewPermissionService(nil, "/tmp", false, []string{"bash"}, rules)
+		err := service.CheckRules(CreatePermissionRequest{
+			ToolName: "bash",
+			Params:   map[string]string{"command": "rm file"},
+		})
+		require.ErrorIs(t, err, ErrorPermissionDenied)
+	})
+
+	t.Run("allow rule grants without prompting", func(t *testing.T) {
+		service := NewPermissionService(nil, "/tmp", false, nil, rules)
+		notifications := service.SubscribeNotifications(t.Context())
+
+		granted, err := service.Request(t.Context(), CreatePermissionRequest{
+			SessionID:  "session",
+			ToolCallID: "call",
+			ToolName:   "view",
+			Action:     "read",
+			Path:       "/tmp/file.txt",
+		})
+		require.NoError(t, err)
+		require.True(t, granted)
+
+		<-notifications // the request itself
+		event := <-notifications
+		require.True(t, event.Payload.Granted)
+		require.Equal(t, rules[0].String(), event.Payload.MatchedRule)
+	})
+
+	t.Run("ask rule overrides allowed tools", func(t *testing.T) {
+		service := NewPermissionService(nil, "/tmp", false, []string{"edit"}, []Rule{
+			{Action: RuleAsk, Tool: "edit", Path: "**/*.go"},
+		})
+		events := service.Subscribe(t.Context())
+
+		result := make(chan bool)
+		go func() {
+			granted, _ := service.Request(t.Context(), CreatePermissionRequest{
+				SessionID: "session",
+				ToolName:  "edit",
+				Action:    "write",
+				Params:    map[string]string{"file_path": "/tmp/main.go"},
+				Path:      "/tmp",
+			})
+			result <- granted
+		}()
+
+		event := <-events
+		require.Equal(t, `ask tool="edit" path="**/*.go"`, event.Payload.MatchedRule)
+		service.Deny(event.Payload)
+		require.False(t, <-result)
+	})
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// Names of the Server-Sent Events sent on /v1/events.
+const (
+	eventSession                = "session"
+	eventMessage                = "message"
+	eventPermissionRequest      = "permission_request"
+	eventPermissionNotification = "permission_notification"
+	eventRun                    = "run"
+)
+
+// events streams service events to the client as Server-Sent Events. The
+// data of each event is a JSON object with the event type (created, updated
+// or deleted) and its payload. If the session_id query parameter is set, only
+// the events of that session are sent.
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	ctx := r.Context()
+	sessionID := r.URL.Query().Get("session_id")
+	matches := func(id string) bool {
+		return sessionID == "" || id == sessionID
+	}
+
+	sessions := s.app.Sessions.Subscribe(ctx)
+	messages := s.app.Messages.Subscribe(ctx)
+	requests := s.requests.Subscribe(ctx)
+	notifications := s.app.Permissions.SubscribeNotifications(ctx)
+	runs := s.runs.Subscribe(ctx)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var err error
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sessions:
+			if !ok {
+				return
+			}
+			if matches(event.Payload.ID) {
+				err = writeEvent(w, eventSession, event)
+			}
+		case event, ok := <-messages:
+			if !ok {
+				return
+			}
+			if matches(event.Payload.SessionID) {
+				err = writeEvent(w, eventMessage, event)
+			}
+		case event, ok := <-requests:
+			if !ok {
+				return
+			}
+			if matches(event.Payload.SessionID) {
+				err = writeEvent(w, eventPermissionRequest, event)
+			}
+		case event, ok := <-notifications:
+			if !ok {
+				return
+			}
+			// Notifications do not carry the session, so they are sent to
+			// every client. They can be matched to a request by tool call ID.
+			err = writeEvent(w, eventPermissionNotification, event)
+		case event, ok := <-runs:
+			if !ok {
+				return
+			}
+			if matches(event.Payload.SessionID) {
+				err = writeEvent(w, eventRun, event)
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent[T any](w http.ResponseWriter, name string, event pubsub.Event[T]) error {
+	data, err := json.Marshal(struct {
+		Type    pubsub.EventType `json:"type"`
+		Payload T                `json:"payload"`
+	}{event.Type, event.Payload})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
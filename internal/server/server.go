@@ -0,0 +1,377 @@
+// Package server exposes the application services over a local HTTP API, so
+// editors and scripts can drive Crush without the TUI.
+//
+// Requests and responses are JSON. Events from the session, message and
+// permission services are streamed with Server-Sent Events on /v1/events.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/agent"
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// Decision is the answer to a permission request.
+type Decision string
+
+const (
+	// DecisionAllow allows the request once.
+	DecisionAllow Decision = "allow"
+	// DecisionAllowSession allows the request for the rest of the session.
+	DecisionAllowSession Decision = "allow_session"
+	// DecisionDeny denies the request.
+	DecisionDeny Decision = "deny"
+)
+
+// RunEvent is published when an agent run started through the API finishes.
+type RunEvent struct {
+	SessionID string `json:"session_id"`
+	Error     string `json:"error,omitempty"`
+	Cancelled bool   `json:"cancelled,omitempty"`
+}
+
+// Server serves the HTTP API for an [app.App].
+type Server struct {
+	app   *app.App
+	token string
+	mux   *http.ServeMux
+	runs  *pubsub.Broker[RunEvent]
+
+	// pending holds the permission requests waiting for an answer, by ID.
+	pending *csync.Map[string, permission.PermissionRequest]
+	// requests republishes the permission requests once they are pending,
+	// so clients never see a request they can't answer yet.
+	requests *pubsub.Broker[permission.PermissionRequest]
+
+	// ctx is the lifetime of the server, which agent runs are bound to
+	// instead of the request that started them.
+	ctx context.Context
+}
+
+// New creates a server for the given app. If token is not empty, clients
+// must send it as a bearer token.
+func New(app *app.App, token string) *Server {
+	s := &Server{
+		app:      app,
+		token:    token,
+		mux:      http.NewServeMux(),
+		runs:     pubsub.NewBroker[RunEvent](),
+		pending:  csync.NewMap[string, permission.PermissionRequest](),
+		requests: pubsub.NewBroker[permission.PermissionRequest](),
+		ctx:      context.Background(),
+	}
+
+	s.mux.HandleFunc("GET /v1/sessions", s.listSessions)
+	s.mux.HandleFunc("POST /v1/sessions", s.createSession)
+	s.mux.HandleFunc("GET /v1/sessions/{id}", s.getSession)
+	s.mux.HandleFunc("DELETE /v1/sessions/{id}", s.deleteSession)
+	s.mux.HandleFunc("GET /v1/sessions/{id}/messages", s.listMessages)
+	s.mux.HandleFunc("POST /v1/sessions/{id}/prompt", s.prompt)
+	s.mux.HandleFunc("POST /v1/sessions/{id}/cancel", s.cancel)
+	s.mux.HandleFunc("GET /v1/permissions", s.listPermissions)
+	s.mux.HandleFunc("POST /v1/permissions/{id}", s.answerPermission)
+	s.mux.HandleFunc("GET /v1/events", s.events)
+	return s
+}
+
+// Serve accepts connections on ln until ctx is done.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	s.ctx = ctx
+	// Subscribe before serving, so no request is missed.
+	requests := s.app.Permissions.Subscribe(ctx)
+	notifications := s.app.Permissions.SubscribeNotifications(ctx)
+	go s.trackPermissions(ctx, requests, notifications)
+
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP implements [http.Handler].
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := CheckLocal(r); err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	if s.token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// CheckLocal rejects requests that may come from a web page rather than a
+// local client: the Host must be a loopback address, so DNS rebinding can't
+// reach the server, and so must the Origin, if any.
+func CheckLocal(r *http.Request) error {
+	if !isLoopback(r.Host) {
+		return fmt.Errorf("host %q is not allowed", r.Host)
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !isLoopback(u.Host) {
+			return fmt.Errorf("origin %q is not allowed", origin)
+		}
+	}
+	return nil
+}
+
+func isLoopback(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// trackPermissions keeps track of the permission requests waiting for an
+// answer, so clients can list them and answer by ID.
+func (s *Server) trackPermissions(
+	ctx context.Context,
+	requests <-chan pubsub.Event[permission.PermissionRequest],
+	notifications <-chan pubsub.Event[permission.PermissionNotification],
+) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-requests:
+			if !ok {
+				return
+			}
+			s.pending.Set(event.Payload.ID, event.Payload)
+			s.requests.Publish(event.Type, event.Payload)
+		case event, ok := <-notifications:
+			if !ok {
+				return
+			}
+			n := event.Payload
+			if !n.Granted && !n.Denied && !n.Canceled {
+				continue
+			}
+			for id, req := range s.pending.Seq2() {
+				if req.ToolCallID == n.ToolCallID {
+					s.pending.Del(id)
+				}
+			}
+		}
+	}
+}
+
+func (s *Server) listSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := s.app.Sessions.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (s *Server) createSession(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Title string `json:"title"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if body.Title == "" {
+		body.Title = "New Session"
+	}
+
+	sess, err := s.app.Sessions.Create(r.Context(), body.Title)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sess)
+}
+
+func (s *Server) getSession(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.app.Sessions.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sess)
+}
+
+func (s *Server) deleteSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if s.app.AgentCoordinator != nil && s.app.AgentCoordinator.IsSessionBusy(id) {
+		writeError(w, http.StatusConflict, errors.New("session is busy"))
+		return
+	}
+	if err := s.app.Sessions.Delete(r.Context(), id); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listMessages(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	msgs, err := s.app.Messages.List(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, msgs)
+}
+
+// prompt starts an agent run and returns right away. Progress is reported
+// through the message events, and a run event is sent once it finishes.
+func (s *Server) prompt(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Prompt string `json:"prompt"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if strings.TrimSpace(body.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("prompt is required"))
+		return
+	}
+	if s.app.AgentCoordinator == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("no providers configured"))
+		return
+	}
+
+	id := r.PathValue("id")
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+
+	go func() {
+		_, err := s.app.AgentCoordinator.Run(s.ctx, id, body.Prompt)
+		event := RunEvent{SessionID: id}
+		switch {
+		case errors.Is(err, context.Canceled) || errors.Is(err, agent.ErrRequestCancelled):
+			event.Cancelled = true
+		case err != nil:
+			slog.Error("Agent run failed", "session_id", id, "error", err)
+			event.Error = err.Error()
+		}
+		s.runs.Publish(pubsub.UpdatedEvent, event)
+	}()
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"session_id": id})
+}
+
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request) {
+	if s.app.AgentCoordinator != nil {
+		s.app.AgentCoordinator.Cancel(r.PathValue("id"))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listPermissions(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	requests := []permission.PermissionRequest{}
+	for _, req := range s.pending.Seq2() {
+		if sessionID == "" || req.SessionID == sessionID {
+			requests = append(requests, req)
+		}
+	}
+	writeJSON(w, http.StatusOK, requests)
+}
+
+func (s *Server) answerPermission(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Decision Decision `json:"decision"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	id := r.PathValue("id")
+	req, ok := s.pending.Take(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no pending permission request %s", id))
+		return
+	}
+
+	switch body.Decision {
+	case DecisionAllow:
+		s.app.Permissions.Grant(req)
+	case DecisionAllowSession:
+		s.app.Permissions.GrantPersistent(req)
+	case DecisionDeny:
+		s.app.Permissions.Deny(req)
+	default:
+		s.pending.Set(id, req)
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid decision %q", body.Decision))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func statusFor(err error) int {
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.ContentLength == 0 {
+		return true
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
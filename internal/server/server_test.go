@@ -0,0 +1,228 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTest(t *testing.T, token string) (*app.App, string) {
+	t.Helper()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	a := &app.App{
+		Sessions:    session.NewService(q, conn),
+		Messages:    message.NewService(q),
+		Permissions: permission.NewPermissionService(q, t.TempDir(), false, nil, nil),
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go New(a, token).Serve(ctx, ln) //nolint:errcheck
+	return a, "http://" + ln.Addr().String()
+}
+
+func do(t *testing.T, method, url, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func TestSessions(t *testing.T) {
+	_, url := setupTest(t, "")
+
+	var created session.Session
+	status := do(t, http.MethodPost, url+"/v1/sessions", `{"title":"From the API"}`, &created)
+	require.Equal(t, http.StatusCreated, status)
+	require.Equal(t, "From the API", created.Title)
+
+	var sessions []session.Session
+	status = do(t, http.MethodGet, url+"/v1/sessions", "", &sessions)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, sessions, 1)
+	require.Equal(t, created.ID, sessions[0].ID)
+
+	var msgs []message.Message
+	status = do(t, http.MethodGet, url+"/v1/sessions/"+created.ID+"/messages", "", &msgs)
+	require.Equal(t, http.StatusOK, status)
+	require.Empty(t, msgs)
+
+	status = do(t, http.MethodGet, url+"/v1/sessions/nonexistent", "", nil)
+	require.Equal(t, http.StatusNotFound, status)
+
+	status = do(t, http.MethodDelete, url+"/v1/sessions/"+created.ID, "", nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status = do(t, http.MethodGet, url+"/v1/sessions/"+created.ID, "", nil)
+	require.Equal(t, http.StatusNotFound, status)
+}
+
+func TestPermissions(t *testing.T) {
+	a, url := setupTest(t, "")
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url+"/v1/events", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	result := make(chan bool)
+	go func() {
+		granted, _ := a.Permissions.Request(t.Context(), permission.CreatePermissionRequest{
+			SessionID:  "session",
+			ToolCallID: "call",
+			ToolName:   "bash",
+			Action:     "execute",
+			Path:       "/tmp",
+		})
+		result <- granted
+	}()
+
+	request := readPermissionRequest(t, resp.Body)
+	require.Equal(t, "call", request.ToolCallID)
+
+	var pending []permission.PermissionRequest
+	status := do(t, http.MethodGet, url+"/v1/permissions", "", &pending)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, pending, 1)
+
+	status = do(t, http.MethodPost, url+"/v1/permissions/"+request.ID, `{"decision":"maybe"}`, nil)
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status = do(t, http.MethodPost, url+"/v1/permissions/"+request.ID, `{"decision":"allow"}`, nil)
+	require.Equal(t, http.StatusNoContent, status)
+	require.True(t, <-result)
+
+	status = do(t, http.MethodPost, url+"/v1/permissions/"+request.ID, `{"decision":"allow"}`, nil)
+	require.Equal(t, http.StatusNotFound, status)
+}
+
+func TestPermissionsCanceled(t *testing.T) {
+	a, url := setupTest(t, "")
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url+"/v1/events", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error)
+	go func() {
+		_, err := a.Permissions.Request(ctx, permission.CreatePermissionRequest{
+			SessionID:  "session",
+			ToolCallID: "call",
+			ToolName:   "bash",
+			Action:     "execute",
+			Path:       "/tmp",
+		})
+		done <- err
+	}()
+
+	request := readPermissionRequest(t, resp.Body)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	require.Eventually(t, func() bool {
+		var pending []permission.PermissionRequest
+		do(t, http.MethodGet, url+"/v1/permissions", "", &pending)
+		return len(pending) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	status := do(t, http.MethodPost, url+"/v1/permissions/"+request.ID, `{"decision":"allow"}`, nil)
+	require.Equal(t, http.StatusNotFound, status)
+}
+
+// readPermissionRequest waits for a permission request to be streamed.
+func readPermissionRequest(t *testing.T, r io.Reader) permission.PermissionRequest {
+	t.Helper()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if scanner.Text() != "event: permission_request" {
+			continue
+		}
+		require.True(t, scanner.Scan())
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		require.True(t, ok)
+		var event struct {
+			Payload permission.PermissionRequest `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(data), &event))
+		return event.Payload
+	}
+	t.Fatal("no permission request was streamed")
+	return permission.PermissionRequest{}
+}
+
+func TestToken(t *testing.T) {
+	_, url := setupTest(t, "secret")
+
+	status := do(t, http.MethodGet, url+"/v1/sessions", "", nil)
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url+"/v1/sessions", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestLocalOnly(t *testing.T) {
+	_, url := setupTest(t, "")
+
+	for _, tt := range []struct {
+		name   string
+		host   string
+		origin string
+		status int
+	}{
+		{name: "loopback", status: http.StatusOK},
+		{name: "localhost origin", origin: "http://localhost:3000", status: http.StatusOK},
+		{name: "remote host", host: "evil.example:7421", status: http.StatusForbidden},
+		{name: "remote origin", origin: "https://evil.example", status: http.StatusForbidden},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url+"/v1/sessions", nil)
+			require.NoError(t, err)
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/crush/internal/db"
 	"github.com/charmbracelet/crush/internal/event"
@@ -39,17 +41,20 @@ func HasIncompleteTodos(todos []Todo) bool {
 }
 
 type Session struct {
-	ID               string
-	ParentSessionID  string
-	Title            string
-	MessageCount     int64
-	PromptTokens     int64
-	CompletionTokens int64
-	SummaryMessageID string
-	Cost             float64
-	Todos            []Todo
-	CreatedAt        int64
-	UpdatedAt        int64
+	ID               string  `json:"id"`
+	ParentSessionID  string  `json:"parent_session_id,omitempty"`
+	Title            string  `json:"title"`
+	MessageCount     int64   `json:"message_count"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	SummaryMessageID string  `json:"summary_message_id,omitempty"`
+	Cost             float64 `json:"cost"`
+	Todos            []Todo  `json:"todos,omitempty"`
+	CreatedAt        int64   `json:"created_at"`
+	UpdatedAt        int64   `json:"updated_at"`
+	// ForkMessageID is the message of the parent session this session was
+	// forked from. It is only set for forks.
+	ForkMessageID string `json:"fork_message_id,omitempty"`
 }
 
 type Service interface {
@@ -57,12 +62,20 @@ type Service interface {
 	Create(ctx context.Context, title string) (Session, error)
 	CreateTitleSession(ctx context.Context, parentSessionID string) (Session, error)
 	CreateTaskSession(ctx context.Context, toolCallID, parentSessionID, title string) (Session, error)
+	Fork(ctx context.Context, sessionID, messageID string) (Session, error)
 	Get(ctx context.Context, id string) (Session, error)
 	List(ctx context.Context) ([]Session, error)
 	Save(ctx context.Context, session Session) (Session, error)
 	UpdateTitleAndUsage(ctx context.Context, sessionID, title string, promptTokens, completionTokens int64, cost float64) error
 	Delete(ctx context.Context, id string) error
 
+	// RecordCost records cost spent on a session, so that the spending of
+	// the project can be told over time.
+	RecordCost(ctx context.Context, sessionID string, cost float64) error
+	// CostSince returns the cost spent on all the sessions of the project
+	// since t, including the sessions deleted since.
+	CostSince(ctx context.Context, t time.Time) (float64, error)
+
 	// Agent tool session management
 	CreateAgentToolSessionID(messageID, toolCallID string) string
 	ParseAgentToolSessionID(sessionID string) (messageID string, toolCallID string, ok bool)
@@ -117,6 +130,128 @@ func (s *service) CreateTitleSession(ctx context.Context, parentSessionID string
 	return session, nil
 }
 
+// Fork creates a session that continues sessionID from messageID. It gets a
+// copy of the session, of its messages up to and including messageID, of the
+// files it touched as they were at that message and of the files it read. The
+// fork's ParentSessionID points at the original session.
+func (s *service) Fork(ctx context.Context, sessionID, messageID string) (Session, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Session{}, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	qtx := s.q.WithTx(tx)
+
+	parent, err := qtx.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return Session{}, err
+	}
+	msgs, err := qtx.ListMessagesBySession(ctx, sessionID)
+	if err != nil {
+		return Session{}, fmt.Errorf("listing messages: %w", err)
+	}
+	end := slices.IndexFunc(msgs, func(m db.Message) bool { return m.ID == messageID })
+	if end == -1 {
+		return Session{}, fmt.Errorf("message %s not found in session %s", messageID, sessionID)
+	}
+	// Keep the results of the tool calls made by the message, so the fork
+	// does not start with unanswered tool calls.
+	for end+1 < len(msgs) && msgs[end+1].Role == "tool" {
+		end++
+	}
+	msgs = msgs[:end+1]
+
+	messageIDs := make(map[string]string, len(msgs))
+	for _, m := range msgs {
+		messageIDs[m.ID] = uuid.New().String()
+	}
+	summaryMessageID := messageIDs[parent.SummaryMessageID.String]
+
+	dbSession, err := qtx.ForkSession(ctx, db.ForkSessionParams{
+		ID:               uuid.New().String(),
+		ParentSessionID:  sql.NullString{String: parent.ID, Valid: true},
+		ForkMessageID:    sql.NullString{String: messageID, Valid: true},
+		Title:            parent.Title,
+		SummaryMessageID: sql.NullString{String: summaryMessageID, Valid: summaryMessageID != ""},
+		Todos:            parent.Todos,
+	})
+	if err != nil {
+		return Session{}, fmt.Errorf("creating fork: %w", err)
+	}
+
+	for _, m := range msgs {
+		if err := qtx.ImportMessage(ctx, db.ImportMessageParams{
+			ID:               messageIDs[m.ID],
+			SessionID:        dbSession.ID,
+			Role:             m.Role,
+			Parts:            m.Parts,
+			Model:            m.Model,
+			Provider:         m.Provider,
+			IsSummaryMessage: m.IsSummaryMessage,
+			CreatedAt:        m.CreatedAt,
+			UpdatedAt:        m.UpdatedAt,
+			FinishedAt:       m.FinishedAt,
+		}); err != nil {
+			return Session{}, fmt.Errorf("copying message %s: %w", m.ID, err)
+		}
+	}
+
+	files, err := qtx.ListFilesBySession(ctx, sessionID)
+	if err != nil {
+		return Session{}, fmt.Errorf("listing files: %w", err)
+	}
+	// Keep the latest version of each file that is not newer than the fork
+	// point, i.e. not written by a message the fork leaves out.
+	var paths []string
+	latest := make(map[string]db.File)
+	for _, f := range files {
+		if f.MessageID.Valid && messageIDs[f.MessageID.String] == "" {
+			continue
+		}
+		if _, ok := latest[f.Path]; !ok {
+			paths = append(paths, f.Path)
+		}
+		latest[f.Path] = f
+	}
+	for _, path := range paths {
+		f := latest[path]
+		// The copies are the starting point of the fork's history and are
+		// not tied to a message, so rewinding the fork leaves them alone.
+		if err := qtx.ImportFile(ctx, db.ImportFileParams{
+			ID:        uuid.New().String(),
+			SessionID: dbSession.ID,
+			Path:      f.Path,
+			Content:   f.Content,
+			Version:   f.Version,
+			CreatedAt: f.CreatedAt,
+			UpdatedAt: f.UpdatedAt,
+		}); err != nil {
+			return Session{}, fmt.Errorf("copying file %s: %w", f.Path, err)
+		}
+	}
+
+	if err := qtx.ForkReadFiles(ctx, db.ForkReadFilesParams{
+		ForkSessionID: dbSession.ID,
+		SessionID:     sessionID,
+	}); err != nil {
+		return Session{}, fmt.Errorf("copying read files: %w", err)
+	}
+
+	// Read the session back for the message count kept by the triggers.
+	if dbSession, err = qtx.GetSessionByID(ctx, dbSession.ID); err != nil {
+		return Session{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return Session{}, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	session := s.fromDBItem(dbSession)
+	s.Publish(pubsub.CreatedEvent, session)
+	event.SessionCreated()
+	return session, nil
+}
+
 func (s *service) Delete(ctx context.Context, id string) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -198,6 +333,20 @@ func (s *service) UpdateTitleAndUsage(ctx context.Context, sessionID, title stri
 	})
 }
 
+func (s *service) RecordCost(ctx context.Context, sessionID string, cost float64) error {
+	if cost == 0 {
+		return nil
+	}
+	return s.q.RecordSessionCost(ctx, db.RecordSessionCostParams{
+		SessionID: sessionID,
+		Cost:      cost,
+	})
+}
+
+func (s *service) CostSince(ctx context.Context, t time.Time) (float64, error) {
+	return s.q.GetCostSince(ctx, t.Unix())
+}
+
 func (s *service) List(ctx context.Context) ([]Session, error) {
 	dbSessions, err := s.q.ListSessions(ctx)
 	if err != nil {
@@ -227,6 +376,7 @@ func (s service) fromDBItem(item db.Session) Session {
 		Todos:            todos,
 		CreatedAt:        item.CreatedAt,
 		UpdatedAt:        item.UpdatedAt,
+		ForkMessageID:    item.ForkMessageID.String,
 	}
 }
 
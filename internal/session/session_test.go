@@ -0,0 +1,74 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFork(t *testing.T) {
+	ctx := t.Context()
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	sessions := NewService(q, conn)
+	messages := message.NewService(q)
+	files := history.NewService(q, conn)
+
+	sess, err := sessions.Create(ctx, "Original")
+	require.NoError(t, err)
+	sess.PromptTokens = 1000
+	sess.Cost = 2.5
+	sess, err = sessions.Save(ctx, sess)
+	require.NoError(t, err)
+
+	reply := func(prompt string) message.Message {
+		_, err := messages.Create(ctx, sess.ID, message.CreateMessageParams{
+			Role:  message.User,
+			Parts: []message.ContentPart{message.TextContent{Text: prompt}},
+		})
+		require.NoError(t, err)
+		msg, err := messages.Create(ctx, sess.ID, message.CreateMessageParams{
+			Role:  message.Assistant,
+			Parts: []message.ContentPart{message.TextContent{Text: "done"}},
+		})
+		require.NoError(t, err)
+		return msg
+	}
+
+	first := reply("first")
+	firstCtx := history.WithMessageID(ctx, first.ID)
+	_, err = files.Create(firstCtx, sess.ID, "/project/main.go", "original")
+	require.NoError(t, err)
+	_, err = files.CreateVersion(firstCtx, sess.ID, "/project/main.go", "first edit")
+	require.NoError(t, err)
+
+	second := reply("second")
+	secondCtx := history.WithMessageID(ctx, second.ID)
+	_, err = files.CreateVersion(secondCtx, sess.ID, "/project/main.go", "second edit")
+	require.NoError(t, err)
+	_, err = files.CreateNew(secondCtx, sess.ID, "/project/new.go")
+	require.NoError(t, err)
+
+	fork, err := sessions.Fork(ctx, sess.ID, first.ID)
+	require.NoError(t, err)
+	require.Equal(t, sess.ID, fork.ParentSessionID)
+	require.Equal(t, int64(2), fork.MessageCount)
+	// The fork only accounts for what it spends itself.
+	require.Zero(t, fork.PromptTokens)
+	require.Zero(t, fork.Cost)
+
+	// The fork gets the files as they were at the fork point, and none of
+	// the versions written after it.
+	forked, err := files.ListBySession(ctx, fork.ID)
+	require.NoError(t, err)
+	require.Len(t, forked, 1)
+	require.Equal(t, "/project/main.go", forked[0].Path)
+	require.Equal(t, "first edit", forked[0].Content)
+	require.Empty(t, forked[0].MessageID)
+}
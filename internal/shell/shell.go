@@ -103,6 +103,17 @@ func (s *Shell) Exec(ctx context.Context, command string) (string, string, error
 	return s.exec(ctx, command)
 }
 
+// ExecInput executes a command in the shell, feeding stdin to its standard
+// input.
+func (s *Shell) ExecInput(ctx context.Context, command string, stdin io.Reader) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stdout, stderr bytes.Buffer
+	err := s.execCommon(ctx, command, stdin, &stdout, &stderr)
+	return stdout.String(), stderr.String(), err
+}
+
 // ExecStream executes a command in the shell with streaming output to provided writers
 func (s *Shell) ExecStream(ctx context.Context, command string, stdout, stderr io.Writer) error {
 	s.mu.Lock()
@@ -237,9 +248,9 @@ func (s *Shell) blockHandler() func(next interp.ExecHandlerFunc) interp.ExecHand
 }
 
 // newInterp creates a new interpreter with the current shell state
-func (s *Shell) newInterp(stdout, stderr io.Writer) (*interp.Runner, error) {
+func (s *Shell) newInterp(stdin io.Reader, stdout, stderr io.Writer) (*interp.Runner, error) {
 	return interp.New(
-		interp.StdIO(nil, stdout, stderr),
+		interp.StdIO(stdin, stdout, stderr),
 		interp.Interactive(false),
 		interp.Env(expand.ListEnviron(s.env...)),
 		interp.Dir(s.cwd),
@@ -259,7 +270,7 @@ func (s *Shell) updateShellFromRunner(runner *interp.Runner) {
 }
 
 // execCommon is the shared implementation for executing commands
-func (s *Shell) execCommon(ctx context.Context, command string, stdout, stderr io.Writer) (err error) {
+func (s *Shell) execCommon(ctx context.Context, command string, stdin io.Reader, stdout, stderr io.Writer) (err error) {
 	var runner *interp.Runner
 	defer func() {
 		if r := recover(); r != nil {
@@ -276,7 +287,7 @@ func (s *Shell) execCommon(ctx context.Context, command string, stdout, stderr i
 		return fmt.Errorf("could not parse command: %w", err)
 	}
 
-	runner, err = s.newInterp(stdout, stderr)
+	runner, err = s.newInterp(stdin, stdout, stderr)
 	if err != nil {
 		return fmt.Errorf("could not run command: %w", err)
 	}
@@ -288,13 +299,13 @@ func (s *Shell) execCommon(ctx context.Context, command string, stdout, stderr i
 // exec executes commands using a cross-platform shell interpreter.
 func (s *Shell) exec(ctx context.Context, command string) (string, string, error) {
 	var stdout, stderr bytes.Buffer
-	err := s.execCommon(ctx, command, &stdout, &stderr)
+	err := s.execCommon(ctx, command, nil, &stdout, &stderr)
 	return stdout.String(), stderr.String(), err
 }
 
 // execStream executes commands using POSIX shell emulation with streaming output
 func (s *Shell) execStream(ctx context.Context, command string, stdout, stderr io.Writer) error {
-	return s.execCommon(ctx, command, stdout, stderr)
+	return s.execCommon(ctx, command, nil, stdout, stderr)
 }
 
 func (s *Shell) execHandlers() []func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
@@ -0,0 +1,190 @@
+package transcript
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// Format is a format a transcript can be exported to.
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+)
+
+// Formats lists the supported export formats.
+var Formats = []Format{FormatJSON, FormatMarkdown, FormatHTML}
+
+// turn is a message as shown in the Markdown and HTML forms.
+type turn struct {
+	Role    message.MessageRole
+	Model   string
+	Time    time.Time
+	Entries []entry
+}
+
+// entry is a message part as shown in the Markdown and HTML forms.
+type entry struct {
+	// Kind is one of text, reasoning, tool_call, tool_result, attachment or
+	// error.
+	Kind  string
+	Title string
+	Body  string
+}
+
+func turns(msgs []message.Message) []turn {
+	var turns []turn
+	for _, msg := range msgs {
+		t := turn{
+			Role:  msg.Role,
+			Model: msg.Model,
+			Time:  time.Unix(msg.CreatedAt, 0).UTC(),
+		}
+		for _, part := range msg.Parts {
+			switch p := part.(type) {
+			case message.ReasoningContent:
+				if strings.TrimSpace(p.Thinking) != "" {
+					t.Entries = append(t.Entries, entry{Kind: "reasoning", Title: "Reasoning", Body: p.Thinking})
+				}
+			case message.TextContent:
+				if strings.TrimSpace(p.Text) != "" {
+					t.Entries = append(t.Entries, entry{Kind: "text", Body: p.Text})
+				}
+			case message.ImageURLContent:
+				t.Entries = append(t.Entries, entry{Kind: "attachment", Title: "Image", Body: p.URL})
+			case message.BinaryContent:
+				t.Entries = append(t.Entries, entry{
+					Kind:  "attachment",
+					Title: "Attachment",
+					Body:  fmt.Sprintf("%s (%s, %d bytes)", p.Path, p.MIMEType, len(p.Data)),
+				})
+			case message.ToolCall:
+				t.Entries = append(t.Entries, entry{Kind: "tool_call", Title: p.Name, Body: p.Input})
+			case message.ToolResult:
+				title := p.Name
+				if p.IsError {
+					title += " (error)"
+				}
+				t.Entries = append(t.Entries, entry{Kind: "tool_result", Title: title, Body: p.Content})
+			case message.Finish:
+				switch p.Reason {
+				case message.FinishReasonError, message.FinishReasonCanceled, message.FinishReasonPermissionDenied:
+					body := p.Message
+					if p.Details != "" {
+						body += "\n" + p.Details
+					}
+					t.Entries = append(t.Entries, entry{Kind: "error", Title: string(p.Reason), Body: body})
+				}
+			}
+		}
+		if len(t.Entries) > 0 {
+			turns = append(turns, t)
+		}
+	}
+	return turns
+}
+
+// Markdown writes t as Markdown.
+func Markdown(w io.Writer, t Transcript) error {
+	var b strings.Builder
+	writeMarkdown(&b, t, 1)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMarkdown(b *strings.Builder, t Transcript, level int) {
+	heading := strings.Repeat("#", level)
+	fmt.Fprintf(b, "%s %s\n\n", heading, t.Session.Title)
+	fmt.Fprintf(b, "- Session: `%s`\n", t.Session.ID)
+	fmt.Fprintf(b, "- Created: %s\n", time.Unix(t.Session.CreatedAt, 0).UTC().Format(time.DateTime))
+	fmt.Fprintf(b, "- Tokens: %d prompt, %d completion\n", t.Session.PromptTokens, t.Session.CompletionTokens)
+	fmt.Fprintf(b, "- Cost: $%.4f\n\n", t.Session.Cost)
+
+	for _, turn := range turns(t.Messages) {
+		fmt.Fprintf(b, "%s# %s", heading, roleName(turn.Role))
+		if turn.Model != "" {
+			fmt.Fprintf(b, " (%s)", turn.Model)
+		}
+		fmt.Fprintf(b, "\n\n_%s_\n\n", turn.Time.Format(time.DateTime))
+		for _, e := range turn.Entries {
+			switch e.Kind {
+			case "text":
+				fmt.Fprintf(b, "%s\n\n", strings.TrimSpace(e.Body))
+			case "reasoning":
+				fmt.Fprintf(b, "<details>\n<summary>Reasoning</summary>\n\n%s\n\n</details>\n\n", strings.TrimSpace(e.Body))
+			case "tool_call":
+				fmt.Fprintf(b, "**Tool call:** `%s`\n\n%s\n", e.Title, codeBlock(e.Body, "json"))
+			case "tool_result":
+				fmt.Fprintf(b, "**Tool result:** `%s`\n\n%s\n", e.Title, codeBlock(e.Body, ""))
+			case "attachment":
+				fmt.Fprintf(b, "**%s:** %s\n\n", e.Title, e.Body)
+			case "error":
+				fmt.Fprintf(b, "> **%s:** %s\n\n", e.Title, strings.ReplaceAll(strings.TrimSpace(e.Body), "\n", "\n> "))
+			}
+		}
+	}
+
+	if len(t.Files) > 0 {
+		fmt.Fprintf(b, "%s# Files\n\n", heading)
+		for _, f := range t.Files {
+			fmt.Fprintf(b, "<details>\n<summary><code>%s</code> version %d</summary>\n\n%s\n</details>\n\n", f.Path, f.Version, codeBlock(f.Content, ""))
+		}
+	}
+
+	for _, task := range t.Tasks {
+		writeMarkdown(b, task, level+1)
+	}
+}
+
+// codeBlock fences s with more backticks than it contains in a row.
+func codeBlock(s, lang string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + lang + "\n" + strings.TrimRight(s, "\n") + "\n" + fence + "\n"
+}
+
+func roleName(role message.MessageRole) string {
+	switch role {
+	case message.User:
+		return "User"
+	case message.Assistant:
+		return "Assistant"
+	case message.Tool:
+		return "Tool"
+	case message.System:
+		return "System"
+	}
+	return string(role)
+}
+
+//go:embed transcript.html
+var htmlTemplate string
+
+var tmpl = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"turns":    turns,
+	"roleName": roleName,
+	"date": func(unix int64) string {
+		return time.Unix(unix, 0).UTC().Format(time.DateTime)
+	},
+}).Parse(htmlTemplate))
+
+// HTML writes t as a standalone HTML page.
+func HTML(w io.Writer, t Transcript) error {
+	return tmpl.Execute(w, t)
+}
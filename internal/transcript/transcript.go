@@ -0,0 +1,282 @@
+// Package transcript exports sessions to a portable form, so they can be
+// shared or attached to bug reports, and imports them back into another
+// project.
+//
+// The JSON form holds everything needed to recreate a session: its messages
+// with every part as stored in the database, the history of the files it
+// touched and the sessions of the sub-agents it started. The Markdown and
+// HTML forms are meant for reading only.
+package transcript
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/google/uuid"
+)
+
+// Version is the version of the JSON form written by [Export].
+const Version = 1
+
+// Transcript is the portable form of a session.
+type Transcript struct {
+	Version int `json:"version"`
+	// WorkingDir is the project the session was exported from.
+	WorkingDir string            `json:"working_dir,omitempty"`
+	Session    session.Session   `json:"session"`
+	Messages   []message.Message `json:"messages"`
+	Files      []File            `json:"files,omitempty"`
+	// Tasks holds the sessions of the sub-agents started from this session.
+	Tasks []Transcript `json:"tasks,omitempty"`
+}
+
+// File is a version of a file the session touched. Its path is relative to
+// the working directory of the transcript, with forward slashes, unless the
+// file is outside of it.
+type File struct {
+	ID        string `json:"id"`
+	MessageID string `json:"message_id,omitempty"`
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	Version   int64  `json:"version"`
+	IsNew     bool   `json:"is_new,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Export returns the transcript of the given session, from the project in
+// workingDir.
+func Export(
+	ctx context.Context,
+	sessions session.Service,
+	messages message.Service,
+	files history.Service,
+	workingDir, sessionID string,
+) (Transcript, error) {
+	sess, err := sessions.Get(ctx, sessionID)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("getting session: %w", err)
+	}
+	msgs, err := messages.List(ctx, sessionID)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("listing messages: %w", err)
+	}
+	versions, err := files.ListBySession(ctx, sessionID)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("listing files: %w", err)
+	}
+
+	t := Transcript{
+		Version:    Version,
+		WorkingDir: workingDir,
+		Session:    sess,
+		Messages:   msgs,
+	}
+	for _, f := range versions {
+		t.Files = append(t.Files, File{
+			ID:        f.ID,
+			MessageID: f.MessageID,
+			Path:      relativePath(workingDir, f.Path),
+			Content:   f.Content,
+			Version:   f.Version,
+			IsNew:     f.IsNew,
+			CreatedAt: f.CreatedAt,
+			UpdatedAt: f.UpdatedAt,
+		})
+	}
+
+	// Sub-agent sessions are named after the tool call that started them.
+	for _, msg := range msgs {
+		for _, tc := range msg.ToolCalls() {
+			taskID := sessions.CreateAgentToolSessionID(msg.ID, tc.ID)
+			task, err := Export(ctx, sessions, messages, files, workingDir, taskID)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return Transcript{}, fmt.Errorf("exporting task %s: %w", taskID, err)
+			}
+			t.Tasks = append(t.Tasks, task)
+		}
+	}
+	return t, nil
+}
+
+// Import recreates the session of t, and the sessions of its sub-agents,
+// in the database and returns it, for the project in workingDir. Sessions,
+// messages and files get new IDs, so a transcript can be imported more than
+// once.
+//
+// The files of the project are moved to workingDir. The files outside of it
+// are imported as they are, but not tied to their message, so rewinding the
+// session never touches them.
+func Import(ctx context.Context, conn *sql.DB, workingDir string, t Transcript) (session.Session, error) {
+	if t.Version != Version {
+		return session.Session{}, fmt.Errorf("unsupported transcript version %d", t.Version)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	q := db.New(conn)
+	sessions := session.NewService(q, conn)
+	sessionID := uuid.New().String()
+	if err := importSession(ctx, q.WithTx(tx), sessions, t, workingDir, sessionID, ""); err != nil {
+		return session.Session{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return session.Session{}, fmt.Errorf("committing transaction: %w", err)
+	}
+	return sessions.Get(ctx, sessionID)
+}
+
+func importSession(
+	ctx context.Context,
+	q *db.Queries,
+	sessions session.Service,
+	t Transcript,
+	workingDir, sessionID, parentSessionID string,
+) error {
+	messageIDs := make(map[string]string, len(t.Messages))
+	for _, msg := range t.Messages {
+		messageIDs[msg.ID] = uuid.New().String()
+	}
+
+	var todos string
+	if len(t.Session.Todos) > 0 {
+		data, err := json.Marshal(t.Session.Todos)
+		if err != nil {
+			return err
+		}
+		todos = string(data)
+	}
+	summaryMessageID := messageIDs[t.Session.SummaryMessageID]
+	if err := q.ImportSession(ctx, db.ImportSessionParams{
+		ID:               sessionID,
+		ParentSessionID:  sql.NullString{String: parentSessionID, Valid: parentSessionID != ""},
+		Title:            t.Session.Title,
+		PromptTokens:     t.Session.PromptTokens,
+		CompletionTokens: t.Session.CompletionTokens,
+		Cost:             t.Session.Cost,
+		SummaryMessageID: sql.NullString{String: summaryMessageID, Valid: summaryMessageID != ""},
+		Todos:            sql.NullString{String: todos, Valid: todos != ""},
+		UpdatedAt:        t.Session.UpdatedAt,
+		CreatedAt:        t.Session.CreatedAt,
+	}); err != nil {
+		return fmt.Errorf("importing session: %w", err)
+	}
+
+	for _, msg := range t.Messages {
+		// The JSON form of a message holds its parts as they are stored.
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		var stored struct {
+			Parts json.RawMessage `json:"parts"`
+		}
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return err
+		}
+		finishedAt := sql.NullInt64{}
+		if f := msg.FinishPart(); f != nil {
+			finishedAt.Int64 = f.Time
+			finishedAt.Valid = true
+		}
+		isSummary := int64(0)
+		if msg.IsSummaryMessage {
+			isSummary = 1
+		}
+		if err := q.ImportMessage(ctx, db.ImportMessageParams{
+			ID:               messageIDs[msg.ID],
+			SessionID:        sessionID,
+			Role:             string(msg.Role),
+			Parts:            string(stored.Parts),
+			Model:            sql.NullString{String: msg.Model, Valid: msg.Model != ""},
+			Provider:         sql.NullString{String: msg.Provider, Valid: msg.Provider != ""},
+			IsSummaryMessage: isSummary,
+			CreatedAt:        msg.CreatedAt,
+			UpdatedAt:        msg.UpdatedAt,
+			FinishedAt:       finishedAt,
+		}); err != nil {
+			return fmt.Errorf("importing message %s: %w", msg.ID, err)
+		}
+	}
+
+	for _, f := range t.Files {
+		path := f.Path
+		messageID := messageIDs[f.MessageID]
+		if filepath.IsAbs(filepath.FromSlash(path)) {
+			messageID = ""
+		} else {
+			path = filepath.Join(workingDir, filepath.FromSlash(path))
+			// Nor are the ones that climb out of the project.
+			if _, ok := within(workingDir, path); !ok {
+				messageID = ""
+			}
+		}
+		isNew := int64(0)
+		if f.IsNew {
+			isNew = 1
+		}
+		if err := q.ImportFile(ctx, db.ImportFileParams{
+			ID:        uuid.New().String(),
+			SessionID: sessionID,
+			Path:      path,
+			Content:   f.Content,
+			Version:   f.Version,
+			MessageID: sql.NullString{String: messageID, Valid: messageID != ""},
+			IsNew:     isNew,
+			CreatedAt: f.CreatedAt,
+			UpdatedAt: f.UpdatedAt,
+		}); err != nil {
+			return fmt.Errorf("importing file %s: %w", f.Path, err)
+		}
+	}
+
+	for _, task := range t.Tasks {
+		messageID, toolCallID, ok := sessions.ParseAgentToolSessionID(task.Session.ID)
+		if !ok || messageIDs[messageID] == "" {
+			return fmt.Errorf("task %s does not belong to a message of the session", task.Session.ID)
+		}
+		taskID := sessions.CreateAgentToolSessionID(messageIDs[messageID], toolCallID)
+		if err := importSession(ctx, q, sessions, task, workingDir, taskID, sessionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relativePath returns path relative to workingDir, with forward slashes, or
+// path itself if it is outside of workingDir.
+func relativePath(workingDir, path string) string {
+	if workingDir == "" {
+		return path
+	}
+	rel, ok := within(workingDir, path)
+	if !ok {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// within returns path relative to dir, and whether it is inside of dir.
+func within(dir, path string) (string, bool) {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
@@ -0,0 +1,222 @@
+package transcript
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+type testEnv struct {
+	conn     *sql.DB
+	sessions session.Service
+	messages message.Service
+	history  history.Service
+}
+
+func setupTest(t *testing.T) *testEnv {
+	t.Helper()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	return &testEnv{
+		conn:     conn,
+		sessions: session.NewService(q, conn),
+		messages: message.NewService(q),
+		history:  history.NewService(q, conn),
+	}
+}
+
+func (e *testEnv) export(t *testing.T, sessionID string) Transcript {
+	t.Helper()
+	tr, err := Export(t.Context(), e.sessions, e.messages, e.history, "/project", sessionID)
+	require.NoError(t, err)
+	return tr
+}
+
+// createSession creates a session where the agent edits a file and starts a
+// sub-agent.
+func (e *testEnv) createSession(t *testing.T) session.Session {
+	t.Helper()
+	ctx := t.Context()
+
+	sess, err := e.sessions.Create(ctx, "Fix the build")
+	require.NoError(t, err)
+	sess.Todos = []session.Todo{{Content: "Fix main.go", Status: session.TodoStatusCompleted}}
+	sess.PromptTokens = 100
+	sess.Cost = 0.25
+	_, err = e.sessions.Save(ctx, sess)
+	require.NoError(t, err)
+
+	_, err = e.messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "The build is broken"}},
+	})
+	require.NoError(t, err)
+
+	assistant, err := e.messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.Assistant,
+		Model: "model",
+		Parts: []message.ContentPart{
+			message.ReasoningContent{Thinking: "Let me look at main.go"},
+			message.ToolCall{ID: "call", Name: "agent", Input: `{"prompt":"find it"}`, Finished: true},
+			message.Finish{Reason: message.FinishReasonToolUse, Time: 42},
+		},
+	})
+	require.NoError(t, err)
+
+	fileCtx := history.WithMessageID(ctx, assistant.ID)
+	_, err = e.history.Create(fileCtx, sess.ID, "/project/main.go", "package main")
+	require.NoError(t, err)
+	_, err = e.history.CreateVersion(fileCtx, sess.ID, "/project/main.go", "package main\n\nfunc main() {}")
+	require.NoError(t, err)
+	_, err = e.history.Create(fileCtx, sess.ID, "/etc/hosts", "127.0.0.1 localhost")
+	require.NoError(t, err)
+
+	task, err := e.sessions.CreateTaskSession(ctx, e.sessions.CreateAgentToolSessionID(assistant.ID, "call"), sess.ID, "New Agent Session")
+	require.NoError(t, err)
+	_, err = e.messages.Create(ctx, task.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "find it"}},
+	})
+	require.NoError(t, err)
+
+	_, err = e.messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role: message.Tool,
+		Parts: []message.ContentPart{message.ToolResult{
+			ToolCallID: "call",
+			Name:       "agent",
+			Content:    "It is in main.go",
+		}},
+	})
+	require.NoError(t, err)
+	return sess
+}
+
+func TestExportImport(t *testing.T) {
+	src := setupTest(t)
+	sess := src.createSession(t)
+
+	exported := src.export(t, sess.ID)
+	require.Equal(t, Version, exported.Version)
+	require.Len(t, exported.Messages, 3)
+	require.Equal(t, "/project", exported.WorkingDir)
+	var paths []string
+	for _, f := range exported.Files {
+		paths = append(paths, f.Path)
+	}
+	require.ElementsMatch(t, []string{"main.go", "main.go", "/etc/hosts"}, paths)
+	require.Len(t, exported.Tasks, 1)
+	require.Len(t, exported.Tasks[0].Messages, 1)
+
+	data, err := json.Marshal(exported)
+	require.NoError(t, err)
+	var decoded Transcript
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	dst := setupTest(t)
+	imported, err := Import(t.Context(), dst.conn, "/elsewhere", decoded)
+	require.NoError(t, err)
+	require.NotEqual(t, sess.ID, imported.ID)
+	require.Equal(t, "Fix the build", imported.Title)
+	require.Equal(t, int64(3), imported.MessageCount)
+	require.Equal(t, int64(100), imported.PromptTokens)
+	require.Equal(t, 0.25, imported.Cost)
+	require.Len(t, imported.Todos, 1)
+
+	reexported := dst.export(t, imported.ID)
+	require.Len(t, reexported.Messages, 3)
+	for i, msg := range reexported.Messages {
+		original := exported.Messages[i]
+		require.Equal(t, imported.ID, msg.SessionID)
+		require.Equal(t, original.Role, msg.Role)
+		require.Equal(t, original.Parts, msg.Parts)
+		require.Equal(t, original.CreatedAt, msg.CreatedAt)
+	}
+
+	// The files of the project move to the new one, and stay tied to the
+	// message that produced them. The others are never rewound.
+	files, err := dst.history.ListBySession(t.Context(), imported.ID)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	for _, f := range files {
+		if f.Path == "/etc/hosts" {
+			require.Empty(t, f.MessageID)
+			continue
+		}
+		require.Equal(t, "/elsewhere/main.go", f.Path)
+		require.Equal(t, reexported.Messages[1].ID, f.MessageID)
+	}
+
+	// The sub-agent session follows the new ID of its tool call's message.
+	require.Len(t, reexported.Tasks, 1)
+	require.Equal(t, imported.ID, reexported.Tasks[0].Session.ParentSessionID)
+	require.Equal(t, "find it", reexported.Tasks[0].Messages[0].Content().Text)
+
+	// Importing again creates another copy.
+	again, err := Import(t.Context(), dst.conn, "/elsewhere", decoded)
+	require.NoError(t, err)
+	require.NotEqual(t, imported.ID, again.ID)
+}
+
+func TestImport_OutsideWorkingDir(t *testing.T) {
+	src := setupTest(t)
+	exported := src.export(t, src.createSession(t).ID)
+	for i, f := range exported.Files {
+		if f.Path == "main.go" {
+			exported.Files[i].Path = "../../home/user/.bashrc"
+		}
+	}
+
+	dst := setupTest(t)
+	imported, err := Import(t.Context(), dst.conn, "/elsewhere/project", exported)
+	require.NoError(t, err)
+
+	// Relative paths that leave the project are never rewound.
+	files, err := dst.history.ListBySession(t.Context(), imported.ID)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	for _, f := range files {
+		require.Empty(t, f.MessageID, f.Path)
+	}
+}
+
+func TestImport_UnsupportedVersion(t *testing.T) {
+	env := setupTest(t)
+	_, err := Import(t.Context(), env.conn, "/project", Transcript{Version: Version + 1})
+	require.ErrorContains(t, err, "unsupported transcript version")
+}
+
+func TestRender(t *testing.T) {
+	env := setupTest(t)
+	tr := env.export(t, env.createSession(t).ID)
+
+	var md bytes.Buffer
+	require.NoError(t, Markdown(&md, tr))
+	require.Contains(t, md.String(), "# Fix the build\n")
+	require.Contains(t, md.String(), "## User")
+	require.Contains(t, md.String(), "**Tool call:** `agent`")
+	require.Contains(t, md.String(), "Let me look at main.go")
+	require.Contains(t, md.String(), "<code>main.go</code> version 1")
+	require.Contains(t, md.String(), "## New Agent Session\n")
+
+	var page bytes.Buffer
+	require.NoError(t, HTML(&page, tr))
+	require.Contains(t, page.String(), "<h1>Fix the build</h1>")
+	require.Contains(t, page.String(), "<h2>New Agent Session</h2>")
+	require.Contains(t, page.String(), "{&#34;prompt&#34;:&#34;find it&#34;}")
+}
+
+func TestCodeBlock(t *testing.T) {
+	require.Equal(t, "```go\nfmt.Println()\n```\n", codeBlock("fmt.Println()\n", "go"))
+	require.Equal(t, "````\n```\n````\n", codeBlock("```", ""))
+}
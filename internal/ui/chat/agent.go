@@ -8,6 +8,7 @@ import (
 	"charm.land/lipgloss/v2"
 	"charm.land/lipgloss/v2/tree"
 	"github.com/charmbracelet/crush/internal/agent"
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/ui/anim"
 	"github.com/charmbracelet/crush/internal/ui/styles"
@@ -115,8 +116,13 @@ func (r *AgentToolRenderContext) RenderTool(sty *styles.Styles, width int, opts
 		return header
 	}
 
-	// Build the task tag and prompt.
-	taskTag := sty.Tool.AgentTaskTag.Render("Task")
+	// Build the task tag and prompt. The tag names the subagent type, if
+	// other than the default.
+	tag := "Task"
+	if params.SubagentType != "" && params.SubagentType != config.AgentTask {
+		tag = params.SubagentType
+	}
+	taskTag := sty.Tool.AgentTaskTag.Render(tag)
 	taskTagWidth := lipgloss.Width(taskTag)
 
 	// Calculate remaining width for prompt.
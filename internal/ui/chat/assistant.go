@@ -3,6 +3,7 @@ package chat
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	tea "charm.land/bubbletea/v2"
 	"charm.land/lipgloss/v2"
@@ -199,7 +200,9 @@ func (a *AssistantMessageItem) renderMarkdown(content string, width int) string
 }
 
 func (a *AssistantMessageItem) renderSpinning() string {
-	if a.message.IsThinking() {
+	if retry := a.message.RetryPart(); retry != nil {
+		a.anim.SetLabel(retryLabel(retry))
+	} else if a.message.IsThinking() {
 		a.anim.SetLabel("Thinking")
 	} else if a.message.IsSummaryMessage {
 		a.anim.SetLabel("Summarizing")
@@ -207,6 +210,16 @@ func (a *AssistantMessageItem) renderSpinning() string {
 	return a.anim.Render()
 }
 
+// retryLabel returns the label of the spinner while a failed request waits
+// to be retried, with a countdown to the retry.
+func retryLabel(retry *message.Retry) string {
+	wait := time.Until(time.UnixMilli(retry.Until)).Round(time.Second)
+	if wait <= 0 {
+		return fmt.Sprintf("%s, retrying (attempt %d)", retry.Reason, retry.Attempt+1)
+	}
+	return fmt.Sprintf("%s, retrying in %s (attempt %d)", retry.Reason, wait, retry.Attempt+1)
+}
+
 // renderError renders an error message.
 func (a *AssistantMessageItem) renderError(width int) string {
 	finishPart := a.message.FinishPart()
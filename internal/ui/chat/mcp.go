@@ -1,10 +1,14 @@
 package chat
 
 import (
+	"bytes"
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"strings"
 
+	"github.com/charmbracelet/x/ansi"
+
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/stringext"
 	"github.com/charmbracelet/crush/internal/ui/styles"
@@ -69,27 +73,91 @@ func (b *MCPToolRenderContext) RenderTool(sty *styles.Styles, width int, opts *T
 		return joinToolParts(header, earlyState)
 	}
 
-	if !opts.HasResult() || opts.Result.Content == "" {
+	if !opts.HasResult() || (opts.Result.Content == "" && len(opts.Result.Parts) == 0) {
 		return header
 	}
 
 	bodyWidth := cappedWidth - toolBodyLeftPaddingTotal
-	// see if the result is json
-	var result json.RawMessage
-	var body string
-	if err := json.Unmarshal([]byte(opts.Result.Content), &result); err == nil {
-		prettyResult, err := json.MarshalIndent(result, "", "  ")
-		if err == nil {
-			body = sty.Tool.Body.Render(toolOutputCodeContent(sty, "result.json", string(prettyResult), 0, bodyWidth, opts.ExpandedContent))
-		} else {
-			body = sty.Tool.Body.Render(toolOutputPlainContent(sty, opts.Result.Content, bodyWidth, opts.ExpandedContent))
+	if len(opts.Result.Parts) == 0 {
+		return joinToolParts(header, mcpTextContent(sty, opts.Result.Content, bodyWidth, opts.ExpandedContent))
+	}
+
+	// Servers are expected to repeat the structured content as JSON text,
+	// which is shown once.
+	var structured string
+	for _, part := range opts.Result.Parts {
+		if part.Type == message.ToolResultPartStructured {
+			structured = part.Text
+		}
+	}
+	var bodies []string
+	for _, part := range opts.Result.Parts {
+		switch part.Type {
+		case message.ToolResultPartText:
+			if structured != "" && sameJSON(part.Text, structured) {
+				continue
+			}
+			bodies = append(bodies, mcpTextContent(sty, part.Text, bodyWidth, opts.ExpandedContent))
+		case message.ToolResultPartStructured:
+			bodies = append(bodies, mcpJSONContent(sty, part.Text, bodyWidth, opts.ExpandedContent))
+		case message.ToolResultPartImage, message.ToolResultPartAudio:
+			bodies = append(bodies, toolOutputImageContent(sty, part.Data, part.MIMEType))
+		case message.ToolResultPartResourceLink:
+			bodies = append(bodies, mcpResourceLinkContent(sty, part, bodyWidth))
+		case message.ToolResultPartResource:
+			if part.Data != "" {
+				bodies = append(bodies, toolOutputImageContent(sty, part.Data, cmp.Or(part.MIMEType, part.URI)))
+				continue
+			}
+			bodies = append(bodies, sty.Tool.Body.Render(toolOutputCodeContent(sty, part.URI, part.Text, 0, bodyWidth, opts.ExpandedContent)))
 		}
-	} else if looksLikeMarkdown(opts.Result.Content) {
-		body = sty.Tool.Body.Render(toolOutputCodeContent(sty, "result.md", opts.Result.Content, 0, bodyWidth, opts.ExpandedContent))
-	} else {
-		body = sty.Tool.Body.Render(toolOutputPlainContent(sty, opts.Result.Content, bodyWidth, opts.ExpandedContent))
 	}
-	return joinToolParts(header, body)
+	return joinToolParts(header, strings.Join(bodies, "\n\n"))
+}
+
+// mcpTextContent renders text content of an MCP tool result, as JSON or
+// markdown when it looks like it.
+func mcpTextContent(sty *styles.Styles, content string, width int, expanded bool) string {
+	if json.Valid([]byte(content)) {
+		return mcpJSONContent(sty, content, width, expanded)
+	}
+	if looksLikeMarkdown(content) {
+		return sty.Tool.Body.Render(toolOutputCodeContent(sty, "result.md", content, 0, width, expanded))
+	}
+	return sty.Tool.Body.Render(toolOutputPlainContent(sty, content, width, expanded))
+}
+
+// mcpJSONContent renders JSON content of an MCP tool result, indented.
+func mcpJSONContent(sty *styles.Styles, content string, width int, expanded bool) string {
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, []byte(content), "", "  "); err != nil {
+		return sty.Tool.Body.Render(toolOutputPlainContent(sty, content, width, expanded))
+	}
+	return sty.Tool.Body.Render(toolOutputCodeContent(sty, "result.json", pretty.String(), 0, width, expanded))
+}
+
+// mcpResourceLinkContent renders a link to a resource of an MCP server.
+func mcpResourceLinkContent(sty *styles.Styles, part message.ToolResultPart, width int) string {
+	link := sty.Base.Foreground(sty.Green).Render("Link")
+	arrow := sty.Base.Foreground(sty.GreenDark).Render("→")
+	line := fmt.Sprintf("%s %s %s", link, arrow, sty.Base.Render(cmp.Or(part.Name, part.URI)))
+	if part.Name != "" && part.Name != part.URI {
+		line += " " + sty.Subtle.Render(part.URI)
+	}
+	lines := []string{ansi.Truncate(line, width, "…")}
+	if part.Description != "" {
+		lines = append(lines, ansi.Truncate(sty.Subtle.Render(part.Description), width, "…"))
+	}
+	return sty.Tool.Body.Render(strings.Join(lines, "\n"))
+}
+
+// sameJSON reports whether a and b are the same JSON, ignoring whitespace.
+func sameJSON(a, b string) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, []byte(a)) != nil || json.Compact(&cb, []byte(b)) != nil {
+		return false
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
 }
 
 func prettyName(name string) string {
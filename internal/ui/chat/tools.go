@@ -311,6 +311,9 @@ func (t *baseToolMessageItem) RawRender(width int) string {
 			IsSpinning:      t.isSpinning(),
 			Status:          t.computeStatus(),
 		})
+		if t.result != nil && t.result.Pruned && !t.isCompact {
+			content += "\n\n" + t.sty.Subtle.Render(prunedToolResultNote)
+		}
 		height = lipgloss.Height(content)
 		// cache the rendered content
 		t.setCachedRender(content, toolItemWidth, height)
@@ -420,6 +423,10 @@ func (t *baseToolMessageItem) HandleKeyEvent(key tea.KeyMsg) (bool, tea.Cmd) {
 	return false, nil
 }
 
+// prunedToolResultNote is shown under tools whose output was pruned from the
+// context of the agent.
+const prunedToolResultNote = "Output elided from the agent's context to save space."
+
 // pendingTool renders a tool that is still in progress with an animation.
 func pendingTool(sty *styles.Styles, name string, anim *anim.Anim) string {
 	icon := sty.Tool.IconPending.Render()
@@ -980,6 +987,9 @@ func (t *baseToolMessageItem) formatParametersForCopy() string {
 	case agent.AgentToolName:
 		var params agent.AgentParams
 		if json.Unmarshal([]byte(t.toolCall.Input), &params) == nil {
+			if params.SubagentType != "" {
+				return fmt.Sprintf("**Subagent:** %s\n**Task:**\n%s", params.SubagentType, params.Prompt)
+			}
 			return fmt.Sprintf("**Task:**\n%s", params.Prompt)
 		}
 	}
@@ -51,11 +51,49 @@ type (
 	ActionTogglePills       struct{}
 	ActionExternalEditor    struct{}
 	ActionToggleYoloMode    struct{}
+	ActionTogglePlanMode    struct{}
 	// ActionInitializeProject is a message to initialize a project.
 	ActionInitializeProject struct{}
 	ActionSummarize         struct {
 		SessionID string
 	}
+	// ActionRewind is a message to rewind a session to the checkpoint right
+	// before the given message.
+	ActionRewind struct {
+		SessionID string
+		MessageID string
+	}
+	// ActionApprovePlan is a message to carry out the plan the agent wrote
+	// in plan mode.
+	ActionApprovePlan struct {
+		SessionID string
+	}
+	// ActionRaiseBudget is a message to raise the limit of a budget the
+	// agent used up.
+	ActionRaiseBudget struct {
+		Kind  config.BudgetKind
+		Limit float64
+	}
+	// ActionAuthorizeMCP is a message to authorize with an MCP server that
+	// requires it.
+	ActionAuthorizeMCP struct {
+		Name string
+	}
+	// ActionAcceptElicitation is a message to answer the form of an MCP
+	// server with the values the user filled in.
+	ActionAcceptElicitation struct {
+		ID     string
+		Values map[string]string
+	}
+	// ActionCancelElicitation is a message to dismiss the form of an MCP
+	// server.
+	ActionCancelElicitation struct {
+		ID string
+	}
+	// ActionSwitchAgent is a message to switch the agent that runs prompts.
+	ActionSwitchAgent struct {
+		AgentID string
+	}
 	// ActionSelectReasoningEffort is a message indicating a reasoning effort has been selected.
 	ActionSelectReasoningEffort struct {
 		Effort string
@@ -126,6 +126,9 @@ func (a *Arguments) focusInput(newIndex int) {
 
 	// Wrap around: Go's modulo can return negative, so add len first.
 	n := len(a.inputs)
+	if n == 0 {
+		return
+	}
 	a.focused = ((newIndex % n) + n) % n
 
 	a.inputs[a.focused].Focus()
@@ -195,8 +198,8 @@ func (a *Arguments) HandleMsg(msg tea.Msg) Action {
 		case key.Matches(msg, a.keyMap.Close):
 			return ActionClose{}
 		case key.Matches(msg, a.keyMap.Confirm):
-			// If we're on the last input or there's only one input, submit.
-			if a.focused == len(a.inputs)-1 || len(a.inputs) == 1 {
+			// If we're on the last input or there's at most one input, submit.
+			if a.focused >= len(a.inputs)-1 {
 				args := make(map[string]string)
 				var warning tea.Cmd
 				for i, arg := range a.arguments {
@@ -217,6 +220,9 @@ func (a *Arguments) HandleMsg(msg tea.Msg) Action {
 				case ActionRunMCPPrompt:
 					action.Args = args
 					return action
+				case ActionAcceptElicitation:
+					action.Values = args
+					return action
 				}
 			}
 			a.focusInput(a.focused + 1)
@@ -225,6 +231,9 @@ func (a *Arguments) HandleMsg(msg tea.Msg) Action {
 		case key.Matches(msg, a.keyMap.Previous):
 			a.focusInput(a.focused - 1)
 		default:
+			if len(a.inputs) == 0 {
+				break
+			}
 			var cmd tea.Cmd
 			a.inputs[a.focused], cmd = a.inputs[a.focused].Update(msg)
 			return ActionCmd{Cmd: cmd}
@@ -236,6 +245,9 @@ func (a *Arguments) HandleMsg(msg tea.Msg) Action {
 			a.focusInput(a.findVisibleFieldByOffset(msg.Button == tea.MouseWheelDown))
 		}
 	case tea.PasteMsg:
+		if len(a.inputs) == 0 {
+			break
+		}
 		var cmd tea.Cmd
 		a.inputs[a.focused], cmd = a.inputs[a.focused].Update(msg)
 		return ActionCmd{Cmd: cmd}
@@ -246,6 +258,9 @@ func (a *Arguments) HandleMsg(msg tea.Msg) Action {
 // Cursor returns the cursor position relative to the dialog.
 // we pass the description height to offset the cursor correctly.
 func (a *Arguments) Cursor(descriptionHeight int) *tea.Cursor {
+	if len(a.inputs) == 0 {
+		return nil
+	}
 	cursor := InputCursor(a.com.Styles, a.inputs[a.focused].Cursor())
 	if cursor == nil {
 		return nil
@@ -305,7 +320,7 @@ func (a *Arguments) Draw(scr uv.Screen, area uv.Rectangle) *tea.Cursor {
 
 	// Anchor width to the longest field, capped at maxInputWidth.
 	const scrollbarWidth = 1
-	width := lipgloss.Width(renderedFields)
+	width := max(lipgloss.Width(renderedFields), minInputWidth)
 	height := lipgloss.Height(renderedFields)
 
 	// Use standard header
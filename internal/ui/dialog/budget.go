@@ -0,0 +1,146 @@
+package dialog
+
+import (
+	"fmt"
+
+	"charm.land/bubbles/v2/key"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/ui/common"
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+// BudgetID is the identifier for the budget dialog.
+const BudgetID = "budget"
+
+// Budget represents a dialog to raise a budget the agent used up.
+type Budget struct {
+	com        *common.Common
+	kind       config.BudgetKind
+	limit      float64
+	spent      float64
+	selectedNo bool // true if "Keep Limit" is selected
+	keyMap     struct {
+		LeftRight,
+		EnterSpace,
+		Yes,
+		No,
+		Tab,
+		Close key.Binding
+	}
+}
+
+var _ Dialog = (*Budget)(nil)
+
+// NewBudget creates a new dialog to raise the given budget, which the
+// agent stopped at.
+func NewBudget(com *common.Common, kind config.BudgetKind, limit, spent float64) *Budget {
+	b := &Budget{
+		com:   com,
+		kind:  kind,
+		limit: limit,
+		spent: spent,
+	}
+	b.keyMap.LeftRight = key.NewBinding(
+		key.WithKeys("left", "right"),
+		key.WithHelp("←/→", "switch options"),
+	)
+	b.keyMap.EnterSpace = key.NewBinding(
+		key.WithKeys("enter", " "),
+		key.WithHelp("enter/space", "confirm"),
+	)
+	b.keyMap.Yes = key.NewBinding(
+		key.WithKeys("y", "Y"),
+		key.WithHelp("y/Y", "raise"),
+	)
+	b.keyMap.No = key.NewBinding(
+		key.WithKeys("n", "N"),
+		key.WithHelp("n/N", "keep limit"),
+	)
+	b.keyMap.Tab = key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "switch options"),
+	)
+	b.keyMap.Close = CloseKey
+	return b
+}
+
+// raisedLimit returns the limit to raise the budget to: twice the limit,
+// or more if the agent already spent that.
+func (b *Budget) raisedLimit() float64 {
+	return max(2*b.limit, b.spent+b.limit)
+}
+
+// ID implements [Model].
+func (*Budget) ID() string {
+	return BudgetID
+}
+
+// HandleMsg implements [Model].
+func (b *Budget) HandleMsg(msg tea.Msg) Action {
+	raise := ActionRaiseBudget{Kind: b.kind, Limit: b.raisedLimit()}
+	switch msg := msg.(type) {
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, b.keyMap.Close):
+			return ActionClose{}
+		case key.Matches(msg, b.keyMap.LeftRight, b.keyMap.Tab):
+			b.selectedNo = !b.selectedNo
+		case key.Matches(msg, b.keyMap.EnterSpace):
+			if !b.selectedNo {
+				return raise
+			}
+			return ActionClose{}
+		case key.Matches(msg, b.keyMap.Yes):
+			return raise
+		case key.Matches(msg, b.keyMap.No):
+			return ActionClose{}
+		}
+	}
+
+	return nil
+}
+
+// Draw implements [Dialog].
+func (b *Budget) Draw(scr uv.Screen, area uv.Rectangle) *tea.Cursor {
+	const detail = "The agent won't carry on until the limit is raised."
+	baseStyle := b.com.Styles.Base
+
+	question := fmt.Sprintf("The agent spent $%.2f of the $%.2f %s budget.", b.spent, b.limit, b.kind)
+	buttonOpts := []common.ButtonOpts{
+		{Text: fmt.Sprintf("Raise to $%.2f", b.raisedLimit()), Selected: !b.selectedNo, Padding: 3},
+		{Text: "Keep Limit", Selected: b.selectedNo, Padding: 3},
+	}
+	buttons := common.ButtonGroup(b.com.Styles, buttonOpts, " ")
+	content := baseStyle.Render(
+		lipgloss.JoinVertical(
+			lipgloss.Center,
+			question,
+			"",
+			b.com.Styles.Subtle.Render(detail),
+			"",
+			buttons,
+		),
+	)
+
+	view := b.com.Styles.BorderFocus.Render(content)
+	DrawCenter(scr, area, view)
+	return nil
+}
+
+// ShortHelp implements [help.KeyMap].
+func (b *Budget) ShortHelp() []key.Binding {
+	return []key.Binding{
+		b.keyMap.LeftRight,
+		b.keyMap.EnterSpace,
+	}
+}
+
+// FullHelp implements [help.KeyMap].
+func (b *Budget) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{b.keyMap.LeftRight, b.keyMap.EnterSpace, b.keyMap.Yes, b.keyMap.No},
+		{b.keyMap.Tab, b.keyMap.Close},
+	}
+}
@@ -1,7 +1,9 @@
 package dialog
 
 import (
+	"maps"
 	"os"
+	"slices"
 	"strings"
 
 	"charm.land/bubbles/v2/help"
@@ -9,8 +11,8 @@ import (
 	"charm.land/bubbles/v2/spinner"
 	"charm.land/bubbles/v2/textinput"
 	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/agent/tools/mcp"
 	"github.com/charmbracelet/crush/internal/commands"
-	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/ui/common"
 	"github.com/charmbracelet/crush/internal/ui/list"
 	"github.com/charmbracelet/crush/internal/ui/styles"
@@ -386,6 +388,7 @@ func (c *Commands) setCommandItems(commandType CommandType) {
 
 // defaultCommands returns the list of default system commands.
 func (c *Commands) defaultCommands() []*CommandItem {
+	cfg := c.com.Config()
 	commands := []*CommandItem{
 		NewCommandItem(c.com.Styles, "new_session", "New Session", "ctrl+n", ActionNewSession{}),
 		NewCommandItem(c.com.Styles, "switch_session", "Sessions", "ctrl+s", ActionOpenDialog{SessionsID}),
@@ -394,12 +397,30 @@ func (c *Commands) defaultCommands() []*CommandItem {
 
 	// Only show compact command if there's an active session
 	if c.hasSession {
-		commands = append(commands, NewCommandItem(c.com.Styles, "summarize", "Summarize Session", "", ActionSummarize{SessionID: c.sessionID}))
+		commands = append(commands,
+			NewCommandItem(c.com.Styles, "summarize", "Summarize Session", "", ActionSummarize{SessionID: c.sessionID}),
+			NewCommandItem(c.com.Styles, "session_permissions", "Session Permissions", "", ActionOpenDialog{GrantsID}),
+		)
+	}
+
+	// Add a command to authorize each MCP server that requires it
+	for _, m := range mcp.Configs(cfg).Sorted() {
+		if state, ok := mcp.GetState(m.Name); ok && state.State == mcp.StateNeedsAuth {
+			commands = append(commands, NewCommandItem(c.com.Styles, "authorize_mcp_"+m.Name, "Authorize MCP: "+m.Name, "", ActionAuthorizeMCP{Name: m.Name}))
+		}
+	}
+
+	// Add a command to switch to each of the other agents
+	currentAgent := c.com.App.CurrentAgent()
+	for _, id := range slices.Sorted(maps.Keys(cfg.Agents)) {
+		if !cfg.Agents[id].IsPrimary() || id == currentAgent.ID {
+			continue
+		}
+		commands = append(commands, NewCommandItem(c.com.Styles, "switch_agent_"+id, "Switch to "+cfg.Agents[id].Name+" Agent", "", ActionSwitchAgent{AgentID: id}))
 	}
 
 	// Add reasoning toggle for models that support it
-	cfg := c.com.Config()
-	if agentCfg, ok := cfg.Agents[config.AgentCoder]; ok {
+	if agentCfg := currentAgent; agentCfg.ID != "" {
 		providerCfg := cfg.GetProviderForModel(agentCfg.Model)
 		model := cfg.GetModelByType(agentCfg.Model)
 		if providerCfg != nil && model != nil && model.CanReason {
@@ -427,9 +448,7 @@ func (c *Commands) defaultCommands() []*CommandItem {
 		commands = append(commands, NewCommandItem(c.com.Styles, "toggle_sidebar", "Toggle Sidebar", "", ActionToggleCompactMode{}))
 	}
 	if c.hasSession {
-		cfg := c.com.Config()
-		agentCfg := cfg.Agents[config.AgentCoder]
-		model := cfg.GetModelByType(agentCfg.Model)
+		model := cfg.GetAgentModel(currentAgent)
 		if model != nil && model.SupportsImages {
 			commands = append(commands, NewCommandItem(c.com.Styles, "file_picker", "Open File Picker", "ctrl+f", ActionOpenDialog{
 				// TODO: Pass in the file picker dialog id
@@ -458,6 +477,7 @@ func (c *Commands) defaultCommands() []*CommandItem {
 
 	commands = append(commands,
 		NewCommandItem(c.com.Styles, "toggle_yolo", "Toggle Yolo Mode", "", ActionToggleYoloMode{}),
+		NewCommandItem(c.com.Styles, "toggle_plan_mode", "Toggle Plan Mode", "", ActionTogglePlanMode{}),
 		NewCommandItem(c.com.Styles, "toggle_help", "Toggle Help", "ctrl+g", ActionToggleHelp{}),
 		NewCommandItem(c.com.Styles, "init", "Initialize Project", "", ActionInitializeProject{}),
 		NewCommandItem(c.com.Styles, "quit", "Quit", "ctrl+c", tea.QuitMsg{}),
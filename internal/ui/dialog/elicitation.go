@@ -0,0 +1,60 @@
+package dialog
+
+import (
+	"cmp"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/agent/tools/mcp"
+	"github.com/charmbracelet/crush/internal/commands"
+	"github.com/charmbracelet/crush/internal/ui/common"
+)
+
+// ElicitationID is the identifier for the dialog of the forms MCP servers
+// ask the user to fill in.
+const ElicitationID = "elicitation"
+
+// Elicitation is a form an MCP server asks the user to fill in.
+type Elicitation struct {
+	*Arguments
+	requestID string
+}
+
+var _ Dialog = (*Elicitation)(nil)
+
+// NewElicitation creates a dialog for the form of an MCP server.
+func NewElicitation(com *common.Common, req mcp.ElicitationRequest) *Elicitation {
+	arguments := make([]commands.Argument, len(req.Fields))
+	for i, field := range req.Fields {
+		description := field.Description
+		switch hint := field.Hint(); {
+		case hint != "" && description != "":
+			description += " (" + hint + ")"
+		case hint != "":
+			description = hint
+		}
+		arguments[i] = commands.Argument{
+			ID:          field.Name,
+			Title:       cmp.Or(field.Title, field.Name),
+			Description: description,
+			Required:    field.Required,
+		}
+	}
+	return &Elicitation{
+		Arguments: NewArguments(com, req.Name+" asks", req.Message, arguments, ActionAcceptElicitation{ID: req.ID}),
+		requestID: req.ID,
+	}
+}
+
+// ID implements Dialog.
+func (e *Elicitation) ID() string {
+	return ElicitationID
+}
+
+// HandleMsg implements Dialog.
+func (e *Elicitation) HandleMsg(msg tea.Msg) Action {
+	action := e.Arguments.HandleMsg(msg)
+	if _, ok := action.(ActionClose); ok {
+		return ActionCancelElicitation{ID: e.requestID}
+	}
+	return action
+}
@@ -0,0 +1,323 @@
+package dialog
+
+import (
+	"context"
+	"slices"
+
+	"charm.land/bubbles/v2/help"
+	"charm.land/bubbles/v2/key"
+	"charm.land/bubbles/v2/textinput"
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/ui/common"
+	"github.com/charmbracelet/crush/internal/ui/list"
+	"github.com/charmbracelet/crush/internal/ui/styles"
+	"github.com/charmbracelet/crush/internal/ui/util"
+	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/sahilm/fuzzy"
+)
+
+// GrantsID is the identifier for the session permissions dialog.
+const GrantsID = "grants"
+
+// Grants is a dialog listing the permissions allowed for the rest of the
+// current session, which lets the user revoke them.
+type Grants struct {
+	com       *common.Common
+	help      help.Model
+	list      *list.FilterableList
+	input     textinput.Model
+	sessionID string
+	grants    []permission.SessionGrant
+	revoking  bool
+
+	keyMap struct {
+		Next          key.Binding
+		Previous      key.Binding
+		UpDown        key.Binding
+		Revoke        key.Binding
+		ConfirmRevoke key.Binding
+		CancelRevoke  key.Binding
+		Close         key.Binding
+	}
+}
+
+// GrantItem wraps a [permission.SessionGrant] to implement the [ListItem]
+// interface.
+type GrantItem struct {
+	permission.SessionGrant
+	t        *styles.Styles
+	revoking bool
+	m        fuzzy.Match
+	cache    map[int]string
+	focused  bool
+}
+
+var (
+	_ Dialog   = (*Grants)(nil)
+	_ ListItem = (*GrantItem)(nil)
+)
+
+// NewGrants creates a new session permissions dialog.
+func NewGrants(com *common.Common, sessionID string) (*Grants, error) {
+	grants, err := com.App.Permissions.ListSessionGrants(context.TODO(), sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Grants{
+		com:       com,
+		sessionID: sessionID,
+		grants:    grants,
+	}
+
+	help := help.New()
+	help.Styles = com.Styles.DialogHelpStyles()
+	g.help = help
+
+	g.list = list.NewFilterableList(g.items()...)
+	g.list.Focus()
+	g.list.SetSelected(0)
+
+	g.input = textinput.New()
+	g.input.SetVirtualCursor(false)
+	g.input.Placeholder = "Type to filter"
+	g.input.SetStyles(com.Styles.TextInput)
+	g.input.Focus()
+
+	g.keyMap.Next = key.NewBinding(
+		key.WithKeys("down", "ctrl+n"),
+		key.WithHelp("↓", "next item"),
+	)
+	g.keyMap.Previous = key.NewBinding(
+		key.WithKeys("up", "ctrl+p"),
+		key.WithHelp("↑", "previous item"),
+	)
+	g.keyMap.UpDown = key.NewBinding(
+		key.WithKeys("up", "down"),
+		key.WithHelp("↑↓", "choose"),
+	)
+	g.keyMap.Revoke = key.NewBinding(
+		key.WithKeys("ctrl+x"),
+		key.WithHelp("ctrl+x", "revoke"),
+	)
+	g.keyMap.ConfirmRevoke = key.NewBinding(
+		key.WithKeys("y"),
+		key.WithHelp("y", "revoke"),
+	)
+	g.keyMap.CancelRevoke = key.NewBinding(
+		key.WithKeys("n", "esc"),
+		key.WithHelp("n", "cancel"),
+	)
+	g.keyMap.Close = CloseKey
+
+	return g, nil
+}
+
+// ID implements Dialog.
+func (g *Grants) ID() string {
+	return GrantsID
+}
+
+// HandleMsg implements Dialog.
+func (g *Grants) HandleMsg(msg tea.Msg) Action {
+	keyMsg, ok := msg.(tea.KeyPressMsg)
+	if !ok {
+		return nil
+	}
+
+	if g.revoking {
+		switch {
+		case key.Matches(keyMsg, g.keyMap.ConfirmRevoke):
+			action := g.confirmRevoke()
+			g.list.SetItems(g.items()...)
+			g.list.SelectFirst()
+			g.list.ScrollToSelected()
+			return action
+		case key.Matches(keyMsg, g.keyMap.CancelRevoke):
+			g.revoking = false
+			g.list.SetItems(g.items()...)
+		}
+		return nil
+	}
+
+	switch {
+	case key.Matches(keyMsg, g.keyMap.Close):
+		return ActionClose{}
+	case key.Matches(keyMsg, g.keyMap.Revoke):
+		if g.list.SelectedItem() == nil {
+			break
+		}
+		g.revoking = true
+		g.list.SetItems(g.items()...)
+	case key.Matches(keyMsg, g.keyMap.Previous):
+		g.list.Focus()
+		if g.list.IsSelectedFirst() {
+			g.list.SelectLast()
+		} else {
+			g.list.SelectPrev()
+		}
+		g.list.ScrollToSelected()
+	case key.Matches(keyMsg, g.keyMap.Next):
+		g.list.Focus()
+		if g.list.IsSelectedLast() {
+			g.list.SelectFirst()
+		} else {
+			g.list.SelectNext()
+		}
+		g.list.ScrollToSelected()
+	default:
+		var cmd tea.Cmd
+		g.input, cmd = g.input.Update(keyMsg)
+		g.list.SetFilter(g.input.Value())
+		g.list.ScrollToTop()
+		g.list.SetSelected(0)
+		return ActionCmd{cmd}
+	}
+	return nil
+}
+
+// Cursor returns the cursor position relative to the dialog.
+func (g *Grants) Cursor() *tea.Cursor {
+	return InputCursor(g.com.Styles, g.input.Cursor())
+}
+
+// Draw implements [Dialog].
+func (g *Grants) Draw(scr uv.Screen, area uv.Rectangle) *tea.Cursor {
+	t := g.com.Styles
+	width := max(0, min(defaultDialogMaxWidth, area.Dx()-t.Dialog.View.GetHorizontalBorderSize()))
+	height := max(0, min(defaultDialogHeight, area.Dy()-t.Dialog.View.GetVerticalBorderSize()))
+	innerWidth := width - t.Dialog.View.GetHorizontalFrameSize()
+	heightOffset := t.Dialog.Title.GetVerticalFrameSize() + titleContentHeight +
+		t.Dialog.InputPrompt.GetVerticalFrameSize() + inputContentHeight +
+		t.Dialog.HelpView.GetVerticalFrameSize() +
+		t.Dialog.View.GetVerticalFrameSize()
+	g.input.SetWidth(max(0, innerWidth-t.Dialog.InputPrompt.GetHorizontalFrameSize()-1)) // (1) cursor padding
+	g.list.SetSize(innerWidth, height-heightOffset)
+	g.help.SetWidth(innerWidth)
+
+	var cur *tea.Cursor
+	rc := NewRenderContext(t, width)
+	rc.Title = "Session Permissions"
+	switch {
+	case g.revoking:
+		rc.TitleStyle = t.Dialog.Sessions.DeletingTitle
+		rc.TitleGradientFromColor = t.Dialog.Sessions.DeletingTitleGradientFromColor
+		rc.TitleGradientToColor = t.Dialog.Sessions.DeletingTitleGradientToColor
+		rc.ViewStyle = t.Dialog.Sessions.DeletingView
+		rc.AddPart(t.Dialog.Sessions.DeletingMessage.Render("Revoke this permission?"))
+	default:
+		cur = g.Cursor()
+		rc.AddPart(t.Dialog.InputPrompt.Render(g.input.View()))
+	}
+
+	listView := t.Subtle.Render("No permissions have been allowed for this session.")
+	if len(g.grants) > 0 {
+		listView = t.Dialog.List.Height(g.list.Height()).Render(g.list.Render())
+	}
+	rc.AddPart(listView)
+	rc.Help = g.help.View(g)
+
+	view := rc.Render()
+
+	DrawCenterCursor(scr, area, view, cur)
+	return cur
+}
+
+func (g *Grants) items() []list.FilterableItem {
+	items := make([]list.FilterableItem, len(g.grants))
+	for i, grant := range g.grants {
+		items[i] = &GrantItem{
+			SessionGrant: grant,
+			t:            g.com.Styles,
+			revoking:     g.revoking,
+		}
+	}
+	return items
+}
+
+func (g *Grants) confirmRevoke() Action {
+	g.revoking = false
+	item, ok := g.list.SelectedItem().(*GrantItem)
+	if !ok {
+		return nil
+	}
+
+	g.grants = slices.DeleteFunc(g.grants, func(grant permission.SessionGrant) bool {
+		return grant.ID == item.ID()
+	})
+	return ActionCmd{g.revokeCmd(item.ID())}
+}
+
+func (g *Grants) revokeCmd(id string) tea.Cmd {
+	return func() tea.Msg {
+		if err := g.com.App.Permissions.RevokeSessionGrant(context.TODO(), g.sessionID, id); err != nil {
+			return util.NewErrorMsg(err)
+		}
+		return util.NewInfoMsg("Permission revoked")
+	}
+}
+
+// ShortHelp implements [help.KeyMap].
+func (g *Grants) ShortHelp() []key.Binding {
+	if g.revoking {
+		return []key.Binding{
+			g.keyMap.ConfirmRevoke,
+			g.keyMap.CancelRevoke,
+		}
+	}
+	return []key.Binding{
+		g.keyMap.UpDown,
+		g.keyMap.Revoke,
+		g.keyMap.Close,
+	}
+}
+
+// FullHelp implements [help.KeyMap].
+func (g *Grants) FullHelp() [][]key.Binding {
+	return [][]key.Binding{g.ShortHelp()}
+}
+
+// Filter returns the filterable value of the grant.
+func (g *GrantItem) Filter() string {
+	return g.title() + " " + g.Path
+}
+
+// ID returns the unique identifier of the grant.
+func (g *GrantItem) ID() string {
+	return g.SessionGrant.ID
+}
+
+// SetFocused sets the focus state of the grant item.
+func (g *GrantItem) SetFocused(focused bool) {
+	if g.focused != focused {
+		g.cache = nil
+	}
+	g.focused = focused
+}
+
+// SetMatch sets the fuzzy match for the grant item.
+func (g *GrantItem) SetMatch(m fuzzy.Match) {
+	g.cache = nil
+	g.m = m
+}
+
+func (g *GrantItem) title() string {
+	return g.ToolName + " " + g.Action
+}
+
+// Render returns the string representation of the grant item.
+func (g *GrantItem) Render(width int) string {
+	styles := ListItemStyles{
+		ItemBlurred:     g.t.Dialog.NormalItem,
+		ItemFocused:     g.t.Dialog.SelectedItem,
+		InfoTextBlurred: g.t.Subtle,
+		InfoTextFocused: g.t.Base,
+	}
+	if g.revoking {
+		styles.ItemBlurred = g.t.Dialog.Sessions.DeletingItemBlurred
+		styles.ItemFocused = g.t.Dialog.Sessions.DeletingItemFocused
+	}
+	return renderItem(styles, g.title(), fsext.PrettyPath(g.Path), g.focused, width, g.cache, &g.m)
+}
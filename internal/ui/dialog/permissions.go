@@ -61,7 +61,8 @@ type Permissions struct {
 	fullscreen   bool // true when dialog is fullscreen
 
 	permission     permission.PermissionRequest
-	selectedOption int // 0: Allow, 1: Allow for session, 2: Deny
+	selectedOption int    // 0: Allow, 1: Allow for session, 2: Deny
+	sessionTitle   string // set for requests from background sessions
 
 	viewport      viewport.Model
 	viewportDirty bool // true when viewport content needs to be re-rendered
@@ -175,6 +176,14 @@ func WithDiffMode(split bool) PermissionsOption {
 	}
 }
 
+// WithSessionTitle shows the title of the session the request comes from, for
+// requests from sessions other than the one on screen.
+func WithSessionTitle(title string) PermissionsOption {
+	return func(p *Permissions) {
+		p.sessionTitle = title
+	}
+}
+
 // NewPermissions creates a new permissions dialog.
 func NewPermissions(com *common.Common, perm permission.PermissionRequest, opts ...PermissionsOption) *Permissions {
 	h := help.New()
@@ -450,7 +459,11 @@ func (p *Permissions) renderHeader(contentWidth int) string {
 	toolLine := p.renderToolName(contentWidth)
 	pathLine := p.renderKeyValue("Path", fsext.PrettyPath(p.permission.Path), contentWidth)
 
-	lines := []string{title, "", toolLine, pathLine}
+	lines := []string{title, ""}
+	if p.sessionTitle != "" {
+		lines = append(lines, p.renderKeyValue("Session", p.sessionTitle, contentWidth))
+	}
+	lines = append(lines, toolLine, pathLine)
 
 	// Add tool-specific header info.
 	switch p.permission.ToolName {
@@ -484,6 +497,10 @@ func (p *Permissions) renderHeader(contentWidth int) string {
 		}
 	}
 
+	if p.permission.MatchedRule != "" {
+		lines = append(lines, p.renderKeyValue("Rule", p.permission.MatchedRule, contentWidth))
+	}
+
 	return lipgloss.JoinVertical(lipgloss.Left, lines...)
 }
 
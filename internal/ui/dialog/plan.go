@@ -0,0 +1,152 @@
+package dialog
+
+import (
+	"fmt"
+	"strings"
+
+	"charm.land/bubbles/v2/key"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/ui/common"
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+// PlanID is the identifier for the plan approval dialog.
+const PlanID = "plan"
+
+// maxPlanWidth is the maximum width of the plan in the dialog.
+const maxPlanWidth = 80
+
+// Plan represents a dialog to approve the plan the agent wrote in plan mode.
+type Plan struct {
+	com        *common.Common
+	sessionID  string
+	todos      []session.Todo
+	selectedNo bool // true if "Keep Planning" is selected
+	keyMap     struct {
+		LeftRight,
+		EnterSpace,
+		Yes,
+		No,
+		Tab,
+		Close key.Binding
+	}
+}
+
+var _ Dialog = (*Plan)(nil)
+
+// NewPlan creates a new plan approval dialog for the plan of the given
+// session.
+func NewPlan(com *common.Common, sessionID string, todos []session.Todo) *Plan {
+	p := &Plan{
+		com:       com,
+		sessionID: sessionID,
+		todos:     todos,
+	}
+	p.keyMap.LeftRight = key.NewBinding(
+		key.WithKeys("left", "right"),
+		key.WithHelp("←/→", "switch options"),
+	)
+	p.keyMap.EnterSpace = key.NewBinding(
+		key.WithKeys("enter", " "),
+		key.WithHelp("enter/space", "confirm"),
+	)
+	p.keyMap.Yes = key.NewBinding(
+		key.WithKeys("y", "Y"),
+		key.WithHelp("y/Y", "approve"),
+	)
+	p.keyMap.No = key.NewBinding(
+		key.WithKeys("n", "N"),
+		key.WithHelp("n/N", "keep planning"),
+	)
+	p.keyMap.Tab = key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "switch options"),
+	)
+	p.keyMap.Close = CloseKey
+	return p
+}
+
+// ID implements [Model].
+func (*Plan) ID() string {
+	return PlanID
+}
+
+// HandleMsg implements [Model].
+func (p *Plan) HandleMsg(msg tea.Msg) Action {
+	switch msg := msg.(type) {
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, p.keyMap.Close):
+			return ActionClose{}
+		case key.Matches(msg, p.keyMap.LeftRight, p.keyMap.Tab):
+			p.selectedNo = !p.selectedNo
+		case key.Matches(msg, p.keyMap.EnterSpace):
+			if !p.selectedNo {
+				return ActionApprovePlan{SessionID: p.sessionID}
+			}
+			return ActionClose{}
+		case key.Matches(msg, p.keyMap.Yes):
+			return ActionApprovePlan{SessionID: p.sessionID}
+		case key.Matches(msg, p.keyMap.No):
+			return ActionClose{}
+		}
+	}
+
+	return nil
+}
+
+// Draw implements [Dialog].
+func (p *Plan) Draw(scr uv.Screen, area uv.Rectangle) *tea.Cursor {
+	const (
+		question = "Carry out this plan?"
+		detail   = "Keep planning to tell the agent what to change."
+	)
+	baseStyle := p.com.Styles.Base
+
+	steps := make([]string, len(p.todos))
+	for i, todo := range p.todos {
+		steps[i] = fmt.Sprintf("%d. %s", i+1, todo.Content)
+	}
+	width := min(maxPlanWidth, area.Dx()-4)
+	plan := baseStyle.Width(width).Render(strings.Join(steps, "\n"))
+
+	buttonOpts := []common.ButtonOpts{
+		{Text: "Approve", Selected: !p.selectedNo, Padding: 3},
+		{Text: "Keep Planning", Selected: p.selectedNo, Padding: 3},
+	}
+	buttons := common.ButtonGroup(p.com.Styles, buttonOpts, " ")
+	content := baseStyle.Render(
+		lipgloss.JoinVertical(
+			lipgloss.Center,
+			question,
+			"",
+			plan,
+			"",
+			p.com.Styles.Subtle.Render(detail),
+			"",
+			buttons,
+		),
+	)
+
+	view := p.com.Styles.BorderFocus.Render(content)
+	DrawCenter(scr, area, view)
+	return nil
+}
+
+// ShortHelp implements [help.KeyMap].
+func (p *Plan) ShortHelp() []key.Binding {
+	return []key.Binding{
+		p.keyMap.LeftRight,
+		p.keyMap.EnterSpace,
+	}
+}
+
+// FullHelp implements [help.KeyMap].
+func (p *Plan) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{p.keyMap.LeftRight, p.keyMap.EnterSpace, p.keyMap.Yes, p.keyMap.No},
+		{p.keyMap.Tab, p.keyMap.Close},
+	}
+}
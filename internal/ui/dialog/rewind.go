@@ -0,0 +1,142 @@
+package dialog
+
+import (
+	"charm.land/bubbles/v2/key"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/crush/internal/ui/common"
+	uv "github.com/charmbracelet/ultraviolet"
+)
+
+// RewindID is the identifier for the rewind dialog.
+const RewindID = "rewind"
+
+// Rewind represents a confirmation dialog for rewinding a session to a
+// checkpoint.
+type Rewind struct {
+	com        *common.Common
+	sessionID  string
+	messageID  string
+	selectedNo bool // true if "No" button is selected
+	keyMap     struct {
+		LeftRight,
+		EnterSpace,
+		Yes,
+		No,
+		Tab,
+		Close key.Binding
+	}
+}
+
+var _ Dialog = (*Rewind)(nil)
+
+// NewRewind creates a new rewind confirmation dialog for the given message.
+func NewRewind(com *common.Common, sessionID, messageID string) *Rewind {
+	r := &Rewind{
+		com:        com,
+		sessionID:  sessionID,
+		messageID:  messageID,
+		selectedNo: true,
+	}
+	r.keyMap.LeftRight = key.NewBinding(
+		key.WithKeys("left", "right"),
+		key.WithHelp("←/→", "switch options"),
+	)
+	r.keyMap.EnterSpace = key.NewBinding(
+		key.WithKeys("enter", " "),
+		key.WithHelp("enter/space", "confirm"),
+	)
+	r.keyMap.Yes = key.NewBinding(
+		key.WithKeys("y", "Y"),
+		key.WithHelp("y/Y", "yes"),
+	)
+	r.keyMap.No = key.NewBinding(
+		key.WithKeys("n", "N"),
+		key.WithHelp("n/N", "no"),
+	)
+	r.keyMap.Tab = key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "switch options"),
+	)
+	r.keyMap.Close = CloseKey
+	return r
+}
+
+// ID implements [Model].
+func (*Rewind) ID() string {
+	return RewindID
+}
+
+// HandleMsg implements [Model].
+func (r *Rewind) HandleMsg(msg tea.Msg) Action {
+	switch msg := msg.(type) {
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, r.keyMap.Close):
+			return ActionClose{}
+		case key.Matches(msg, r.keyMap.LeftRight, r.keyMap.Tab):
+			r.selectedNo = !r.selectedNo
+		case key.Matches(msg, r.keyMap.EnterSpace):
+			if !r.selectedNo {
+				return r.action()
+			}
+			return ActionClose{}
+		case key.Matches(msg, r.keyMap.Yes):
+			return r.action()
+		case key.Matches(msg, r.keyMap.No):
+			return ActionClose{}
+		}
+	}
+
+	return nil
+}
+
+func (r *Rewind) action() Action {
+	return ActionRewind{
+		SessionID: r.sessionID,
+		MessageID: r.messageID,
+	}
+}
+
+// Draw implements [Dialog].
+func (r *Rewind) Draw(scr uv.Screen, area uv.Rectangle) *tea.Cursor {
+	const (
+		question = "Rewind the session to before this message?"
+		detail   = "Files changed since then will be restored."
+	)
+	baseStyle := r.com.Styles.Base
+	buttonOpts := []common.ButtonOpts{
+		{Text: "Rewind", Selected: !r.selectedNo, Padding: 3},
+		{Text: "Cancel", Selected: r.selectedNo, Padding: 3},
+	}
+	buttons := common.ButtonGroup(r.com.Styles, buttonOpts, " ")
+	content := baseStyle.Render(
+		lipgloss.JoinVertical(
+			lipgloss.Center,
+			question,
+			r.com.Styles.Subtle.Render(detail),
+			"",
+			buttons,
+		),
+	)
+
+	view := r.com.Styles.BorderFocus.Render(content)
+	DrawCenter(scr, area, view)
+	return nil
+}
+
+// ShortHelp implements [help.KeyMap].
+func (r *Rewind) ShortHelp() []key.Binding {
+	return []key.Binding{
+		r.keyMap.LeftRight,
+		r.keyMap.EnterSpace,
+	}
+}
+
+// FullHelp implements [help.KeyMap].
+func (r *Rewind) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{r.keyMap.LeftRight, r.keyMap.EnterSpace, r.keyMap.Yes, r.keyMap.No},
+		{r.keyMap.Tab, r.keyMap.Close},
+	}
+}
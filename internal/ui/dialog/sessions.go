@@ -28,6 +28,36 @@ const (
 	sessionsModeUpdating
 )
 
+// SessionStatus is the status of the agent in a session.
+type SessionStatus uint8
+
+// Possible statuses of the agent in a session
+const (
+	SessionStatusIdle SessionStatus = iota
+	SessionStatusRunning
+	SessionStatusWaiting
+	SessionStatusDone
+	SessionStatusError
+	SessionStatusOverBudget
+)
+
+// String returns the label of the status shown next to sessions.
+func (s SessionStatus) String() string {
+	switch s {
+	case SessionStatusRunning:
+		return "running"
+	case SessionStatusWaiting:
+		return "needs permission"
+	case SessionStatusDone:
+		return "done"
+	case SessionStatusError:
+		return "error"
+	case SessionStatusOverBudget:
+		return "over budget"
+	}
+	return ""
+}
+
 // Session is a session selector dialog.
 type Session struct {
 	com                *common.Common
@@ -36,6 +66,7 @@ type Session struct {
 	input              textinput.Model
 	selectedSessionInx int
 	sessions           []session.Session
+	statuses           map[string]SessionStatus
 
 	sessionsMode sessionsMode
 
@@ -56,16 +87,20 @@ type Session struct {
 
 var _ Dialog = (*Session)(nil)
 
-// NewSessions creates a new Session dialog.
-func NewSessions(com *common.Common, selectedSessionID string) (*Session, error) {
+// NewSessions creates a new Session dialog. statuses holds the status of the
+// agent in each session by ID; the dialog shows changes made to it while it
+// is open.
+func NewSessions(com *common.Common, selectedSessionID string, statuses map[string]SessionStatus) (*Session, error) {
 	s := new(Session)
 	s.sessionsMode = sessionsModeNormal
 	s.com = com
+	s.statuses = statuses
 	sessions, err := com.App.Sessions.List(context.TODO())
 	if err != nil {
 		return nil, err
 	}
 
+	sessions = sessionTree(sessions)
 	s.sessions = sessions
 	for i, sess := range sessions {
 		if sess.ID == selectedSessionID {
@@ -78,7 +113,7 @@ func NewSessions(com *common.Common, selectedSessionID string) (*Session, error)
 	help.Styles = com.Styles.DialogHelpStyles()
 
 	s.help = help
-	s.list = list.NewFilterableList(sessionItems(com.Styles, sessionsModeNormal, sessions...)...)
+	s.list = list.NewFilterableList(sessionItems(com.Styles, sessionsModeNormal, statuses, sessions...)...)
 	s.list.Focus()
 	s.list.SetSelected(s.selectedSessionInx)
 
@@ -147,23 +182,23 @@ func (s *Session) HandleMsg(msg tea.Msg) Action {
 			switch {
 			case key.Matches(msg, s.keyMap.ConfirmDelete):
 				action := s.confirmDeleteSession()
-				s.list.SetItems(sessionItems(s.com.Styles, sessionsModeNormal, s.sessions...)...)
+				s.list.SetItems(sessionItems(s.com.Styles, sessionsModeNormal, s.statuses, s.sessions...)...)
 				s.list.SelectFirst()
 				s.list.ScrollToSelected()
 				return action
 			case key.Matches(msg, s.keyMap.CancelDelete):
 				s.sessionsMode = sessionsModeNormal
-				s.list.SetItems(sessionItems(s.com.Styles, sessionsModeNormal, s.sessions...)...)
+				s.list.SetItems(sessionItems(s.com.Styles, sessionsModeNormal, s.statuses, s.sessions...)...)
 			}
 		case sessionsModeUpdating:
 			switch {
 			case key.Matches(msg, s.keyMap.ConfirmRename):
 				action := s.confirmRenameSession()
-				s.list.SetItems(sessionItems(s.com.Styles, sessionsModeNormal, s.sessions...)...)
+				s.list.SetItems(sessionItems(s.com.Styles, sessionsModeNormal, s.statuses, s.sessions...)...)
 				return action
 			case key.Matches(msg, s.keyMap.CancelRename):
 				s.sessionsMode = sessionsModeNormal
-				s.list.SetItems(sessionItems(s.com.Styles, sessionsModeNormal, s.sessions...)...)
+				s.list.SetItems(sessionItems(s.com.Styles, sessionsModeNormal, s.statuses, s.sessions...)...)
 			default:
 				item := s.list.SelectedItem()
 				if item == nil {
@@ -179,13 +214,13 @@ func (s *Session) HandleMsg(msg tea.Msg) Action {
 				return ActionClose{}
 			case key.Matches(msg, s.keyMap.Rename):
 				s.sessionsMode = sessionsModeUpdating
-				s.list.SetItems(sessionItems(s.com.Styles, sessionsModeUpdating, s.sessions...)...)
+				s.list.SetItems(sessionItems(s.com.Styles, sessionsModeUpdating, s.statuses, s.sessions...)...)
 			case key.Matches(msg, s.keyMap.Delete):
 				if s.isCurrentSessionBusy() {
 					return ActionCmd{util.ReportWarn("Agent is busy, please wait...")}
 				}
 				s.sessionsMode = sessionsModeDeleting
-				s.list.SetItems(sessionItems(s.com.Styles, sessionsModeDeleting, s.sessions...)...)
+				s.list.SetItems(sessionItems(s.com.Styles, sessionsModeDeleting, s.statuses, s.sessions...)...)
 			case key.Matches(msg, s.keyMap.Previous):
 				s.list.Focus()
 				if s.list.IsSelectedFirst() {
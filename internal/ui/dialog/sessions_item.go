@@ -36,6 +36,12 @@ type SessionItem struct {
 	cache            map[int]string
 	updateTitleInput textinput.Model
 	focused          bool
+	// depth is how deep the session is in the tree of forks.
+	depth int
+	// statuses is shared with the dialog, and status is the status the item
+	// was last rendered with.
+	statuses map[string]SessionStatus
+	status   SessionStatus
 }
 
 var _ ListItem = &SessionItem{}
@@ -75,7 +81,14 @@ func (s *SessionItem) Cursor() *tea.Cursor {
 
 // Render returns the string representation of the session item.
 func (s *SessionItem) Render(width int) string {
+	if status := s.statuses[s.ID()]; status != s.status {
+		s.cache = nil
+		s.status = status
+	}
 	info := humanize.Time(time.Unix(s.UpdatedAt, 0))
+	if s.status != SessionStatusIdle {
+		info = s.status.String() + " · " + info
+	}
 	styles := ListItemStyles{
 		ItemBlurred:     s.t.Dialog.NormalItem,
 		ItemFocused:     s.t.Dialog.SelectedItem,
@@ -98,7 +111,17 @@ func (s *SessionItem) Render(width int) string {
 		}
 	}
 
-	return renderItem(styles, s.Title, info, s.focused, width, s.cache, &s.m)
+	title, m := s.Title, s.m
+	if s.depth > 0 {
+		// Show forks under the session they were forked from.
+		prefix := strings.Repeat("  ", s.depth-1) + "↳ "
+		title = prefix + title
+		m.MatchedIndexes = make([]int, len(s.m.MatchedIndexes))
+		for i, idx := range s.m.MatchedIndexes {
+			m.MatchedIndexes[i] = idx + len(prefix)
+		}
+	}
+	return renderItem(styles, title, info, s.focused, width, s.cache, &m)
 }
 
 type ListItemStyles struct {
@@ -184,10 +207,15 @@ func (s *SessionItem) SetFocused(focused bool) {
 
 // sessionItems takes a slice of [session.Session]s and convert them to a slice
 // of [ListItem]s.
-func sessionItems(t *styles.Styles, mode sessionsMode, sessions ...session.Session) []list.FilterableItem {
+func sessionItems(t *styles.Styles, mode sessionsMode, statuses map[string]SessionStatus, sessions ...session.Session) []list.FilterableItem {
 	items := make([]list.FilterableItem, len(sessions))
+	depths := make(map[string]int, len(sessions))
 	for i, s := range sessions {
-		item := &SessionItem{Session: s, t: t, sessionsMode: mode}
+		item := &SessionItem{Session: s, t: t, sessionsMode: mode, statuses: statuses}
+		if parentDepth, ok := depths[s.ParentSessionID]; ok && s.ForkMessageID != "" {
+			item.depth = parentDepth + 1
+		}
+		depths[s.ID] = item.depth
 		if mode == sessionsModeUpdating {
 			item.updateTitleInput = textinput.New()
 			item.updateTitleInput.SetVirtualCursor(false)
@@ -202,6 +230,38 @@ func sessionItems(t *styles.Styles, mode sessionsMode, sessions ...session.Sessi
 	return items
 }
 
+// sessionTree orders sessions so that forks follow the session they were
+// forked from. Sessions keep their order otherwise, and forks of sessions
+// that are not listed stay at the top level.
+func sessionTree(sessions []session.Session) []session.Session {
+	listed := make(map[string]bool, len(sessions))
+	for _, s := range sessions {
+		listed[s.ID] = true
+	}
+	var roots []session.Session
+	forks := make(map[string][]session.Session)
+	for _, s := range sessions {
+		if s.ForkMessageID != "" && listed[s.ParentSessionID] {
+			forks[s.ParentSessionID] = append(forks[s.ParentSessionID], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+
+	ordered := make([]session.Session, 0, len(sessions))
+	var walk func(s session.Session)
+	walk = func(s session.Session) {
+		ordered = append(ordered, s)
+		for _, fork := range forks[s.ID] {
+			walk(fork)
+		}
+	}
+	for _, s := range roots {
+		walk(s)
+	}
+	return ordered
+}
+
 func matchedRanges(in []int) [][2]int {
 	if len(in) == 0 {
 		return [][2]int{}
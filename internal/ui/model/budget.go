@@ -0,0 +1,41 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/agent"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/ui/util"
+)
+
+// budgetUsage returns how much of the budgets of a session was spent.
+func (m *UI) budgetUsage(sessionID string) []agent.BudgetUsage {
+	usage, err := m.com.App.AgentCoordinator.BudgetUsage(context.Background(), sessionID)
+	if err != nil {
+		slog.Error("Failed to get budget usage", "session_id", sessionID, "error", err)
+	}
+	return usage
+}
+
+// budgetWarning warns about the first budget that is running low.
+func (m *UI) budgetWarning(usage []agent.BudgetUsage) tea.Cmd {
+	threshold := m.com.Config().Options.Budget.WarnThreshold()
+	for _, u := range usage {
+		if u.Fraction() >= threshold {
+			return util.ReportWarn(fmt.Sprintf("The agent spent $%.2f of the $%.2f %s budget", u.Spent, u.Limit, u.Kind))
+		}
+	}
+	return nil
+}
+
+// raiseBudget raises the limit of a budget the agent used up, so it can
+// carry on.
+func (m *UI) raiseBudget(kind config.BudgetKind, limit float64) tea.Cmd {
+	if err := m.com.Config().SetBudget(kind, limit); err != nil {
+		return util.ReportError(err)
+	}
+	return util.ReportInfo(fmt.Sprintf("Raised the %s budget to $%.2f, send a prompt to carry on", kind, limit))
+}
@@ -479,6 +479,18 @@ func (m *Chat) MessageItem(id string) chat.MessageItem {
 	return item
 }
 
+// SelectedMessageID returns the ID of the selected user or assistant message,
+// or an empty string if the selected item is not one.
+func (m *Chat) SelectedMessageID() string {
+	switch item := m.list.SelectedItem().(type) {
+	case *chat.UserMessageItem:
+		return item.ID()
+	case *chat.AssistantMessageItem:
+		return item.ID()
+	}
+	return ""
+}
+
 // ToggleExpandedSelectedItem expands the selected message item if it is expandable.
 func (m *Chat) ToggleExpandedSelectedItem() {
 	if expandable, ok := m.list.SelectedItem().(chat.Expandable); ok {
@@ -47,6 +47,8 @@ type KeyMap struct {
 		Copy           key.Binding
 		ClearHighlight key.Binding
 		Expand         key.Binding
+		Rewind         key.Binding
+		Fork           key.Binding
 	}
 
 	Initialize struct {
@@ -245,6 +247,14 @@ func DefaultKeyMap() KeyMap {
 		key.WithKeys("space"),
 		key.WithHelp("space", "expand/collapse"),
 	)
+	km.Chat.Rewind = key.NewBinding(
+		key.WithKeys("R"),
+		key.WithHelp("R", "rewind"),
+	)
+	km.Chat.Fork = key.NewBinding(
+		key.WithKeys("F"),
+		key.WithHelp("F", "fork"),
+	)
 	km.Initialize.Yes = key.NewBinding(
 		key.WithKeys("y", "Y"),
 		key.WithHelp("y", "yes"),
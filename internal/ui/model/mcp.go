@@ -1,13 +1,17 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
+	tea "charm.land/bubbletea/v2"
 	"charm.land/lipgloss/v2"
 	"github.com/charmbracelet/crush/internal/agent/tools/mcp"
 	"github.com/charmbracelet/crush/internal/ui/common"
 	"github.com/charmbracelet/crush/internal/ui/styles"
+	"github.com/charmbracelet/crush/internal/ui/util"
+	"github.com/pkg/browser"
 )
 
 // mcpInfo renders the MCP status section showing active MCP clients and their
@@ -16,7 +20,7 @@ func (m *UI) mcpInfo(width, maxItems int, isSection bool) string {
 	var mcps []mcp.ClientInfo
 	t := m.com.Styles
 
-	for _, mcp := range m.com.Config().MCP.Sorted() {
+	for _, mcp := range mcp.Configs(m.com.Config()).Sorted() {
 		if state, ok := m.mcpStates[mcp.Name]; ok {
 			mcps = append(mcps, state)
 		}
@@ -76,6 +80,9 @@ func mcpList(t *styles.Styles, mcps []mcp.ClientInfo, width, maxItems int) strin
 			if m.Error != nil {
 				description = t.ResourceStatus.Render(fmt.Sprintf("error: %s", m.Error.Error()))
 			}
+		case mcp.StateNeedsAuth:
+			icon = t.ResourceBusyIcon.String()
+			description = t.ResourceStatus.Render("needs auth: ctrl+p to authorize")
 		case mcp.StateDisabled:
 			icon = t.ResourceOfflineIcon.Foreground(t.Muted.GetBackground()).String()
 			description = t.ResourceStatus.Render("disabled")
@@ -99,3 +106,24 @@ func mcpList(t *styles.Styles, mcps []mcp.ClientInfo, width, maxItems int) strin
 	}
 	return lipgloss.JoinVertical(lipgloss.Left, renderedMcps...)
 }
+
+// authorizeMCP runs the OAuth authorization flow of an MCP server in the
+// browser, and gives the agent its tools once connected.
+func (m *UI) authorizeMCP(name string) tea.Cmd {
+	cfg := m.com.Config()
+	return tea.Batch(
+		util.ReportInfo(fmt.Sprintf("Authorize %s in your browser to connect to it", name)),
+		func() tea.Msg {
+			ctx := context.Background()
+			if err := mcp.Authorize(ctx, cfg, name, browser.OpenURL); err != nil {
+				return util.NewErrorMsg(fmt.Errorf("failed to authorize %s: %w", name, err))
+			}
+			if m.com.App.AgentCoordinator != nil {
+				if err := m.com.App.AgentCoordinator.UpdateModels(ctx); err != nil {
+					return util.NewErrorMsg(err)
+				}
+			}
+			return util.NewInfoMsg(fmt.Sprintf("Authorized %s", name))
+		},
+	)
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"context"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/ui/dialog"
+	"github.com/charmbracelet/crush/internal/ui/util"
+)
+
+// togglePlanMode turns plan mode on or off for the prompts sent from now on.
+func (m *UI) togglePlanMode() tea.Cmd {
+	m.planMode = !m.planMode
+	if m.hasSession() {
+		m.com.App.AgentCoordinator.SetPlanMode(m.session.ID, m.planMode)
+	}
+	if m.planMode {
+		return util.ReportInfo("Plan mode enabled: the agent will plan with read-only tools")
+	}
+	return util.ReportInfo("Plan mode disabled")
+}
+
+// openPlanDialog asks the user to approve the plan the agent wrote for the
+// current session.
+func (m *UI) openPlanDialog(sessionID string) tea.Cmd {
+	if !m.hasSession() || m.session.ID != sessionID {
+		return nil
+	}
+	sess, err := m.com.App.Sessions.Get(context.Background(), sessionID)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	if len(sess.Todos) == 0 {
+		return util.ReportWarn("The agent wrote no plan, ask it to write one with its todos")
+	}
+	m.dialog.OpenDialog(dialog.NewPlan(m.com, sessionID, sess.Todos))
+	return nil
+}
+
+// approvePlan leaves plan mode and has the agent carry out the plan.
+func (m *UI) approvePlan(sessionID string) tea.Cmd {
+	m.planMode = false
+	if m.hasSession() && m.session.ID == sessionID {
+		m.setSessionStatus(sessionID, m.session.Title, dialog.SessionStatusRunning)
+	}
+	return func() tea.Msg {
+		result, err := m.com.App.AgentCoordinator.ApprovePlan(context.Background(), sessionID)
+		// A nil result means the prompt was queued, so the agent is not done.
+		if err == nil && result == nil {
+			return nil
+		}
+		return agentRunFinishedMsg{sessionID: sessionID, budgets: m.budgetUsage(sessionID), err: err}
+	}
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"path/filepath"
@@ -10,11 +11,15 @@ import (
 
 	tea "charm.land/bubbletea/v2"
 	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/crush/internal/agent"
+	"github.com/charmbracelet/crush/internal/checkpoint"
 	"github.com/charmbracelet/crush/internal/diff"
 	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/session"
 	"github.com/charmbracelet/crush/internal/ui/common"
+	"github.com/charmbracelet/crush/internal/ui/dialog"
 	"github.com/charmbracelet/crush/internal/ui/styles"
 	"github.com/charmbracelet/crush/internal/ui/util"
 	"github.com/charmbracelet/x/ansi"
@@ -28,6 +33,18 @@ type loadSessionMsg struct {
 	readFiles []string
 }
 
+// sessionRewoundMsg is a message indicating that a session has been rewound
+// to a checkpoint.
+type sessionRewoundMsg struct {
+	sessionID string
+	result    checkpoint.RewindResult
+}
+
+// sessionForkedMsg is a message indicating that a session has been forked.
+type sessionForkedMsg struct {
+	session session.Session
+}
+
 // lspFilePaths returns deduplicated file paths from both modified and read
 // files for starting LSP servers.
 func (msg loadSessionMsg) lspFilePaths() []string {
@@ -89,6 +106,32 @@ func (m *UI) loadSession(sessionID string) tea.Cmd {
 	}
 }
 
+// rewindSession rewinds the session to the checkpoint right before the given
+// message.
+func (m *UI) rewindSession(sessionID, messageID string) tea.Cmd {
+	return func() tea.Msg {
+		result, err := m.com.App.Checkpoints.Rewind(context.Background(), sessionID, messageID)
+		if err != nil {
+			return util.ReportError(err)()
+		}
+		return sessionRewoundMsg{
+			sessionID: sessionID,
+			result:    result,
+		}
+	}
+}
+
+// forkSession forks the session at the given message.
+func (m *UI) forkSession(sessionID, messageID string) tea.Cmd {
+	return func() tea.Msg {
+		fork, err := m.com.App.Sessions.Fork(context.Background(), sessionID, messageID)
+		if err != nil {
+			return util.ReportError(err)()
+		}
+		return sessionForkedMsg{session: fork}
+	}
+}
+
 func (m *UI) loadSessionFiles(sessionID string) ([]SessionFile, error) {
 	files, err := m.com.App.History.ListBySession(context.Background(), sessionID)
 	if err != nil {
@@ -246,3 +289,144 @@ func (m *UI) startLSPs(paths []string) tea.Cmd {
 		return nil
 	}
 }
+
+// agentRunFinishedMsg is sent when the agent is done with the prompts of a
+// session, whether the session is on screen or in the background.
+type agentRunFinishedMsg struct {
+	sessionID string
+	// planReady is set when the agent wrote a plan in plan mode, so it can
+	// be approved.
+	planReady bool
+	// budgets is how much of the budgets of the session was spent, to warn
+	// when they run low.
+	budgets []agent.BudgetUsage
+	err     error
+}
+
+// handleAgentRunFinished records how the agent finished in a session and
+// reports it. Sessions in the background are reported by title, and keep
+// their status until the user opens them. Sessions in the background that
+// used up a budget offer to raise it once opened.
+func (m *UI) handleAgentRunFinished(msg agentRunFinishedMsg) tea.Cmd {
+	isCurrent := m.hasSession() && m.session.ID == msg.sessionID
+	title := m.sessionTitles[msg.sessionID]
+
+	isCancelErr := errors.Is(msg.err, context.Canceled)
+	isPermissionErr := errors.Is(msg.err, permission.ErrorPermissionDenied)
+	failed := msg.err != nil && !isCancelErr && !isPermissionErr
+
+	var budgetErr *agent.BudgetError
+	if isCurrent {
+		m.clearSessionStatus(msg.sessionID)
+		switch {
+		case errors.As(msg.err, &budgetErr):
+			m.dialog.OpenDialog(dialog.NewBudget(m.com, budgetErr.Kind, budgetErr.Limit, budgetErr.Spent))
+			return nil
+		case failed:
+			return util.ReportError(msg.err)
+		case msg.planReady:
+			return tea.Batch(m.openPlanDialog(msg.sessionID), m.budgetWarning(msg.budgets))
+		}
+		return m.budgetWarning(msg.budgets)
+	}
+
+	if errors.As(msg.err, &budgetErr) {
+		m.setSessionStatus(msg.sessionID, title, dialog.SessionStatusOverBudget)
+		m.sessionBudgetErrors[msg.sessionID] = budgetErr
+		return util.ReportWarn(fmt.Sprintf("Session %q stopped: %s. Open it to raise the budget", title, budgetErr))
+	}
+	if failed {
+		m.setSessionStatus(msg.sessionID, title, dialog.SessionStatusError)
+		return util.ReportError(fmt.Errorf("session %q failed: %w", title, msg.err))
+	}
+	m.setSessionStatus(msg.sessionID, title, dialog.SessionStatusDone)
+	if msg.err == nil {
+		return util.ReportInfo(fmt.Sprintf("Session %q is done", title))
+	}
+	return nil
+}
+
+// setSessionStatus records the status of the agent in a session.
+func (m *UI) setSessionStatus(sessionID, title string, status dialog.SessionStatus) {
+	m.sessionStatuses[sessionID] = status
+	m.sessionTitles[sessionID] = title
+}
+
+// clearSessionStatus forgets the status of a session, once the agent is done
+// with it and the user has seen it.
+func (m *UI) clearSessionStatus(sessionID string) {
+	delete(m.sessionStatuses, sessionID)
+	delete(m.sessionTitles, sessionID)
+	delete(m.sessionBudgetErrors, sessionID)
+}
+
+// permissionAnswered marks the session of an answered permission request as
+// running again.
+func (m *UI) permissionAnswered(perm permission.PermissionRequest) {
+	sess, err := m.rootSession(perm.SessionID)
+	if err != nil {
+		return
+	}
+	if m.sessionStatuses[sess.ID] == dialog.SessionStatusWaiting {
+		m.sessionStatuses[sess.ID] = dialog.SessionStatusRunning
+	}
+}
+
+// rootSession returns the session with the given ID, or its parent for the
+// sessions of sub-agents, whose requests belong to the session that ran them.
+func (m *UI) rootSession(sessionID string) (session.Session, error) {
+	sess, err := m.com.App.Sessions.Get(context.Background(), sessionID)
+	if err != nil {
+		return session.Session{}, err
+	}
+	if sess.ParentSessionID != "" && m.com.App.Sessions.IsAgentToolSession(sessionID) {
+		return m.com.App.Sessions.Get(context.Background(), sess.ParentSessionID)
+	}
+	return sess, nil
+}
+
+// backgroundSessionsInfo renders the sidebar section listing the other
+// sessions the agent ran in, with their status.
+func (m *UI) backgroundSessionsInfo(width, maxItems int) string {
+	t := m.com.Styles
+
+	var ids []string
+	for id := range m.sessionStatuses {
+		if m.hasSession() && id == m.session.ID {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 || maxItems <= 0 {
+		return ""
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		return strings.Compare(m.sessionTitles[a], m.sessionTitles[b])
+	})
+
+	var rendered []string
+	for _, id := range ids {
+		status := m.sessionStatuses[id]
+		var icon string
+		switch status {
+		case dialog.SessionStatusRunning, dialog.SessionStatusWaiting:
+			icon = t.ResourceBusyIcon.String()
+		case dialog.SessionStatusError, dialog.SessionStatusOverBudget:
+			icon = t.ResourceErrorIcon.String()
+		default:
+			icon = t.ResourceOnlineIcon.String()
+		}
+		rendered = append(rendered, common.Status(t, common.StatusOpts{
+			Icon:        icon,
+			Title:       ansi.Truncate(m.sessionTitles[id], width/2, "…"),
+			Description: status.String(),
+		}, width))
+	}
+
+	if len(rendered) > maxItems {
+		remaining := len(rendered) - maxItems + 1
+		rendered = append(rendered[:maxItems-1], t.ResourceAdditionalText.Render(fmt.Sprintf("…and %d more", remaining)))
+	}
+	title := common.Section(t, "Sessions", width)
+	return lipgloss.NewStyle().Width(width).Render(fmt.Sprintf("%s\n\n%s", title, lipgloss.JoinVertical(lipgloss.Left, rendered...)))
+}
@@ -143,20 +143,19 @@ func (m *UI) drawSidebar(scr uv.Screen, area uv.Rectangle) {
 	mcpSection := m.mcpInfo(width, maxMCPs, true)
 	filesSection := m.filesInfo(m.com.Config().WorkingDir(), width, maxFiles, true)
 
+	sections := []string{sidebarHeader}
+	// Show what the agent is up to in the other sessions, if anything.
+	if sessionsSection := m.backgroundSessionsInfo(width, maxLSPs); sessionsSection != "" {
+		sections = append(sections, sessionsSection, "")
+	}
+	sections = append(sections, filesSection, "", lspSection, "", mcpSection)
+
 	uv.NewStyledString(
 		lipgloss.NewStyle().
 			MaxWidth(width).
 			MaxHeight(height).
 			Render(
-				lipgloss.JoinVertical(
-					lipgloss.Left,
-					sidebarHeader,
-					filesSection,
-					"",
-					lspSection,
-					"",
-					mcpSection,
-				),
+				lipgloss.JoinVertical(lipgloss.Left, sections...),
 			),
 	).Draw(scr, area)
 }
@@ -24,6 +24,7 @@ import (
 	tea "charm.land/bubbletea/v2"
 	"charm.land/catwalk/pkg/catwalk"
 	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/crush/internal/agent"
 	agenttools "github.com/charmbracelet/crush/internal/agent/tools"
 	"github.com/charmbracelet/crush/internal/agent/tools/mcp"
 	"github.com/charmbracelet/crush/internal/app"
@@ -157,6 +158,18 @@ type UI struct {
 	// isCanceling tracks whether the user has pressed escape once to cancel.
 	isCanceling bool
 
+	// planMode tracks whether prompts are sent in plan mode.
+	planMode bool
+
+	// sessionStatuses tracks the status of the agent in each session the
+	// agent ran in, including the ones in the background, and sessionTitles
+	// their titles.
+	sessionStatuses map[string]dialog.SessionStatus
+	sessionTitles   map[string]string
+	// sessionBudgetErrors holds the budgets used up by sessions in the
+	// background, to offer raising them once the sessions are opened.
+	sessionBudgetErrors map[string]*agent.BudgetError
+
 	header *header
 
 	// sendProgressBar instructs the TUI to send progress bar updates to the
@@ -290,6 +303,10 @@ func New(com *common.Common) *UI {
 		todoSpinner: todoSpinner,
 		lspStates:   make(map[string]app.LSPClientInfo),
 		mcpStates:   make(map[string]mcp.ClientInfo),
+
+		sessionStatuses:     make(map[string]dialog.SessionStatus),
+		sessionTitles:       make(map[string]string),
+		sessionBudgetErrors: make(map[string]*agent.BudgetError),
 	}
 
 	status := NewStatus(com, ui)
@@ -396,6 +413,32 @@ func (m *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.sendProgressBar = slices.Contains(msg, "WT_SESSION")
 		}
 		cmds = append(cmds, common.QueryCmd(uv.Environ(msg)))
+	case sessionRewoundMsg:
+		m.textarea.SetValue(msg.result.Prompt)
+		m.textarea.MoveToEnd()
+		m.focus = uiFocusEditor
+		m.chat.Blur()
+		cmds = append(cmds,
+			m.textarea.Focus(),
+			m.loadSession(msg.sessionID),
+			util.ReportInfo(fmt.Sprintf(
+				"Rewound session: %d file(s) restored, %d file(s) deleted",
+				len(msg.result.RestoredFiles),
+				len(msg.result.DeletedFiles),
+			)),
+		)
+	case agentRunFinishedMsg:
+		if cmd := m.handleAgentRunFinished(msg); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+	case sessionForkedMsg:
+		m.focus = uiFocusEditor
+		m.chat.Blur()
+		cmds = append(cmds,
+			m.textarea.Focus(),
+			m.loadSession(msg.session.ID),
+			util.ReportInfo("Forked session, continue from here"),
+		)
 	case loadSessionMsg:
 		if m.forceCompactMode {
 			m.isCompact = true
@@ -403,6 +446,20 @@ func (m *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.setState(uiChat, m.focus)
 		m.session = msg.session
 		m.sessionFiles = msg.files
+		// Each session has its own queue and plan mode.
+		m.isCanceling = false
+		if coordinator := m.com.App.AgentCoordinator; coordinator != nil {
+			m.promptQueue = coordinator.QueuedPrompts(m.session.ID)
+			m.planMode = coordinator.PlanMode(m.session.ID)
+		}
+		switch m.sessionStatuses[m.session.ID] {
+		case dialog.SessionStatusDone, dialog.SessionStatusError:
+			m.clearSessionStatus(m.session.ID)
+		case dialog.SessionStatusOverBudget:
+			budgetErr := m.sessionBudgetErrors[m.session.ID]
+			m.clearSessionStatus(m.session.ID)
+			m.dialog.OpenDialog(dialog.NewBudget(m.com, budgetErr.Kind, budgetErr.Limit, budgetErr.Spent))
+		}
 		cmds = append(cmds, m.startLSPs(msg.lspFilePaths()))
 		msgs, err := m.com.App.Messages.List(context.Background(), m.session.ID)
 		if err != nil {
@@ -472,6 +529,7 @@ func (m *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case pubsub.Event[session.Session]:
 		if msg.Type == pubsub.DeletedEvent {
+			m.clearSessionStatus(msg.Payload.ID)
 			if m.session != nil && m.session.ID == msg.Payload.ID {
 				if cmd := m.newSession(); cmd != nil {
 					cmds = append(cmds, cmd)
@@ -479,6 +537,9 @@ func (m *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			break
 		}
+		if _, ok := m.sessionTitles[msg.Payload.ID]; ok {
+			m.sessionTitles[msg.Payload.ID] = msg.Payload.Title
+		}
 		if m.session != nil && msg.Payload.ID == m.session.ID {
 			prevHasInProgress := hasInProgressTodo(m.session.Todos)
 			m.session = &msg.Payload
@@ -532,11 +593,11 @@ func (m *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			)
 		case mcp.EventPromptsListChanged:
 			return m, handleMCPPromptsEvent(msg.Payload.Name)
-		case mcp.EventToolsListChanged:
-			return m, handleMCPToolsEvent(m.com.Config(), msg.Payload.Name)
 		case mcp.EventResourcesListChanged:
 			return m, handleMCPResourcesEvent(msg.Payload.Name)
 		}
+	case pubsub.Event[mcp.ElicitationRequest]:
+		m.dialog.OpenDialog(dialog.NewElicitation(m.com, msg.Payload))
 	case pubsub.Event[permission.PermissionRequest]:
 		if cmd := m.openPermissionsDialog(msg.Payload); cmd != nil {
 			cmds = append(cmds, cmd)
@@ -764,6 +825,9 @@ func (m *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.com.App.Permissions.SkipRequests() {
 			m.textarea.Placeholder = "Yolo mode!"
 		}
+		if m.planMode && !m.isAgentBusy() {
+			m.textarea.Placeholder = "Plan mode: what should the agent plan?"
+		}
 	}
 
 	// at this point this can only handle [message.Attachment] message, and we
@@ -974,6 +1038,16 @@ func (m *UI) handleClickFocus(msg tea.MouseClickMsg) (cmd tea.Cmd) {
 // when an assistant message is updated it may include updated tool calls as well
 // that is why we need to handle creating/updating each tool call message too
 func (m *UI) updateSessionMessage(msg message.Message) tea.Cmd {
+	// Tool results are updated when they get pruned from the context.
+	if msg.Role == message.Tool {
+		for _, tr := range msg.ToolResults() {
+			if toolItem, ok := m.chat.MessageItem(tr.ToolCallID).(chat.ToolMessageItem); ok {
+				toolItem.SetResult(&tr)
+			}
+		}
+		return nil
+	}
+
 	var cmds []tea.Cmd
 	existingItem := m.chat.MessageItem(msg.ID)
 
@@ -1180,11 +1254,36 @@ func (m *UI) handleDialogMsg(msg tea.Msg) tea.Cmd {
 		m.com.App.Permissions.SetSkipRequests(yolo)
 		m.setEditorPrompt(yolo)
 		m.dialog.CloseDialog(dialog.CommandsID)
-	case dialog.ActionNewSession:
+	case dialog.ActionTogglePlanMode:
+		cmds = append(cmds, m.togglePlanMode())
+		m.dialog.CloseDialog(dialog.CommandsID)
+	case dialog.ActionApprovePlan:
+		m.dialog.CloseDialog(dialog.PlanID)
 		if m.isAgentBusy() {
-			cmds = append(cmds, util.ReportWarn("Agent is busy, please wait before starting a new session..."))
+			cmds = append(cmds, util.ReportWarn("Agent is busy, please wait before approving the plan..."))
 			break
 		}
+		cmds = append(cmds, m.approvePlan(msg.SessionID))
+	case dialog.ActionRaiseBudget:
+		m.dialog.CloseDialog(dialog.BudgetID)
+		cmds = append(cmds, m.raiseBudget(msg.Kind, msg.Limit))
+	case dialog.ActionAcceptElicitation:
+		err := mcp.AcceptElicitation(msg.ID, msg.Values)
+		if errors.Is(err, mcp.ErrElicitationDone) {
+			m.dialog.CloseFrontDialog()
+		}
+		if err != nil {
+			cmds = append(cmds, util.ReportWarn(err.Error()))
+			break
+		}
+		m.dialog.CloseFrontDialog()
+	case dialog.ActionCancelElicitation:
+		mcp.CancelElicitation(msg.ID)
+		m.dialog.CloseFrontDialog()
+	case dialog.ActionAuthorizeMCP:
+		m.dialog.CloseDialog(dialog.CommandsID)
+		cmds = append(cmds, m.authorizeMCP(msg.Name))
+	case dialog.ActionNewSession:
 		if cmd := m.newSession(); cmd != nil {
 			cmds = append(cmds, cmd)
 		}
@@ -1202,6 +1301,25 @@ func (m *UI) handleDialogMsg(msg tea.Msg) tea.Cmd {
 			return nil
 		})
 		m.dialog.CloseDialog(dialog.CommandsID)
+	case dialog.ActionSwitchAgent:
+		if m.isAnyAgentBusy() {
+			cmds = append(cmds, util.ReportWarn("Agent is busy, please wait before switching agents..."))
+			break
+		}
+		cmds = append(cmds, func() tea.Msg {
+			if err := m.com.App.AgentCoordinator.SetMainAgent(context.Background(), msg.AgentID); err != nil {
+				return util.ReportError(err)()
+			}
+			return util.NewInfoMsg("Switched to " + m.com.App.CurrentAgent().Name + " agent")
+		})
+		m.dialog.CloseDialog(dialog.CommandsID)
+	case dialog.ActionRewind:
+		m.dialog.CloseDialog(dialog.RewindID)
+		if m.isAgentBusy() {
+			cmds = append(cmds, util.ReportWarn("Agent is busy, please wait before rewinding the session..."))
+			break
+		}
+		cmds = append(cmds, m.rewindSession(msg.SessionID, msg.MessageID))
 	case dialog.ActionToggleHelp:
 		m.status.ToggleHelp()
 		m.dialog.CloseDialog(dialog.CommandsID)
@@ -1227,9 +1345,9 @@ func (m *UI) handleDialogMsg(msg tea.Msg) tea.Cmd {
 				return util.ReportError(errors.New("configuration not found"))()
 			}
 
-			agentCfg, ok := cfg.Agents[config.AgentCoder]
-			if !ok {
-				return util.ReportError(errors.New("agent configuration not found"))()
+			agentCfg := m.com.App.CurrentAgent()
+			if _, ok := cfg.Models[agentCfg.Model]; !ok {
+				return util.ReportError(errors.New("agent model not found"))()
 			}
 
 			currentModel := cfg.Models[agentCfg.Model]
@@ -1256,7 +1374,7 @@ func (m *UI) handleDialogMsg(msg tea.Msg) tea.Cmd {
 		m.dialog.CloseDialog(dialog.CommandsID)
 
 	case dialog.ActionSelectModel:
-		if m.isAgentBusy() {
+		if m.isAnyAgentBusy() {
 			cmds = append(cmds, util.ReportWarn("Agent is busy, please wait..."))
 			break
 		}
@@ -1318,7 +1436,7 @@ func (m *UI) handleDialogMsg(msg tea.Msg) tea.Cmd {
 			}
 		}
 	case dialog.ActionSelectReasoningEffort:
-		if m.isAgentBusy() {
+		if m.isAnyAgentBusy() {
 			cmds = append(cmds, util.ReportWarn("Agent is busy, please wait..."))
 			break
 		}
@@ -1329,9 +1447,9 @@ func (m *UI) handleDialogMsg(msg tea.Msg) tea.Cmd {
 			break
 		}
 
-		agentCfg, ok := cfg.Agents[config.AgentCoder]
-		if !ok {
-			cmds = append(cmds, util.ReportError(errors.New("agent configuration not found")))
+		agentCfg := m.com.App.CurrentAgent()
+		if _, ok := cfg.Models[agentCfg.Model]; !ok {
+			cmds = append(cmds, util.ReportError(errors.New("agent model not found")))
 			break
 		}
 
@@ -1349,6 +1467,7 @@ func (m *UI) handleDialogMsg(msg tea.Msg) tea.Cmd {
 		m.dialog.CloseDialog(dialog.ReasoningID)
 	case dialog.ActionPermissionResponse:
 		m.dialog.CloseDialog(dialog.PermissionsID)
+		m.permissionAnswered(msg.Permission)
 		switch msg.Action {
 		case dialog.PermissionAllow:
 			m.com.App.Permissions.Grant(msg.Permission)
@@ -1500,7 +1619,7 @@ func (m *UI) handleKeyPressMsg(msg tea.KeyPressMsg) tea.Cmd {
 				return true
 			}
 		case key.Matches(msg, m.keyMap.Suspend):
-			if m.isAgentBusy() {
+			if m.isAnyAgentBusy() {
 				cmds = append(cmds, util.ReportWarn("Agent is busy, please wait..."))
 				return true
 			}
@@ -1607,10 +1726,6 @@ func (m *UI) handleKeyPressMsg(msg tea.KeyPressMsg) tea.Cmd {
 				if !m.hasSession() {
 					break
 				}
-				if m.isAgentBusy() {
-					cmds = append(cmds, util.ReportWarn("Agent is busy, please wait before starting a new session..."))
-					break
-				}
 				if cmd := m.newSession(); cmd != nil {
 					cmds = append(cmds, cmd)
 				}
@@ -1722,16 +1837,32 @@ func (m *UI) handleKeyPressMsg(msg tea.KeyPressMsg) tea.Cmd {
 				if !m.hasSession() {
 					break
 				}
-				if m.isAgentBusy() {
-					cmds = append(cmds, util.ReportWarn("Agent is busy, please wait before starting a new session..."))
-					break
-				}
 				m.focus = uiFocusEditor
 				if cmd := m.newSession(); cmd != nil {
 					cmds = append(cmds, cmd)
 				}
 			case key.Matches(msg, m.keyMap.Chat.Expand):
 				m.chat.ToggleExpandedSelectedItem()
+			case key.Matches(msg, m.keyMap.Chat.Rewind):
+				messageID := m.chat.SelectedMessageID()
+				if !m.hasSession() || messageID == "" {
+					break
+				}
+				if m.isAgentBusy() {
+					cmds = append(cmds, util.ReportWarn("Agent is busy, please wait before rewinding the session..."))
+					break
+				}
+				m.dialog.OpenDialog(dialog.NewRewind(m.com, m.session.ID, messageID))
+			case key.Matches(msg, m.keyMap.Chat.Fork):
+				messageID := m.chat.SelectedMessageID()
+				if !m.hasSession() || messageID == "" {
+					break
+				}
+				if m.isAgentBusy() {
+					cmds = append(cmds, util.ReportWarn("Agent is busy, please wait before forking the session..."))
+					break
+				}
+				cmds = append(cmds, m.forkSession(m.session.ID, messageID))
 			case key.Matches(msg, m.keyMap.Chat.Up):
 				if cmd := m.chat.ScrollByAndAnimate(-1); cmd != nil {
 					cmds = append(cmds, cmd)
@@ -1966,7 +2097,7 @@ func (m *UI) View() tea.View {
 	content = strings.Join(contentLines, "\n")
 
 	v.Content = content
-	if m.progressBarEnabled && m.sendProgressBar && m.isAgentBusy() {
+	if m.progressBarEnabled && m.sendProgressBar && m.isAnyAgentBusy() {
 		// HACK: use a random percentage to prevent ghostty from hiding it
 		// after a timeout.
 		v.ProgressBar = tea.NewProgressBar(tea.ProgressBarIndeterminate, rand.Intn(100))
@@ -2136,6 +2267,8 @@ func (m *UI) FullHelp() [][]key.Binding {
 				[]key.Binding{
 					k.Chat.Copy,
 					k.Chat.ClearHighlight,
+					k.Chat.Rewind,
+					k.Chat.Fork,
 				},
 			)
 			if m.pillsExpanded && hasIncompleteTodos(m.session.Todos) && m.promptQueue > 0 {
@@ -2641,8 +2774,19 @@ func isWhitespace(b byte) bool {
 }
 
 // isAgentBusy returns true if the agent coordinator exists and is currently
-// busy processing a request.
+// busy processing a request in the current session. Other sessions may keep
+// running in the background.
 func (m *UI) isAgentBusy() bool {
+	return m.hasSession() &&
+		m.com.App != nil &&
+		m.com.App.AgentCoordinator != nil &&
+		m.com.App.AgentCoordinator.IsSessionBusy(m.session.ID)
+}
+
+// isAnyAgentBusy returns true if the agent coordinator is busy processing a
+// request in any session. Changes that affect all sessions, like switching
+// models, have to wait for it.
+func (m *UI) isAnyAgentBusy() bool {
 	return m.com.App != nil &&
 		m.com.App.AgentCoordinator != nil &&
 		m.com.App.AgentCoordinator.IsBusy()
@@ -2733,20 +2877,20 @@ func (m *UI) sendMessage(content string, attachments ...message.Attachment) tea.
 
 	// Capture session ID to avoid race with main goroutine updating m.session.
 	sessionID := m.session.ID
+	m.com.App.AgentCoordinator.SetPlanMode(sessionID, m.planMode)
+	m.setSessionStatus(sessionID, m.session.Title, dialog.SessionStatusRunning)
 	cmds = append(cmds, func() tea.Msg {
-		_, err := m.com.App.AgentCoordinator.Run(context.Background(), sessionID, content, attachments...)
-		if err != nil {
-			isCancelErr := errors.Is(err, context.Canceled)
-			isPermissionErr := errors.Is(err, permission.ErrorPermissionDenied)
-			if isCancelErr || isPermissionErr {
-				return nil
-			}
-			return util.InfoMsg{
-				Type: util.InfoTypeError,
-				Msg:  err.Error(),
-			}
+		result, err := m.com.App.AgentCoordinator.Run(context.Background(), sessionID, content, attachments...)
+		// A nil result means the prompt was queued, so the agent is not done.
+		if err == nil && result == nil {
+			return nil
+		}
+		return agentRunFinishedMsg{
+			sessionID: sessionID,
+			planReady: err == nil && m.com.App.AgentCoordinator.PlanMode(sessionID),
+			budgets:   m.budgetUsage(sessionID),
+			err:       err,
 		}
-		return nil
 	})
 	return tea.Batch(cmds...)
 }
@@ -2814,6 +2958,10 @@ func (m *UI) openDialog(id string) tea.Cmd {
 		if cmd := m.openReasoningDialog(); cmd != nil {
 			cmds = append(cmds, cmd)
 		}
+	case dialog.GrantsID:
+		if cmd := m.openGrantsDialog(); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
 	case dialog.QuitID:
 		if cmd := m.openQuitDialog(); cmd != nil {
 			cmds = append(cmds, cmd)
@@ -2899,6 +3047,26 @@ func (m *UI) openReasoningDialog() tea.Cmd {
 	return nil
 }
 
+// openGrantsDialog opens the dialog listing the permissions allowed for the
+// rest of the current session.
+func (m *UI) openGrantsDialog() tea.Cmd {
+	if m.dialog.ContainsDialog(dialog.GrantsID) {
+		m.dialog.BringToFront(dialog.GrantsID)
+		return nil
+	}
+	if m.session == nil {
+		return util.ReportWarn("No active session")
+	}
+
+	grantsDialog, err := dialog.NewGrants(m.com, m.session.ID)
+	if err != nil {
+		return util.ReportError(err)
+	}
+
+	m.dialog.OpenDialog(grantsDialog)
+	return nil
+}
+
 // openSessionsDialog opens the sessions dialog. If the dialog is already open,
 // it brings it to the front. Otherwise, it will list all the sessions and open
 // the dialog.
@@ -2914,7 +3082,7 @@ func (m *UI) openSessionsDialog() tea.Cmd {
 		selectedSessionID = m.session.ID
 	}
 
-	dialog, err := dialog.NewSessions(m.com, selectedSessionID)
+	dialog, err := dialog.NewSessions(m.com, selectedSessionID, m.sessionStatuses)
 	if err != nil {
 		return util.ReportError(err)
 	}
@@ -2949,6 +3117,15 @@ func (m *UI) openPermissionsDialog(perm permission.PermissionRequest) tea.Cmd {
 		opts = append(opts, dialog.WithDiffMode(diffMode == "split"))
 	}
 
+	// Requests from other sessions wait for the user like any other, but
+	// show which session they come from.
+	if sess, err := m.rootSession(perm.SessionID); err == nil {
+		m.setSessionStatus(sess.ID, sess.Title, dialog.SessionStatusWaiting)
+		if !m.hasSession() || m.session.ID != sess.ID {
+			opts = append(opts, dialog.WithSessionTitle(sess.Title))
+		}
+	}
+
 	permDialog := dialog.NewPermissions(m.com, perm, opts...)
 	m.dialog.OpenDialog(permDialog)
 	return nil
@@ -2961,7 +3138,7 @@ func (m *UI) handlePermissionNotification(notification permission.PermissionNoti
 		return
 	}
 
-	if permItem, ok := toolItem.(chat.ToolMessageItem); ok {
+	if permItem, ok := toolItem.(chat.ToolMessageItem); ok && !notification.Canceled {
 		if notification.Granted {
 			permItem.SetStatus(chat.ToolStatusRunning)
 		} else {
@@ -2990,6 +3167,10 @@ func (m *UI) newSession() tea.Cmd {
 	m.pillsView = ""
 	m.historyReset()
 	agenttools.ResetCache()
+	// Sessions running in the background may still use the LSPs.
+	if m.isAnyAgentBusy() {
+		return m.loadPromptHistory()
+	}
 	return tea.Batch(
 		func() tea.Msg {
 			m.com.App.LSPManager.StopAll(context.Background())
@@ -3266,9 +3447,10 @@ func (m *UI) runMCPPrompt(clientID, promptID string, arguments map[string]string
 	return tea.Sequence(cmds...)
 }
 
+// handleStateChanged loads the states of the MCP clients. The app rebuilds
+// the tools of the agent itself.
 func (m *UI) handleStateChanged() tea.Cmd {
 	return func() tea.Msg {
-		m.com.App.UpdateAgentModel(context.Background())
 		return mcpStateChangedMsg{
 			states: mcp.GetStates(),
 		}
@@ -3282,17 +3464,6 @@ func handleMCPPromptsEvent(name string) tea.Cmd {
 	}
 }
 
-func handleMCPToolsEvent(cfg *config.Config, name string) tea.Cmd {
-	return func() tea.Msg {
-		mcp.RefreshTools(
-			context.Background(),
-			cfg,
-			name,
-		)
-		return nil
-	}
-}
-
 func handleMCPResourcesEvent(name string) tea.Cmd {
 	return func() tea.Msg {
 		mcp.RefreshResources(context.Background(), name)
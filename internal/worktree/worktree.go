@@ -0,0 +1,208 @@
+// Package worktree manages the git worktrees sessions run in, so that
+// parallel sessions in the same project don't fight over the same working
+// tree.
+//
+// The worktree of a session lives in a directory named after the session
+// and is checked out on its own branch, so it can be found again from the
+// session ID alone.
+package worktree
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// BranchPrefix is the prefix of the branches of session worktrees.
+const BranchPrefix = "crush/"
+
+var (
+	// ErrNotRepository is returned when the project is not in a git
+	// repository.
+	ErrNotRepository = errors.New("not a git repository")
+	// ErrNotFound is returned when a session has no worktree.
+	ErrNotFound = errors.New("session has no worktree")
+)
+
+// Worktree is the worktree of a session.
+type Worktree struct {
+	SessionID string `json:"session_id"`
+	// Path is the root of the worktree.
+	Path   string `json:"path"`
+	Branch string `json:"branch"`
+	// WorkingDir is the directory in the worktree that matches the working
+	// directory of the project, for projects in a subdirectory of their
+	// repository.
+	WorkingDir string `json:"working_dir"`
+}
+
+// Manager manages the session worktrees of a repository.
+type Manager struct {
+	repoDir string
+	prefix  string
+	dir     string
+}
+
+// New returns a manager for the repository that workingDir is in, which
+// keeps worktrees in dir. It returns [ErrNotRepository] when workingDir is
+// not in a git repository.
+func New(ctx context.Context, workingDir, dir string) (*Manager, error) {
+	out, err := git(ctx, workingDir, "rev-parse", "--show-toplevel", "--show-prefix")
+	if err != nil {
+		return nil, ErrNotRepository
+	}
+	repoDir, prefix, _ := strings.Cut(out, "\n")
+	return &Manager{
+		repoDir: filepath.FromSlash(repoDir),
+		prefix:  filepath.FromSlash(strings.TrimSpace(prefix)),
+		dir:     dir,
+	}, nil
+}
+
+func (m *Manager) worktree(sessionID string) Worktree {
+	path := filepath.Join(m.dir, sessionID)
+	return Worktree{
+		SessionID:  sessionID,
+		Path:       path,
+		Branch:     BranchPrefix + sessionID,
+		WorkingDir: filepath.Join(path, m.prefix),
+	}
+}
+
+// Get returns the worktree of a session, or [ErrNotFound] if it has none.
+func (m *Manager) Get(sessionID string) (Worktree, error) {
+	wt := m.worktree(sessionID)
+	if _, err := os.Stat(wt.Path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Worktree{}, ErrNotFound
+		}
+		return Worktree{}, err
+	}
+	return wt, nil
+}
+
+// Create creates the worktree of a session on a new branch off the current
+// HEAD of the repository.
+func (m *Manager) Create(ctx context.Context, sessionID string) (Worktree, error) {
+	wt := m.worktree(sessionID)
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return Worktree{}, fmt.Errorf("failed to create worktrees directory: %w", err)
+	}
+	if _, err := git(ctx, m.repoDir, "worktree", "add", "-b", wt.Branch, wt.Path, "HEAD"); err != nil {
+		return Worktree{}, fmt.Errorf("failed to create worktree: %w", err)
+	}
+	return wt, nil
+}
+
+// List returns the session worktrees of the repository.
+func (m *Manager) List(ctx context.Context) ([]Worktree, error) {
+	out, err := git(ctx, m.repoDir, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	var worktrees []Worktree
+	for line := range strings.SplitSeq(out, "\n") {
+		path, ok := strings.CutPrefix(line, "worktree ")
+		if !ok {
+			continue
+		}
+		path = filepath.FromSlash(path)
+		if filepath.Dir(path) != filepath.Clean(m.dir) {
+			continue
+		}
+		worktrees = append(worktrees, m.worktree(filepath.Base(path)))
+	}
+	return worktrees, nil
+}
+
+// Diff returns the changes made in the worktree of a session since it
+// branched off, including changes that are not committed yet.
+func (m *Manager) Diff(ctx context.Context, sessionID string) (string, error) {
+	wt, err := m.Get(sessionID)
+	if err != nil {
+		return "", err
+	}
+	// Mark new files so the diff includes them.
+	if _, err := git(ctx, wt.Path, "add", "--all", "--intent-to-add"); err != nil {
+		return "", err
+	}
+	base, err := m.base(ctx, wt)
+	if err != nil {
+		return "", err
+	}
+	return git(ctx, wt.Path, "diff", base)
+}
+
+// Merge commits the pending changes in the worktree of a session, running
+// the git hooks as any commit does, merges its branch into the current
+// branch of the repository and discards the worktree. If the merge fails, it
+// is aborted and the worktree is kept.
+func (m *Manager) Merge(ctx context.Context, sessionID, message string) error {
+	wt, err := m.Get(sessionID)
+	if err != nil {
+		return err
+	}
+	if _, err := git(ctx, wt.Path, "add", "--all"); err != nil {
+		return err
+	}
+	status, err := git(ctx, wt.Path, "status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if status != "" {
+		if _, err := git(ctx, wt.Path, "commit", "-m", message); err != nil {
+			return fmt.Errorf("failed to commit worktree changes: %w", err)
+		}
+	}
+	if _, err := git(ctx, m.repoDir, "merge", "--no-edit", wt.Branch); err != nil {
+		_, _ = git(ctx, m.repoDir, "merge", "--abort")
+		return fmt.Errorf("failed to merge %s: %w", wt.Branch, err)
+	}
+	return m.Discard(ctx, sessionID)
+}
+
+// Discard removes the worktree of a session and deletes its branch, along
+// with any changes that were not merged.
+func (m *Manager) Discard(ctx context.Context, sessionID string) error {
+	wt, err := m.Get(sessionID)
+	if err != nil {
+		return err
+	}
+	if _, err := git(ctx, m.repoDir, "worktree", "remove", "--force", wt.Path); err != nil {
+		return fmt.Errorf("failed to remove worktree: %w", err)
+	}
+	if _, err := git(ctx, m.repoDir, "branch", "-D", wt.Branch); err != nil {
+		return fmt.Errorf("failed to delete branch: %w", err)
+	}
+	return nil
+}
+
+// base returns the commit the worktree branched off the current branch of
+// the repository.
+func (m *Manager) base(ctx context.Context, wt Worktree) (string, error) {
+	head, err := git(ctx, m.repoDir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return git(ctx, wt.Path, "merge-base", head, "HEAD")
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,102 @@
+package worktree
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "main.go"), []byte("package main\n"), 0o644))
+	for _, args := range [][]string{
+		{"init", "--initial-branch", "main"},
+		{"add", "."},
+		{"commit", "-m", "initial"},
+	} {
+		_, err := git(t.Context(), dir, args...)
+		require.NoError(t, err)
+	}
+	return dir
+}
+
+func TestNew_notRepository(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(t.Context(), t.TempDir(), t.TempDir())
+	require.ErrorIs(t, err, ErrNotRepository)
+}
+
+func TestManager(t *testing.T) {
+	repo := newRepo(t)
+	ctx := t.Context()
+
+	m, err := New(ctx, filepath.Join(repo, "sub"), t.TempDir())
+	require.NoError(t, err)
+
+	_, err = m.Get("session")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	wt, err := m.Create(ctx, "session")
+	require.NoError(t, err)
+	require.Equal(t, BranchPrefix+"session", wt.Branch)
+	require.Equal(t, filepath.Join(wt.Path, "sub"), wt.WorkingDir)
+	require.FileExists(t, filepath.Join(wt.WorkingDir, "main.go"))
+
+	worktrees, err := m.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Worktree{wt}, worktrees)
+
+	require.NoError(t, os.WriteFile(filepath.Join(wt.WorkingDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(wt.WorkingDir, "new.go"), []byte("package main\n"), 0o644))
+
+	diff, err := m.Diff(ctx, "session")
+	require.NoError(t, err)
+	require.Contains(t, diff, "+func main() {}")
+	require.Contains(t, diff, "b/sub/new.go")
+
+	// The project itself is untouched until the worktree is merged.
+	require.NoFileExists(t, filepath.Join(repo, "sub", "new.go"))
+
+	require.NoError(t, m.Merge(ctx, "session", "Changes from session"))
+	require.FileExists(t, filepath.Join(repo, "sub", "new.go"))
+	_, err = m.Get("session")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	worktrees, err = m.List(ctx)
+	require.NoError(t, err)
+	require.Empty(t, worktrees)
+}
+
+func TestManager_Discard(t *testing.T) {
+	repo := newRepo(t)
+	ctx := t.Context()
+
+	m, err := New(ctx, repo, t.TempDir())
+	require.NoError(t, err)
+
+	wt, err := m.Create(ctx, "session")
+	require.NoError(t, err)
+	require.Equal(t, wt.Path, wt.WorkingDir)
+	require.NoError(t, os.WriteFile(filepath.Join(wt.Path, "new.go"), []byte("package main\n"), 0o644))
+
+	require.NoError(t, m.Discard(ctx, "session"))
+	require.NoDirExists(t, wt.Path)
+	require.NoFileExists(t, filepath.Join(repo, "new.go"))
+
+	_, err = git(ctx, repo, "rev-parse", "--verify", wt.Branch)
+	require.Error(t, err)
+}